@@ -0,0 +1,31 @@
+package modbus
+
+// SetAlias make the node of slaveID answer the requests to unitID as well,
+// the alias takes precedence over the node of unitID, so it can remap an existing one.
+// it is used to emulate the gateways which expose one device under several unit ids.
+func (sf *serverCommon) SetAlias(unitID, slaveID byte) {
+	sf.aliases.Store(unitID, slaveID)
+}
+
+// DeleteAlias remove the alias of unitID
+func (sf *serverCommon) DeleteAlias(unitID byte) {
+	sf.aliases.Delete(unitID)
+}
+
+// Aliases got the alias table, unit id -> slave id
+func (sf *serverCommon) Aliases() map[byte]byte {
+	m := make(map[byte]byte)
+	sf.aliases.Range(func(k, v interface{}) bool {
+		m[k.(byte)] = v.(byte)
+		return true
+	})
+	return m
+}
+
+// lookupNode got the node answer the unit id, resolve the alias first
+func (sf *serverCommon) lookupNode(unitID byte) (*NodeRegister, error) {
+	if v, ok := sf.aliases.Load(unitID); ok {
+		return sf.GetNode(v.(byte))
+	}
+	return sf.GetNode(unitID)
+}
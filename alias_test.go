@@ -0,0 +1,55 @@
+package modbus
+
+import (
+	"testing"
+)
+
+func TestServer_SetAlias(t *testing.T) {
+	srv := newServerCommon()
+	srv.AddNodes(
+		NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10),
+		NewNodeRegister(2, 0, 10, 0, 10, 0, 10, 0, 10))
+	srv.SetAlias(10, 1)
+	srv.SetAlias(11, 1)
+	srv.SetAlias(2, 1) // remap the existing node
+	srv.SetAlias(12, 99)
+
+	tests := []struct {
+		unitID  byte
+		wantErr error
+	}{
+		{1, nil},
+		{2, nil},
+		{10, nil},
+		{11, nil},
+		{12, ErrSlaveNotExist},
+		{13, ErrSlaveNotExist},
+	}
+	for _, tt := range tests {
+		_, err := srv.serve(&ServerRequest{
+			SlaveID:  tt.unitID,
+			FuncCode: FuncCodeWriteSingleRegister,
+			Data:     []byte{0x00, 0x00, 0x00, tt.unitID},
+		})
+		if err != tt.wantErr {
+			t.Errorf("serve(%d) error = %v, want %v", tt.unitID, err, tt.wantErr)
+		}
+	}
+	node1, _ := srv.GetNode(1)
+	node2, _ := srv.GetNode(2)
+	if v, _ := node1.ReadHoldings(0, 1); v[0] != 11 {
+		t.Errorf("node 1 holding[0] = %v, want 11", v[0])
+	}
+	if v, _ := node2.ReadHoldings(0, 1); v[0] != 0 {
+		t.Errorf("node 2 holding[0] = %v, want 0", v[0])
+	}
+
+	srv.DeleteAlias(2)
+	if got := srv.Aliases(); len(got) != 3 || got[10] != 1 {
+		t.Errorf("Aliases() = %v", got)
+	}
+	srv.serve(&ServerRequest{SlaveID: 2, FuncCode: FuncCodeWriteSingleRegister, Data: []byte{0x00, 0x00, 0x00, 0x02}})
+	if v, _ := node2.ReadHoldings(0, 1); v[0] != 2 {
+		t.Errorf("node 2 holding[0] = %v, want 2", v[0])
+	}
+}
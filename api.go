@@ -1,43 +1,154 @@
 package modbus
 
-// Client interface
+import (
+	"context"
+)
+
+// Client interface, it is safe for concurrent use by multiple goroutines,
+// the transactions are serialized by the provider, one request and its response at a time
+// on a connection, so the frames of the goroutines never interleave.
+// the request and response buffers passed to a method belong to the caller goroutine.
 type Client interface {
 	ClientProvider
+	// SetLogLevel set log output level of the provider if it implements LogLeveler
+	SetLogLevel(level LogLevel)
+	// ConnectContext connect like Connect, but abort the dial or open when the context is done
+	ConnectContext(ctx context.Context) error
+	// OnConnected set the callback called after the connection is established or reopened
+	OnConnected(f func())
+	// OnDisconnected set the callback called after the connection is closed(err nil) or lost
+	OnDisconnected(f func(err error))
+	// OnReconnecting set the callback called before every reconnect attempt
+	OnReconnecting(f func(attempt int))
+	// Go send the request asynchronously, the Done channel of the Call fires when it is complete
+	Go(slaveID byte, request ProtocolDataUnit, done chan *Call) *Call
+	// Use add middlewares which intercept every request and response
+	Use(mws ...Middleware)
+	// WithContext return a view of the client whose transactions carry the context,
+	// the deadline of the context bound the transaction instead of the provider timeout
+	WithContext(ctx context.Context) Client
+	// ReadBatch executes the reads one after another, possibly to different slaves,
+	// optionally coalescing adjacent ranges, and returns the per-item results.
+	ReadBatch(specs []ReadSpec, opts ...BatchOption) ([]ReadResult, error)
 	// Bits
+	BitReader
+	BitWriter
+
+	// 16-bits
+	RegisterReader
+	RegisterWriter
+
+	//ReadFIFOQueue reads the contents of a First-In-First-Out (FIFO) queue
+	// of register in a remote device and returns FIFO value register.
+	ReadFIFOQueue(slaveID byte, address uint16) (results []byte, err error)
+	// ReadFileRecord read length records of the file start at record by one sub-request,
+	// it returns the big endian bytes of the records, see FileReader for the large file area.
+	ReadFileRecord(slaveID byte, file, record, length uint16) (results []byte, err error)
+	// WriteFileRecord write the big endian bytes of records to the file start at record
+	// by one sub-request, see FileWriter for the large file area.
+	WriteFileRecord(slaveID byte, file, record uint16, value []byte) error
+
+	// typed value on holding registers, see Order for the byte and word order
+
+	// ReadUint32 read 2 holding registers as uint32
+	ReadUint32(slaveID byte, address uint16, order Order) (uint32, error)
+	// ReadInt32 read 2 holding registers as int32
+	ReadInt32(slaveID byte, address uint16, order Order) (int32, error)
+	// ReadFloat32 read 2 holding registers as IEEE 754 float32
+	ReadFloat32(slaveID byte, address uint16, order Order) (float32, error)
+	// ReadUint64 read 4 holding registers as uint64
+	ReadUint64(slaveID byte, address uint16, order Order) (uint64, error)
+	// ReadInt64 read 4 holding registers as int64
+	ReadInt64(slaveID byte, address uint16, order Order) (int64, error)
+	// ReadFloat64 read 4 holding registers as IEEE 754 float64
+	ReadFloat64(slaveID byte, address uint16, order Order) (float64, error)
+	// WriteUint32 write uint32 into 2 holding registers
+	WriteUint32(slaveID byte, address uint16, value uint32, order Order) error
+	// WriteInt32 write int32 into 2 holding registers
+	WriteInt32(slaveID byte, address uint16, value int32, order Order) error
+	// WriteFloat32 write IEEE 754 float32 into 2 holding registers
+	WriteFloat32(slaveID byte, address uint16, value float32, order Order) error
+	// WriteUint64 write uint64 into 4 holding registers
+	WriteUint64(slaveID byte, address uint16, value uint64, order Order) error
+	// WriteInt64 write int64 into 4 holding registers
+	WriteInt64(slaveID byte, address uint16, value int64, order Order) error
+	// WriteFloat64 write IEEE 754 float64 into 4 holding registers
+	WriteFloat64(slaveID byte, address uint16, value float64, order Order) error
+	// ReadString read the text stored in holding registers, swap the bytes in every register if swap
+	ReadString(slaveID byte, address, quantity uint16, swap bool) (string, error)
+	// WriteString write the text into holding registers padding with NUL, swap the bytes in every register if swap
+	WriteString(slaveID byte, address, quantity uint16, s string, swap bool) error
+
+	// Stats return the communication counters since created or last reset
+	Stats() ClientStats
+	// ResetStats clear the communication counters
+	ResetStats()
+}
 
+// BitReader the reads of coils and discrete inputs, it is a subset of Client,
+// accept it instead of Client so the tests can substitute a small fake.
+type BitReader interface {
 	// ReadCoils reads from 1 to 2000 contiguous status of coils in a
 	// remote device and returns coil status.
 	ReadCoils(slaveID byte, address, quantity uint16) (results []byte, err error)
 	// ReadDiscreteInputs reads from 1 to 2000 contiguous status of
 	// discrete inputs in a remote device and returns input status.
 	ReadDiscreteInputs(slaveID byte, address, quantity uint16) (results []byte, err error)
+	// ReadCoilsInto same as ReadCoils, but decode the result into dst,
+	// reuse the result as dst to avoid allocation.
+	ReadCoilsInto(dst []byte, slaveID byte, address, quantity uint16) (results []byte, err error)
+	// ReadDiscreteInputsInto same as ReadDiscreteInputs, but decode the result into dst,
+	// reuse the result as dst to avoid allocation.
+	ReadDiscreteInputsInto(dst []byte, slaveID byte, address, quantity uint16) (results []byte, err error)
+}
+
+// BitWriter the writes of coils, it is a subset of Client.
+type BitWriter interface {
 	// WriteSingleCoil write a single output to either ON or OFF in a
 	// remote device and returns success or failed.
 	WriteSingleCoil(slaveID byte, address uint16, isOn bool) error
 	// WriteMultipleCoils forces each coil in a sequence of coils to either
 	// ON or OFF in a remote device and returns success or failed.
 	WriteMultipleCoils(slaveID byte, address, quantity uint16, value []byte) error
+	// WriteCoilsBulk write any quantity of coils in the spec compliant chunks,
+	// the error is *BulkError tell which chunk failed.
+	WriteCoilsBulk(slaveID byte, address, quantity uint16, value []byte, opts ...BulkOption) error
+}
 
-	// 16-bits
-
+// RegisterReader the reads of input and holding registers, it is a subset of Client,
+// accept it instead of Client so the tests can substitute a small fake.
+type RegisterReader interface {
 	// ReadInputRegisters reads from 1 to 125 contiguous input registers in
 	// a remote device and returns input registers.
 	ReadInputRegistersBytes(slaveID byte, address, quantity uint16) (results []byte, err error)
 	// ReadInputRegisters reads from 1 to 125 contiguous input registers in
 	// a remote device and returns input registers.
 	ReadInputRegisters(slaveID byte, address, quantity uint16) (results []uint16, err error)
+	// ReadInputRegistersInto same as ReadInputRegistersBytes, but decode the result into dst,
+	// reuse the result as dst to avoid allocation.
+	ReadInputRegistersInto(dst []byte, slaveID byte, address, quantity uint16) (results []byte, err error)
 	// ReadHoldingRegistersBytes reads the contents of a contiguous block of
 	// holding registers in a remote device and returns register value.
 	ReadHoldingRegistersBytes(slaveID byte, address, quantity uint16) (results []byte, err error)
 	// ReadHoldingRegisters reads the contents of a contiguous block of
 	// holding registers in a remote device and returns register value.
 	ReadHoldingRegisters(slaveID byte, address, quantity uint16) (results []uint16, err error)
+	// ReadHoldingRegistersInto same as ReadHoldingRegistersBytes, but decode the result into dst,
+	// reuse the result as dst to avoid allocation.
+	ReadHoldingRegistersInto(dst []byte, slaveID byte, address, quantity uint16) (results []byte, err error)
+}
+
+// RegisterWriter the writes of holding registers, it is a subset of Client.
+type RegisterWriter interface {
 	// WriteSingleRegister writes a single holding register in a remote
 	// device and returns success or failed.
 	WriteSingleRegister(slaveID byte, address, value uint16) error
 	// WriteMultipleRegisters writes a block of contiguous registers
 	// (1 to 123 registers) in a remote device and returns success or failed.
 	WriteMultipleRegisters(slaveID byte, address, quantity uint16, value []byte) error
+	// WriteRegistersBulk write any quantity of holding registers in the spec compliant chunks,
+	// the error is *BulkError tell which chunk failed.
+	WriteRegistersBulk(slaveID byte, address, quantity uint16, value []byte, opts ...BulkOption) error
 	// ReadWriteMultipleRegisters performs a combination of one read
 	// operation and one write operation. It returns read registers value.
 	ReadWriteMultipleRegistersBytes(slaveID byte, readAddress, readQuantity,
@@ -50,7 +161,4 @@ type Client interface {
 	// register using a combination of an AND mask, an OR mask, and the
 	// register's current contents. The function returns success or failed.
 	MaskWriteRegister(slaveID byte, address, andMask, orMask uint16) error
-	//ReadFIFOQueue reads the contents of a First-In-First-Out (FIFO) queue
-	// of register in a remote device and returns FIFO value register.
-	ReadFIFOQueue(slaveID byte, address uint16) (results []byte, err error)
 }
@@ -0,0 +1,41 @@
+package modbus
+
+import (
+	"testing"
+)
+
+var (
+	_ BitReader      = Client(nil)
+	_ BitWriter      = Client(nil)
+	_ RegisterReader = Client(nil)
+	_ RegisterWriter = Client(nil)
+)
+
+// fakeRegisters a fake RegisterWriter, other methods of Client panic if called
+type fakeRegisters struct {
+	RegisterWriter
+	regs map[uint16]uint16
+}
+
+func (sf *fakeRegisters) WriteSingleRegister(slaveID byte, address, value uint16) error {
+	sf.regs[address] = value
+	return nil
+}
+
+func TestRegisterWriter_Fake(t *testing.T) {
+	reset := func(w RegisterWriter) error {
+		for addr := uint16(0); addr < 3; addr++ {
+			if err := w.WriteSingleRegister(1, addr, 0xffff); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	f := &fakeRegisters{regs: make(map[uint16]uint16)}
+	if err := reset(f); err != nil {
+		t.Fatalf("reset() error = %v", err)
+	}
+	if len(f.regs) != 3 || f.regs[2] != 0xffff {
+		t.Errorf("registers = %v, want 3 registers of 0xffff", f.regs)
+	}
+}
@@ -1,8 +1,10 @@
 package modbus
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+	"time"
 )
 
 const (
@@ -15,6 +17,7 @@ const (
 type ASCIIClientProvider struct {
 	serialPort
 	logger
+	providerCommon
 	// 请求池,所有ascii客户端共用一个请求池
 	*pool
 }
@@ -32,6 +35,7 @@ func NewASCIIClientProvider() *ASCIIClientProvider {
 		logger: newLogger("modbusASCIIMaster => "),
 		pool:   asciiPool,
 	}
+	p.events = &p.lifecycle
 	p.Timeout = SerialDefaultTimeout
 	p.autoReconnect = SerialDefaultAutoReconnect
 	return p
@@ -102,13 +106,31 @@ func decodeASCIIFrame(adu []byte) (uint8, []byte, error) {
 	var lrc lrc
 	sum := lrc.reset().push(buf[:length-1]...).value()
 	if buf[length-1] != sum { // LRC
-		return 0, nil, fmt.Errorf("modbus: response lrc '%x' does not match expected '%x'", buf[length-1], sum)
+		return 0, nil, &ChecksumError{"lrc", uint16(buf[length-1]), uint16(sum)}
 	}
 	return buf[0], buf[1 : length-1], nil
 }
 
+// asciiSlaveID got the slave id of the ASCII frame,use for log only
+func asciiSlaveID(adu []byte) interface{} {
+	var id [1]byte
+	if len(adu) < 3 {
+		return "unknown"
+	}
+	if _, err := hex.Decode(id[:], adu[1:3]); err != nil {
+		return "unknown"
+	}
+	return id[0]
+}
+
 // Send request to the remote server,it implements on SendRawFrame
 func (sf *ASCIIClientProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.SendContext(context.Background(), slaveID, request)
+}
+
+// SendContext send the request like Send, the response is read until the deadline of ctx
+// instead of the serial timeout, and the read is aborted when ctx is done.
+func (sf *ASCIIClientProvider) SendContext(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
 	var response ProtocolDataUnit
 
 	frame := sf.pool.get()
@@ -118,8 +140,8 @@ func (sf *ASCIIClientProvider) Send(slaveID byte, request ProtocolDataUnit) (Pro
 	if err != nil {
 		return response, err
 	}
-	aduResponse, err := sf.SendRawFrame(aduRequest)
-	if err != nil {
+	aduResponse, err := sf.sendRawFrame(ctx, aduRequest)
+	if err != nil || slaveID == AddressBroadCast {
 		return response, err
 	}
 	rspSlaveID, pdu, err := decodeASCIIFrame(aduResponse)
@@ -128,6 +150,7 @@ func (sf *ASCIIClientProvider) Send(slaveID byte, request ProtocolDataUnit) (Pro
 	}
 
 	response = ProtocolDataUnit{pdu[0], pdu[1:]}
+	rspSlaveID = sf.tolerate(slaveID, rspSlaveID, request, &response)
 	if err = verify(slaveID, rspSlaveID, request, response); err != nil {
 		return response, err
 	}
@@ -150,7 +173,7 @@ func (sf *ASCIIClientProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte,
 		return nil, err
 	}
 	aduResponse, err := sf.SendRawFrame(aduRequest)
-	if err != nil {
+	if err != nil || slaveID == AddressBroadCast {
 		return nil, err
 	}
 	rspSlaveID, pdu, err := decodeASCIIFrame(aduResponse)
@@ -158,34 +181,52 @@ func (sf *ASCIIClientProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte,
 		return nil, err
 	}
 	response := ProtocolDataUnit{pdu[0], pdu[1:]}
+	rspSlaveID = sf.tolerate(slaveID, rspSlaveID, request, &response)
 	if err = verify(slaveID, rspSlaveID, request, response); err != nil {
 		return nil, err
 	}
+	pdu = pdu[:1+len(response.Data)]
 	return pdu, nil
 }
 
 // SendRawFrame send Adu frame
 func (sf *ASCIIClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte, err error) {
+	return sf.sendRawFrame(context.Background(), aduRequest)
+}
+
+// sendRawFrame send Adu frame, the response is read until the deadline of ctx
+func (sf *ASCIIClientProvider) sendRawFrame(ctx context.Context, aduRequest []byte) (aduResponse []byte, err error) {
 	sf.mu.Lock()
 	defer sf.mu.Unlock()
+	defer func() {
+		if err != nil {
+			sf.checkGone(err)
+		}
+	}()
 
 	// check  port is connected
 	if !sf.isConnected() {
 		return nil, ErrClosedConnection
 	}
+	sf.waitIdle(0)
+	defer func() { sf.lastFrame = time.Now() }()
 
 	// Send the request
-	sf.Debug("sending [% x]", aduRequest)
+	sf.pace()
+	sf.with("slave", asciiSlaveID(aduRequest)).Debug("sending [% x]", aduRequest)
+	sf.tapSend(aduRequest)
 	var tryCnt byte
 	for {
-		_, err = sf.port.Write(aduRequest)
+		_, err = sf.write(aduRequest)
 		if err == nil { // success
 			break
 		}
 		if sf.autoReconnect == 0 {
 			return
 		}
+		sf.events.emitDisconnected(err)
 		for {
+			sf.events.emitReconnecting(int(tryCnt) + 1)
 			err = sf.connect()
 			if err == nil {
 				break
@@ -196,12 +237,17 @@ func (sf *ASCIIClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []by
 		}
 	}
 
+	if len(aduRequest) >= 3 && string(aduRequest[1:3]) == "00" { // broadcast no response
+		return nil, nil
+	}
+
 	// Get the response
 	var n int
 	var data [asciiCharacterMaxSize]byte
 	length := 0
+	port := serialReader(ctx, sf.port)
 	for {
-		if n, err = sf.port.Read(data[length:]); err != nil {
+		if n, err = port.Read(data[length:]); err != nil {
 			return
 		}
 		length += n
@@ -216,6 +262,7 @@ func (sf *ASCIIClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []by
 		}
 	}
 	aduResponse = data[:length]
-	sf.Debug("received [% x]", aduResponse)
+	sf.with("slave", asciiSlaveID(aduResponse)).Debug("received [% x]", aduResponse)
+	sf.tapReceived(aduResponse)
 	return
 }
@@ -0,0 +1,73 @@
+package modbus
+
+import (
+	"sync"
+)
+
+// Call 异步请求, 类似 net/rpc 的 Call
+type Call struct {
+	SlaveID  byte
+	Request  ProtocolDataUnit
+	Response ProtocolDataUnit // valid after the call is done
+	Error    error            // after completion, the error status
+	Done     chan *Call       // receives *Call when the request is complete
+}
+
+// done deliver the call, the Done channel must have enough buffer
+func (sf *Call) done() {
+	select {
+	case sf.Done <- sf:
+	default:
+		// the caller must make sure the channel has enough buffer space
+	}
+}
+
+// asyncQueue the pending calls, a worker goroutine runs while it is not empty
+type asyncQueue struct {
+	mu      sync.Mutex
+	calls   []*Call
+	running bool
+}
+
+// Go send the request asynchronously through the middlewares in the order of calls,
+// it returns the Call whose Done channel fires with it when the transaction is complete.
+// if done is nil, Go will allocate a new channel, if non-nil, done must be buffered or Go will panic.
+// the calls are served by a worker goroutine which exits when there is no pending call.
+func (sf *client) Go(slaveID byte, request ProtocolDataUnit, done chan *Call) *Call {
+	if done == nil {
+		done = make(chan *Call, 1)
+	} else if cap(done) == 0 {
+		panic("modbus: done channel is unbuffered")
+	}
+	call := &Call{SlaveID: slaveID, Request: request, Done: done}
+
+	q := &sf.async
+	q.mu.Lock()
+	q.calls = append(q.calls, call)
+	if !q.running {
+		q.running = true
+		go sf.serveCalls()
+	}
+	q.mu.Unlock()
+	return call
+}
+
+// serveCalls serve the pending calls until the queue is empty
+func (sf *client) serveCalls() {
+	q := &sf.async
+	for {
+		q.mu.Lock()
+		if len(q.calls) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		call := q.calls[0]
+		q.calls[0] = nil
+		q.calls = q.calls[1:]
+		q.mu.Unlock()
+
+		call.Response, call.Error = sf.Send(call.SlaveID, call.Request)
+		call.done()
+	}
+}
@@ -0,0 +1,52 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_Go(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	if err := node.WriteHoldings(0, []uint16{10, 11, 12, 13}); err != nil {
+		t.Fatal(err)
+	}
+	p := NewLoopbackProvider(node)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(p)
+
+	done := make(chan *Call, 5)
+	for _, address := range []byte{0, 1, 2, 3, 100} {
+		c.Go(1, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, address, 0x00, 0x01}}, done)
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case call := <-done:
+			// the calls are served in order
+			if i < 4 {
+				if call.Error != nil || call.Response.Data[2] != byte(10+i) {
+					t.Errorf("call %d = %v, %v, want %v", i, call.Response, call.Error, 10+i)
+				}
+			} else if !IsIllegalDataAddress(call.Error) {
+				t.Errorf("call %d error = %v, want illegal data address", i, call.Error)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("call is not done")
+		}
+	}
+
+	call := <-c.Go(1, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x01}}, nil).Done
+	if call.Error != nil {
+		t.Errorf("Go() error = %v", call.Error)
+	}
+}
+
+func TestClient_Go_unbuffered(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Go() want panic with unbuffered done channel")
+		}
+	}()
+	NewClient(&provider{}).Go(1, ProtocolDataUnit{}, make(chan *Call))
+}
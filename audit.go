@@ -0,0 +1,105 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// AuditEntry a request handled by the server
+type AuditEntry struct {
+	Time          time.Time
+	RemoteAddr    string // 远端地址, 串口为空
+	SlaveID       byte
+	FuncCode      byte
+	Address       uint16 // start address, 0 if the function has not address
+	Quantity      uint16 // quantity, 1 for single coil and register
+	ExceptionCode byte   // 0 if success
+}
+
+// AuditLog server request audit log, keep the recent requests in a ring buffer,
+// so it can answer "who wrote that coil" without external capture.
+// the request to not exist slave is not recorded.
+// use it like: server.Use(audit.Middleware())
+type AuditLog struct {
+	mu   sync.Mutex
+	ring []AuditEntry
+	next int
+	full bool
+}
+
+// NewAuditLog new audit log keep the recent size requests
+func NewAuditLog(size int) *AuditLog {
+	if size <= 0 {
+		size = 1
+	}
+	return &AuditLog{ring: make([]AuditEntry, size)}
+}
+
+// Middleware return the server middleware which record the requests
+func (sf *AuditLog) Middleware() ServerMiddleware {
+	return func(next ServerHandler) ServerHandler {
+		return func(req *ServerRequest) ([]byte, error) {
+			rsp, err := next(req)
+			if err == ErrSlaveNotExist {
+				return rsp, err
+			}
+			entry := AuditEntry{
+				Time:     time.Now(),
+				SlaveID:  req.SlaveID,
+				FuncCode: req.FuncCode,
+			}
+			if req.RemoteAddr != nil {
+				entry.RemoteAddr = req.RemoteAddr.String()
+			}
+			entry.Address, entry.Quantity = pduAddressQuantity(ProtocolDataUnit{req.FuncCode, req.Data})
+			if err != nil && err != errNoReply { // the broadcast is served but not replied
+				entry.ExceptionCode = exceptionCode(err)
+			}
+			sf.add(entry)
+			return rsp, err
+		}
+	}
+}
+
+// add append the entry, overwrite the oldest one if full
+func (sf *AuditLog) add(entry AuditEntry) {
+	sf.mu.Lock()
+	sf.ring[sf.next] = entry
+	if sf.next++; sf.next == len(sf.ring) {
+		sf.next, sf.full = 0, true
+	}
+	sf.mu.Unlock()
+}
+
+// Entries got the recorded requests, the oldest first
+func (sf *AuditLog) Entries() []AuditEntry {
+	return sf.Find(nil)
+}
+
+// Find got the recorded requests match the filter, the oldest first, nil filter match all
+func (sf *AuditLog) Find(filter func(e *AuditEntry) bool) []AuditEntry {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	var list []AuditEntry
+	if sf.full {
+		list = appendMatched(list, sf.ring[sf.next:], filter)
+	}
+	return appendMatched(list, sf.ring[:sf.next], filter)
+}
+
+// Reset drop all the recorded requests
+func (sf *AuditLog) Reset() {
+	sf.mu.Lock()
+	sf.next, sf.full = 0, false
+	sf.mu.Unlock()
+}
+
+// appendMatched append the entries match the filter
+func appendMatched(list, entries []AuditEntry, filter func(e *AuditEntry) bool) []AuditEntry {
+	for i := range entries {
+		if filter == nil || filter(&entries[i]) {
+			list = append(list, entries[i])
+		}
+	}
+	return list
+}
@@ -0,0 +1,90 @@
+package modbus
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	audit := NewAuditLog(3)
+	h := audit.Middleware()(func(req *ServerRequest) ([]byte, error) {
+		switch req.SlaveID {
+		case 1:
+			return req.Data, nil
+		case 2:
+			return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
+		}
+		return nil, ErrSlaveNotExist
+	})
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 1502}
+	h(&ServerRequest{1, FuncCodeWriteSingleCoil, []byte{0x00, 0x05, 0xff, 0x00}, remote})
+	h(&ServerRequest{2, FuncCodeReadHoldingRegisters, []byte{0x00, 0x10, 0x00, 0x02}, remote})
+	h(&ServerRequest{3, FuncCodeReadHoldingRegisters, []byte{0x00, 0x10, 0x00, 0x02}, remote}) // not recorded
+	h(&ServerRequest{1, FuncCodeWriteMultipleRegisters, []byte{0x00, 0x01, 0x00, 0x01, 0x02, 0x00, 0x01}, nil})
+	h(&ServerRequest{1, FuncCodeWriteSingleCoil, []byte{0x00, 0x06, 0x00, 0x00}, remote})
+
+	entries := audit.Entries()
+	want := []AuditEntry{
+		{SlaveID: 2, FuncCode: FuncCodeReadHoldingRegisters, Address: 0x10, Quantity: 2, ExceptionCode: ExceptionCodeIllegalDataAddress, RemoteAddr: remote.String()},
+		{SlaveID: 1, FuncCode: FuncCodeWriteMultipleRegisters, Address: 1, Quantity: 1},
+		{SlaveID: 1, FuncCode: FuncCodeWriteSingleCoil, Address: 6, Quantity: 1, RemoteAddr: remote.String()},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Entries() = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		if entries[i].Time.IsZero() {
+			t.Errorf("Entries()[%d] time is zero", i)
+		}
+		entries[i].Time = want[i].Time
+		if entries[i] != want[i] {
+			t.Errorf("Entries()[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	coilWrites := audit.Find(func(e *AuditEntry) bool { return e.FuncCode == FuncCodeWriteSingleCoil && e.Address == 6 })
+	if len(coilWrites) != 1 || coilWrites[0].RemoteAddr != remote.String() {
+		t.Errorf("Find() = %+v, want the coil write from %v", coilWrites, remote)
+	}
+	audit.Reset()
+	if len(audit.Entries()) != 0 {
+		t.Errorf("Entries() after Reset() not empty")
+	}
+}
+
+func TestAuditLog_broadcast(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := NewAuditLog(8)
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(
+		NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10),
+		NewNodeRegister(testslaveID2, 0, 10, 0, 10, 0, 10, 0, 10))
+	mbSrv.SetUnitIDMode(255, UnitIDBroadcast, 0)
+	mbSrv.Use(audit.Middleware())
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	p := NewTCPClientProvider(listen.Addr().String())
+	p.Timeout = 200 * time.Millisecond
+	if err = p.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer p.Close()
+	// the broadcast is not replied
+	if _, err = p.SendPdu(255, []byte{FuncCodeWriteSingleRegister, 0x00, 0x02, 0x00, 0x07}); err == nil {
+		t.Errorf("SendPdu(255) broadcast error = nil, want no reply")
+	}
+
+	entries := audit.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Entries() = %+v, want the broadcast", entries)
+	}
+	if e := entries[0]; e.SlaveID != 255 || e.FuncCode != FuncCodeWriteSingleRegister ||
+		e.Address != 2 || e.Quantity != 1 || e.ExceptionCode != 0 {
+		t.Errorf("Entries()[0] = %+v, want the broadcast write without exception", e)
+	}
+}
@@ -0,0 +1,106 @@
+package modbus
+
+// 本文件提供了虚拟寄存器后端, 节点的表可以委托给用户实现的后端,
+// 将寄存器直接映射到GPIO, 数据库或其它协议, 而不是内存映像
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// RegisterBackend 虚拟寄存器后端, address 为协议地址, 节点已检查地址范围在表的范围内.
+// 线圈和离散量表每个值为一个位, 非0为1.
+// 返回 *ExceptionError 时回复对应的异常码, 其它错误回复 从站设备故障(0x04).
+// 调用时持有对应表的锁, 同一个表的调用不会并发, 后端内不可调用该节点的方法, 否则死锁
+type RegisterBackend interface {
+	ReadRegs(address, quantity uint16) ([]uint16, error)
+	WriteRegs(address uint16, values []uint16) error
+}
+
+// tableLock 表的锁
+func (sf *NodeRegister) tableLock(table Table) (*sync.RWMutex, error) {
+	switch table {
+	case TableCoils:
+		return &sf.coilsRW, nil
+	case TableDiscreteInputs:
+		return &sf.discreteRW, nil
+	case TableInputRegisters:
+		return &sf.inputRW, nil
+	case TableHoldingRegisters:
+		return &sf.holdingRW, nil
+	}
+	return nil, fmt.Errorf("modbus: unknown table '%v'", byte(table))
+}
+
+// SetBackend 将表 table 委托给后端 b, b 为nil时恢复使用内存映像.
+// 表的地址范围仍由 NewNodeRegister 决定, 写保护区和写入订阅对后端同样有效,
+// Update 失败时不会回滚已写入后端的值, 整表操作 (BitTable 等) 对后端表不可用
+func (sf *NodeRegister) SetBackend(table Table, b RegisterBackend) error {
+	mu, err := sf.tableLock(table)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	sf.backends[table] = b
+	mu.Unlock()
+	return nil
+}
+
+// backendTableError 表委托给后端时返回错误, 调用者需持有对应表的锁
+func (sf *NodeRegister) backendTableError(table Table) error {
+	if sf.backends[table] != nil {
+		return fmt.Errorf("modbus: %v table is delegated to a backend", table)
+	}
+	return nil
+}
+
+// readBackend 从后端读取, 检查返回值的数量, 返回的值为副本, 后端可以返回内部的切片
+func readBackend(b RegisterBackend, address, quantity uint16) ([]uint16, error) {
+	v, err := b.ReadRegs(address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != int(quantity) {
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceFailure}
+	}
+	return append([]uint16(nil), v...), nil
+}
+
+// maskWriteBackend 屏蔽写后端的一个寄存器, 先读后写
+func maskWriteBackend(b RegisterBackend, address, andMask, orMask uint16) error {
+	v, err := readBackend(b, address, 1)
+	if err != nil {
+		return err
+	}
+	return b.WriteRegs(address, []uint16{(v[0] & andMask) | (orMask & ^andMask)})
+}
+
+// packBits 每个值一个位压缩为协议的位图, 低位在前
+func packBits(values []uint16) []byte {
+	b := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v != 0 {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return b
+}
+
+// unpackBits 协议的位图展开为每个位一个值
+func unpackBits(buf []byte, quantity uint16) []uint16 {
+	v := make([]uint16, quantity)
+	for i := range v {
+		v[i] = uint16(buf[i/8]>>uint(i%8)) & 1
+	}
+	return v
+}
+
+// uint162Bytes uint16 register conver to bytes
+func uint162Bytes(values []uint16) []byte {
+	b := make([]byte, len(values)*2)
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[i*2:], v)
+	}
+	return b
+}
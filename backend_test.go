@@ -0,0 +1,151 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// mapBackend a backend keeps the values in a map, record the writes
+type mapBackend struct {
+	regs   map[uint16]uint16
+	writes int
+	err    error
+}
+
+func newMapBackend() *mapBackend {
+	return &mapBackend{regs: make(map[uint16]uint16)}
+}
+
+func (sf *mapBackend) ReadRegs(address, quantity uint16) ([]uint16, error) {
+	if sf.err != nil {
+		return nil, sf.err
+	}
+	v := make([]uint16, quantity)
+	for i := range v {
+		v[i] = sf.regs[address+uint16(i)]
+	}
+	return v, nil
+}
+
+func (sf *mapBackend) WriteRegs(address uint16, values []uint16) error {
+	if sf.err != nil {
+		return sf.err
+	}
+	sf.writes++
+	for i, v := range values {
+		sf.regs[address+uint16(i)] = v
+	}
+	return nil
+}
+
+func TestNodeRegister_SetBackend(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 100, 8, 200, 8)
+	if err := node.SetBackend(Table(9), newMapBackend()); err == nil {
+		t.Errorf("SetBackend() unknown table error = nil")
+	}
+	holding, input, coils := newMapBackend(), newMapBackend(), newMapBackend()
+	_ = node.SetBackend(TableHoldingRegisters, holding)
+	_ = node.SetBackend(TableInputRegisters, input)
+	_ = node.SetBackend(TableCoils, coils)
+
+	if err := node.WriteHoldings(202, []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.WriteHoldingsBytes(204, 1, []byte{0x12, 0x34}); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.MaskWriteHolding(204, 0xff00, 0x0001); err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint16]uint16{202: 1, 203: 2, 204: 0x1201}
+	if !reflect.DeepEqual(holding.regs, want) {
+		t.Errorf("holding backend = %v, want %v", holding.regs, want)
+	}
+	if got, _ := node.ReadHoldings(202, 3); !reflect.DeepEqual(got, []uint16{1, 2, 0x1201}) {
+		t.Errorf("ReadHoldings() = %v", got)
+	}
+	if got, _ := node.ReadHoldingsBytes(203, 1); !reflect.DeepEqual(got, []byte{0, 2}) {
+		t.Errorf("ReadHoldingsBytes() = %v", got)
+	}
+	if _, err := node.ReadHoldings(207, 2); !IsIllegalDataAddress(err) {
+		t.Errorf("ReadHoldings() out of range error = %v, want illegal data address", err)
+	}
+
+	input.regs[100] = 7
+	if got, _ := node.ReadInputsBytes(100, 1); !reflect.DeepEqual(got, []byte{0, 7}) {
+		t.Errorf("ReadInputsBytes() = %v", got)
+	}
+
+	if err := node.WriteCoils(1, 10, []byte{0x05, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if coils.regs[1] != 1 || coils.regs[2] != 0 || coils.regs[3] != 1 || coils.regs[10] != 1 {
+		t.Errorf("coils backend = %v", coils.regs)
+	}
+	if got, _ := node.ReadCoils(1, 10); !reflect.DeepEqual(got, []byte{0x05, 0x02}) {
+		t.Errorf("ReadCoils() = %v", got)
+	}
+	if _, err := node.BitTable(TableCoils); err == nil {
+		t.Errorf("BitTable() backend table error = nil")
+	}
+	if _, err := node.BitTable(TableDiscreteInputs); err != nil {
+		t.Errorf("BitTable() memory table error = %v", err)
+	}
+
+	_ = node.SetBackend(TableHoldingRegisters, nil)
+	if got, _ := node.ReadHoldings(202, 1); got[0] != 0 {
+		t.Errorf("ReadHoldings() after removing the backend = %v, want the memory image", got)
+	}
+}
+
+func TestNodeRegister_BackendError(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 8)
+	b := newMapBackend()
+	_ = node.SetBackend(TableHoldingRegisters, b)
+	ch, cancel := node.Subscribe(TableHoldingRegisters, 0, 8, 4)
+	defer cancel()
+
+	b.err = &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceBusy}
+	if err := node.WriteHoldings(0, []uint16{1}); ExceptionOf(err) != ExceptionCodeServerDeviceBusy {
+		t.Errorf("WriteHoldings() error = %v, want server device busy", err)
+	}
+	b.err = errors.New("gpio failure")
+	c := NewClient(NewLoopbackProvider(node))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); ExceptionOf(err) != ExceptionCodeServerDeviceFailure {
+		t.Errorf("ReadHoldingRegisters() error = %v, want server device failure", err)
+	}
+	if got := drainChanges(ch); got != nil {
+		t.Errorf("events = %v, want none for the failed writes", got)
+	}
+
+	b.err = nil
+	if err := c.WriteMultipleRegisters(1, 1, 1, []byte{0, 9}); err != nil {
+		t.Fatal(err)
+	}
+	if b.regs[1] != 9 {
+		t.Errorf("backend = %v, want the remote write", b.regs)
+	}
+	if got, want := drainChanges(ch), []ChangeEvent{{1, TableHoldingRegisters, 1, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+// shortBackend returns less values than requested
+type shortBackend struct{ mapBackend }
+
+func (sf *shortBackend) ReadRegs(address, quantity uint16) ([]uint16, error) {
+	return make([]uint16, quantity-1), nil
+}
+
+func TestNodeRegister_BackendShortRead(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 4, 0, 0)
+	_ = node.SetBackend(TableInputRegisters, &shortBackend{})
+	if _, err := node.ReadInputs(0, 2); ExceptionOf(err) != ExceptionCodeServerDeviceFailure {
+		t.Errorf("ReadInputs() error = %v, want server device failure", err)
+	}
+}
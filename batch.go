@@ -0,0 +1,179 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReadSpec a read of the batch
+type ReadSpec struct {
+	SlaveID  byte
+	Table    Table
+	Address  uint16
+	Quantity uint16
+}
+
+// ReadResult the result of the ReadSpec,
+// Data is the packed bits of coils and discrete inputs,
+// or the big endian bytes of the registers, same as the single read.
+type ReadResult struct {
+	ReadSpec
+	Data []byte
+	Err  error
+}
+
+// BatchOption 批量读可选项
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	coalesce bool
+	maxGap   uint16
+}
+
+// BatchCoalesce merge the reads of the same slave and table into one request,
+// when the gap between them is not bigger than maxGap and
+// the merged quantity does not exceed the protocol limit.
+// the gap is read and dropped, so only use it when the gap is readable.
+func BatchCoalesce(maxGap uint16) BatchOption {
+	return func(o *batchOptions) {
+		o.coalesce, o.maxGap = true, maxGap
+	}
+}
+
+// batchRead a request of the batch, it may serve several specs
+type batchRead struct {
+	ReadSpec
+	items []int
+}
+
+// ReadBatch executes the reads one after another, possibly to different slaves,
+// and returns the per-item results in the order of the specs.
+// the error is the first failed one, the others are still executed.
+// every planned read is a separate transaction which takes the provider lock as usual,
+// so the transactions of other goroutines may run between them,
+// BatchCoalesce reduce the transactions of the batch.
+func (sf *client) ReadBatch(specs []ReadSpec, opts ...BatchOption) ([]ReadResult, error) {
+	var o batchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	results := make([]ReadResult, len(specs))
+	for i := range specs {
+		results[i].ReadSpec = specs[i]
+	}
+	var firstErr error
+	for _, r := range planBatch(specs, results, o) {
+		data, err := sf.readTable(r.ReadSpec)
+		for _, i := range r.items {
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			offset := int(specs[i].Address - r.Address)
+			if r.Table == TableCoils || r.Table == TableDiscreteInputs {
+				results[i].Data = extractBits(data, offset, int(specs[i].Quantity))
+			} else {
+				results[i].Data = append([]byte(nil), data[offset*2:(offset+int(specs[i].Quantity))*2]...)
+			}
+		}
+	}
+	for i := range results {
+		if results[i].Err != nil {
+			firstErr = results[i].Err
+			break
+		}
+	}
+	return results, firstErr
+}
+
+// planBatch plan the requests of the specs, invalid spec's error is filled into results
+func planBatch(specs []ReadSpec, results []ReadResult, o batchOptions) []*batchRead {
+	order := make([]int, 0, len(specs))
+	for i, s := range specs {
+		max := tableQuantityMax(s.Table)
+		switch {
+		case max == 0:
+			results[i].Err = fmt.Errorf("modbus: unknown table '%v'", s.Table)
+		case s.Quantity == 0 || s.Quantity > max:
+			results[i].Err = fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", s.Quantity, 1, max)
+		default:
+			order = append(order, i)
+		}
+	}
+	if !o.coalesce {
+		reads := make([]*batchRead, 0, len(order))
+		for _, i := range order {
+			reads = append(reads, &batchRead{specs[i], []int{i}})
+		}
+		return reads
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := specs[order[a]], specs[order[b]]
+		if x.SlaveID != y.SlaveID {
+			return x.SlaveID < y.SlaveID
+		}
+		if x.Table != y.Table {
+			return x.Table < y.Table
+		}
+		return x.Address < y.Address
+	})
+	var reads []*batchRead
+	var cur *batchRead
+	for _, i := range order {
+		s := specs[i]
+		if cur != nil && cur.SlaveID == s.SlaveID && cur.Table == s.Table {
+			curEnd := uint32(cur.Address) + uint32(cur.Quantity)
+			end := uint32(s.Address) + uint32(s.Quantity)
+			if end < curEnd {
+				end = curEnd
+			}
+			if uint32(s.Address) <= curEnd+uint32(o.maxGap) &&
+				end-uint32(cur.Address) <= uint32(tableQuantityMax(s.Table)) {
+				cur.Quantity = uint16(end - uint32(cur.Address))
+				cur.items = append(cur.items, i)
+				continue
+			}
+		}
+		cur = &batchRead{s, []int{i}}
+		reads = append(reads, cur)
+	}
+	return reads
+}
+
+// readTable read the table of the spec
+func (sf *client) readTable(s ReadSpec) ([]byte, error) {
+	switch s.Table {
+	case TableCoils:
+		return sf.ReadCoils(s.SlaveID, s.Address, s.Quantity)
+	case TableDiscreteInputs:
+		return sf.ReadDiscreteInputs(s.SlaveID, s.Address, s.Quantity)
+	case TableInputRegisters:
+		return sf.ReadInputRegistersBytes(s.SlaveID, s.Address, s.Quantity)
+	}
+	return sf.ReadHoldingRegistersBytes(s.SlaveID, s.Address, s.Quantity)
+}
+
+// tableQuantityMax the max read quantity of the table, 0 if the table is unknown
+func tableQuantityMax(t Table) uint16 {
+	switch t {
+	case TableCoils, TableDiscreteInputs:
+		return ReadBitsQuantityMax
+	case TableInputRegisters, TableHoldingRegisters:
+		return ReadRegQuantityMax
+	}
+	return 0
+}
+
+// extractBits extract quantity bits start at offset from the packed bits
+func extractBits(data []byte, offset, quantity int) []byte {
+	result := make([]byte, (quantity+7)/8)
+	for i := 0; i < quantity; i++ {
+		bit := offset + i
+		if data[bit/8]&(1<<uint(bit%8)) != 0 {
+			result[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package modbus
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func Test_ReadBatch(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	node := NewNodeRegister(testslaveID1, 0, 32, 0, 10, 0, 10, 0, 20)
+	node.WriteHoldings(0, []uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	node.WriteCoils(0, 16, []byte{0xa5, 0x0f})
+	mbSrv.AddNodes(node)
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	var requests int
+	mbCli.Use(func(next Doer) Doer {
+		return DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+			requests++
+			return next.Do(slaveID, request)
+		})
+	})
+
+	specs := []ReadSpec{
+		{testslaveID1, TableHoldingRegisters, 4, 2},
+		{testslaveID1, TableCoils, 4, 8},
+		{testslaveID1, TableHoldingRegisters, 1, 2},
+		{testslaveID1, TableHoldingRegisters, 30, 1}, // illegal address
+		{testslaveID1, TableHoldingRegisters, 1, 0},  // invalid quantity
+	}
+	want := [][]byte{
+		{0x00, 0x04, 0x00, 0x05},
+		{0xfa},
+		{0x00, 0x01, 0x00, 0x02},
+		nil,
+		nil,
+	}
+	tests := []struct {
+		name         string
+		opts         []BatchOption
+		wantRequests int
+	}{
+		{"no coalesce", nil, 4},
+		{"coalesce", []BatchOption{BatchCoalesce(1)}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			results, err := mbCli.ReadBatch(specs, tt.opts...)
+			if !IsIllegalDataAddress(err) {
+				t.Errorf("ReadBatch() error = %v, want illegal data address", err)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %v, want %v", requests, tt.wantRequests)
+			}
+			for i, r := range results {
+				if (r.Err != nil) != (want[i] == nil) || !bytes.Equal(r.Data, want[i]) {
+					t.Errorf("results[%d] = % x, %v, want % x", i, r.Data, r.Err, want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// BCD(binary coded decimal) helpers, the packed BCD is 4 digits per register,
+// the most significant digit first, as the register bytes on the wire.
+// the signed one use the most significant nibble as the sign,
+// 0x0 is positive, 0xF or 0x8 is negative, BCDNegativeSign is used when encoding.
+
+// BCDNegativeSign the sign nibble of the negative signed BCD
+const BCDNegativeSign = 0xF
+
+// DecodeBCD decode the unsigned packed BCD of the register bytes
+func DecodeBCD(b []byte) (uint64, error) {
+	if len(b) > 9 { // 18 digits at most, so it fit in uint64 and int64
+		return 0, fmt.Errorf("modbus: bcd of '%v' bytes overflow", len(b))
+	}
+	var v uint64
+	for i, c := range b {
+		hi, lo := c>>4, c&0x0f
+		if hi > 9 || lo > 9 {
+			return 0, fmt.Errorf("modbus: invalid bcd byte '%#02x' at '%v'", c, i)
+		}
+		v = v*100 + uint64(hi)*10 + uint64(lo)
+	}
+	return v, nil
+}
+
+// EncodeBCD encode the value into dst as unsigned packed BCD,
+// it return error if the value does not fit in dst.
+func EncodeBCD(dst []byte, v uint64) error {
+	for i := len(dst) - 1; i >= 0; i-- {
+		dst[i] = byte(v%10) | byte(v/10%10)<<4
+		v /= 100
+	}
+	if v != 0 {
+		return fmt.Errorf("modbus: value does not fit in '%v' bcd bytes", len(dst))
+	}
+	return nil
+}
+
+// DecodeSignedBCD decode the signed packed BCD of the register bytes
+func DecodeSignedBCD(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	sign := b[0] >> 4
+	if sign != 0 && sign != 0x8 && sign != 0xF {
+		return 0, fmt.Errorf("modbus: invalid bcd sign '%#x'", sign)
+	}
+	// decode the digits without the sign nibble
+	digits := make([]byte, len(b))
+	copy(digits, b)
+	digits[0] &= 0x0f
+	v, err := DecodeBCD(digits)
+	if err != nil {
+		return 0, err
+	}
+	if sign != 0 {
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+// EncodeSignedBCD encode the value into dst as signed packed BCD,
+// it return error if the value does not fit in dst.
+func EncodeSignedBCD(dst []byte, v int64) error {
+	if len(dst) == 0 {
+		return fmt.Errorf("modbus: value does not fit in '%v' bcd bytes", len(dst))
+	}
+	abs := uint64(v)
+	if v < 0 {
+		abs = uint64(-v)
+	}
+	if err := EncodeBCD(dst, abs); err != nil {
+		return err
+	}
+	if dst[0]>>4 != 0 { // the sign nibble is occupied by digit
+		return fmt.Errorf("modbus: value does not fit in '%v' bcd bytes", len(dst))
+	}
+	if v < 0 {
+		dst[0] |= BCDNegativeSign << 4
+	}
+	return nil
+}
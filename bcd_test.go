@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBCD(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    uint64
+		wantErr bool
+	}{
+		{"one register", []byte{0x12, 0x34}, 1234, false},
+		{"two registers", []byte{0x00, 0x12, 0x34, 0x56}, 123456, false},
+		{"invalid digit", []byte{0x1a, 0x34}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeBCD(tt.b)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("DecodeBCD() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			b := make([]byte, len(tt.b))
+			if err = EncodeBCD(b, tt.want); err != nil || !bytes.Equal(b, tt.b) {
+				t.Errorf("EncodeBCD() = % x, %v, want % x", b, err, tt.b)
+			}
+		})
+	}
+	if err := EncodeBCD(make([]byte, 2), 12345); err == nil {
+		t.Errorf("EncodeBCD() overflow error = nil, want error")
+	}
+}
+
+func TestSignedBCD(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       []byte
+		want    int64
+		wantErr bool
+	}{
+		{"positive", []byte{0x01, 0x23}, 123, false},
+		{"negative", []byte{0xf1, 0x23}, -123, false},
+		{"negative sign bit", []byte{0x81, 0x23}, -123, false},
+		{"invalid sign", []byte{0x31, 0x23}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeSignedBCD(tt.b)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("DecodeSignedBCD() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+	b := make([]byte, 2)
+	if err := EncodeSignedBCD(b, -123); err != nil || !bytes.Equal(b, []byte{0xf1, 0x23}) {
+		t.Errorf("EncodeSignedBCD() = % x, %v, want f1 23", b, err)
+	}
+	if err := EncodeSignedBCD(b, 1234); err == nil {
+		t.Errorf("EncodeSignedBCD() overflow sign nibble error = nil, want error")
+	}
+}
@@ -0,0 +1,61 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// BitNames the names of the bits of a status or alarm register,
+// index is the bit number, 0 is the least significant bit, empty name is unused.
+type BitNames [16]string
+
+// Unpack map the set bits of the register to the named booleans, unused bits are ignored
+func (sf BitNames) Unpack(reg uint16) map[string]bool {
+	flags := make(map[string]bool)
+	for i, name := range sf {
+		if name != "" {
+			flags[name] = reg&(1<<uint(i)) != 0
+		}
+	}
+	return flags
+}
+
+// Pack pack the named booleans into a register, the bits not given are 0
+func (sf BitNames) Pack(flags map[string]bool) (uint16, error) {
+	return sf.Apply(0, flags)
+}
+
+// Apply set or clear the named bits of the register, the others are kept,
+// it is used to modify some bits of the register read.
+func (sf BitNames) Apply(reg uint16, flags map[string]bool) (uint16, error) {
+	for name, on := range flags {
+		n, ok := sf.Index(name)
+		if !ok {
+			return reg, fmt.Errorf("modbus: unknown bit name '%s'", name)
+		}
+		reg = SetBit(reg, n, on)
+	}
+	return reg, nil
+}
+
+// Index got the bit number of the name
+func (sf BitNames) Index(name string) (uint, bool) {
+	for i, v := range sf {
+		if v != "" && v == name {
+			return uint(i), true
+		}
+	}
+	return 0, false
+}
+
+// Bit whether the bit n of the register is set
+func Bit(reg uint16, n uint) bool {
+	return reg&(1<<n) != 0
+}
+
+// SetBit set or clear the bit n of the register
+func SetBit(reg uint16, n uint, on bool) uint16 {
+	if on {
+		return reg | 1<<n
+	}
+	return reg &^ (1 << n)
+}
@@ -0,0 +1,28 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBitNames(t *testing.T) {
+	names := BitNames{0: "running", 1: "fault", 15: "remote"}
+
+	got := names.Unpack(0x8001)
+	want := map[string]bool{"running": true, "fault": false, "remote": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unpack() = %v, want %v", got, want)
+	}
+	if reg, err := names.Pack(want); err != nil || reg != 0x8001 {
+		t.Errorf("Pack() = %#x, %v, want 0x8001", reg, err)
+	}
+	if reg, err := names.Apply(0x00f1, map[string]bool{"running": false, "fault": true}); err != nil || reg != 0x00f2 {
+		t.Errorf("Apply() = %#x, %v, want 0xf2", reg, err)
+	}
+	if _, err := names.Pack(map[string]bool{"unknown": true}); err == nil {
+		t.Errorf("Pack() unknown name error = nil, want error")
+	}
+	if !Bit(0x0004, 2) || Bit(0x0004, 1) {
+		t.Errorf("Bit() wrong")
+	}
+}
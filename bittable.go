@@ -0,0 +1,140 @@
+package modbus
+
+// 本文件提供了线圈和离散量整表的导入导出, 便于仿真加载大量的IO映像
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// bitTable the lock, the packed bits and the quantity of the coils or discrete inputs table,
+// the packed bits must be accessed with the lock held.
+func (sf *NodeRegister) bitTable(table Table) (*sync.RWMutex, []byte, uint16, error) {
+	switch table {
+	case TableCoils:
+		return &sf.coilsRW, sf.coils, sf.coilsQuantity, nil
+	case TableDiscreteInputs:
+		return &sf.discreteRW, sf.discrete, sf.discreteQuantity, nil
+	}
+	return nil, nil, 0, fmt.Errorf("modbus: table '%v' is not a bit table", table)
+}
+
+// notifyBitTable 通知整表写入的订阅者, 调用者需持有对应表的写锁
+func (sf *NodeRegister) notifyBitTable(table Table, quantity uint16) {
+	start := sf.coilsAddrStart
+	if table == TableDiscreteInputs {
+		start = sf.discreteAddrStart
+	}
+	sf.notify(table, start, quantity)
+}
+
+// BitTable 获取整个线圈或离散量表, 每个位一个bool
+func (sf *NodeRegister) BitTable(table Table) ([]bool, error) {
+	mu, buf, quantity, err := sf.bitTable(table)
+	if err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if err = sf.backendTableError(table); err != nil {
+		return nil, err
+	}
+	result := make([]bool, quantity)
+	for i := range result {
+		result[i] = buf[i/8]&(1<<uint(i%8)) != 0
+	}
+	return result, nil
+}
+
+// SetBitTable 设置整个线圈或离散量表, values 的长度必须等于表的数量
+func (sf *NodeRegister) SetBitTable(table Table, values []bool) error {
+	mu, buf, quantity, err := sf.bitTable(table)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err = sf.backendTableError(table); err != nil {
+		return err
+	}
+	if len(values) != int(quantity) {
+		return fmt.Errorf("modbus: values size '%v' does not match %v quantity '%v'", len(values), table, quantity)
+	}
+	for i := range buf {
+		buf[i] = 0
+	}
+	for i, v := range values {
+		if v {
+			buf[i/8] |= 1 << uint(i%8)
+		}
+	}
+	sf.notifyBitTable(table, quantity)
+	return nil
+}
+
+// BitTableBytes 获取整个线圈或离散量表的紧凑位图, 与协议相同, 低位在前
+func (sf *NodeRegister) BitTableBytes(table Table) ([]byte, error) {
+	mu, buf, _, err := sf.bitTable(table)
+	if err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if err = sf.backendTableError(table); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf...), nil
+}
+
+// SetBitTableBytes 用紧凑位图设置整个线圈或离散量表, 低位在前,
+// packed 的长度必须等于 (数量+7)/8, 最后一个字节多余的位被忽略
+func (sf *NodeRegister) SetBitTableBytes(table Table, packed []byte) error {
+	mu, buf, quantity, err := sf.bitTable(table)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if err = sf.backendTableError(table); err != nil {
+		return err
+	}
+	if len(packed) != len(buf) {
+		return fmt.Errorf("modbus: packed size '%v' does not match %v quantity '%v' to bytes '%v'",
+			len(packed), table, quantity, len(buf))
+	}
+	copy(buf, packed)
+	if rem := quantity % 8; rem != 0 {
+		buf[len(buf)-1] &= byte(1<<rem - 1)
+	}
+	sf.notifyBitTable(table, quantity)
+	return nil
+}
+
+// ExportBitTable 将整个线圈或离散量表的紧凑位图写入w
+func (sf *NodeRegister) ExportBitTable(table Table, w io.Writer) error {
+	b, err := sf.BitTableBytes(table)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ImportBitTable 从r读取紧凑位图设置整个线圈或离散量表, 读取 (数量+7)/8 个字节,
+// 数据不足时返回 io.ErrUnexpectedEOF, 表不被修改
+func (sf *NodeRegister) ImportBitTable(table Table, r io.Reader) error {
+	_, buf, _, err := sf.bitTable(table)
+	if err != nil {
+		return err
+	}
+	size := len(buf)
+	b := make([]byte, size)
+	if _, err = io.ReadFull(r, b); err != nil {
+		if err == io.EOF && size > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return sf.SetBitTableBytes(table, b)
+}
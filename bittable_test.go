@@ -0,0 +1,85 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestNodeRegister_BitTable(t *testing.T) {
+	node := NewNodeRegister(1, 0, 10, 100, 3, 0, 0, 0, 0)
+	coils := []bool{true, false, true, true, false, false, false, false, true, true}
+	if err := node.SetBitTable(TableCoils, coils); err != nil {
+		t.Fatalf("SetBitTable() error = %v", err)
+	}
+	got, err := node.BitTable(TableCoils)
+	if err != nil || !reflect.DeepEqual(got, coils) {
+		t.Errorf("BitTable() = %v, %v, want %v", got, err, coils)
+	}
+	b, _ := node.ReadCoils(0, 10)
+	if !bytes.Equal(b, []byte{0x0d, 0x03}) {
+		t.Errorf("ReadCoils() = % x, want 0d 03", b)
+	}
+
+	if err = node.SetBitTableBytes(TableDiscreteInputs, []byte{0xff}); err != nil {
+		t.Fatalf("SetBitTableBytes() error = %v", err)
+	}
+	if b, _ = node.BitTableBytes(TableDiscreteInputs); !bytes.Equal(b, []byte{0x07}) {
+		t.Errorf("BitTableBytes() = % x, want padding cleared 07", b)
+	}
+	b[0] = 0
+	if v, _ := node.ReadSingleDiscrete(101); !v {
+		t.Errorf("BitTableBytes() must return a copy")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"不是位表", node.SetBitTable(TableHoldingRegisters, nil)},
+		{"长度不符", node.SetBitTable(TableCoils, coils[:9])},
+		{"位图长度不符", node.SetBitTableBytes(TableCoils, []byte{0x01})},
+	}
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("%v: want error", tt.name)
+		}
+	}
+	if _, err = node.BitTable(TableInputRegisters); err == nil {
+		t.Errorf("BitTable() not a bit table, want error")
+	}
+}
+
+func TestNodeRegister_ImportExportBitTable(t *testing.T) {
+	src := NewNodeRegister(1, 0, 2000, 0, 0, 0, 0, 0, 0)
+	for i := uint16(0); i < 2000; i += 3 {
+		_ = src.WriteSingleCoil(i, true)
+	}
+	var buf bytes.Buffer
+	if err := src.ExportBitTable(TableCoils, &buf); err != nil {
+		t.Fatalf("ExportBitTable() error = %v", err)
+	}
+	if buf.Len() != 250 {
+		t.Errorf("exported %v bytes, want 250", buf.Len())
+	}
+
+	dst := NewNodeRegister(1, 0, 2000, 0, 0, 0, 0, 0, 0)
+	if err := dst.ImportBitTable(TableCoils, &buf); err != nil {
+		t.Fatalf("ImportBitTable() error = %v", err)
+	}
+	want, _ := src.BitTable(TableCoils)
+	if got, _ := dst.BitTable(TableCoils); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported table does not match the exported")
+	}
+
+	if err := dst.ImportBitTable(TableCoils, bytes.NewReader(make([]byte, 10))); err != io.ErrUnexpectedEOF {
+		t.Errorf("ImportBitTable() short = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if err := dst.ImportBitTable(TableCoils, bytes.NewReader(nil)); err != io.ErrUnexpectedEOF {
+		t.Errorf("ImportBitTable() empty = %v, want io.ErrUnexpectedEOF", err)
+	}
+	if got, _ := dst.BitTable(TableCoils); !reflect.DeepEqual(got, want) {
+		t.Errorf("failed import must not modify the table")
+	}
+}
@@ -0,0 +1,117 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// Value the values of a block in a table,
+// Bits for the coils and discrete inputs, Registers for the input and holding registers.
+type Value struct {
+	Time      time.Time
+	SlaveID   byte
+	Table     modbus.Table
+	Address   uint16
+	Bits      []bool
+	Registers []uint16
+}
+
+// isBits whether the table is coils or discrete inputs
+func isBits(t modbus.Table) bool {
+	return t == modbus.TableCoils || t == modbus.TableDiscreteInputs
+}
+
+// Codec encode the polled value into the payload, and decode the command payload,
+// the SlaveID, Table and Address of the value to decode are set from the topic.
+type Codec interface {
+	Encode(v *Value) ([]byte, error)
+	Decode(payload []byte, v *Value) error
+}
+
+// JSONCodec the json payload, such as
+// {"time":"2006-01-02T15:04:05Z","slaveId":1,"table":"holding","address":0,"values":[1,2]},
+// the command payload need only the values, such as {"values":[true,false]}.
+type JSONCodec struct{}
+
+// jsonValue the json payload
+type jsonValue struct {
+	Time    time.Time       `json:"time"`
+	SlaveID byte            `json:"slaveId"`
+	Table   string          `json:"table"`
+	Address uint16          `json:"address"`
+	Values  json.RawMessage `json:"values"`
+}
+
+// Encode implements Codec
+func (JSONCodec) Encode(v *Value) ([]byte, error) {
+	var values interface{} = v.Registers
+	if isBits(v.Table) {
+		values = v.Bits
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue{v.Time, v.SlaveID, tableNames[v.Table], v.Address, b})
+}
+
+// Decode implements Codec
+func (JSONCodec) Decode(payload []byte, v *Value) error {
+	var jv jsonValue
+	if err := json.Unmarshal(payload, &jv); err != nil {
+		return err
+	}
+	if len(jv.Values) == 0 {
+		return errors.New("mqtt: payload without values")
+	}
+	if isBits(v.Table) {
+		return json.Unmarshal(jv.Values, &v.Bits)
+	}
+	return json.Unmarshal(jv.Values, &v.Registers)
+}
+
+// RawCodec the binary payload, the registers are big endian 2 bytes each,
+// the bits are 1 byte each, not 0 is ON.
+type RawCodec struct{}
+
+// Encode implements Codec
+func (RawCodec) Encode(v *Value) ([]byte, error) {
+	if isBits(v.Table) {
+		b := make([]byte, len(v.Bits))
+		for i, on := range v.Bits {
+			if on {
+				b[i] = 1
+			}
+		}
+		return b, nil
+	}
+	b := make([]byte, len(v.Registers)*2)
+	for i, r := range v.Registers {
+		binary.BigEndian.PutUint16(b[i*2:], r)
+	}
+	return b, nil
+}
+
+// Decode implements Codec
+func (RawCodec) Decode(payload []byte, v *Value) error {
+	if isBits(v.Table) {
+		v.Bits = make([]bool, len(payload))
+		for i, b := range payload {
+			v.Bits[i] = b != 0
+		}
+		return nil
+	}
+	if len(payload)%2 != 0 {
+		return fmt.Errorf("mqtt: raw registers payload size '%v' is odd", len(payload))
+	}
+	v.Registers = make([]uint16, len(payload)/2)
+	for i := range v.Registers {
+		v.Registers[i] = binary.BigEndian.Uint16(payload[i*2:])
+	}
+	return nil
+}
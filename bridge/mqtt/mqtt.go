@@ -0,0 +1,276 @@
+// Package mqtt bridge the mb poller to a MQTT broker, the polled values are published
+// to the topics, and the commands subscribed are translated into modbus writes,
+// which are queued into the poller and executed on the same bus serialization as the polls.
+//
+// the package does not depend on a MQTT library, adapt the client you use,
+// such as github.com/eclipse/paho.mqtt.golang, to the Client interface.
+//
+// the default topics are
+//
+//	<prefix>/<slaveID>/<table>/<address>      the polled block start at address
+//	<prefix>/<slaveID>/<table>/<address>/set  the command write from address
+//
+// table is coils, discrete, input or holding, only the coils and holding registers are writable.
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+)
+
+// Client the MQTT client used by the bridge
+type Client interface {
+	// Publish the payload to the topic
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+	// Subscribe the topic filter, the handler is called with every message
+	Subscribe(filter string, qos byte, handler func(topic string, payload []byte)) error
+}
+
+// tableNames the table in the topic
+var tableNames = map[modbus.Table]string{
+	modbus.TableCoils:            "coils",
+	modbus.TableDiscreteInputs:   "discrete",
+	modbus.TableInputRegisters:   "input",
+	modbus.TableHoldingRegisters: "holding",
+}
+
+// Bridge publish the polled values and translate the commands into writes,
+// it implements mb.Handler, set it to the poller by mb.WitchHandler.
+type Bridge struct {
+	client   Client
+	codec    Codec
+	prefix   string
+	topic    func(v *Value) string
+	qos      byte
+	retained bool
+	next     mb.Handler
+	onError  func(err error)
+	poller   *mb.Client
+}
+
+var _ mb.Handler = (*Bridge)(nil)
+
+// Option the option of the bridge
+type Option func(b *Bridge)
+
+// WithCodec set the payload codec, default JSONCodec
+func WithCodec(c Codec) Option {
+	return func(b *Bridge) {
+		if c != nil {
+			b.codec = c
+		}
+	}
+}
+
+// WithPrefix set the topic prefix, default "modbus"
+func WithPrefix(prefix string) Option {
+	return func(b *Bridge) {
+		b.prefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithTopic set the topic of the polled value instead of the default,
+// the command topics are not affected.
+func WithTopic(f func(v *Value) string) Option {
+	return func(b *Bridge) {
+		b.topic = f
+	}
+}
+
+// WithQoS set the qos and retained flag of the publish and subscribe
+func WithQoS(qos byte, retained bool) Option {
+	return func(b *Bridge) {
+		b.qos, b.retained = qos, retained
+	}
+}
+
+// WithHandler set the handler called after the value is published, such as the tags handler
+func WithHandler(h mb.Handler) Option {
+	return func(b *Bridge) {
+		b.next = h
+	}
+}
+
+// WithErrorHandler set the callback of the publish, decode and write errors, default ignore them
+func WithErrorHandler(f func(err error)) Option {
+	return func(b *Bridge) {
+		if f != nil {
+			b.onError = f
+		}
+	}
+}
+
+// NewBridge new a bridge on the MQTT client
+func NewBridge(c Client, opts ...Option) *Bridge {
+	b := &Bridge{
+		client:  c,
+		codec:   JSONCodec{},
+		prefix:  "modbus",
+		onError: func(error) {},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Start subscribe the command topics, the commands are queued into the poller
+func (sf *Bridge) Start(poller *mb.Client) error {
+	sf.poller = poller
+	return sf.client.Subscribe(sf.prefix+"/+/+/+/set", sf.qos, sf.command)
+}
+
+// ProcReadCoils implements mb.Handler
+func (sf *Bridge) ProcReadCoils(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.publish(modbus.TableCoils, slaveID, address, quantity, valBuf)
+	if sf.next != nil {
+		sf.next.ProcReadCoils(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcReadDiscretes implements mb.Handler
+func (sf *Bridge) ProcReadDiscretes(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.publish(modbus.TableDiscreteInputs, slaveID, address, quantity, valBuf)
+	if sf.next != nil {
+		sf.next.ProcReadDiscretes(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcReadHoldingRegisters implements mb.Handler
+func (sf *Bridge) ProcReadHoldingRegisters(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.publish(modbus.TableHoldingRegisters, slaveID, address, quantity, valBuf)
+	if sf.next != nil {
+		sf.next.ProcReadHoldingRegisters(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcReadInputRegisters implements mb.Handler
+func (sf *Bridge) ProcReadInputRegisters(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.publish(modbus.TableInputRegisters, slaveID, address, quantity, valBuf)
+	if sf.next != nil {
+		sf.next.ProcReadInputRegisters(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcResult implements mb.Handler
+func (sf *Bridge) ProcResult(err error, result *mb.Result) {
+	if sf.next != nil {
+		sf.next.ProcResult(err, result)
+	}
+}
+
+// publish the polled block
+func (sf *Bridge) publish(table modbus.Table, slaveID byte, address, quantity uint16, valBuf []byte) {
+	v := &Value{Time: time.Now(), SlaveID: slaveID, Table: table, Address: address}
+	if isBits(table) {
+		v.Bits = make([]bool, quantity)
+		for i := range v.Bits {
+			v.Bits[i] = valBuf[i/8]>>uint(i%8)&0x01 != 0
+		}
+	} else {
+		v.Registers = make([]uint16, quantity)
+		for i := range v.Registers {
+			v.Registers[i] = uint16(valBuf[i*2])<<8 | uint16(valBuf[i*2+1])
+		}
+	}
+	payload, err := sf.codec.Encode(v)
+	if err != nil {
+		sf.onError(err)
+		return
+	}
+	topic := fmt.Sprintf("%s/%d/%s/%d", sf.prefix, slaveID, tableNames[table], address)
+	if sf.topic != nil {
+		topic = sf.topic(v)
+	}
+	if err = sf.client.Publish(topic, sf.qos, sf.retained, payload); err != nil {
+		sf.onError(err)
+	}
+}
+
+// command translate the command message into a write queued into the poller
+func (sf *Bridge) command(topic string, payload []byte) {
+	v, err := parseTopic(strings.TrimPrefix(topic, sf.prefix+"/"))
+	if err == nil {
+		err = sf.codec.Decode(payload, v)
+	}
+	if err == nil {
+		err = sf.enqueue(v)
+	}
+	if err != nil {
+		sf.onError(fmt.Errorf("mqtt: command '%s': %v", topic, err))
+	}
+}
+
+// parseTopic parse <slaveID>/<table>/<address>/set
+func parseTopic(topic string) (*Value, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[3] != "set" {
+		return nil, errors.New("invalid topic")
+	}
+	slaveID, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil {
+		return nil, err
+	}
+	address, err := strconv.ParseUint(parts[2], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	for table, name := range tableNames {
+		if name == parts[1] {
+			return &Value{SlaveID: byte(slaveID), Table: table, Address: uint16(address)}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown table '%s'", parts[1])
+}
+
+// enqueue the write of the value into the poller
+func (sf *Bridge) enqueue(v *Value) error {
+	if sf.poller == nil {
+		return errors.New("bridge not started")
+	}
+	cmd := mb.Command{
+		SlaveID: v.SlaveID,
+		Address: v.Address,
+		Done: func(err error) {
+			if err != nil {
+				sf.onError(err)
+			}
+		},
+	}
+	switch {
+	case v.Table == modbus.TableCoils && len(v.Bits) == 1:
+		cmd.FuncCode = modbus.FuncCodeWriteSingleCoil
+		cmd.Value = []byte{0}
+		if v.Bits[0] {
+			cmd.Value[0] = 1
+		}
+	case v.Table == modbus.TableCoils && len(v.Bits) > 1:
+		cmd.FuncCode = modbus.FuncCodeWriteMultipleCoils
+		cmd.Quantity = uint16(len(v.Bits))
+		cmd.Value = make([]byte, (len(v.Bits)+7)/8)
+		for i, on := range v.Bits {
+			if on {
+				cmd.Value[i/8] |= 1 << uint(i%8)
+			}
+		}
+	case v.Table == modbus.TableHoldingRegisters && len(v.Registers) == 1:
+		cmd.FuncCode = modbus.FuncCodeWriteSingleRegister
+		cmd.Value = []byte{byte(v.Registers[0] >> 8), byte(v.Registers[0])}
+	case v.Table == modbus.TableHoldingRegisters && len(v.Registers) > 1:
+		cmd.FuncCode = modbus.FuncCodeWriteMultipleRegisters
+		cmd.Quantity = uint16(len(v.Registers))
+		cmd.Value = make([]byte, len(v.Registers)*2)
+		for i, r := range v.Registers {
+			cmd.Value[i*2], cmd.Value[i*2+1] = byte(r>>8), byte(r)
+		}
+	default:
+		return fmt.Errorf("no values to write to %v", v.Table)
+	}
+	return sf.poller.Enqueue(cmd)
+}
@@ -0,0 +1,105 @@
+package mqtt
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+)
+
+// fakeClient a MQTT client record the publishes
+type fakeClient struct {
+	mu        sync.Mutex
+	published map[string][]byte
+	handler   func(topic string, payload []byte)
+	filter    string
+}
+
+func (sf *fakeClient) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	sf.mu.Lock()
+	sf.published[topic] = payload
+	sf.mu.Unlock()
+	return nil
+}
+
+func (sf *fakeClient) Subscribe(filter string, qos byte, handler func(topic string, payload []byte)) error {
+	sf.filter, sf.handler = filter, handler
+	return nil
+}
+
+func (sf *fakeClient) get(topic string) []byte {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.published[topic]
+}
+
+func TestCodec(t *testing.T) {
+	values := []*Value{
+		{SlaveID: 1, Table: modbus.TableCoils, Address: 2, Bits: []bool{true, false, true}},
+		{SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 2, Registers: []uint16{0x1234, 0xffff}},
+	}
+	for _, codec := range []Codec{JSONCodec{}, RawCodec{}} {
+		for _, want := range values {
+			payload, err := codec.Encode(want)
+			if err != nil {
+				t.Fatalf("%T.Encode() error = %v", codec, err)
+			}
+			got := &Value{SlaveID: want.SlaveID, Table: want.Table, Address: want.Address}
+			if err = codec.Decode(payload, got); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%T.Decode() = %+v, %v, want %+v", codec, got, err, want)
+			}
+		}
+	}
+	if err := (RawCodec{}).Decode([]byte{1, 2, 3}, &Value{Table: modbus.TableHoldingRegisters}); err == nil {
+		t.Errorf("RawCodec.Decode() odd registers payload, want error")
+	}
+}
+
+func TestBridge(t *testing.T) {
+	node := modbus.NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	_ = node.WriteHoldings(0, []uint16{7, 8})
+	client := &fakeClient{published: make(map[string][]byte)}
+	errs := make(chan error, 10)
+	bridge := NewBridge(client, WithPrefix("site/"), WithCodec(RawCodec{}),
+		WithErrorHandler(func(err error) { errs <- err }))
+	poller := mb.NewClient(modbus.NewLoopbackProvider(node), mb.WitchHandler(bridge))
+	if err := bridge.Start(poller); err != nil {
+		t.Fatal(err)
+	}
+	if err := poller.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+	if client.filter != "site/+/+/+/set" {
+		t.Errorf("Subscribe() filter = %v", client.filter)
+	}
+	_ = poller.AddGatherJob(mb.Request{SlaveID: 1, FuncCode: modbus.FuncCodeReadHoldingRegisters,
+		Address: 0, Quantity: 2, ScanRate: 5 * time.Millisecond})
+
+	client.handler("site/1/holding/1/set", []byte{0x00, 0x2a})
+	client.handler("site/1/coils/3/set", []byte{1, 0, 1})
+	client.handler("site/1/input/0/set", []byte{0x00, 0x01})
+	select {
+	case err := <-errs:
+		t.Logf("input registers command error = %v", err)
+	case <-time.After(time.Second):
+		t.Errorf("write input registers, want error")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if got := client.get("site/1/holding/0"); reflect.DeepEqual(got, []byte{0, 7, 0, 0x2a}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("published = % x, want the polled and written registers", client.get("site/1/holding/0"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if coils, _ := node.ReadCoils(3, 3); coils[0] != 0x05 {
+		t.Errorf("coils = %#x, want 0x05", coils[0])
+	}
+}
@@ -0,0 +1,167 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// BulkError the bulk write aborted at the chunk,
+// the chunks before it were written, the ones after it were not.
+type BulkError struct {
+	Address     uint16 // the start address of the failed chunk
+	Quantity    uint16 // the quantity of the failed chunk
+	Written     uint16 // the quantity written before the failed chunk
+	Err         error  // the error of the failed chunk
+	RolledBack  bool   // the written chunks are restored
+	RollbackErr error  // the error of the rollback, nil if succeed or not rollback
+}
+
+// Error implements error interface.
+func (e *BulkError) Error() string {
+	s := fmt.Sprintf("modbus: bulk write aborted at address '%v' quantity '%v' after '%v' written, %v",
+		e.Address, e.Quantity, e.Written, e.Err)
+	switch {
+	case e.RollbackErr != nil:
+		s += fmt.Sprintf(", rollback failed, %v", e.RollbackErr)
+	case e.RolledBack:
+		s += ", rolled back"
+	}
+	return s
+}
+
+// Unwrap return the error of the failed chunk
+func (e *BulkError) Unwrap() error { return e.Err }
+
+// BulkOption 批量写可选项
+type BulkOption func(*bulkOptions)
+
+type bulkOptions struct {
+	chunk    uint16
+	rollback bool
+}
+
+// BulkChunkSize set the quantity of every chunk, 0 or bigger than the protocol limit use the limit,
+// some devices accept less than the limit in a request.
+func BulkChunkSize(n uint16) BulkOption {
+	return func(o *bulkOptions) {
+		o.chunk = n
+	}
+}
+
+// BulkRollback read the original content before writing every chunk,
+// and restore the written chunks in reverse order when a chunk failed,
+// the failed chunk is restored too unless it failed with a modbus exception.
+// it is not supported by broadcast.
+func BulkRollback() BulkOption {
+	return func(o *bulkOptions) {
+		o.rollback = true
+	}
+}
+
+// WriteCoilsBulk write any quantity of coils, value is the packed bits as WriteMultipleCoils,
+// it is split into the spec compliant chunks, the error is *BulkError tell which chunk failed.
+func (sf *client) WriteCoilsBulk(slaveID byte, address, quantity uint16, value []byte, opts ...BulkOption) error {
+	return sf.writeBulk(slaveID, TableCoils, address, quantity, value, opts...)
+}
+
+// WriteRegistersBulk write any quantity of holding registers, value is the big endian bytes as WriteMultipleRegisters,
+// it is split into the spec compliant chunks, the error is *BulkError tell which chunk failed.
+func (sf *client) WriteRegistersBulk(slaveID byte, address, quantity uint16, value []byte, opts ...BulkOption) error {
+	return sf.writeBulk(slaveID, TableHoldingRegisters, address, quantity, value, opts...)
+}
+
+// bulkChunk a written chunk and its original content
+type bulkChunk struct {
+	address, quantity uint16
+	original          []byte
+}
+
+// writeBulk write the multiple coils or registers in chunks
+func (sf *client) writeBulk(slaveID byte, table Table, address, quantity uint16, value []byte, opts ...BulkOption) error {
+	var o bulkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.rollback && slaveID == AddressBroadCast {
+		return fmt.Errorf("modbus: bulk write rollback is not supported by broadcast")
+	}
+	if quantity == 0 {
+		return fmt.Errorf("modbus: quantity '%v' must not be zero", quantity)
+	}
+	if int(address)+int(quantity) > 65536 {
+		return fmt.Errorf("modbus: address '%v' quantity '%v' exceed the address space", address, quantity)
+	}
+	max, size := uint16(WriteRegQuantityMax), int(quantity)*2
+	if isBitTable(table) {
+		max, size = WriteBitsQuantityMax, (int(quantity)+7)/8
+	}
+	if len(value) < size {
+		return fmt.Errorf("modbus: value size '%v' is less than quantity to bytes '%v'", len(value), size)
+	}
+	if o.chunk > 0 && o.chunk < max {
+		max = o.chunk
+	}
+
+	var written []bulkChunk
+	for done := uint16(0); done < quantity; {
+		n := quantity - done
+		if n > max {
+			n = max
+		}
+		start := address + done
+		var original []byte
+		var err error
+		if o.rollback {
+			original, err = sf.readTable(ReadSpec{slaveID, table, start, n})
+		}
+		if err == nil {
+			err = sf.writeChunk(slaveID, table, start, n, chunkValue(table, value, done, n))
+			if _, ok := AsExceptionError(err); err != nil && !ok && o.rollback {
+				// the failed chunk may be written without the response, such as timeout, restore it too
+				written = append(written, bulkChunk{start, n, original})
+			}
+		}
+		if err != nil {
+			e := &BulkError{Address: start, Quantity: n, Written: done, Err: err}
+			if o.rollback {
+				e.RollbackErr = sf.rollback(slaveID, table, written)
+				e.RolledBack = e.RollbackErr == nil
+			}
+			return e
+		}
+		if o.rollback {
+			written = append(written, bulkChunk{start, n, original})
+		}
+		done += n
+	}
+	return nil
+}
+
+// rollback restore the written chunks in reverse order
+func (sf *client) rollback(slaveID byte, table Table, written []bulkChunk) error {
+	for i := len(written) - 1; i >= 0; i-- {
+		c := written[i]
+		if err := sf.writeChunk(slaveID, table, c.address, c.quantity, c.original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChunk write a chunk not exceed the quantity limit
+func (sf *client) writeChunk(slaveID byte, table Table, address, quantity uint16, value []byte) error {
+	if isBitTable(table) {
+		return sf.WriteMultipleCoils(slaveID, address, quantity, value)
+	}
+	return sf.WriteMultipleRegisters(slaveID, address, quantity, value)
+}
+
+// chunkValue the value of the chunk at offset, the bits not start at multiple of 8 are repacked.
+func chunkValue(table Table, value []byte, offset, quantity uint16) []byte {
+	if !isBitTable(table) {
+		return value[int(offset)*2 : (int(offset)+int(quantity))*2]
+	}
+	if offset%8 == 0 {
+		return value[int(offset)/8 : (int(offset)+int(quantity)+7)/8]
+	}
+	return extractBits(value, int(offset), int(quantity))
+}
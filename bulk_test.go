@@ -0,0 +1,141 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/goburrow/serial"
+)
+
+func TestClient_WriteRegistersBulk(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 300, 0, 0, 0, 0, 0, 250))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	node, _ := mbSrv.GetNode(testslaveID1)
+
+	values := bytes.Repeat([]byte{0x12, 0x34}, 300)
+	if err = mbCli.WriteRegistersBulk(testslaveID1, 0, 250, values); err != nil {
+		t.Fatalf("WriteRegistersBulk() error = %v", err)
+	}
+	if got, _ := node.ReadHoldingsBytes(0, 250); !bytes.Equal(got, values[:500]) {
+		t.Errorf("holding = [% x], want all 0x1234", got)
+	}
+
+	tests := []struct {
+		name         string
+		opts         []BulkOption
+		wantWritten  uint16
+		wantAddress  uint16
+		wantRollback bool
+		want         uint16
+	}{
+		{"abort", []BulkOption{BulkChunkSize(100)}, 200, 200, false, 0xabcd},
+		{"rollback", []BulkOption{BulkChunkSize(100), BulkRollback()}, 200, 200, true, 0x1234},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node.WriteHoldingsBytes(0, 250, values[:500])
+			// the third chunk exceed the holding registers
+			err := mbCli.WriteRegistersBulk(testslaveID1, 0, 300, bytes.Repeat([]byte{0xab, 0xcd}, 300), tt.opts...)
+			e, ok := err.(*BulkError)
+			if !ok {
+				t.Fatalf("WriteRegistersBulk() error = %v, want *BulkError", err)
+			}
+			if e.Written != tt.wantWritten || e.Address != tt.wantAddress || e.RolledBack != tt.wantRollback {
+				t.Errorf("WriteRegistersBulk() error = %+v", e)
+			}
+			if !IsIllegalDataAddress(err) {
+				t.Errorf("WriteRegistersBulk() error = %v, want illegal data address", err)
+			}
+			if got, _ := node.ReadHoldings(150, 1); got[0] != tt.want {
+				t.Errorf("holding[150] = %#x, want %#x", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_WriteRegistersBulkRollbackTimeout(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 0, 0, 0, 0, 0, 0, 300))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	// the write of the third chunk is done but its response is lost, the rollback of it succeed
+	lost := false
+	mbCli.Use(func(next Doer) Doer {
+		return DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+			response, err := next.Do(slaveID, request)
+			if !lost && request.FuncCode == FuncCodeWriteMultipleRegisters && binary.BigEndian.Uint16(request.Data) == 200 {
+				lost = true
+				return ProtocolDataUnit{}, serial.ErrTimeout
+			}
+			return response, err
+		})
+	})
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	node, _ := mbSrv.GetNode(testslaveID1)
+	node.WriteHoldingsBytes(0, 300, bytes.Repeat([]byte{0x12, 0x34}, 300))
+
+	err = mbCli.WriteRegistersBulk(testslaveID1, 0, 300, bytes.Repeat([]byte{0xab, 0xcd}, 300),
+		BulkChunkSize(100), BulkRollback())
+	e, ok := err.(*BulkError)
+	if !ok || e.Address != 200 || e.Err != serial.ErrTimeout || !e.RolledBack {
+		t.Fatalf("WriteRegistersBulk() error = %v, want rolled back timeout at 200", err)
+	}
+	for _, address := range []uint16{0, 150, 250} {
+		if got, _ := node.ReadHoldings(address, 1); got[0] != 0x1234 {
+			t.Errorf("holding[%v] = %#x, want %#x", address, got[0], 0x1234)
+		}
+	}
+}
+
+func TestClient_WriteCoilsBulk(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 3000, 0, 0, 0, 0, 0, 0))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	value := make([]byte, 375)
+	for i := range value {
+		value[i] = byte(i*13 + 1)
+	}
+	// chunk not multiple of 8 is repacked
+	if err = mbCli.WriteCoilsBulk(testslaveID1, 0, 3000, value, BulkChunkSize(1001)); err != nil {
+		t.Fatalf("WriteCoilsBulk() error = %v", err)
+	}
+	got, err := mbCli.ReadCoils(testslaveID1, 992, 1000)
+	if err != nil || !bytes.Equal(got, value[124:249]) {
+		t.Errorf("ReadCoils() = [% x], %v", got, err)
+	}
+}
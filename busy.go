@@ -0,0 +1,64 @@
+package modbus
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultBusyDelay 从机忙时默认的重试间隔
+const DefaultBusyDelay = 100 * time.Millisecond
+
+// AckHandler 从机回复确认异常(0x05)时调用, 确认表示从机已接受请求但需要长时间处理,
+// 处理函数可按规范轮询程序完成(Poll Program Complete)等方式等待处理完成, 返回最终的响应,
+// doer 为不经过中间件的发送, 返回错误时请求失败.
+type AckHandler func(doer Doer, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+
+// busyPolicy 从机忙(0x06)和确认(0x05)异常的处理
+type busyPolicy struct {
+	n       int     // 从机忙的最多重试次数
+	backoff Backoff // 从机忙的重试间隔
+	onAck   AckHandler
+}
+
+// WithBusyRetry 从机回复忙异常(0x06)时最多重试n次, backoff 为每次重试前的等待, nil 使用 DefaultBusyDelay,
+// 重试用尽后返回最后的忙异常, n<=0 不重试. 与 WithRetry 一样在中间件内部, 中间件只看到一次事务.
+func WithBusyRetry(n int, backoff Backoff) ClientOption {
+	return func(c *client) {
+		if c.busy == nil {
+			c.busy = &busyPolicy{}
+		}
+		if backoff == nil {
+			backoff = ConstantBackoff(DefaultBusyDelay)
+		}
+		c.busy.n, c.busy.backoff = n, backoff
+	}
+}
+
+// WithAcknowledge 从机回复确认异常(0x05)时调用 h 等待处理完成, nil 时确认异常作为错误返回
+func WithAcknowledge(h AckHandler) ClientOption {
+	return func(c *client) {
+		if c.busy == nil {
+			c.busy = &busyPolicy{}
+		}
+		c.busy.onAck = h
+	}
+}
+
+// wrap the doer with the busy retry and acknowledge handler
+func (sf busyPolicy) wrap(next Doer) Doer {
+	return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		response, err := DoContext(ctx, next, slaveID, request)
+		for attempt := 1; attempt <= sf.n && ExceptionOf(err) == ExceptionServerDeviceBusy; attempt++ {
+			if e := sleepContext(ctx, sf.backoff(attempt)); e != nil {
+				return response, err
+			}
+			response, err = DoContext(ctx, next, slaveID, request)
+		}
+		if sf.onAck != nil && ExceptionOf(err) == ExceptionAcknowledge {
+			return sf.onAck(DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+				return DoContext(ctx, next, slaveID, request)
+			}), slaveID, request)
+		}
+		return response, err
+	})
+}
@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithBusyRetry(t *testing.T) {
+	busy := &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceBusy}
+	failure := &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceFailure}
+	tests := []struct {
+		name      string
+		err       error
+		fails     int
+		n         int
+		wantCalls int
+		wantErr   Exception
+	}{
+		{"not busy", busy, 0, 3, 1, 0},
+		{"recovered", busy, 2, 3, 3, 0},
+		{"exhausted", busy, 5, 3, 4, ExceptionServerDeviceBusy},
+		{"other exception not retried", failure, 5, 3, 1, ExceptionServerDeviceFailure},
+		{"retry disabled", busy, 1, 0, 1, ExceptionServerDeviceBusy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &flakyProvider{provider: provider{err: tt.err}, fails: tt.fails}
+			c := NewClient(p, WithBusyRetry(tt.n, ConstantBackoff(time.Millisecond)))
+			err := c.WriteSingleRegister(1, 1, 2)
+			if ExceptionOf(err) != tt.wantErr {
+				t.Errorf("WriteSingleRegister() error = %v, want %v", err, tt.wantErr)
+			}
+			if p.calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", p.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithAcknowledge(t *testing.T) {
+	ack := &ExceptionError{ExceptionCode: ExceptionCodeAcknowledge}
+
+	c := NewClient(&flakyProvider{provider: provider{err: ack}, fails: 1})
+	if err := c.WriteSingleRegister(1, 1, 2); ExceptionOf(err) != ExceptionAcknowledge {
+		t.Errorf("WriteSingleRegister() without handler error = %v, want acknowledge", err)
+	}
+
+	// poll until the slave complete the program, resending the request here
+	p := &flakyProvider{provider: provider{err: ack}, fails: 3}
+	polls := 0
+	c = NewClient(p, WithAcknowledge(func(doer Doer, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		for {
+			polls++
+			response, err := doer.Do(slaveID, request)
+			if ExceptionOf(err) != ExceptionAcknowledge {
+				return response, err
+			}
+		}
+	}), WithBusyRetry(2, nil))
+	if err := c.WriteSingleRegister(1, 1, 2); err != nil {
+		t.Errorf("WriteSingleRegister() with handler error = %v", err)
+	}
+	if polls != 3 || p.calls != 4 {
+		t.Errorf("polls = %v, calls = %v, want 3, 4", polls, p.calls)
+	}
+}
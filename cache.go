@@ -0,0 +1,256 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cacheKey the key of the cached read, the request range of a slave table
+type cacheKey struct {
+	slaveID  byte
+	table    Table
+	address  uint16
+	quantity uint16
+}
+
+// cacheEntry the cached response, done is closed when the request is finished
+type cacheEntry struct {
+	done     chan struct{}
+	expire   time.Time
+	response ProtocolDataUnit
+	err      error
+}
+
+// cacheSweepMin the entries count which trigger the first sweep of the expired entries
+const cacheSweepMin = 64
+
+// cacheRange the ttl of the address range [start, end), the ranges never overlap
+type cacheRange struct {
+	slaveID    byte
+	table      Table
+	start, end uint32
+	ttl        time.Duration
+}
+
+// ReadCache client read cache, the reads are keyed by (slave, table, address range),
+// identical reads in the ttl are served from the cache,
+// concurrent identical reads share one transaction, so bursts of reads from
+// multiple goroutines do not each hit the slow serial bus.
+// the write through the same client invalidate the overlapping cached reads,
+// the broadcast write invalidate them of all slaves.
+// the expired reads are swept when the cached reads doubled since the last sweep.
+// use it like: client.Use(cache.Middleware())
+type ReadCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	ranges  []cacheRange
+	entries map[cacheKey]*cacheEntry
+	sweepAt int // the entries count which trigger the next sweep
+}
+
+// NewReadCache new read cache with the default ttl, ttl <= 0 only share the concurrent reads
+func NewReadCache(ttl time.Duration) *ReadCache {
+	return &ReadCache{
+		ttl:     ttl,
+		entries: make(map[cacheKey]*cacheEntry),
+		sweepAt: cacheSweepMin,
+	}
+}
+
+// SetTTL set the ttl of the address range of the slave table, ttl <= 0 disable cache of the range.
+// it replaces the overlapping part of the ranges set before, and merges with the overlapping
+// or adjacent ones of the same ttl. the read covering several ranges use the shortest ttl of them,
+// the part outside all ranges use the default ttl.
+func (sf *ReadCache) SetTTL(slaveID byte, table Table, address, quantity uint16, ttl time.Duration) {
+	start, end := uint32(address), uint32(address)+uint32(quantity)
+	sf.mu.Lock()
+	ranges := make([]cacheRange, 0, len(sf.ranges)+2)
+	for _, r := range sf.ranges {
+		if r.slaveID != slaveID || r.table != table || r.end < start || end < r.start {
+			ranges = append(ranges, r)
+			continue
+		}
+		if r.ttl == ttl { // merge
+			if r.start < start {
+				start = r.start
+			}
+			if r.end > end {
+				end = r.end
+			}
+			continue
+		}
+		if r.end == start || end == r.start { // adjacent
+			ranges = append(ranges, r)
+			continue
+		}
+		if r.start < start {
+			ranges = append(ranges, cacheRange{slaveID, table, r.start, start, r.ttl})
+		}
+		if end < r.end {
+			ranges = append(ranges, cacheRange{slaveID, table, end, r.end, r.ttl})
+		}
+	}
+	if start < end {
+		ranges = append(ranges, cacheRange{slaveID, table, start, end, ttl})
+	}
+	sf.ranges = ranges
+	sf.mu.Unlock()
+}
+
+// Invalidate drop the cached reads overlapping the address range of the slave table
+func (sf *ReadCache) Invalidate(slaveID byte, table Table, address, quantity uint16) {
+	sf.mu.Lock()
+	sf.invalidate(slaveID, table, address, quantity)
+	sf.mu.Unlock()
+}
+
+// Purge drop all the cached reads
+func (sf *ReadCache) Purge() {
+	sf.mu.Lock()
+	sf.entries = make(map[cacheKey]*cacheEntry)
+	sf.sweepAt = cacheSweepMin
+	sf.mu.Unlock()
+}
+
+// Middleware return the client middleware of the cache
+func (sf *ReadCache) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+			address, quantity := pduAddressQuantity(request)
+			switch request.FuncCode {
+			case FuncCodeReadCoils, FuncCodeReadDiscreteInputs,
+				FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters:
+				return sf.read(ctx, next, slaveID, request, cacheKey{slaveID, readTable(request.FuncCode), address, quantity})
+			case FuncCodeWriteSingleCoil, FuncCodeWriteMultipleCoils:
+				defer sf.Invalidate(slaveID, TableCoils, address, quantity)
+			case FuncCodeWriteSingleRegister, FuncCodeWriteMultipleRegisters, FuncCodeMaskWriteRegister:
+				defer sf.Invalidate(slaveID, TableHoldingRegisters, address, quantity)
+			case FuncCodeReadWriteMultipleRegisters:
+				if len(request.Data) >= 8 {
+					defer sf.Invalidate(slaveID, TableHoldingRegisters, uint16(request.Data[4])<<8|uint16(request.Data[5]),
+						uint16(request.Data[6])<<8|uint16(request.Data[7]))
+				}
+			}
+			return DoContext(ctx, next, slaveID, request)
+		})
+	}
+}
+
+// read serve the read from the cache, or do it and cache the response
+func (sf *ReadCache) read(ctx context.Context, next Doer, slaveID byte, request ProtocolDataUnit, key cacheKey) (ProtocolDataUnit, error) {
+	sf.mu.Lock()
+	ttl := sf.rangeTTL(key)
+	if e, ok := sf.entries[key]; ok {
+		if !isClosed(e.done) { // in flight, share it
+			sf.mu.Unlock()
+			<-e.done
+			return copyPdu(e.response), e.err
+		}
+		if time.Now().Before(e.expire) {
+			sf.mu.Unlock()
+			return copyPdu(e.response), nil
+		}
+	}
+	e := &cacheEntry{done: make(chan struct{})}
+	sf.entries[key] = e
+	if len(sf.entries) >= sf.sweepAt {
+		sf.sweep()
+	}
+	sf.mu.Unlock()
+
+	response, err := DoContext(ctx, next, slaveID, request)
+	sf.mu.Lock()
+	e.response, e.err = copyPdu(response), err
+	e.expire = time.Now().Add(ttl)
+	if (err != nil || ttl <= 0) && sf.entries[key] == e {
+		delete(sf.entries, key)
+	}
+	close(e.done)
+	sf.mu.Unlock()
+	return response, err
+}
+
+// rangeTTL got the shortest ttl of the ranges overlapping the key,
+// the default ttl if the key is not covered by them, caller must hold the mutex
+func (sf *ReadCache) rangeTTL(key cacheKey) time.Duration {
+	start, end := uint32(key.address), uint32(key.address)+uint32(key.quantity)
+	ttl, covered := time.Duration(0), uint32(0)
+	for _, r := range sf.ranges {
+		if r.slaveID != key.slaveID || r.table != key.table || r.end <= start || end <= r.start {
+			continue
+		}
+		if covered == 0 || r.ttl < ttl {
+			ttl = r.ttl
+		}
+		lo, hi := r.start, r.end
+		if lo < start {
+			lo = start
+		}
+		if hi > end {
+			hi = end
+		}
+		covered += hi - lo
+	}
+	if covered < end-start && (covered == 0 || sf.ttl < ttl) {
+		ttl = sf.ttl
+	}
+	return ttl
+}
+
+// sweep drop the expired reads, caller must hold the mutex
+func (sf *ReadCache) sweep() {
+	now := time.Now()
+	for k, e := range sf.entries {
+		if isClosed(e.done) && !now.Before(e.expire) {
+			delete(sf.entries, k)
+		}
+	}
+	sf.sweepAt = 2 * len(sf.entries)
+	if sf.sweepAt < cacheSweepMin {
+		sf.sweepAt = cacheSweepMin
+	}
+}
+
+// invalidate drop the cached reads overlapping the range, the broadcast drop them of all slaves,
+// caller must hold the mutex
+func (sf *ReadCache) invalidate(slaveID byte, table Table, address, quantity uint16) {
+	for k := range sf.entries {
+		if (k.slaveID == slaveID || slaveID == AddressBroadCast) && k.table == table &&
+			uint32(k.address) < uint32(address)+uint32(quantity) &&
+			uint32(address) < uint32(k.address)+uint32(k.quantity) {
+			delete(sf.entries, k)
+		}
+	}
+}
+
+// readTable got the table of the read function code
+func readTable(funcCode byte) Table {
+	switch funcCode {
+	case FuncCodeReadCoils:
+		return TableCoils
+	case FuncCodeReadDiscreteInputs:
+		return TableDiscreteInputs
+	case FuncCodeReadInputRegisters:
+		return TableInputRegisters
+	}
+	return TableHoldingRegisters
+}
+
+// copyPdu copy the pdu, so the cached one is not modified by the caller
+func copyPdu(pdu ProtocolDataUnit) ProtocolDataUnit {
+	if pdu.Data != nil {
+		pdu.Data = append([]byte(nil), pdu.Data...)
+	}
+	return pdu
+}
+
+// isClosed whether the channel is closed
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
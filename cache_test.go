@@ -0,0 +1,130 @@
+package modbus
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadCache(t *testing.T) {
+	var calls int32
+	d := DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return ProtocolDataUnit{FuncCode: request.FuncCode, Data: []byte{0x02, 0x12, 0x34}}, nil
+	})
+	cache := NewReadCache(time.Hour)
+	cache.SetTTL(1, TableHoldingRegisters, 100, 10, 0)
+	doer := cache.Middleware()(d)
+
+	read := func(slaveID byte, funcCode byte, address uint16) {
+		doer.Do(slaveID, ProtocolDataUnit{funcCode, []byte{byte(address >> 8), byte(address), 0x00, 0x01}})
+	}
+	tests := []struct {
+		name      string
+		do        func()
+		wantCalls int32
+	}{
+		{"concurrent identical reads share one", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					read(1, FuncCodeReadHoldingRegisters, 1)
+					wg.Done()
+				}()
+			}
+			wg.Wait()
+		}, 1},
+		{"cached", func() { read(1, FuncCodeReadHoldingRegisters, 1) }, 0},
+		{"other slave", func() { read(2, FuncCodeReadHoldingRegisters, 1) }, 1},
+		{"other table", func() { read(1, FuncCodeReadInputRegisters, 1) }, 1},
+		{"range ttl disabled", func() {
+			read(1, FuncCodeReadHoldingRegisters, 100)
+			read(1, FuncCodeReadHoldingRegisters, 100)
+		}, 2},
+		{"write invalidate", func() {
+			doer.Do(1, ProtocolDataUnit{FuncCodeWriteSingleRegister, []byte{0x00, 0x01, 0x00, 0x02}})
+			read(1, FuncCodeReadHoldingRegisters, 1)
+		}, 2},
+		{"broadcast write invalidate all slaves", func() {
+			doer.Do(AddressBroadCast, ProtocolDataUnit{FuncCodeWriteSingleRegister, []byte{0x00, 0x01, 0x00, 0x02}})
+			read(1, FuncCodeReadHoldingRegisters, 1)
+			read(2, FuncCodeReadHoldingRegisters, 1)
+		}, 3},
+		{"purge", func() {
+			cache.Purge()
+			read(1, FuncCodeReadHoldingRegisters, 1)
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			tt.do()
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestReadCache_SetTTL(t *testing.T) {
+	cache := NewReadCache(time.Hour)
+	cache.SetTTL(1, TableHoldingRegisters, 0, 100, time.Minute)
+	cache.SetTTL(1, TableHoldingRegisters, 40, 20, time.Second) // split
+	cache.SetTTL(1, TableHoldingRegisters, 90, 20, 0)           // trim
+	cache.SetTTL(1, TableHoldingRegisters, 200, 10, time.Minute)
+	cache.SetTTL(1, TableHoldingRegisters, 210, 10, time.Minute) // merge adjacent
+	cache.SetTTL(1, TableHoldingRegisters, 205, 10, time.Minute) // merge overlapping
+	cache.SetTTL(2, TableHoldingRegisters, 0, 10, time.Second)
+
+	if len(cache.ranges) != 6 {
+		t.Errorf("ranges = %+v, want 6 ranges", cache.ranges)
+	}
+	tests := []struct {
+		name     string
+		slaveID  byte
+		address  uint16
+		quantity uint16
+		want     time.Duration
+	}{
+		{"head of the split", 1, 0, 40, time.Minute},
+		{"replaced", 1, 45, 10, time.Second},
+		{"tail of the split", 1, 60, 30, time.Minute},
+		{"trimmed", 1, 95, 10, 0},
+		{"across the ranges", 1, 30, 40, time.Second},
+		{"merged", 1, 200, 20, time.Minute},
+		{"partly default", 1, 215, 10, time.Minute},
+		{"default", 1, 300, 10, time.Hour},
+		{"other slave", 2, 0, 10, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := cacheKey{tt.slaveID, TableHoldingRegisters, tt.address, tt.quantity}
+			if got := cache.rangeTTL(key); got != tt.want {
+				t.Errorf("rangeTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCache_sweep(t *testing.T) {
+	d := DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		return ProtocolDataUnit{FuncCode: request.FuncCode, Data: []byte{0x02, 0x12, 0x34}}, nil
+	})
+	cache := NewReadCache(time.Millisecond)
+	doer := cache.Middleware()(d)
+	for i := 0; i < 1000; i++ {
+		doer.Do(1, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{byte(i >> 8), byte(i), 0x00, 0x01}})
+		if i%100 == 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	cache.mu.Lock()
+	n := len(cache.entries)
+	cache.mu.Unlock()
+	if n >= 500 {
+		t.Errorf("entries = %v, want the expired ones swept", n)
+	}
+}
@@ -0,0 +1,100 @@
+package modbus
+
+// 本文件提供了节点寄存器的写入订阅, 远端主站和本地代码的写入都会通知订阅者,
+// 应用无需轮询自己的节点寄存器即可响应写入
+
+import (
+	"sync"
+)
+
+// ChangeEvent 寄存器写入事件, 地址范围为写入范围与订阅范围的交集
+type ChangeEvent struct {
+	SlaveID  byte
+	Table    Table
+	Address  uint16
+	Quantity uint16
+}
+
+// changeWatcher 一个订阅者, 订阅 table 中 [address, end) 范围
+type changeWatcher struct {
+	ch      chan ChangeEvent
+	table   Table
+	address uint16
+	end     uint32
+}
+
+// changeRange 一次写入的范围, 原子更新中记录, 更新成功后通知
+type changeRange struct {
+	table    Table
+	address  uint16
+	quantity uint16
+}
+
+// Subscribe 订阅表 table 中 [address, address+quantity) 范围的写入, 仅成功的写入产生事件,
+// 写入时持有对应表的锁, 同一个表的事件顺序与写入顺序一致.
+// 通道缓冲为 size, 满时事件被丢弃, 调用返回的函数取消订阅并关闭通道
+func (sf *NodeRegister) Subscribe(table Table, address, quantity uint16, size int) (<-chan ChangeEvent, func()) {
+	w := &changeWatcher{
+		ch:      make(chan ChangeEvent, size),
+		table:   table,
+		address: address,
+		end:     uint32(address) + uint32(quantity),
+	}
+	sf.watchMu.Lock()
+	if sf.watchers == nil {
+		sf.watchers = make(map[*changeWatcher]struct{})
+	}
+	sf.watchers[w] = struct{}{}
+	sf.watchMu.Unlock()
+
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			sf.watchMu.Lock()
+			delete(sf.watchers, w)
+			sf.watchMu.Unlock()
+			close(w.ch)
+		})
+	}
+}
+
+// notify 通知订阅了该范围的订阅者, 调用者持有对应表的写锁
+func (sf *NodeRegister) notify(table Table, address, quantity uint16) {
+	sf.watchMu.Lock()
+	defer sf.watchMu.Unlock()
+	if len(sf.watchers) == 0 || quantity == 0 {
+		return
+	}
+	slaveID := sf.SlaveID()
+	end := uint32(address) + uint32(quantity)
+	for w := range sf.watchers {
+		if w.table != table {
+			continue
+		}
+		start, stop := address, end
+		if w.address > start {
+			start = w.address
+		}
+		if w.end < stop {
+			stop = w.end
+		}
+		if uint32(start) >= stop {
+			continue
+		}
+		select {
+		case w.ch <- ChangeEvent{slaveID, table, start, uint16(stop - uint32(start))}:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅从站 slaveID 表 table 中 [address, address+quantity) 范围的写入,
+// 远端主站和本地代码的写入都会产生事件, 见 NodeRegister.Subscribe
+func (sf *serverCommon) Subscribe(slaveID byte, table Table, address, quantity uint16, size int) (<-chan ChangeEvent, func(), error) {
+	node, err := sf.GetNode(slaveID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, cancel := node.Subscribe(table, address, quantity, size)
+	return ch, cancel, nil
+}
@@ -0,0 +1,173 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// drainChanges collect the events already in the channel
+func drainChanges(ch <-chan ChangeEvent) []ChangeEvent {
+	var events []ChangeEvent
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestNodeRegister_Subscribe(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(node *NodeRegister) error
+		want  []ChangeEvent
+	}{
+		{
+			"holdings inside",
+			func(node *NodeRegister) error { return node.WriteHoldings(3, []uint16{1, 2}) },
+			[]ChangeEvent{{1, TableHoldingRegisters, 3, 2}},
+		},
+		{
+			"holdings clipped",
+			func(node *NodeRegister) error { return node.WriteHoldingsBytes(0, 4, make([]byte, 8)) },
+			[]ChangeEvent{{1, TableHoldingRegisters, 2, 2}},
+		},
+		{
+			"holdings outside",
+			func(node *NodeRegister) error { return node.WriteHoldings(8, []uint16{1}) },
+			nil,
+		},
+		{
+			"other table",
+			func(node *NodeRegister) error { return node.WriteInputs(2, []uint16{1}) },
+			nil,
+		},
+		{
+			"failed write",
+			func(node *NodeRegister) error { return node.WriteHoldings(15, []uint16{1, 2}) },
+			nil,
+		},
+		{
+			"mask write",
+			func(node *NodeRegister) error { return node.MaskWriteHolding(5, 0, 1) },
+			[]ChangeEvent{{1, TableHoldingRegisters, 5, 1}},
+		},
+		{
+			"write read",
+			func(node *NodeRegister) error {
+				_, err := node.WriteReadHoldingsBytes(4, 1, []byte{0, 1}, 0, 1)
+				return err
+			},
+			[]ChangeEvent{{1, TableHoldingRegisters, 4, 1}},
+		},
+		{
+			"update",
+			func(node *NodeRegister) error {
+				return node.Update(func(u *NodeUpdate) error {
+					if err := u.WriteInputs(0, []uint16{1}); err != nil {
+						return err
+					}
+					return u.WriteHoldings(2, []uint16{1, 2, 3})
+				})
+			},
+			[]ChangeEvent{{1, TableHoldingRegisters, 2, 3}},
+		},
+		{
+			"update rolled back",
+			func(node *NodeRegister) error {
+				_ = node.Update(func(u *NodeUpdate) error {
+					if err := u.WriteHoldings(2, []uint16{1}); err != nil {
+						return err
+					}
+					return errors.New("abort")
+				})
+				return nil
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+			ch, cancel := node.Subscribe(TableHoldingRegisters, 2, 4, 8)
+			defer cancel()
+			_ = tt.write(node)
+			if got := drainChanges(ch); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeRegister_SubscribeBitTable(t *testing.T) {
+	node := NewNodeRegister(1, 10, 16, 20, 16, 0, 0, 0, 0)
+	ch, cancel := node.Subscribe(TableCoils, 0, 0xffff, 8)
+	defer cancel()
+	if err := node.WriteSingleCoil(12, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SetBitTable(TableCoils, make([]bool, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.WriteDiscretes(20, 1, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	want := []ChangeEvent{{1, TableCoils, 12, 1}, {1, TableCoils, 10, 16}}
+	if got := drainChanges(ch); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestNodeRegister_SubscribeCancel(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 4)
+	ch, cancel := node.Subscribe(TableHoldingRegisters, 0, 4, 1)
+	if err := node.WriteHoldings(0, []uint16{1}); err != nil {
+		t.Fatal(err)
+	}
+	// the buffer is full, the event is discarded
+	if err := node.WriteHoldings(1, []uint16{1}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	cancel()
+	if err := node.WriteHoldings(2, []uint16{1}); err != nil {
+		t.Fatal(err)
+	}
+	var got []ChangeEvent
+	for e := range ch {
+		got = append(got, e)
+	}
+	if want := []ChangeEvent{{1, TableHoldingRegisters, 0, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	srv := NewTCPServer()
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	srv.AddNodes(node)
+	if _, _, err := srv.Subscribe(2, TableHoldingRegisters, 0, 16, 1); err != ErrSlaveNotExist {
+		t.Fatalf("Subscribe() error = %v, want %v", err, ErrSlaveNotExist)
+	}
+	ch, cancel, err := srv.Subscribe(1, TableCoils, 0, 16, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	c := NewClient(NewLoopbackProvider(node))
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err = c.WriteMultipleCoils(1, 3, 9, []byte{0xff, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	want := []ChangeEvent{{1, TableCoils, 3, 9}}
+	if got := drainChanges(ch); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
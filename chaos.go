@@ -0,0 +1,188 @@
+package modbus
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrChaosTimeout the timeout injected by ChaosProvider, it implements net.Error
+var ErrChaosTimeout error = chaosTimeoutError{}
+
+// chaosTimeoutError 注入的超时错误
+type chaosTimeoutError struct{}
+
+func (chaosTimeoutError) Error() string   { return "modbus: chaos: i/o timeout" }
+func (chaosTimeoutError) Timeout() bool   { return true }
+func (chaosTimeoutError) Temporary() bool { return true }
+
+// ChaosPolicy the policy of ChaosProvider, each probability is in [0,1],
+// they are evaluated in order timeout, short read, garbage prefix, duplicate,
+// the first hit one is applied to the transaction.
+type ChaosPolicy struct {
+	Seed          int64         // the seed make the injection reproducible
+	Timeout       float64       // the request is sent but the response is lost
+	TimeoutDelay  time.Duration // 超时前等待时间, 一般设为provider的超时时间
+	ShortRead     float64       // only the first half of the response is received
+	GarbagePrefix float64       // 1 to 4 random bytes are received ahead of the response
+	Duplicate     float64       // the response is received twice, the next transaction get the stale one
+}
+
+// chaos 故障类型
+type chaos byte
+
+const (
+	chaosNone chaos = iota
+	chaosTimeout
+	chaosShortRead
+	chaosGarbagePrefix
+	chaosDuplicate
+)
+
+// ChaosProvider ClientProvider decorator which randomly injects transport faults,
+// it is used to soak test the application against flaky links.
+// Send and SendPdu mangle the response pdu, SendRawFrame mangle the response adu.
+type ChaosProvider struct {
+	ClientProvider
+	mu     sync.Mutex
+	policy ChaosPolicy
+	rnd    *rand.Rand
+	// 重复的响应, 下一次传输收到它
+	stale      *ProtocolDataUnit
+	staleSlave byte
+	staleFrame []byte
+}
+
+// check ChaosProvider implements underlying method
+var _ ClientProvider = (*ChaosProvider)(nil)
+
+// NewChaosProvider new chaos provider wrap p
+func NewChaosProvider(p ClientProvider, policy ChaosPolicy) *ChaosProvider {
+	return &ChaosProvider{
+		ClientProvider: p,
+		policy:         policy,
+		rnd:            rand.New(rand.NewSource(policy.Seed)),
+	}
+}
+
+// SetLogLevel set log output level of the wrapped provider if it implements LogLeveler
+func (sf *ChaosProvider) SetLogLevel(level LogLevel) {
+	setLogLevel(sf.ClientProvider, level)
+}
+
+// SetPolicy replace the policy, the random source is kept
+func (sf *ChaosProvider) SetPolicy(policy ChaosPolicy) {
+	sf.mu.Lock()
+	sf.policy = policy
+	sf.mu.Unlock()
+}
+
+// decide got the chaos of the transaction
+func (sf *ChaosProvider) decide() (chaos, time.Duration) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	p := sf.policy
+	for i, prob := range []float64{p.Timeout, p.ShortRead, p.GarbagePrefix, p.Duplicate} {
+		if prob > 0 && sf.rnd.Float64() < prob {
+			return chaos(i + 1), p.TimeoutDelay
+		}
+	}
+	return chaosNone, 0
+}
+
+// mangle apply the chaos on b, the result is a new slice
+func (sf *ChaosProvider) mangle(c chaos, b []byte) []byte {
+	switch c {
+	case chaosShortRead:
+		return append([]byte(nil), b[:len(b)/2]...)
+	case chaosGarbagePrefix:
+		sf.mu.Lock()
+		garbage := make([]byte, 1+sf.rnd.Intn(4), 4+len(b))
+		sf.rnd.Read(garbage)
+		sf.mu.Unlock()
+		return append(garbage, b...)
+	}
+	return b
+}
+
+// Send request to the remote server, the response pdu may be mangled by the policy
+func (sf *ChaosProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	response, err := sf.ClientProvider.Send(slaveID, request)
+	if slaveID == AddressBroadCast {
+		return response, err
+	}
+	rspSlaveID := slaveID
+	sf.mu.Lock()
+	// the stale response is received instead, unless the link failed, then it is still pending
+	if stale := sf.stale; stale != nil && (err == nil || isSerialTimeout(err)) {
+		response, rspSlaveID, err = *stale, sf.staleSlave, nil
+		sf.stale = nil
+	}
+	sf.mu.Unlock()
+	if err != nil {
+		return response, err
+	}
+
+	c, delay := sf.decide()
+	switch c {
+	case chaosTimeout:
+		time.Sleep(delay)
+		return ProtocolDataUnit{}, ErrChaosTimeout
+	case chaosDuplicate:
+		sf.mu.Lock()
+		sf.stale = &ProtocolDataUnit{response.FuncCode, append([]byte(nil), response.Data...)}
+		sf.staleSlave = rspSlaveID
+		sf.mu.Unlock()
+	default:
+		response.Data = sf.mangle(c, response.Data)
+	}
+	// 与provider相同的校验
+	if err = verify(slaveID, rspSlaveID, request, response); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// SendPdu send pdu request to the remote server, it implements on Send
+func (sf *ChaosProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) < pduMinSize || len(pduRequest) > pduMaxSize {
+		return nil, fmt.Errorf("modbus: pdu size '%v' must not be between '%v' and '%v'",
+			len(pduRequest), pduMinSize, pduMaxSize)
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{pduRequest[0], pduRequest[1:]})
+	if err != nil {
+		return nil, err
+	}
+	pduResponse := make([]byte, 0, len(response.Data)+1)
+	pduResponse = append(pduResponse, response.FuncCode)
+	return append(pduResponse, response.Data...), nil
+}
+
+// SendRawFrame send raw frame to the remote server, the response adu may be mangled by the policy
+func (sf *ChaosProvider) SendRawFrame(aduRequest []byte) ([]byte, error) {
+	aduResponse, err := sf.ClientProvider.SendRawFrame(aduRequest)
+	sf.mu.Lock()
+	// the stale response is received instead, unless the link failed, then it is still pending
+	if stale := sf.staleFrame; stale != nil && (err == nil && aduResponse != nil || isSerialTimeout(err)) {
+		aduResponse, err = stale, nil
+		sf.staleFrame = nil
+	}
+	sf.mu.Unlock()
+	if err != nil || aduResponse == nil {
+		return aduResponse, err
+	}
+
+	c, delay := sf.decide()
+	switch c {
+	case chaosTimeout:
+		time.Sleep(delay)
+		return nil, ErrChaosTimeout
+	case chaosDuplicate:
+		sf.mu.Lock()
+		sf.staleFrame = append([]byte(nil), aduResponse...)
+		sf.mu.Unlock()
+		return aduResponse, nil
+	}
+	return sf.mangle(c, aduResponse), nil
+}
@@ -0,0 +1,171 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/goburrow/serial"
+)
+
+// counterProvider reply the holding register read with the sequence number of the transaction
+type counterProvider struct {
+	provider
+	seq byte
+}
+
+func (sf *counterProvider) Send(_ byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	if sf.err != nil {
+		return ProtocolDataUnit{}, sf.err
+	}
+	sf.seq++
+	return ProtocolDataUnit{FuncCode: request.FuncCode, Data: []byte{0x02, 0x00, sf.seq}}, nil
+}
+
+func (sf *counterProvider) SendRawFrame(aduRequest []byte) ([]byte, error) {
+	if sf.err != nil {
+		return nil, sf.err
+	}
+	sf.seq++
+	return []byte{aduRequest[0], FuncCodeReadHoldingRegisters, 0x02, 0x00, sf.seq}, nil
+}
+
+func TestChaosProvider_Send(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ChaosPolicy
+		want    uint16
+		wantErr bool
+	}{
+		{"none", ChaosPolicy{}, 1, false},
+		{"timeout", ChaosPolicy{Timeout: 1}, 0, true},
+		{"short read", ChaosPolicy{ShortRead: 1}, 0, true},
+		{"garbage prefix", ChaosPolicy{GarbagePrefix: 1}, 0, true},
+		{"duplicate", ChaosPolicy{Duplicate: 1}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mbCli := NewClient(NewChaosProvider(&counterProvider{}, tt.policy))
+			got, err := mbCli.ReadHoldingRegisters(1, 0, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadHoldingRegisters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got[0] != tt.want {
+				t.Errorf("ReadHoldingRegisters() = %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestChaosProvider_Duplicate(t *testing.T) {
+	p := NewChaosProvider(&counterProvider{}, ChaosPolicy{Duplicate: 1})
+	mbCli := NewClient(p)
+	// the first response is duplicated, the second transaction receive it again
+	for i, want := range []uint16{1, 1} {
+		got, err := mbCli.ReadHoldingRegisters(1, 0, 1)
+		if err != nil || got[0] != want {
+			t.Errorf("ReadHoldingRegisters() #%d = %v, %v, want %v", i, got, err, want)
+		}
+		p.SetPolicy(ChaosPolicy{})
+	}
+	if got, _ := mbCli.ReadHoldingRegisters(1, 0, 1); got[0] != 3 {
+		t.Errorf("ReadHoldingRegisters() = %v, want 3", got[0])
+	}
+
+	// the stale response is checked as the provider does
+	p.SetPolicy(ChaosPolicy{Duplicate: 1})
+	mbCli.ReadHoldingRegisters(1, 0, 1)
+	p.SetPolicy(ChaosPolicy{})
+	if _, err := mbCli.ReadInputRegisters(1, 0, 1); err == nil {
+		t.Errorf("ReadInputRegisters() error = nil, want function code mismatch")
+	}
+}
+
+func TestChaosProvider_DuplicateLinkError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantStale bool // the stale response is received instead of the error
+	}{
+		{"closed", ErrClosedConnection, false},
+		{"write failed", errors.New("write: broken pipe"), false},
+		{"timeout", serial.ErrTimeout, true},
+	}
+	req := []byte{0x01, FuncCodeReadHoldingRegisters, 0x00, 0x00, 0x00, 0x01}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &counterProvider{}
+			p := NewChaosProvider(inner, ChaosPolicy{Duplicate: 1})
+			mbCli := NewClient(p)
+			if _, err := mbCli.ReadHoldingRegisters(1, 0, 1); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.SendRawFrame(req); err != nil {
+				t.Fatal(err)
+			}
+			p.SetPolicy(ChaosPolicy{})
+
+			inner.err = tt.err
+			_, err := mbCli.ReadHoldingRegisters(1, 0, 1)
+			_, rawErr := p.SendRawFrame(req)
+			if (err == nil) != tt.wantStale || (rawErr == nil) != tt.wantStale {
+				t.Errorf("error = %v, raw error = %v, want stale %v", err, rawErr, tt.wantStale)
+			}
+			// the stale response is still pending after the link error
+			inner.err = nil
+			got, err := mbCli.ReadHoldingRegisters(1, 0, 1)
+			adu, rawErr := p.SendRawFrame(req)
+			wantStale := !tt.wantStale
+			if err != nil || rawErr != nil || (got[0] == 1) != wantStale || (adu[4] == 2) != wantStale {
+				t.Errorf("after the link recovered = %v, [% x], %v, %v, want stale %v", got, adu, err, rawErr, wantStale)
+			}
+		})
+	}
+}
+
+func TestChaosProvider_SendRawFrame(t *testing.T) {
+	req := []byte{0x01, FuncCodeReadHoldingRegisters, 0x00, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name   string
+		policy ChaosPolicy
+		check  func(adu []byte) bool
+	}{
+		{"none", ChaosPolicy{}, func(adu []byte) bool { return len(adu) == 5 }},
+		{"short read", ChaosPolicy{ShortRead: 1}, func(adu []byte) bool { return len(adu) == 2 }},
+		{"garbage prefix", ChaosPolicy{GarbagePrefix: 1}, func(adu []byte) bool {
+			return len(adu) > 5 && len(adu) <= 9 && bytes.HasSuffix(adu, []byte{0x01, FuncCodeReadHoldingRegisters, 0x02, 0x00, 0x01})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adu, err := NewChaosProvider(&counterProvider{}, tt.policy).SendRawFrame(req)
+			if err != nil || !tt.check(adu) {
+				t.Errorf("SendRawFrame() = [% x], %v", adu, err)
+			}
+		})
+	}
+
+	_, err := NewChaosProvider(&counterProvider{}, ChaosPolicy{Timeout: 1}).SendRawFrame(req)
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("SendRawFrame() error = %v, want timeout", err)
+	}
+}
+
+func TestChaosProvider_Seed(t *testing.T) {
+	run := func() []bool {
+		mbCli := NewClient(NewChaosProvider(&counterProvider{}, ChaosPolicy{Seed: 42, ShortRead: 0.5}))
+		var ok []bool
+		for i := 0; i < 20; i++ {
+			_, err := mbCli.ReadHoldingRegisters(1, 0, 1)
+			ok = append(ok, err == nil)
+		}
+		return ok
+	}
+	a, b := run(), run()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed got different injection at %d", i)
+		}
+	}
+}
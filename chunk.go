@@ -0,0 +1,43 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// WithAutoChunk split the reads and the multiple writes which exceed the quantity limit
+// of the protocol into several transactions and stitch the results, like the gather job does,
+// without it the oversized request returns an error as the spec.
+// the chunks are not atomic, the device may change between them.
+func WithAutoChunk() ClientOption {
+	return func(c *client) {
+		c.autoChunk = true
+	}
+}
+
+// isBitTable whether the table is coils or discrete inputs
+func isBitTable(t Table) bool {
+	return t == TableCoils || t == TableDiscreteInputs
+}
+
+// readChunked read the spec in chunks of the max quantity, the result is appended to dst[:0].
+// the max quantity of bits is multiple of 8, so the packed bits of the chunks are just concatenated.
+func (sf *client) readChunked(dst []byte, s ReadSpec) ([]byte, error) {
+	if int(s.Address)+int(s.Quantity) > 65536 {
+		return nil, fmt.Errorf("modbus: address '%v' quantity '%v' exceed the address space", s.Address, s.Quantity)
+	}
+	max := tableQuantityMax(s.Table)
+	result := dst[:0]
+	for done := uint16(0); done < s.Quantity; {
+		n := s.Quantity - done
+		if n > max {
+			n = max
+		}
+		data, err := sf.readTable(ReadSpec{s.SlaveID, s.Table, s.Address + done, n})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data...)
+		done += n
+	}
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package modbus
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestWithAutoChunk(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 5000, 0, 5000, 0, 300, 0, 300))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	p := NewTCPClientProvider(listen.Addr().String())
+	if err = p.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer p.Close()
+
+	// keep strict behavior by default
+	if _, err = NewClient(p).ReadHoldingRegisters(testslaveID1, 0, 300); err == nil {
+		t.Errorf("ReadHoldingRegisters() error = nil, want quantity error")
+	}
+
+	mbCli := NewClient(p, WithAutoChunk())
+	values := make([]byte, 600)
+	for i := range values {
+		values[i] = byte(i)
+	}
+	if err = mbCli.WriteMultipleRegisters(testslaveID1, 0, 300, values); err != nil {
+		t.Fatalf("WriteMultipleRegisters() error = %v", err)
+	}
+	got, err := mbCli.ReadHoldingRegistersBytes(testslaveID1, 0, 300)
+	if err != nil || !bytes.Equal(got, values) {
+		t.Errorf("ReadHoldingRegistersBytes() = %v, %v, want the written values", len(got), err)
+	}
+
+	coils := make([]byte, 625) // 5000 coils
+	for i := range coils {
+		coils[i] = byte(i * 7)
+	}
+	if err = mbCli.WriteMultipleCoils(testslaveID1, 0, 5000, coils); err != nil {
+		t.Fatalf("WriteMultipleCoils() error = %v", err)
+	}
+	got, err = mbCli.ReadCoils(testslaveID1, 0, 4500)
+	if err != nil || len(got) != 563 || !bytes.Equal(got[:562], coils[:562]) {
+		t.Errorf("ReadCoils() = %v, %v, want the written coils", len(got), err)
+	}
+	// the last chunk is partial, so the unused bits of the last byte are zero
+	if got[562] != coils[562]&0x0f {
+		t.Errorf("ReadCoils() last byte = %#x, want %#x", got[562], coils[562]&0x0f)
+	}
+
+	if _, err = mbCli.ReadInputRegisters(testslaveID1, 200, 200); err == nil {
+		t.Errorf("ReadInputRegisters() out of range error = nil, want exception")
+	}
+	if err = mbCli.WriteMultipleRegisters(testslaveID1, 0, 300, values[:10]); err == nil {
+		t.Errorf("WriteMultipleRegisters() short value error = nil, want error")
+	}
+}
@@ -1,8 +1,11 @@
 package modbus
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"sync"
+	"sync/atomic"
 )
 
 // check implements Client interface
@@ -11,35 +14,260 @@ var _ Client = (*client)(nil)
 // client implements Client interface
 type client struct {
 	ClientProvider
+	mu          sync.Mutex
+	middlewares []Middleware
+	doer        atomic.Value // Doer chain, nil if no middleware
+	base        Doer         // the innermost doer, nil use ClientProvider.Send
+	retry       *retryPolicy
+	busy        *busyPolicy
+	strict      bool // 严格校验请求与响应
+	autoChunk   bool // 超出数量限制时自动拆分
+	stats       *clientStats
+	async       asyncQueue      // 异步请求队列
+	ctx         context.Context // 事务的上下文, 由 WithContext 设置, nil 为 context.Background
+	root        *client         // WithContext 返回的视图所属的客户端, 共享中间件
 }
 
-// NewClient creates a new modbus client with given backend handler.
-func NewClient(p ClientProvider) Client {
-	return &client{p}
+// ClientOption 客户端可选项
+type ClientOption func(*client)
+
+// WithRetry retry the failed transaction at most n times transparently,
+// shouldRetry decide whether the error should be retried, nil use ShouldRetry,
+// backoff is the wait before every retry, nil retry immediately.
+// the retry is inside the middlewares, so they see a transaction once.
+func WithRetry(n int, shouldRetry func(error) bool, backoff Backoff) ClientOption {
+	return func(c *client) {
+		if n <= 0 {
+			c.retry = nil
+			return
+		}
+		if shouldRetry == nil {
+			shouldRetry = ShouldRetry
+		}
+		c.retry = &retryPolicy{n, shouldRetry, backoff}
+	}
+}
+
+// NewClient creates a new modbus client with given backend handler,
+// the client is safe for concurrent use, see Client.
+func NewClient(p ClientProvider, opts ...ClientOption) Client {
+	c := &client{ClientProvider: p, stats: new(clientStats)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.base = c.statsDoer(providerDoer(p))
+	if c.busy != nil {
+		c.base = c.busy.wrap(c.base)
+	}
+	if c.retry != nil {
+		c.base = c.retry.wrap(c.base)
+	}
+	return c
+}
+
+// contextConnector the provider which support ConnectContext
+type contextConnector interface {
+	ConnectContext(ctx context.Context) error
+}
+
+// ConnectContext connect the provider like Connect, return when the context is done,
+// if the provider not support context, the connection established after that is closed.
+func (sf *client) ConnectContext(ctx context.Context) error {
+	if p, ok := sf.ClientProvider.(contextConnector); ok {
+		return p.ConnectContext(ctx)
+	}
+	if ctx.Done() == nil {
+		return sf.Connect()
+	}
+	ch := make(chan error, 1)
+	go func() { ch <- sf.Connect() }()
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		go func() {
+			if err := <-ch; err == nil {
+				sf.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// contextSender the provider which bound the transaction by the context
+type contextSender interface {
+	SendContext(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+}
+
+// providerDoer the doer send request by the provider with the context
+func providerDoer(p ClientProvider) Doer {
+	return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		if err := ctx.Err(); err != nil {
+			return ProtocolDataUnit{}, err
+		}
+		if cp, ok := p.(contextSender); ok {
+			return cp.SendContext(ctx, slaveID, request)
+		}
+		return p.Send(slaveID, request)
+	})
+}
+
+// WithContext return a view of the client whose transactions carry ctx,
+// the middlewares see it by ContextDoer, such as the parent span of TracingMiddleware,
+// and the deadline of ctx bound the transaction instead of the provider timeout.
+// the view shares the connection, middlewares and statistics with the client,
+// its Go calls are served in their own order.
+func (sf *client) WithContext(ctx context.Context) Client {
+	if ctx == nil {
+		panic("modbus: nil context")
+	}
+	owner := sf.owner()
+	return &client{
+		ClientProvider: owner.ClientProvider,
+		retry:          owner.retry,
+		busy:           owner.busy,
+		strict:         owner.strict,
+		autoChunk:      owner.autoChunk,
+		stats:          owner.stats,
+		ctx:            ctx,
+		root:           owner,
+	}
+}
+
+// owner the client which own the middlewares
+func (sf *client) owner() *client {
+	if sf.root != nil {
+		return sf.root
+	}
+	return sf
+}
+
+// context the context of the transaction
+func (sf *client) context() context.Context {
+	if sf.ctx != nil {
+		return sf.ctx
+	}
+	return context.Background()
+}
+
+// baseDoer the innermost doer
+func (sf *client) baseDoer() Doer {
+	if sf.base != nil {
+		return sf.base
+	}
+	return providerDoer(sf.ClientProvider)
+}
+
+// SetLogLevel set log output level of the provider if it implements LogLeveler
+func (sf *client) SetLogLevel(level LogLevel) {
+	setLogLevel(sf.ClientProvider, level)
+}
+
+// Use add middlewares to the client,
+// the middleware added first is the outermost one.
+func (sf *client) Use(mws ...Middleware) {
+	sf = sf.owner()
+	sf.mu.Lock()
+	sf.middlewares = append(sf.middlewares, mws...)
+	sf.doer.Store(chain(sf.baseDoer(), sf.middlewares...))
+	sf.mu.Unlock()
+}
+
+// Send request to the remote server through the middlewares
+func (sf *client) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	if !sf.strict {
+		return sf.do(slaveID, request)
+	}
+	if err := validateRequest(request); err != nil {
+		return ProtocolDataUnit{}, err
+	}
+	response, err := sf.do(slaveID, request)
+	if err != nil || slaveID == AddressBroadCast && len(response.Data) == 0 {
+		return response, err
+	}
+	return response, validateResponse(request, response)
+}
+
+// do send request through the middlewares
+func (sf *client) do(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	owner := sf.owner()
+	if d, ok := owner.doer.Load().(Doer); ok {
+		return DoContext(sf.context(), d, slaveID, request)
+	}
+	return DoContext(sf.context(), owner.baseDoer(), slaveID, request)
+}
+
+// intoSender the provider which can decode the response data into the buffer
+type intoSender interface {
+	sendInto(dst []byte, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+}
+
+// sendInto send request and decode the response data into dst,
+// without middleware and retry the provider decode it directly without allocation.
+func (sf *client) sendInto(dst []byte, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	if p, ok := sf.ClientProvider.(intoSender); ok && dst != nil && sf.ctx == nil && sf.retry == nil && sf.busy == nil && !sf.strict && sf.doer.Load() == nil {
+		response, err := p.sendInto(dst, slaveID, request)
+		if sf.stats != nil {
+			sf.stats.record(err)
+		}
+		return response, err
+	}
+	response, err := sf.Send(slaveID, request)
+	if err == nil && dst != nil {
+		response.Data = append(dst[:0], response.Data...)
+	}
+	return response, err
+}
+
+// SendPdu send pdu request to the remote server through the middlewares
+func (sf *client) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) < pduMinSize || len(pduRequest) > pduMaxSize {
+		return nil, fmt.Errorf("modbus: pdu size '%v' must not be between '%v' and '%v'",
+			len(pduRequest), pduMinSize, pduMaxSize)
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{pduRequest[0], pduRequest[1:]})
+	if err != nil {
+		return nil, err
+	}
+	pduResponse := make([]byte, 0, len(response.Data)+1)
+	pduResponse = append(pduResponse, response.FuncCode)
+	return append(pduResponse, response.Data...), nil
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x01)
-//  Starting address      : 2 bytes
-//  Quantity of coils     : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x01)
+//	Starting address      : 2 bytes
+//	Quantity of coils     : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x01)
-//  Byte count            : 1 byte
-//  Coil status           : N* bytes (=N or N+1)
-//  return coils status
+//
+//	Function code         : 1 byte (0x01)
+//	Byte count            : 1 byte
+//	Coil status           : N* bytes (=N or N+1)
+//	return coils status
 func (sf *client) ReadCoils(slaveID byte, address, quantity uint16) ([]byte, error) {
+	return sf.ReadCoilsInto(nil, slaveID, address, quantity)
+}
+
+// ReadCoilsInto same as ReadCoils, but the result is decoded into dst,
+// dst is grown if its capacity is not enough, reuse the result as dst to avoid allocation.
+func (sf *client) ReadCoilsInto(dst []byte, slaveID byte, address, quantity uint16) ([]byte, error) {
 	if slaveID < AddressMin || slaveID > AddressMax {
 		return nil, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
 			slaveID, AddressMin, AddressMax)
 	}
+	if quantity > ReadBitsQuantityMax && sf.autoChunk {
+		return sf.readChunked(dst, ReadSpec{slaveID, TableCoils, address, quantity})
+	}
 	if quantity < ReadBitsQuantityMin || quantity > ReadBitsQuantityMax {
 		return nil, fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'",
 			quantity, ReadBitsQuantityMin, ReadBitsQuantityMax)
 
 	}
 
-	response, err := sf.Send(slaveID, ProtocolDataUnit{
+	response, err := sf.sendInto(dst, slaveID, ProtocolDataUnit{
 		FuncCodeReadCoils,
 		pduDataBlock(address, quantity),
 	})
@@ -54,29 +282,41 @@ func (sf *client) ReadCoils(slaveID byte, address, quantity uint16) ([]byte, err
 		return nil, fmt.Errorf("modbus: response byte size '%v' does not match quantity to bytes '%v'",
 			response.Data[0], (quantity+7)/8)
 	}
-	return response.Data[1:], nil
+	return trimByteCount(dst, response.Data), nil
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x02)
-//  Starting address      : 2 bytes
-//  Quantity of inputs    : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x02)
+//	Starting address      : 2 bytes
+//	Quantity of inputs    : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x02)
-//  Byte count            : 1 byte
-//  Input status          : N* bytes (=N or N+1)
-//  return result data
+//
+//	Function code         : 1 byte (0x02)
+//	Byte count            : 1 byte
+//	Input status          : N* bytes (=N or N+1)
+//	return result data
 func (sf *client) ReadDiscreteInputs(slaveID byte, address, quantity uint16) ([]byte, error) {
+	return sf.ReadDiscreteInputsInto(nil, slaveID, address, quantity)
+}
+
+// ReadDiscreteInputsInto same as ReadDiscreteInputs, but the result is decoded into dst,
+// dst is grown if its capacity is not enough, reuse the result as dst to avoid allocation.
+func (sf *client) ReadDiscreteInputsInto(dst []byte, slaveID byte, address, quantity uint16) ([]byte, error) {
 	if slaveID < AddressMin || slaveID > AddressMax {
 		return nil, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
 			slaveID, AddressMin, AddressMax)
 	}
+	if quantity > ReadBitsQuantityMax && sf.autoChunk {
+		return sf.readChunked(dst, ReadSpec{slaveID, TableDiscreteInputs, address, quantity})
+	}
 	if quantity < ReadBitsQuantityMin || quantity > ReadBitsQuantityMax {
 		return nil, fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'",
 			quantity, ReadBitsQuantityMin, ReadBitsQuantityMax)
 	}
-	response, err := sf.Send(slaveID, ProtocolDataUnit{
+	response, err := sf.sendInto(dst, slaveID, ProtocolDataUnit{
 		FuncCode: FuncCodeReadDiscreteInputs,
 		Data:     pduDataBlock(address, quantity),
 	})
@@ -91,28 +331,40 @@ func (sf *client) ReadDiscreteInputs(slaveID byte, address, quantity uint16) ([]
 		return nil, fmt.Errorf("modbus: response byte size '%v' does not match quantity to bytes '%v'",
 			response.Data[0], (quantity+7)/8)
 	}
-	return response.Data[1:], nil
+	return trimByteCount(dst, response.Data), nil
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x03)
-//  Starting address      : 2 bytes
-//  Quantity of registers : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x03)
+//	Starting address      : 2 bytes
+//	Quantity of registers : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x03)
-//  Byte count            : 1 byte
-//  Register value        : Nx2 bytes
+//
+//	Function code         : 1 byte (0x03)
+//	Byte count            : 1 byte
+//	Register value        : Nx2 bytes
 func (sf *client) ReadHoldingRegistersBytes(slaveID byte, address, quantity uint16) ([]byte, error) {
+	return sf.ReadHoldingRegistersInto(nil, slaveID, address, quantity)
+}
+
+// ReadHoldingRegistersInto same as ReadHoldingRegistersBytes, but the result is decoded into dst,
+// dst is grown if its capacity is not enough, reuse the result as dst to avoid allocation.
+func (sf *client) ReadHoldingRegistersInto(dst []byte, slaveID byte, address, quantity uint16) ([]byte, error) {
 	if slaveID < AddressMin || slaveID > AddressMax {
 		return nil, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
 			slaveID, AddressMin, AddressMax)
 	}
+	if quantity > ReadRegQuantityMax && sf.autoChunk {
+		return sf.readChunked(dst, ReadSpec{slaveID, TableHoldingRegisters, address, quantity})
+	}
 	if quantity < ReadRegQuantityMin || quantity > ReadRegQuantityMax {
 		return nil, fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'",
 			quantity, ReadRegQuantityMin, ReadRegQuantityMax)
 	}
-	response, err := sf.Send(slaveID, ProtocolDataUnit{
+	response, err := sf.sendInto(dst, slaveID, ProtocolDataUnit{
 		FuncCode: FuncCodeReadHoldingRegisters,
 		Data:     pduDataBlock(address, quantity),
 	})
@@ -127,18 +379,21 @@ func (sf *client) ReadHoldingRegistersBytes(slaveID byte, address, quantity uint
 		return nil, fmt.Errorf("modbus: response data size '%v' does not match quantity to bytes '%v'",
 			response.Data[0], quantity*2)
 	}
-	return response.Data[1:], nil
+	return trimByteCount(dst, response.Data), nil
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x03)
-//  Starting address      : 2 bytes
-//  Quantity of registers : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x03)
+//	Starting address      : 2 bytes
+//	Quantity of registers : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x03)
-//  Byte count            : 1 byte
-//  Register value        : N 2-bytes
+//
+//	Function code         : 1 byte (0x03)
+//	Byte count            : 1 byte
+//	Register value        : N 2-bytes
 func (sf *client) ReadHoldingRegisters(slaveID byte, address, quantity uint16) ([]uint16, error) {
 	b, err := sf.ReadHoldingRegistersBytes(slaveID, address, quantity)
 	if err != nil {
@@ -148,25 +403,37 @@ func (sf *client) ReadHoldingRegisters(slaveID byte, address, quantity uint16) (
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x04)
-//  Starting address      : 2 bytes
-//  Quantity of registers : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x04)
+//	Starting address      : 2 bytes
+//	Quantity of registers : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x04)
-//  Byte count            : 1 byte
-//  Input registers       : Nx2 bytes
+//
+//	Function code         : 1 byte (0x04)
+//	Byte count            : 1 byte
+//	Input registers       : Nx2 bytes
 func (sf *client) ReadInputRegistersBytes(slaveID byte, address, quantity uint16) ([]byte, error) {
+	return sf.ReadInputRegistersInto(nil, slaveID, address, quantity)
+}
+
+// ReadInputRegistersInto same as ReadInputRegistersBytes, but the result is decoded into dst,
+// dst is grown if its capacity is not enough, reuse the result as dst to avoid allocation.
+func (sf *client) ReadInputRegistersInto(dst []byte, slaveID byte, address, quantity uint16) ([]byte, error) {
 	if slaveID < AddressMin || slaveID > AddressMax {
 		return nil, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
 			slaveID, AddressMin, AddressMax)
 	}
+	if quantity > ReadRegQuantityMax && sf.autoChunk {
+		return sf.readChunked(dst, ReadSpec{slaveID, TableInputRegisters, address, quantity})
+	}
 	if quantity < ReadRegQuantityMin || quantity > ReadRegQuantityMax {
 		return nil, fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'",
 			quantity, ReadRegQuantityMin, ReadRegQuantityMax)
 
 	}
-	response, err := sf.Send(slaveID, ProtocolDataUnit{
+	response, err := sf.sendInto(dst, slaveID, ProtocolDataUnit{
 		FuncCode: FuncCodeReadInputRegisters,
 		Data:     pduDataBlock(address, quantity),
 	})
@@ -184,18 +451,21 @@ func (sf *client) ReadInputRegistersBytes(slaveID byte, address, quantity uint16
 		return nil, fmt.Errorf("modbus: response data size '%v' does not match quantity to bytes '%v'",
 			response.Data[0], quantity*2)
 	}
-	return response.Data[1:], nil
+	return trimByteCount(dst, response.Data), nil
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x04)
-//  Starting address      : 2 bytes
-//  Quantity of registers : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x04)
+//	Starting address      : 2 bytes
+//	Quantity of registers : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x04)
-//  Byte count            : 1 byte
-//  Input registers       : N 2-bytes
+//
+//	Function code         : 1 byte (0x04)
+//	Byte count            : 1 byte
+//	Input registers       : N 2-bytes
 func (sf *client) ReadInputRegisters(slaveID byte, address, quantity uint16) ([]uint16, error) {
 	b, err := sf.ReadInputRegistersBytes(slaveID, address, quantity)
 	if err != nil {
@@ -205,14 +475,17 @@ func (sf *client) ReadInputRegisters(slaveID byte, address, quantity uint16) ([]
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x05)
-//  Output address        : 2 bytes
-//  Output value          : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x05)
+//	Output address        : 2 bytes
+//	Output value          : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x05)
-//  Output address        : 2 bytes
-//  Output value          : 2 bytes
+//
+//	Function code         : 1 byte (0x05)
+//	Output address        : 2 bytes
+//	Output value          : 2 bytes
 func (sf *client) WriteSingleCoil(slaveID byte, address uint16, isOn bool) error {
 	if slaveID > AddressMax {
 		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
@@ -230,6 +503,8 @@ func (sf *client) WriteSingleCoil(slaveID byte, address uint16, isOn bool) error
 	switch {
 	case err != nil:
 		return err
+	case slaveID == AddressBroadCast && len(response.Data) == 0: // broadcast no response
+		return nil
 	case len(response.Data) != 4:
 		// Fixed response length
 		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'",
@@ -247,14 +522,17 @@ func (sf *client) WriteSingleCoil(slaveID byte, address uint16, isOn bool) error
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x06)
-//  Register address      : 2 bytes
-//  Register value        : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x06)
+//	Register address      : 2 bytes
+//	Register value        : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x06)
-//  Register address      : 2 bytes
-//  Register value        : 2 bytes
+//
+//	Function code         : 1 byte (0x06)
+//	Register address      : 2 bytes
+//	Register value        : 2 bytes
 func (sf *client) WriteSingleRegister(slaveID byte, address, value uint16) error {
 	if slaveID > AddressMax {
 		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
@@ -268,6 +546,8 @@ func (sf *client) WriteSingleRegister(slaveID byte, address, value uint16) error
 	switch {
 	case err != nil:
 		return err
+	case slaveID == AddressBroadCast && len(response.Data) == 0: // broadcast no response
+		return nil
 	case len(response.Data) != 4:
 		// Fixed response length
 		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'",
@@ -283,21 +563,27 @@ func (sf *client) WriteSingleRegister(slaveID byte, address, value uint16) error
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x0F)
-//  Starting address      : 2 bytes
-//  Quantity of outputs   : 2 bytes
-//  Byte count            : 1 byte
-//  Outputs value         : N* bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x0F)
+//	Starting address      : 2 bytes
+//	Quantity of outputs   : 2 bytes
+//	Byte count            : 1 byte
+//	Outputs value         : N* bytes
+//
 // Response:
-//  Function code         : 1 byte (0x0F)
-//  Starting address      : 2 bytes
-//  Quantity of outputs   : 2 bytes
+//
+//	Function code         : 1 byte (0x0F)
+//	Starting address      : 2 bytes
+//	Quantity of outputs   : 2 bytes
 func (sf *client) WriteMultipleCoils(slaveID byte, address, quantity uint16, value []byte) error {
 	if slaveID > AddressMax {
 		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
 			slaveID, AddressBroadCast, AddressMax)
 	}
+	if quantity > WriteBitsQuantityMax && sf.autoChunk {
+		return sf.writeBulk(slaveID, TableCoils, address, quantity, value)
+	}
 	if quantity < WriteBitsQuantityMin || quantity > WriteBitsQuantityMax {
 		return fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'",
 			quantity, WriteBitsQuantityMin, WriteBitsQuantityMax)
@@ -310,6 +596,8 @@ func (sf *client) WriteMultipleCoils(slaveID byte, address, quantity uint16, val
 	switch {
 	case err != nil:
 		return err
+	case slaveID == AddressBroadCast && len(response.Data) == 0: // broadcast no response
+		return nil
 	case len(response.Data) != 4:
 		// Fixed response length
 		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'",
@@ -325,21 +613,27 @@ func (sf *client) WriteMultipleCoils(slaveID byte, address, quantity uint16, val
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x10)
-//  Starting address      : 2 bytes
-//  Quantity of outputs   : 2 bytes
-//  Byte count            : 1 byte
-//  Registers value       : N* bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x10)
+//	Starting address      : 2 bytes
+//	Quantity of outputs   : 2 bytes
+//	Byte count            : 1 byte
+//	Registers value       : N* bytes
+//
 // Response:
-//  Function code         : 1 byte (0x10)
-//  Starting address      : 2 bytes
-//  Quantity of registers : 2 bytes
+//
+//	Function code         : 1 byte (0x10)
+//	Starting address      : 2 bytes
+//	Quantity of registers : 2 bytes
 func (sf *client) WriteMultipleRegisters(slaveID byte, address, quantity uint16, value []byte) error {
 	if slaveID > AddressMax {
 		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
 			slaveID, AddressBroadCast, AddressMax)
 	}
+	if quantity > WriteRegQuantityMax && sf.autoChunk {
+		return sf.writeBulk(slaveID, TableHoldingRegisters, address, quantity, value)
+	}
 	if quantity < WriteRegQuantityMin || quantity > WriteRegQuantityMax {
 		return fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'",
 			quantity, WriteRegQuantityMin, WriteRegQuantityMax)
@@ -353,6 +647,8 @@ func (sf *client) WriteMultipleRegisters(slaveID byte, address, quantity uint16,
 	switch {
 	case err != nil:
 		return err
+	case slaveID == AddressBroadCast && len(response.Data) == 0: // broadcast no response
+		return nil
 	case len(response.Data) != 4:
 		// Fixed response length
 		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'",
@@ -368,16 +664,19 @@ func (sf *client) WriteMultipleRegisters(slaveID byte, address, quantity uint16,
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x16)
-//  Reference address     : 2 bytes
-//  AND-mask              : 2 bytes
-//  OR-mask               : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x16)
+//	Reference address     : 2 bytes
+//	AND-mask              : 2 bytes
+//	OR-mask               : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x16)
-//  Reference address     : 2 bytes
-//  AND-mask              : 2 bytes
-//  OR-mask               : 2 bytes
+//
+//	Function code         : 1 byte (0x16)
+//	Reference address     : 2 bytes
+//	AND-mask              : 2 bytes
+//	OR-mask               : 2 bytes
 func (sf *client) MaskWriteRegister(slaveID byte, address, andMask, orMask uint16) error {
 	if slaveID > AddressMax {
 		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
@@ -391,6 +690,8 @@ func (sf *client) MaskWriteRegister(slaveID byte, address, andMask, orMask uint1
 	switch {
 	case err != nil:
 		return err
+	case slaveID == AddressBroadCast && len(response.Data) == 0: // broadcast no response
+		return nil
 	case len(response.Data) != 6:
 		// Fixed response length
 		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'",
@@ -409,18 +710,21 @@ func (sf *client) MaskWriteRegister(slaveID byte, address, andMask, orMask uint1
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x17)
-//  Read starting address : 2 bytes
-//  Quantity to read      : 2 bytes
-//  Write starting address: 2 bytes
-//  Quantity to write     : 2 bytes
-//  Write byte count      : 1 byte
-//  Write registers value : N* bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x17)
+//	Read starting address : 2 bytes
+//	Quantity to read      : 2 bytes
+//	Write starting address: 2 bytes
+//	Quantity to write     : 2 bytes
+//	Write byte count      : 1 byte
+//	Write registers value : N* bytes
+//
 // Response:
-//  Function code         : 1 byte (0x17)
-//  Byte count            : 1 byte
-//  Read registers value  : Nx2 bytes
+//
+//	Function code         : 1 byte (0x17)
+//	Byte count            : 1 byte
+//	Read registers value  : Nx2 bytes
 func (sf *client) ReadWriteMultipleRegistersBytes(slaveID byte, readAddress, readQuantity,
 	writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
 	if slaveID < AddressMin || slaveID > AddressMax {
@@ -451,18 +755,21 @@ func (sf *client) ReadWriteMultipleRegistersBytes(slaveID byte, readAddress, rea
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x17)
-//  Read starting address quantity: 2 bytes
-//  Quantity to read      : 2 bytes
-//  Write starting address: 2 bytes
-//  Quantity to write     : 2 bytes
-//  Write byte count      : 1 byte
-//  Write registers value : N* bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x17)
+//	Read starting address quantity: 2 bytes
+//	Quantity to read      : 2 bytes
+//	Write starting address: 2 bytes
+//	Quantity to write     : 2 bytes
+//	Write byte count      : 1 byte
+//	Write registers value : N* bytes
+//
 // Response:
-//  Function code         : 1 byte (0x17)
-//  Byte count            : 1 byte
-//  Read registers value  : N 2-bytes
+//
+//	Function code         : 1 byte (0x17)
+//	Byte count            : 1 byte
+//	Read registers value  : N 2-bytes
 func (sf *client) ReadWriteMultipleRegisters(slaveID byte, readAddress, readQuantity,
 	writeAddress, writeQuantity uint16, value []byte) ([]uint16, error) {
 	b, err := sf.ReadWriteMultipleRegistersBytes(slaveID, readAddress, readQuantity,
@@ -474,14 +781,17 @@ func (sf *client) ReadWriteMultipleRegisters(slaveID byte, readAddress, readQuan
 }
 
 // Request:
-//  Slave Id              : 1 byte
-//  Function code         : 1 byte (0x18)
-//  FIFO pointer address  : 2 bytes
+//
+//	Slave Id              : 1 byte
+//	Function code         : 1 byte (0x18)
+//	FIFO pointer address  : 2 bytes
+//
 // Response:
-//  Function code         : 1 byte (0x18)
-//  Byte count            : 2 bytes  only include follow
-//  FIFO count            : 2 bytes (<=31)
-//  FIFO value register   : Nx2 bytes
+//
+//	Function code         : 1 byte (0x18)
+//	Byte count            : 2 bytes  only include follow
+//	FIFO count            : 2 bytes (<=31)
+//	FIFO value register   : Nx2 bytes
 func (sf *client) ReadFIFOQueue(slaveID byte, address uint16) ([]byte, error) {
 	if slaveID < AddressMin || slaveID > AddressMax {
 		return nil, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
@@ -530,13 +840,22 @@ func pduDataBlockSuffix(suffix []byte, value ...uint16) []byte {
 
 // responseError response error
 func responseError(response ProtocolDataUnit) error {
-	mbError := &ExceptionError{}
+	mbError := &ExceptionError{FuncCode: response.FuncCode &^ 0x80}
 	if response.Data != nil && len(response.Data) > 0 {
 		mbError.ExceptionCode = response.Data[0]
 	}
 	return mbError
 }
 
+// trimByteCount drop the leading byte count of the response data,
+// the result keep starting at dst if it is not nil.
+func trimByteCount(dst, data []byte) []byte {
+	if dst == nil {
+		return data[1:]
+	}
+	return data[:copy(data, data[1:])]
+}
+
 // bytes2Uint16 bytes conver to uint16 for register
 func bytes2Uint16(buf []byte) []uint16 {
 	result := make([]uint16, len(buf)/2)
@@ -2,6 +2,7 @@ package modbus
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 )
@@ -18,6 +19,7 @@ func (*provider) Connect() error             { return nil }
 func (*provider) IsConnected() bool          { return true }
 func (*provider) SetAutoReconnect(byte)      {}
 func (*provider) LogMode(bool)               {}
+func (*provider) SetLogLevel(LogLevel)       {}
 func (*provider) SetLogProvider(LogProvider) {}
 func (*provider) Close() error               { return nil }
 func (r *provider) Send(_ byte, _ ProtocolDataUnit) (ProtocolDataUnit, error) {
@@ -693,3 +695,67 @@ func Benchmark_dataBlockSuffix(b *testing.B) {
 		pduDataBlockSuffix(suffix, data...)
 	}
 }
+
+func Test_client_Use(t *testing.T) {
+	var trace []string
+	mw := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+				trace = append(trace, fmt.Sprintf("%s>%d:%d:% x", name, slaveID, request.FuncCode, request.Data))
+				response, err := next.Do(slaveID, request)
+				trace = append(trace, fmt.Sprintf("%s<% x:%v", name, response.Data, err))
+				return response, err
+			})
+		}
+	}
+
+	c := NewClient(&provider{data: []byte{0x02, 0x12, 0x34}})
+	c.Use(mw("a"), mw("b"))
+	result, err := c.ReadHoldingRegistersBytes(1, 2, 1)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegistersBytes() error = %v", err)
+	}
+	if !reflect.DeepEqual(result, []byte{0x12, 0x34}) {
+		t.Errorf("ReadHoldingRegistersBytes() = % x, want % x", result, []byte{0x12, 0x34})
+	}
+	want := []string{
+		"a>1:3:00 02 00 01",
+		"b>1:3:00 02 00 01",
+		"b<02 12 34:<nil>",
+		"a<02 12 34:<nil>",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("middleware trace = %v, want %v", trace, want)
+	}
+
+	// rewrite request and response
+	c = NewClient(&provider{data: []byte{0x01}})
+	c.Use(func(next Doer) Doer {
+		return DoerFunc(func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+			if _, err := next.Do(slaveID+1, request); err != nil {
+				return ProtocolDataUnit{}, err
+			}
+			return ProtocolDataUnit{request.FuncCode, []byte{0xaa}}, nil
+		})
+	})
+	pdu, err := c.SendPdu(1, []byte{0x41, 0x01})
+	if err != nil {
+		t.Fatalf("SendPdu() error = %v", err)
+	}
+	if !reflect.DeepEqual(pdu, []byte{0x41, 0xaa}) {
+		t.Errorf("SendPdu() = % x, want % x", pdu, []byte{0x41, 0xaa})
+	}
+}
+
+func Test_client_broadcastWrite(t *testing.T) {
+	c := NewClient(&provider{})
+	if err := c.WriteSingleRegister(AddressBroadCast, 1, 2); err != nil {
+		t.Errorf("WriteSingleRegister() broadcast error = %v", err)
+	}
+	if err := c.WriteMultipleRegisters(AddressBroadCast, 1, 1, []byte{0x00, 0x02}); err != nil {
+		t.Errorf("WriteMultipleRegisters() broadcast error = %v", err)
+	}
+	if err := c.WriteSingleRegister(1, 1, 2); err == nil {
+		t.Errorf("WriteSingleRegister() want response size error")
+	}
+}
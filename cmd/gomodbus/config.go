@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// duration time.Duration which unmarshal from string like "1s" or "500ms"
+type duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (sf *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*sf = duration(d)
+	return nil
+}
+
+// regBlock 寄存器区块
+type regBlock struct {
+	Start    uint16 `json:"start"`
+	Quantity uint16 `json:"quantity"`
+}
+
+// valueConfig 初始值
+type valueConfig struct {
+	Table   string   `json:"table"`
+	Address uint16   `json:"address"`
+	Values  []uint16 `json:"values"`
+}
+
+// generatorConfig 值发生器
+type generatorConfig struct {
+	Table     string   `json:"table"`
+	Address   uint16   `json:"address"`
+	Quantity  uint16   `json:"quantity"`
+	Type      string   `json:"type"` // ramp, sine, randomwalk or sequence
+	Min       uint16   `json:"min"`
+	Max       uint16   `json:"max"`
+	Start     uint16   `json:"start"`
+	Step      uint16   `json:"step"`
+	Offset    float64  `json:"offset"`
+	Amplitude float64  `json:"amplitude"`
+	Period    duration `json:"period"`
+	Interval  duration `json:"interval"`
+	Values    []uint16 `json:"values"`
+}
+
+// nodeConfig 节点
+type nodeConfig struct {
+	SlaveID    byte              `json:"slaveId"`
+	Coils      regBlock          `json:"coils"`
+	Discrete   regBlock          `json:"discrete"`
+	Input      regBlock          `json:"input"`
+	Holding    regBlock          `json:"holding"`
+	Values     []valueConfig     `json:"values"`
+	Generators []generatorConfig `json:"generators"`
+}
+
+// serveConfig register map config file
+type serveConfig struct {
+	// Interval 值发生器更新周期
+	Interval duration     `json:"interval"`
+	Nodes    []nodeConfig `json:"nodes"`
+}
+
+// loadConfig 加载配置
+func loadConfig(r io.Reader) (*serveConfig, error) {
+	cfg := &serveConfig{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Nodes) == 0 {
+		return nil, fmt.Errorf("config has no nodes")
+	}
+	return cfg, nil
+}
+
+// parseTable 解析表名
+func parseTable(name string) (modbus.Table, error) {
+	switch strings.ToLower(name) {
+	case "coils":
+		return modbus.TableCoils, nil
+	case "discrete":
+		return modbus.TableDiscreteInputs, nil
+	case "input":
+		return modbus.TableInputRegisters, nil
+	case "holding":
+		return modbus.TableHoldingRegisters, nil
+	}
+	return 0, fmt.Errorf("unknown table %q", name)
+}
+
+// generator 创建值发生器
+func (sf generatorConfig) generator() (modbus.Generator, error) {
+	switch strings.ToLower(sf.Type) {
+	case "ramp":
+		return modbus.Ramp(sf.Min, sf.Max, time.Duration(sf.Period)), nil
+	case "sine":
+		return modbus.Sine(sf.Offset, sf.Amplitude, time.Duration(sf.Period)), nil
+	case "randomwalk":
+		return modbus.RandomWalk(sf.Start, sf.Step, sf.Min, sf.Max), nil
+	case "sequence":
+		return modbus.Sequence(time.Duration(sf.Interval), sf.Values...), nil
+	}
+	return nil, fmt.Errorf("unknown generator type %q", sf.Type)
+}
+
+// build 创建节点寄存器并绑定值发生器
+func (sf *serveConfig) build(sim *modbus.Simulator) ([]*modbus.NodeRegister, error) {
+	nodes := make([]*modbus.NodeRegister, 0, len(sf.Nodes))
+	for _, nc := range sf.Nodes {
+		node := modbus.NewNodeRegister(nc.SlaveID,
+			nc.Coils.Start, nc.Coils.Quantity,
+			nc.Discrete.Start, nc.Discrete.Quantity,
+			nc.Input.Start, nc.Input.Quantity,
+			nc.Holding.Start, nc.Holding.Quantity)
+		for _, v := range nc.Values {
+			if err := writeValues(node, v); err != nil {
+				return nil, fmt.Errorf("slave %d: %v", nc.SlaveID, err)
+			}
+		}
+		for _, gc := range nc.Generators {
+			table, err := parseTable(gc.Table)
+			if err != nil {
+				return nil, fmt.Errorf("slave %d: %v", nc.SlaveID, err)
+			}
+			g, err := gc.generator()
+			if err != nil {
+				return nil, fmt.Errorf("slave %d: %v", nc.SlaveID, err)
+			}
+			sim.Attach(node, table, gc.Address, gc.Quantity, g)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// writeValues 写入初始值, 位表中非0值为ON
+func writeValues(node *modbus.NodeRegister, v valueConfig) error {
+	table, err := parseTable(v.Table)
+	if err != nil {
+		return err
+	}
+	switch table {
+	case modbus.TableInputRegisters:
+		return node.WriteInputs(v.Address, v.Values)
+	case modbus.TableHoldingRegisters:
+		return node.WriteHoldings(v.Address, v.Values)
+	}
+	for i, val := range v.Values {
+		addr := v.Address + uint16(i)
+		if table == modbus.TableCoils {
+			err = node.WriteSingleCoil(addr, val != 0)
+		} else {
+			err = node.WriteSingleDiscrete(addr, val != 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+func Test_loadConfig(t *testing.T) {
+	f, err := os.Open("testdata/simulator.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cfg, err := loadConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(cfg.Interval) != 500*time.Millisecond {
+		t.Errorf("interval = %v, want 500ms", time.Duration(cfg.Interval))
+	}
+	sim := modbus.NewSimulator(time.Duration(cfg.Interval))
+	nodes, err := cfg.build(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].SlaveID() != 1 {
+		t.Fatalf("nodes = %v, want slave 1", nodes)
+	}
+	holding, _ := nodes[0].ReadHoldings(0, 3)
+	if want := []uint16{100, 200, 300}; !reflect.DeepEqual(holding, want) {
+		t.Errorf("holding = %v, want %v", holding, want)
+	}
+	coils, _ := nodes[0].ReadCoils(0, 8)
+	if want := []byte{0x14}; !reflect.DeepEqual(coils, want) {
+		t.Errorf("coils = % x, want % x", coils, want)
+	}
+	sim.Step(0)
+	input, _ := nodes[0].ReadInputs(0, 2)
+	if want := []uint16{1000, 1000}; !reflect.DeepEqual(input, want) {
+		t.Errorf("input = %v, want %v", input, want)
+	}
+}
+
+func Test_loadConfig_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"no nodes", `{"nodes": []}`},
+		{"unknown field", `{"nodes": [{"slaveId": 1, "foo": 1}]}`},
+		{"bad duration", `{"interval": "1x", "nodes": [{"slaveId": 1}]}`},
+		{"unknown table", `{"nodes": [{"slaveId": 1, "values": [{"table": "foo"}]}]}`},
+		{"unknown generator", `{"nodes": [{"slaveId": 1, "generators": [{"table": "input", "type": "foo"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(strings.NewReader(tt.config))
+			if err == nil {
+				_, err = cfg.build(modbus.NewSimulator(0))
+			}
+			if err == nil {
+				t.Errorf("want error")
+			}
+		})
+	}
+}
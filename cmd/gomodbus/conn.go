@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// connFlags 连接参数
+type connFlags struct {
+	tcp      string
+	serial   string
+	mode     string
+	baudRate int
+	dataBits int
+	stopBits int
+	parity   string
+	timeout  time.Duration
+	verbose  bool
+}
+
+// register 注册连接参数
+func (sf *connFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&sf.tcp, "tcp", "", "TCP endpoint `host:port`")
+	fs.StringVar(&sf.serial, "serial", "", "serial `device`, e.g. /dev/ttyUSB0")
+	fs.StringVar(&sf.mode, "mode", "rtu", "serial transmission mode, rtu or ascii")
+	fs.IntVar(&sf.baudRate, "baud", 19200, "serial baud rate")
+	fs.IntVar(&sf.dataBits, "databits", 8, "serial data bits")
+	fs.IntVar(&sf.stopBits, "stopbits", 1, "serial stop bits")
+	fs.StringVar(&sf.parity, "parity", "N", "serial parity, N, E or O")
+	fs.DurationVar(&sf.timeout, "timeout", 500*time.Millisecond, "response timeout")
+	fs.BoolVar(&sf.verbose, "v", false, "enable debug log")
+}
+
+// client 根据连接参数创建客户端
+func (sf *connFlags) client() (modbus.Client, error) {
+	var p modbus.ClientProvider
+
+	switch {
+	case sf.tcp != "" && sf.serial != "":
+		return nil, errors.New("-tcp and -serial are mutually exclusive")
+	case sf.tcp != "":
+		tp := modbus.NewTCPClientProvider(sf.tcp)
+		tp.Timeout = sf.timeout
+		p = tp
+	case sf.serial != "":
+		switch sf.mode {
+		case "rtu":
+			sp := modbus.NewRTUClientProvider()
+			sp.Address, sp.BaudRate, sp.DataBits, sp.StopBits, sp.Parity, sp.Timeout =
+				sf.serial, sf.baudRate, sf.dataBits, sf.stopBits, sf.parity, sf.timeout
+			p = sp
+		case "ascii":
+			sp := modbus.NewASCIIClientProvider()
+			sp.Address, sp.BaudRate, sp.DataBits, sp.StopBits, sp.Parity, sp.Timeout =
+				sf.serial, sf.baudRate, sf.dataBits, sf.stopBits, sf.parity, sf.timeout
+			p = sp
+		default:
+			return nil, fmt.Errorf("unknown serial mode %q", sf.mode)
+		}
+	default:
+		return nil, errors.New("one of -tcp or -serial is required")
+	}
+	client := modbus.NewClient(p)
+	client.LogMode(sf.verbose)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,55 @@
+// Command gomodbus is a modbus toolbox built on the gomodbus library.
+//
+// Usage:
+//
+//	gomodbus <command> [arguments]
+//
+// The commands are:
+//
+//	scan    probe a serial bus or TCP endpoint for responding slaves and readable ranges
+//	serve   start a TCP/RTU slave emulator from a register map config file
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command 子命令
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"scan", "probe a serial bus or TCP endpoint for responding slaves and readable ranges", runScan},
+	{"serve", "start a TCP/RTU slave emulator from a register map config file", runServe},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n\n\tgomodbus <command> [arguments]\n\nThe commands are:\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-8s%s\n", c.name, c.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"gomodbus <command> -h\" for more information about a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "gomodbus %s: %v\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "gomodbus: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// addrRange 可读的地址区间 [start, end]
+type addrRange struct {
+	start, end uint16
+}
+
+// latencyStats 响应时间统计
+type latencyStats struct {
+	count    int
+	min, max time.Duration
+	total    time.Duration
+}
+
+// add 增加一个响应时间
+func (sf *latencyStats) add(d time.Duration) {
+	if sf.count == 0 || d < sf.min {
+		sf.min = d
+	}
+	if d > sf.max {
+		sf.max = d
+	}
+	sf.count++
+	sf.total += d
+}
+
+// String min/avg/max
+func (sf latencyStats) String() string {
+	if sf.count == 0 {
+		return "-"
+	}
+	avg := sf.total / time.Duration(sf.count)
+	return fmt.Sprintf("%v/%v/%v", sf.min.Round(time.Microsecond),
+		avg.Round(time.Microsecond), sf.max.Round(time.Microsecond))
+}
+
+// scanTable 探测的表
+type scanTable struct {
+	name  string
+	probe func(c modbus.Client, slaveID byte, address uint16) error
+}
+
+var scanTables = map[string]scanTable{
+	"coils": {"coils", func(c modbus.Client, id byte, address uint16) error {
+		_, err := c.ReadCoils(id, address, 1)
+		return err
+	}},
+	"discrete": {"discrete", func(c modbus.Client, id byte, address uint16) error {
+		_, err := c.ReadDiscreteInputs(id, address, 1)
+		return err
+	}},
+	"input": {"input", func(c modbus.Client, id byte, address uint16) error {
+		_, err := c.ReadInputRegisters(id, address, 1)
+		return err
+	}},
+	"holding": {"holding", func(c modbus.Client, id byte, address uint16) error {
+		_, err := c.ReadHoldingRegisters(id, address, 1)
+		return err
+	}},
+}
+
+// slaveResult 一个从机的探测结果
+type slaveResult struct {
+	slaveID byte
+	ranges  map[string][]addrRange
+	latency latencyStats
+}
+
+// scanner 总线探测
+type scanner struct {
+	client  modbus.Client
+	tables  []scanTable
+	maxAddr int
+	step    int
+}
+
+// timed 执行探测并统计响应时间, 返回从机是否应答, 应答是否是正常响应
+func (sf *scanner) timed(res *slaveResult, probe func() error) (answered, ok bool) {
+	start := time.Now()
+	err := probe()
+	if err == nil {
+		res.latency.add(time.Since(start))
+		return true, true
+	}
+	if _, isException := err.(*modbus.ExceptionError); isException {
+		res.latency.add(time.Since(start))
+		return true, false
+	}
+	return false, false
+}
+
+// scan 探测一个从机, 从机不应答时返回nil
+func (sf *scanner) scan(slaveID byte) *slaveResult {
+	res := &slaveResult{slaveID: slaveID, ranges: make(map[string][]addrRange)}
+
+	answered := false
+	for _, t := range sf.tables {
+		t := t
+		if a, _ := sf.timed(res, func() error { return t.probe(sf.client, slaveID, 0) }); a {
+			answered = true
+			break
+		}
+	}
+	if !answered {
+		return nil
+	}
+	if sf.step <= 0 {
+		return res
+	}
+
+	for _, t := range sf.tables {
+		var readable []uint16
+		for addr := 0; addr <= sf.maxAddr; addr += sf.step {
+			address := uint16(addr)
+			if _, ok := sf.timed(res, func() error { return t.probe(sf.client, slaveID, address) }); ok {
+				readable = append(readable, address)
+			}
+		}
+		res.ranges[t.name] = mergeRanges(readable, uint16(sf.step))
+	}
+	return res
+}
+
+// mergeRanges 将连续(间隔为step)的可读地址合并为区间
+func mergeRanges(addrs []uint16, step uint16) []addrRange {
+	var ranges []addrRange
+	for _, addr := range addrs {
+		if n := len(ranges); n > 0 && int(ranges[n-1].end)+int(step) == int(addr) {
+			ranges[n-1].end = addr
+			continue
+		}
+		ranges = append(ranges, addrRange{addr, addr})
+	}
+	return ranges
+}
+
+// formatRanges 格式化区间
+func formatRanges(ranges []addrRange) string {
+	if len(ranges) == 0 {
+		return "-"
+	}
+	s := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.start == r.end {
+			s = append(s, fmt.Sprintf("%d", r.start))
+		} else {
+			s = append(s, fmt.Sprintf("%d-%d", r.start, r.end))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func runScan(args []string) error {
+	var cf connFlags
+
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	cf.register(fs)
+	first := fs.Int("first", modbus.AddressMin, "first slave id to probe")
+	last := fs.Int("last", int(modbus.AddressMax), "last slave id to probe")
+	tables := fs.String("tables", "holding,input,coils,discrete", "comma separated tables to probe")
+	maxAddr := fs.Int("max", 1000, "highest address probed for readable ranges")
+	step := fs.Int("step", 10, "address step between range probes, 0 to only detect slaves")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gomodbus scan [-tcp host:port | -serial device] [flags]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *first < modbus.AddressMin || *last > int(modbus.AddressMax) || *first > *last {
+		return fmt.Errorf("slave id range %d-%d must be within %d-%d",
+			*first, *last, modbus.AddressMin, modbus.AddressMax)
+	}
+	if *maxAddr < 0 || *maxAddr > 0xffff {
+		return fmt.Errorf("max address %d must be within 0-65535", *maxAddr)
+	}
+
+	sc := &scanner{maxAddr: *maxAddr, step: *step}
+	for _, name := range strings.Split(*tables, ",") {
+		t, ok := scanTables[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown table %q", name)
+		}
+		sc.tables = append(sc.tables, t)
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	sc.client = client
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	header := []string{"SLAVE", "LATENCY(min/avg/max)"}
+	for _, t := range sc.tables {
+		header = append(header, strings.ToUpper(t.name))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	found := 0
+	start := time.Now()
+	for id := *first; id <= *last; id++ {
+		res := sc.scan(byte(id))
+		if res == nil {
+			continue
+		}
+		found++
+		row := []string{fmt.Sprintf("%d", res.slaveID), res.latency.String()}
+		for _, t := range sc.tables {
+			row = append(row, formatRanges(res.ranges[t.name]))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+		w.Flush()
+	}
+	w.Flush()
+	fmt.Printf("\n%d slave(s) found in %v\n", found, time.Since(start).Round(time.Millisecond))
+	return nil
+}
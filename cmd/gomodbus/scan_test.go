@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_mergeRanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		addrs []uint16
+		step  uint16
+		want  []addrRange
+	}{
+		{"empty", nil, 10, nil},
+		{"single", []uint16{20}, 10, []addrRange{{20, 20}}},
+		{"contiguous", []uint16{0, 10, 20}, 10, []addrRange{{0, 20}}},
+		{"gap", []uint16{0, 10, 30, 40, 60}, 10, []addrRange{{0, 10}, {30, 40}, {60, 60}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeRanges(tt.addrs, tt.step); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeRanges() = %v, want %v", got, tt.want)
+			}
+			if got := formatRanges(tt.want); got == "" {
+				t.Errorf("formatRanges() empty")
+			}
+		})
+	}
+}
+
+func Test_latencyStats(t *testing.T) {
+	var s latencyStats
+	if s.String() != "-" {
+		t.Errorf("String() = %v, want -", s.String())
+	}
+	s.add(2 * time.Millisecond)
+	s.add(time.Millisecond)
+	s.add(3 * time.Millisecond)
+	if want := "1ms/2ms/3ms"; s.String() != want {
+		t.Errorf("String() = %v, want %v", s.String(), want)
+	}
+}
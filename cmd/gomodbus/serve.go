@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// server TCPServer 与 RTUServer 共有的方法
+type server interface {
+	AddNodes(nodes ...*modbus.NodeRegister)
+	LogMode(enable bool)
+	Close() error
+}
+
+func runServe(args []string) error {
+	var cf connFlags
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	cf.register(fs)
+	config := fs.String("config", "", "register map config `file` (json)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gomodbus serve -config file [-tcp :502 | -serial device] [flags]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *config == "" {
+		return fmt.Errorf("-config is required")
+	}
+
+	f, err := os.Open(*config)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", *config, err)
+	}
+
+	sim := modbus.NewSimulator(time.Duration(cfg.Interval))
+	sim.LogMode(cf.verbose)
+	nodes, err := cfg.build(sim)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *config, err)
+	}
+
+	var srv server
+	var serve func() error
+	switch {
+	case cf.tcp != "" && cf.serial != "":
+		return fmt.Errorf("-tcp and -serial are mutually exclusive")
+	case cf.serial != "":
+		if cf.mode != "rtu" {
+			return fmt.Errorf("serial mode %q not supported by server", cf.mode)
+		}
+		rs := modbus.NewRTUServer()
+		rs.Address, rs.BaudRate, rs.DataBits, rs.StopBits, rs.Parity =
+			cf.serial, cf.baudRate, cf.dataBits, cf.stopBits, cf.parity
+		srv, serve = rs, rs.ListenAndServe
+	default:
+		addr := cf.tcp
+		if addr == "" {
+			addr = ":502"
+		}
+		ts := modbus.NewTCPServer()
+		srv, serve = ts, func() error { return ts.ListenAndServe(addr) }
+	}
+	srv.LogMode(cf.verbose)
+	srv.AddNodes(nodes...)
+
+	sim.Start()
+	defer sim.Close()
+
+	stopped := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stopped)
+		srv.Close()
+	}()
+
+	fmt.Printf("serving %d node(s)\n", len(nodes))
+	err = serve()
+	select {
+	case <-stopped: // stopped by signal
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,100 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+)
+
+// Codec 帧编解码器, 与 I/O 分离,
+// 配合 Transport 可在其它链路(如电台, CAN 隧道)上实现 ClientProvider, 见 NewTransportProvider
+type Codec interface {
+	// Encode encode the request pdu into the adu
+	Encode(slaveID byte, pdu ProtocolDataUnit) ([]byte, error)
+	// Decode decode the response adu of the request adu, the checksum and the header are verified,
+	// but the slave id and function code are not, they are returned to the caller.
+	Decode(aduRequest, aduResponse []byte) (slaveID byte, pdu ProtocolDataUnit, err error)
+}
+
+// check implements Codec interface
+var _ Codec = RTUCodec{}
+var _ Codec = ASCIICodec{}
+var _ Codec = (*TCPCodec)(nil)
+
+// RTUCodec the RTU framing, slave id + pdu + crc
+type RTUCodec struct{}
+
+// Encode encode the request pdu into the RTU adu
+func (RTUCodec) Encode(slaveID byte, pdu ProtocolDataUnit) ([]byte, error) {
+	frame := protocolFrame{make([]byte, 0, rtuAduMaxSize)}
+	return frame.encodeRTUFrame(slaveID, pdu)
+}
+
+// Decode decode the RTU response adu and verify the crc
+func (RTUCodec) Decode(_, aduResponse []byte) (byte, ProtocolDataUnit, error) {
+	slaveID, pdu, err := decodeRTUFrame(aduResponse)
+	if err != nil {
+		return 0, ProtocolDataUnit{}, err
+	}
+	return slaveID, ProtocolDataUnit{pdu[0], pdu[1:]}, nil
+}
+
+// RTUResponseLength the length of the RTU response adu of the request adu, include the crc,
+// determined is false if it can not be calculated from the request, such as FIFO queue or user defined function,
+// the stream based transport can use it to find the end of the frame.
+func RTUResponseLength(aduRequest []byte) (length int, determined bool) {
+	if len(aduRequest) < rtuAduMinSize {
+		return rtuAduMinSize, false
+	}
+	return calculateResponseLength(aduRequest), rtuResponseDetermined(aduRequest[1])
+}
+
+// ASCIICodec the ASCII framing, ':' + hex(slave id + pdu + lrc) + CRLF
+type ASCIICodec struct{}
+
+// Encode encode the request pdu into the ASCII adu
+func (ASCIICodec) Encode(slaveID byte, pdu ProtocolDataUnit) ([]byte, error) {
+	frame := protocolFrame{make([]byte, 0, asciiCharacterMaxSize)}
+	return frame.encodeASCIIFrame(slaveID, pdu)
+}
+
+// Decode decode the ASCII response adu and verify the lrc
+func (ASCIICodec) Decode(_, aduResponse []byte) (byte, ProtocolDataUnit, error) {
+	slaveID, pdu, err := decodeASCIIFrame(aduResponse)
+	if err != nil {
+		return 0, ProtocolDataUnit{}, err
+	}
+	return slaveID, ProtocolDataUnit{pdu[0], pdu[1:]}, nil
+}
+
+// TCPCodec the TCP framing, MBAP header + pdu,
+// the transaction id is increased on every Encode, the zero value is ready to use.
+type TCPCodec struct {
+	transactionID uint32
+}
+
+// Encode encode the request pdu into the TCP adu with the next transaction id
+func (sf *TCPCodec) Encode(slaveID byte, pdu ProtocolDataUnit) ([]byte, error) {
+	frame := protocolFrame{make([]byte, 0, tcpAduMaxSize)}
+	tid := uint16(atomic.AddUint32(&sf.transactionID, 1))
+	_, adu, err := frame.encodeTCPFrame(tid, slaveID, pdu)
+	return adu, err
+}
+
+// Decode decode the TCP response adu, verify the transaction id and protocol id with the request adu
+func (sf *TCPCodec) Decode(aduRequest, aduResponse []byte) (byte, ProtocolDataUnit, error) {
+	if len(aduRequest) < tcpHeaderMbapSize {
+		return 0, ProtocolDataUnit{}, fmt.Errorf("modbus: request length '%v' does not meet minimum '%v'", len(aduRequest), tcpHeaderMbapSize)
+	}
+	head, pdu, err := decodeTCPFrame(aduResponse)
+	if err != nil {
+		return 0, ProtocolDataUnit{}, err
+	}
+	if tid := binary.BigEndian.Uint16(aduRequest); head.transactionID != tid {
+		return 0, ProtocolDataUnit{}, &HeaderError{"transaction id", head.transactionID, tid}
+	}
+	if head.protocolID != tcpProtocolIdentifier {
+		return 0, ProtocolDataUnit{}, &HeaderError{"protocol id", head.protocolID, tcpProtocolIdentifier}
+	}
+	return head.slaveID, ProtocolDataUnit{pdu[0], pdu[1:]}, nil
+}
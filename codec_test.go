@@ -0,0 +1,76 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	request := ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x01, 0x00, 0x01}}
+	response := ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x02, 0x12, 0x34}}
+	tests := []struct {
+		name  string
+		codec Codec
+		reply func(req []byte) []byte
+	}{
+		{"rtu", RTUCodec{}, func([]byte) []byte {
+			return rtuFrame([]byte{0x01, 0x03, 0x02, 0x12, 0x34})
+		}},
+		{"ascii", ASCIICodec{}, func([]byte) []byte {
+			adu, _ := ASCIICodec{}.Encode(0x01, response)
+			return adu
+		}},
+		{"tcp", &TCPCodec{}, func(req []byte) []byte {
+			return append(append([]byte{}, req[:4]...), 0x00, 0x05, 0x01, 0x03, 0x02, 0x12, 0x34)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.codec.Encode(0x01, request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slaveID, got, err := tt.codec.Decode(req, tt.reply(req))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if slaveID != 0x01 || !reflect.DeepEqual(got, response) {
+				t.Errorf("Decode() = %v, %v, want 1, %v", slaveID, got, response)
+			}
+		})
+	}
+}
+
+func TestTCPCodec_Decode(t *testing.T) {
+	var c TCPCodec
+	req, _ := c.Encode(0x01, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x01, 0x00, 0x01}})
+	rsp := []byte{0x00, 0x02, 0x00, 0x00, 0x00, 0x05, 0x01, 0x03, 0x02, 0x12, 0x34}
+	if _, _, err := c.Decode(req, rsp); err == nil {
+		t.Errorf("Decode() want transaction id error")
+	}
+	rsp[1], rsp[3] = 0x01, 0x01
+	if _, _, err := c.Decode(req, rsp); err == nil {
+		t.Errorf("Decode() want protocol id error")
+	}
+}
+
+func TestRTUResponseLength(t *testing.T) {
+	tests := []struct {
+		name           string
+		adu            []byte
+		wantLength     int
+		wantDetermined bool
+	}{
+		{"read holding", []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00}, 9, true},
+		{"fifo", []byte{0x01, 0x18, 0x00, 0x00, 0x00, 0x00}, 4, false},
+		{"short", []byte{0x01}, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length, determined := RTUResponseLength(tt.adu)
+			if length != tt.wantLength || determined != tt.wantDetermined {
+				t.Errorf("RTUResponseLength() = %v, %v, want %v, %v", length, determined, tt.wantLength, tt.wantDetermined)
+			}
+		})
+	}
+}
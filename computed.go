@@ -0,0 +1,136 @@
+package modbus
+
+// 本文件提供了按需计算的寄存器区, 读请求到达时调用回调计算值 (如当前时间, 派生的累计值),
+// 不需要后台协程不断把值拷贝到节点寄存器
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ComputeFunc 计算区的回调, 返回区域内全部的值, 线圈和离散量每个值为一个位, 非0为1.
+// 返回 *ExceptionError 时回复对应的异常码, 其它错误回复 从站设备故障(0x04).
+// 调用时持有对应表的锁, 回调内不可调用该节点的方法, 否则死锁
+type ComputeFunc func() ([]uint16, error)
+
+// computedRegion 计算区, 缓存 ttl 时间内有效
+type computedRegion struct {
+	address, quantity uint16
+	ttl               time.Duration
+	compute           ComputeFunc
+
+	mu      sync.Mutex // 缓存锁, 读请求持有表的读锁, 可能并发
+	values  []uint16
+	expires time.Time
+}
+
+// overlap 是否与 [address, address+quantity) 重叠
+func (sf *computedRegion) overlap(address, quantity uint16) bool {
+	return int(address) < int(sf.address)+int(sf.quantity) && int(sf.address) < int(address)+int(quantity)
+}
+
+// get 区域的值, 缓存过期时重新计算
+func (sf *computedRegion) get() ([]uint16, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	now := time.Now()
+	if sf.values != nil && now.Before(sf.expires) {
+		return sf.values, nil
+	}
+	v, err := sf.compute()
+	if err != nil {
+		return nil, err
+	}
+	if len(v) != int(sf.quantity) {
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceFailure}
+	}
+	if sf.ttl > 0 {
+		sf.values, sf.expires = append([]uint16(nil), v...), now.Add(sf.ttl)
+	}
+	return v, nil
+}
+
+// SetComputed 注册表 table 中 [address, address+quantity) 的计算区, 读请求与之重叠时调用 f 计算值,
+// 结果缓存 ttl 时间, ttl 为0时每次读请求都计算. 计算区不可重叠, 且必须在表的范围内.
+// 写入计算区的值保存在内存映像或后端中, 但读取时被计算值覆盖, 整表操作 (BitTable 等) 不包含计算值
+func (sf *NodeRegister) SetComputed(table Table, address, quantity uint16, ttl time.Duration, f ComputeFunc) error {
+	mu, err := sf.tableLock(table)
+	if err != nil {
+		return err
+	}
+	start, size := sf.tableRange(table)
+	if quantity == 0 || address < start || int(address)+int(quantity) > int(start)+int(size) {
+		return fmt.Errorf("modbus: computed region '%v' quantity '%v' out of %v range", address, quantity, table)
+	}
+	r := &computedRegion{address: address, quantity: quantity, ttl: ttl, compute: f}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range sf.computed[table] {
+		if v.overlap(address, quantity) {
+			return fmt.Errorf("modbus: computed region '%v' quantity '%v' overlaps region '%v'", address, quantity, v.address)
+		}
+	}
+	sf.computed[table] = append(sf.computed[table], r)
+	return nil
+}
+
+// RemoveComputed 删除与 [address, address+quantity) 重叠的计算区
+func (sf *NodeRegister) RemoveComputed(table Table, address, quantity uint16) error {
+	mu, err := sf.tableLock(table)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	kept := make([]*computedRegion, 0, len(sf.computed[table]))
+	for _, v := range sf.computed[table] {
+		if !v.overlap(address, quantity) {
+			kept = append(kept, v)
+		}
+	}
+	sf.computed[table] = kept
+	mu.Unlock()
+	return nil
+}
+
+// overlay 用计算区的值覆盖读取 [address, address+quantity) 的结果, set 设置结果中偏移 offset 的值,
+// 调用者需持有对应表的锁
+func (sf *NodeRegister) overlay(table Table, address, quantity uint16, set func(offset, v uint16)) error {
+	for _, r := range sf.computed[table] {
+		if !r.overlap(address, quantity) {
+			continue
+		}
+		v, err := r.get()
+		if err != nil {
+			return err
+		}
+		start, end := int(address), int(address)+int(quantity)
+		if int(r.address) > start {
+			start = int(r.address)
+		}
+		if int(r.address)+int(r.quantity) < end {
+			end = int(r.address) + int(r.quantity)
+		}
+		for a := start; a < end; a++ {
+			set(uint16(a-int(address)), v[a-int(r.address)])
+		}
+	}
+	return nil
+}
+
+// overlayBits 用计算区的值覆盖读取的位图
+func (sf *NodeRegister) overlayBits(table Table, address, quantity uint16, result []byte) error {
+	return sf.overlay(table, address, quantity, func(offset, v uint16) {
+		if v != 0 {
+			v = 1
+		}
+		setBits(result, offset, 1, byte(v))
+	})
+}
+
+// overlayRegs 用计算区的值覆盖读取的寄存器
+func (sf *NodeRegister) overlayRegs(table Table, address uint16, result []uint16) error {
+	return sf.overlay(table, address, uint16(len(result)), func(offset, v uint16) {
+		result[offset] = v
+	})
+}
@@ -0,0 +1,126 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNodeRegister_SetComputed(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 0, 0, 8, 100, 8)
+	calls := 0
+	clock := func() ([]uint16, error) {
+		calls++
+		return []uint16{0x1234, uint16(calls)}, nil
+	}
+
+	tests := []struct {
+		name     string
+		table    Table
+		address  uint16
+		quantity uint16
+		wantErr  bool
+	}{
+		{"holding", TableHoldingRegisters, 102, 2, false},
+		{"overlap", TableHoldingRegisters, 103, 1, true},
+		{"out of range", TableHoldingRegisters, 107, 2, true},
+		{"below range", TableHoldingRegisters, 99, 2, true},
+		{"zero quantity", TableHoldingRegisters, 100, 0, true},
+		{"unknown table", Table(9), 0, 1, true},
+		{"input", TableInputRegisters, 0, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := node.SetComputed(tt.table, tt.address, tt.quantity, 0, clock)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetComputed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := node.WriteHoldings(100, []uint16{1, 2, 3, 4, 5}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := node.ReadHoldings(100, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{1, 2, 0x1234, 1, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadHoldings() = %v, want %v", got, want)
+	}
+	// no caching, every request computes
+	b, _ := node.ReadHoldingsBytes(103, 1)
+	if want := []byte{0, 2}; !reflect.DeepEqual(b, want) {
+		t.Errorf("ReadHoldingsBytes() = %v, want %v", b, want)
+	}
+	// requests not overlapping the region do not compute
+	if _, err = node.ReadHoldings(104, 2); err != nil || calls != 2 {
+		t.Errorf("ReadHoldings() error = %v, calls = %v, want 2", err, calls)
+	}
+	b, _ = node.ReadInputsBytes(1, 2)
+	if want := []byte{0, 3, 0, 0}; !reflect.DeepEqual(b, want) {
+		t.Errorf("ReadInputsBytes() = %v, want %v", b, want)
+	}
+
+	if err = node.RemoveComputed(TableHoldingRegisters, 0, 0xffff); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = node.ReadHoldings(102, 2); !reflect.DeepEqual(got, []uint16{3, 4}) {
+		t.Errorf("ReadHoldings() after remove = %v, want the memory image", got)
+	}
+}
+
+func TestNodeRegister_ComputedTTL(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 4)
+	calls := uint16(0)
+	_ = node.SetComputed(TableHoldingRegisters, 0, 1, time.Hour, func() ([]uint16, error) {
+		calls++
+		return []uint16{calls}, nil
+	})
+	for i := 0; i < 3; i++ {
+		if got, _ := node.ReadHoldings(0, 1); got[0] != 1 {
+			t.Fatalf("ReadHoldings() = %v, want the cached value", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("calls = %v, want 1", calls)
+	}
+}
+
+func TestNodeRegister_ComputedBits(t *testing.T) {
+	node := NewNodeRegister(1, 10, 16, 0, 0, 0, 0, 0, 0)
+	if err := node.WriteCoils(10, 16, []byte{0xff, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	_ = node.SetComputed(TableCoils, 13, 3, 0, func() ([]uint16, error) {
+		return []uint16{0, 5, 0}, nil
+	})
+	got, err := node.ReadCoils(10, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0xd7, 0xff}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadCoils() = %#x, want %#x", got, want)
+	}
+}
+
+func TestNodeRegister_ComputedError(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 4)
+	_ = node.SetComputed(TableHoldingRegisters, 0, 2, 0, func() ([]uint16, error) {
+		return nil, errors.New("meter offline")
+	})
+	_ = node.SetComputed(TableHoldingRegisters, 2, 2, 0, func() ([]uint16, error) {
+		return []uint16{1}, nil
+	})
+	c := NewClient(NewLoopbackProvider(node))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, address := range []uint16{0, 2} {
+		if _, err := c.ReadHoldingRegisters(1, address, 1); ExceptionOf(err) != ExceptionCodeServerDeviceFailure {
+			t.Errorf("ReadHoldingRegisters(%v) error = %v, want server device failure", address, err)
+		}
+	}
+}
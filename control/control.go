@@ -0,0 +1,303 @@
+// Package control provide the control API of the mb poller, so the poller can run
+// as a headless daemon controlled by other services: add and remove the gather jobs,
+// read the job status, trigger the one-shot reads and stream the poll results.
+//
+// the package is transport neutral, it does not ship the gRPC stubs nor serve gRPC,
+// so the module does not depend on a gRPC library. control.proto define the gRPC service,
+// a daemon serving it generates the stubs with protoc in its own module and writes an adapter
+// which implements the generated PollerServer by Server: each rpc converts the message,
+// calls the method of the same name and converts the result back. the generated stream
+// of StreamResults sends the generated Result, so it is wrapped to satisfy ResultStream:
+//
+//	type resultStream struct {
+//		controlpb.Poller_StreamResultsServer
+//	}
+//
+//	func (s resultStream) Send(r *control.Result) error {
+//		return s.Poller_StreamResultsServer.Send(&controlpb.Result{
+//			Time:    timestamppb.New(r.Time),
+//			SlaveId: uint32(r.SlaveID),
+//			// ... the other fields
+//		})
+//	}
+//
+//	func (a *adapter) StreamResults(f *controlpb.StreamFilter, stream controlpb.Poller_StreamResultsServer) error {
+//		return a.srv.StreamResults(&control.StreamFilter{SlaveID: byte(f.SlaveId)}, resultStream{stream})
+//	}
+package control
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aloncn/gomodbus/mb"
+)
+
+// DefaultStreamBuffer the default results buffered by each stream
+const DefaultStreamBuffer = 64
+
+// ErrNotStarted the server is not started with a poller
+var ErrNotStarted = errors.New("control: server not started")
+
+// Job the gather job, ScanRate is ignored by Read and RemoveJob
+type Job struct {
+	SlaveID  byte
+	FuncCode byte
+	Address  uint16
+	Quantity uint16
+	ScanRate time.Duration
+	Timeout  time.Duration
+}
+
+// request the mb request of the job
+func (sf *Job) request() mb.Request {
+	return mb.Request{
+		SlaveID:  sf.SlaveID,
+		FuncCode: sf.FuncCode,
+		Address:  sf.Address,
+		Quantity: sf.Quantity,
+		ScanRate: sf.ScanRate,
+		Timeout:  sf.Timeout,
+	}
+}
+
+// JobStatus the job and its counters
+type JobStatus struct {
+	Job
+	TxCnt   uint64
+	ErrCnt  uint64
+	Suspend bool
+}
+
+// StreamFilter select the results to stream, SlaveID 0 for all slaves
+type StreamFilter struct {
+	SlaveID byte
+}
+
+// Result the poll result, Values is the response data on success,
+// the coils are packed low bit first and the registers are big-endian.
+type Result struct {
+	Time     time.Time
+	SlaveID  byte
+	FuncCode byte
+	Address  uint16
+	Quantity uint16
+	Values   []byte
+	Err      string
+	TxCnt    uint64
+	ErrCnt   uint64
+	Suspend  bool
+}
+
+// ResultStream the server stream of StreamResults, the shape of a gRPC server stream
+type ResultStream interface {
+	Context() context.Context
+	Send(r *Result) error
+}
+
+// Server the control service of the poller,
+// it implements mb.Handler, set it to the poller by mb.WitchHandler.
+type Server struct {
+	poller  *mb.Client
+	next    mb.Handler
+	buffer  int
+	values  []byte // the values of the current read, only accessed by the poll goroutine
+	mu      sync.Mutex
+	streams map[chan *Result]StreamFilter
+}
+
+var _ mb.Handler = (*Server)(nil)
+
+// Option the option of the server
+type Option func(s *Server)
+
+// WithHandler set the handler called after the result is streamed, such as the tags handler
+func WithHandler(h mb.Handler) Option {
+	return func(s *Server) {
+		s.next = h
+	}
+}
+
+// WithStreamBuffer set the results buffered by each stream, default DefaultStreamBuffer,
+// the results are dropped when the buffer of a slow stream is full, so the bus is never blocked.
+func WithStreamBuffer(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.buffer = n
+		}
+	}
+}
+
+// NewServer new a control server
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		buffer:  DefaultStreamBuffer,
+		streams: make(map[chan *Result]StreamFilter),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Start serve the poller
+func (sf *Server) Start(poller *mb.Client) {
+	sf.mu.Lock()
+	sf.poller = poller
+	sf.mu.Unlock()
+}
+
+// getPoller the started poller
+func (sf *Server) getPoller() (*mb.Client, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.poller == nil {
+		return nil, ErrNotStarted
+	}
+	return sf.poller, nil
+}
+
+// AddJob add a gather job
+func (sf *Server) AddJob(_ context.Context, job *Job) error {
+	poller, err := sf.getPoller()
+	if err != nil {
+		return err
+	}
+	return poller.AddGatherJob(job.request())
+}
+
+// RemoveJob remove the gather jobs in the range of the job, return the number removed
+func (sf *Server) RemoveJob(_ context.Context, job *Job) (int, error) {
+	poller, err := sf.getPoller()
+	if err != nil {
+		return 0, err
+	}
+	return poller.RemoveGatherJob(job.SlaveID, job.FuncCode, job.Address, job.Quantity), nil
+}
+
+// ListJobs the status of all gather jobs
+func (sf *Server) ListJobs(context.Context) ([]JobStatus, error) {
+	poller, err := sf.getPoller()
+	if err != nil {
+		return nil, err
+	}
+	jobs := poller.Jobs()
+	status := make([]JobStatus, 0, len(jobs))
+	for _, r := range jobs {
+		status = append(status, JobStatus{
+			Job:     Job{r.SlaveID, r.FuncCode, r.Address, r.Quantity, r.ScanRate, r.Timeout},
+			TxCnt:   r.TxCnt,
+			ErrCnt:  r.ErrCnt,
+			Suspend: r.Suspend,
+		})
+	}
+	return status, nil
+}
+
+// Read trigger a one-shot read, the result is sent to the result streams
+func (sf *Server) Read(_ context.Context, job *Job) error {
+	poller, err := sf.getPoller()
+	if err != nil {
+		return err
+	}
+	return poller.ReadOnce(job.request())
+}
+
+// StreamResults stream the poll results until the stream context is done or Send fails,
+// nil filter stream the results of all slaves
+func (sf *Server) StreamResults(filter *StreamFilter, stream ResultStream) error {
+	var f StreamFilter
+	if filter != nil {
+		f = *filter
+	}
+	ch := make(chan *Result, sf.buffer)
+	sf.mu.Lock()
+	sf.streams[ch] = f
+	sf.mu.Unlock()
+	defer func() {
+		sf.mu.Lock()
+		delete(sf.streams, ch)
+		sf.mu.Unlock()
+	}()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case r := <-ch:
+			if err := stream.Send(r); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ProcReadCoils implements mb.Handler
+func (sf *Server) ProcReadCoils(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.values = append(sf.values[:0], valBuf...)
+	if sf.next != nil {
+		sf.next.ProcReadCoils(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcReadDiscretes implements mb.Handler
+func (sf *Server) ProcReadDiscretes(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.values = append(sf.values[:0], valBuf...)
+	if sf.next != nil {
+		sf.next.ProcReadDiscretes(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcReadHoldingRegisters implements mb.Handler
+func (sf *Server) ProcReadHoldingRegisters(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.values = append(sf.values[:0], valBuf...)
+	if sf.next != nil {
+		sf.next.ProcReadHoldingRegisters(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcReadInputRegisters implements mb.Handler
+func (sf *Server) ProcReadInputRegisters(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.values = append(sf.values[:0], valBuf...)
+	if sf.next != nil {
+		sf.next.ProcReadInputRegisters(slaveID, address, quantity, valBuf)
+	}
+}
+
+// ProcResult implements mb.Handler, send the result to the streams
+func (sf *Server) ProcResult(err error, result *mb.Result) {
+	r := &Result{
+		Time:     time.Now(),
+		SlaveID:  result.SlaveID,
+		FuncCode: result.FuncCode,
+		Address:  result.Address,
+		Quantity: result.Quantity,
+		TxCnt:    result.TxCnt,
+		ErrCnt:   result.ErrCnt,
+		Suspend:  result.Suspend,
+	}
+	if err != nil {
+		r.Err = err.Error()
+	} else {
+		r.Values = append([]byte(nil), sf.values...)
+	}
+	sf.values = sf.values[:0]
+
+	sf.mu.Lock()
+	for ch, filter := range sf.streams {
+		if filter.SlaveID != 0 && filter.SlaveID != r.SlaveID {
+			continue
+		}
+		select {
+		case ch <- r:
+		default: // drop for the slow stream, never block the bus
+		}
+	}
+	sf.mu.Unlock()
+	if sf.next != nil {
+		sf.next.ProcResult(err, result)
+	}
+}
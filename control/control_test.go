@@ -0,0 +1,118 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+)
+
+// chanStream a result stream deliver the results to a channel
+type chanStream struct {
+	ctx     context.Context
+	results chan *Result
+}
+
+func (sf *chanStream) Context() context.Context { return sf.ctx }
+
+func (sf *chanStream) Send(r *Result) error {
+	sf.results <- r
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	if err := srv.AddJob(context.Background(), &Job{SlaveID: 1}); err != ErrNotStarted {
+		t.Fatalf("AddJob() before Start error = %v, want ErrNotStarted", err)
+	}
+
+	node := modbus.NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 200)
+	_ = node.WriteHoldings(0, []uint16{7, 8})
+	poller := mb.NewClient(modbus.NewLoopbackProvider(node), mb.WitchHandler(srv))
+	srv.Start(poller)
+	if err := poller.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &chanStream{ctx, make(chan *Result, 100)}
+	done := make(chan error, 1)
+	go func() { done <- srv.StreamResults(&StreamFilter{SlaveID: 1}, stream) }()
+	time.Sleep(10 * time.Millisecond) // let the stream subscribe
+
+	if err := srv.Read(ctx, &Job{SlaveID: 1, FuncCode: modbus.FuncCodeReadHoldingRegisters, Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-stream.results:
+		if r.Err != "" || string(r.Values) != "\x00\x07\x00\x08" {
+			t.Errorf("Read() result = %+v, want values 7, 8", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read() no result streamed")
+	}
+	if err := srv.Read(ctx, &Job{SlaveID: 1, FuncCode: modbus.FuncCodeReadHoldingRegisters, Quantity: 200}); err == nil {
+		t.Errorf("Read() quantity over max, want error")
+	}
+
+	job := &Job{SlaveID: 1, FuncCode: modbus.FuncCodeReadHoldingRegisters, Quantity: 200, ScanRate: time.Hour, Timeout: 300 * time.Millisecond}
+	if err := srv.AddJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	_ = poller.AddGatherJob(mb.Request{SlaveID: 1, FuncCode: modbus.FuncCodeReadCoils, Quantity: 16, ScanRate: time.Hour})
+	jobs, err := srv.ListJobs(ctx)
+	if err != nil || len(jobs) != 3 {
+		t.Fatalf("ListJobs() = %+v, %v, want 3 jobs", jobs, err)
+	}
+	if jobs[1].Address != 125 || jobs[1].Quantity != 75 || jobs[1].Timeout != job.Timeout {
+		t.Errorf("ListJobs()[1] = %+v, want the split job", jobs[1])
+	}
+	if n, _ := srv.RemoveJob(ctx, job); n != 2 {
+		t.Errorf("RemoveJob() = %v, want 2", n)
+	}
+	if jobs, _ = srv.ListJobs(ctx); len(jobs) != 1 || jobs[0].FuncCode != modbus.FuncCodeReadCoils {
+		t.Errorf("ListJobs() after remove = %+v", jobs)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("StreamResults() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StreamResults() not return after cancel")
+	}
+}
+
+func TestServer_StreamResultsNilFilter(t *testing.T) {
+	srv := NewServer()
+	node := modbus.NewNodeRegister(2, 0, 16, 0, 16, 0, 16, 0, 16)
+	poller := mb.NewClient(modbus.NewLoopbackProvider(node), mb.WitchHandler(srv))
+	srv.Start(poller)
+	if err := poller.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &chanStream{ctx, make(chan *Result, 10)}
+	go func() { _ = srv.StreamResults(nil, stream) }()
+	time.Sleep(10 * time.Millisecond) // let the stream subscribe
+
+	if err := srv.Read(ctx, &Job{SlaveID: 2, FuncCode: modbus.FuncCodeReadCoils, Quantity: 8}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-stream.results:
+		if r.SlaveID != 2 || r.Err != "" {
+			t.Errorf("StreamResults(nil) result = %+v, want slave 2", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StreamResults(nil) no result streamed")
+	}
+}
@@ -0,0 +1,58 @@
+package modbus
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// transactionDeadline the deadline of the transaction, the deadline of ctx replace the provider timeout,
+// zero means no deadline
+func transactionDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	if timeout > 0 {
+		return time.Now().Add(timeout)
+	}
+	return time.Time{}
+}
+
+// deadlineReader read the serial port until the deadline of the transaction,
+// the read timeout of the port is retried before the deadline, and the context done abort it.
+// a read blocked in the port can not be interrupted, it returns after the serial timeout.
+type deadlineReader struct {
+	r        io.Reader
+	ctx      context.Context
+	deadline time.Time
+}
+
+// serialReader the reader of the transaction on the port,
+// the port is used directly when the context has no deadline and can not be cancelled.
+func serialReader(ctx context.Context, port io.Reader) io.Reader {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		if ctx.Done() == nil {
+			return port
+		}
+		// no deadline, the serial timeout bound the read as usual
+		return &deadlineReader{port, ctx, time.Now()}
+	}
+	return &deadlineReader{port, ctx, deadline}
+}
+
+// Read implements io.Reader
+func (sf *deadlineReader) Read(p []byte) (int, error) {
+	for {
+		n, err := sf.r.Read(p)
+		if n > 0 || !isSerialTimeout(err) {
+			return n, err
+		}
+		if e := sf.ctx.Err(); e != nil {
+			return 0, e
+		}
+		if !time.Now().Before(sf.deadline) {
+			return 0, err
+		}
+	}
+}
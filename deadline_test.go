@@ -0,0 +1,89 @@
+package modbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goburrow/serial"
+)
+
+// latePort a serial port whose response arrives after some read timeouts
+type latePort struct {
+	chunkPort
+	timeouts int
+}
+
+func (sf *latePort) Read(b []byte) (int, error) {
+	if sf.timeouts > 0 {
+		sf.timeouts--
+		time.Sleep(5 * time.Millisecond)
+		return 0, serial.ErrTimeout
+	}
+	return sf.chunkPort.Read(b)
+}
+
+func TestRTUClientProvider_SendContext(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, FuncCodeReadHoldingRegisters, 0x02, 0x12, 0x34})
+	request := ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x01}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		send    bool // Send without context
+		wantErr error
+	}{
+		{"serial timeout", context.Background(), true, serial.ErrTimeout},
+		{"cancelled", cancelled, false, context.Canceled},
+		{"deadline", nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRTUClientProvider()
+			p.port = &latePort{chunkPort{chunks: [][]byte{rsp}}, 3}
+			ctx := tt.ctx
+			if ctx == nil {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+			}
+			var err error
+			var response ProtocolDataUnit
+			if tt.send {
+				_, err = p.Send(1, request)
+			} else {
+				response, err = p.SendContext(ctx, 1, request)
+			}
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && response.Data[1] != 0x12 {
+				t.Errorf("response = %+v", response)
+			}
+		})
+	}
+}
+
+func TestASCIIClientProvider_SendContext(t *testing.T) {
+	p := NewASCIIClientProvider()
+	frame := &protocolFrame{adu: make([]byte, 0, asciiCharacterMaxSize)}
+	rsp, err := frame.encodeASCIIFrame(1, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x02, 0x12, 0x34}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.port = &latePort{chunkPort{chunks: [][]byte{append([]byte(nil), rsp...)}}, 3}
+	request := ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x01}}
+	if _, err = p.Send(1, request); err != serial.ErrTimeout {
+		t.Fatalf("Send() error = %v, want serial timeout", err)
+	}
+
+	p.port = &latePort{chunkPort{chunks: [][]byte{append([]byte(nil), rsp...)}}, 3}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err := p.SendContext(ctx, 1, request)
+	if err != nil || response.Data[1] != 0x12 {
+		t.Errorf("SendContext() = %+v, %v", response, err)
+	}
+}
@@ -0,0 +1,69 @@
+package modbus
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Dialer dial the connection to the address,
+// both *net.Dialer and golang.org/x/net/proxy.Dialer implement it.
+type Dialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
+// contextDialer dialer which support context, such as *net.Dialer and proxy.ContextDialer
+type contextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialerFunc is an adapter to allow the use of ordinary functions as Dialer.
+type DialerFunc func(network, address string) (net.Conn, error)
+
+// Dial implements Dialer, calls f(network, address).
+func (f DialerFunc) Dial(network, address string) (net.Conn, error) {
+	return f(network, address)
+}
+
+// dialWithTimeout dial with timeout, 0 means no timeout,
+// if the dialer not support context, the connection established after timeout is closed.
+func dialWithTimeout(d Dialer, network, address string, timeout time.Duration) (net.Conn, error) {
+	return dialContext(context.Background(), d, network, address, timeout)
+}
+
+// dialContext dial until the context is done or timeout, 0 means no timeout,
+// if the dialer not support context, the connection established after that is closed.
+func dialContext(ctx context.Context, d Dialer, network, address string, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return d.Dial(network, address)
+	}
+	if cd, ok := d.(contextDialer); ok {
+		return cd.DialContext(ctx, network, address)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := d.Dial(network, address)
+		ch <- result{conn, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+	}
+}
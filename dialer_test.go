@@ -0,0 +1,103 @@
+package modbus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPClientProvider_SetDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// a jump host dialer which redirect to the listener
+	var dialed string
+	p := NewTCPClientProvider("plc.internal:502")
+	p.SetDialer(DialerFunc(func(network, address string) (net.Conn, error) {
+		dialed = address
+		return net.Dial(network, ln.Addr().String())
+	}))
+	if err = p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if dialed != "plc.internal:502" {
+		t.Errorf("dialed address = %v, want plc.internal:502", dialed)
+	}
+
+	// a dialer which hang, fail by the dial timeout
+	block := make(chan struct{})
+	defer close(block)
+	p.SetDialTimeout(20 * time.Millisecond)
+	p.SetDialer(DialerFunc(func(string, string) (net.Conn, error) {
+		<-block
+		return nil, net.ErrWriteToConnected
+	}))
+	start := time.Now()
+	if err = p.Connect(); err == nil {
+		t.Fatal("Connect() want timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Connect() return after %v, want dial timeout 20ms", elapsed)
+	}
+}
+
+func TestTCPClientProvider_ConnectContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	p := NewTCPClientProvider("plc.internal:502")
+	p.SetDialTimeout(time.Minute)
+	p.SetDialer(DialerFunc(func(string, string) (net.Conn, error) {
+		<-block
+		return nil, net.ErrWriteToConnected
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if err := p.ConnectContext(ctx); err == nil {
+		t.Fatal("ConnectContext() want canceled error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("ConnectContext() return after %v, want canceled at 20ms", elapsed)
+	}
+}
+
+// blockProvider a provider which Connect hang until unblocked
+type blockProvider struct {
+	provider
+	block  chan struct{}
+	closed chan struct{}
+}
+
+func (sf *blockProvider) Connect() error { <-sf.block; return nil }
+func (sf *blockProvider) Close() error   { close(sf.closed); return nil }
+
+func TestClient_ConnectContext(t *testing.T) {
+	p := &blockProvider{block: make(chan struct{}), closed: make(chan struct{})}
+	c := NewClient(p)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.ConnectContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("ConnectContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// the connection established late is closed
+	close(p.block)
+	select {
+	case <-p.closed:
+	case <-time.After(time.Second):
+		t.Error("the late connection is not closed")
+	}
+}
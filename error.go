@@ -2,7 +2,96 @@ package modbus
 
 import (
 	"errors"
+	"fmt"
 )
 
 // ErrClosedConnection 连接已关闭
 var ErrClosedConnection = errors.New("use of closed connection")
+
+// ErrSlaveNotExist 从机地址不存在
+var ErrSlaveNotExist = errors.New("slaveID not exist")
+
+// ChecksumError the crc of RTU or the lrc of ASCII frame does not match
+type ChecksumError struct {
+	Kind string // crc or lrc
+	Got  uint16
+	Want uint16
+}
+
+// Error implements error interface.
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("modbus: response %v '%x' does not match expected '%x'", e.Kind, e.Got, e.Want)
+}
+
+// AsExceptionError find the first *ExceptionError in the error chain,
+// the chain is unwrapped by the Unwrap() error method.
+func AsExceptionError(err error) (*ExceptionError, bool) {
+	for err != nil {
+		if e, ok := err.(*ExceptionError); ok {
+			return e, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// IsException reports whether the error is a modbus exception with the code
+func IsException(err error, code byte) bool {
+	e, ok := AsExceptionError(err)
+	return ok && e.ExceptionCode == code
+}
+
+// IsIllegalFunction reports whether the error is illegal function exception
+func IsIllegalFunction(err error) bool {
+	return IsException(err, ExceptionCodeIllegalFunction)
+}
+
+// IsIllegalDataAddress reports whether the error is illegal data address exception
+func IsIllegalDataAddress(err error) bool {
+	return IsException(err, ExceptionCodeIllegalDataAddress)
+}
+
+// IsIllegalDataValue reports whether the error is illegal data value exception
+func IsIllegalDataValue(err error) bool {
+	return IsException(err, ExceptionCodeIllegalDataValue)
+}
+
+// IsServerDeviceFailure reports whether the error is server device failure exception
+func IsServerDeviceFailure(err error) bool {
+	return IsException(err, ExceptionCodeServerDeviceFailure)
+}
+
+// IsAcknowledge reports whether the error is acknowledge exception
+func IsAcknowledge(err error) bool {
+	return IsException(err, ExceptionCodeAcknowledge)
+}
+
+// IsServerDeviceBusy reports whether the error is server device busy exception
+func IsServerDeviceBusy(err error) bool {
+	return IsException(err, ExceptionCodeServerDeviceBusy)
+}
+
+// IsNegativeAcknowledge reports whether the error is negative acknowledge exception
+func IsNegativeAcknowledge(err error) bool {
+	return IsException(err, ExceptionCodeNegativeAcknowledge)
+}
+
+// IsMemoryParityError reports whether the error is memory parity error exception
+func IsMemoryParityError(err error) bool {
+	return IsException(err, ExceptionCodeMemoryParityError)
+}
+
+// IsGatewayPathUnavailable reports whether the error is gateway path unavailable exception
+func IsGatewayPathUnavailable(err error) bool {
+	return IsException(err, ExceptionCodeGatewayPathUnavailable)
+}
+
+// IsGatewayTargetDeviceFailedToRespond reports whether the error is
+// gateway target device failed to respond exception
+func IsGatewayTargetDeviceFailedToRespond(err error) bool {
+	return IsException(err, ExceptionCodeGatewayTargetDeviceFailedToRespond)
+}
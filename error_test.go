@@ -0,0 +1,76 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+// wrapError wrap an error with Unwrap
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *wrapError) Unwrap() error { return e.err }
+
+func TestAsExceptionError(t *testing.T) {
+	exc := &ExceptionError{FuncCode: FuncCodeReadHoldingRegisters, ExceptionCode: ExceptionCodeIllegalDataAddress}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other", errors.New("other"), false},
+		{"exception", exc, true},
+		{"wrapped", &wrapError{"read", &wrapError{"poll", exc}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := AsExceptionError(tt.err)
+			if ok != tt.want || (ok && got != exc) {
+				t.Errorf("AsExceptionError() = %v, %v, want %v", got, ok, tt.want)
+			}
+			if IsIllegalDataAddress(tt.err) != tt.want {
+				t.Errorf("IsIllegalDataAddress() = %v, want %v", !tt.want, tt.want)
+			}
+			if IsIllegalFunction(tt.err) {
+				t.Errorf("IsIllegalFunction() = true, want false")
+			}
+		})
+	}
+}
+
+func TestExceptionError_Is(t *testing.T) {
+	err := &ExceptionError{FuncCode: FuncCodeReadCoils, ExceptionCode: ExceptionCodeServerDeviceBusy}
+	tests := []struct {
+		name   string
+		target error
+		want   bool
+	}{
+		{"same code", &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceBusy}, true},
+		{"same code and function", &ExceptionError{FuncCode: FuncCodeReadCoils, ExceptionCode: ExceptionCodeServerDeviceBusy}, true},
+		{"other function", &ExceptionError{FuncCode: FuncCodeWriteSingleCoil, ExceptionCode: ExceptionCodeServerDeviceBusy}, false},
+		{"other code", &ExceptionError{ExceptionCode: ExceptionCodeAcknowledge}, false},
+		{"not exception", errors.New("busy"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := err.Is(tt.target); got != tt.want {
+				t.Errorf("Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_responseError(t *testing.T) {
+	err := responseError(ProtocolDataUnit{FuncCode: 0x83, Data: []byte{ExceptionCodeIllegalDataAddress}})
+	e, ok := err.(*ExceptionError)
+	if !ok || e.FuncCode != FuncCodeReadHoldingRegisters || e.ExceptionCode != ExceptionCodeIllegalDataAddress {
+		t.Fatalf("responseError() = %#v", err)
+	}
+	if want := "modbus: function '3' exception '2' (illegal data address)"; e.Error() != want {
+		t.Errorf("Error() = %v, want %v", e.Error(), want)
+	}
+}
@@ -0,0 +1,82 @@
+package modbus
+
+import (
+	"fmt"
+)
+
+// Exception modbus异常码, ExceptionCodeXXX 常量为无类型常量, 可直接用于 byte 或 Exception
+type Exception byte
+
+// Exception 异常码, 同 ExceptionCodeXXX
+const (
+	ExceptionIllegalFunction                    Exception = ExceptionCodeIllegalFunction                    // 0x01 非法功能
+	ExceptionIllegalDataAddress                 Exception = ExceptionCodeIllegalDataAddress                 // 0x02 非法数据地址
+	ExceptionIllegalDataValue                   Exception = ExceptionCodeIllegalDataValue                   // 0x03 非法数据值
+	ExceptionServerDeviceFailure                Exception = ExceptionCodeServerDeviceFailure                // 0x04 从站设备故障
+	ExceptionAcknowledge                        Exception = ExceptionCodeAcknowledge                        // 0x05 确认, 需长时间处理
+	ExceptionServerDeviceBusy                   Exception = ExceptionCodeServerDeviceBusy                   // 0x06 从站设备忙
+	ExceptionNegativeAcknowledge                Exception = ExceptionCodeNegativeAcknowledge                // 0x07 否定确认
+	ExceptionMemoryParityError                  Exception = ExceptionCodeMemoryParityError                  // 0x08 存储奇偶性差错
+	ExceptionGatewayPathUnavailable             Exception = ExceptionCodeGatewayPathUnavailable             // 0x0A 网关路径不可用
+	ExceptionGatewayTargetDeviceFailedToRespond Exception = ExceptionCodeGatewayTargetDeviceFailedToRespond // 0x0B 网关目标设备响应失败
+)
+
+// name 异常码的名称, 未知的异常码返回空
+func (e Exception) name() string {
+	switch e {
+	case ExceptionIllegalFunction:
+		return "illegal function"
+	case ExceptionIllegalDataAddress:
+		return "illegal data address"
+	case ExceptionIllegalDataValue:
+		return "illegal data value"
+	case ExceptionServerDeviceFailure:
+		return "server device failure"
+	case ExceptionAcknowledge:
+		return "acknowledge"
+	case ExceptionServerDeviceBusy:
+		return "server device busy"
+	case ExceptionNegativeAcknowledge:
+		return "negative acknowledge"
+	case ExceptionMemoryParityError:
+		return "memory parity error"
+	case ExceptionGatewayPathUnavailable:
+		return "gateway path unavailable"
+	case ExceptionGatewayTargetDeviceFailedToRespond:
+		return "gateway target device failed to respond"
+	}
+	return ""
+}
+
+// String 异常码的名称, 未知的异常码为 exception(0xNN)
+func (e Exception) String() string {
+	if name := e.name(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("exception(0x%02x)", byte(e))
+}
+
+// Err 异常码的错误, 自定义的 FunctionHandler 或 ServerHandler 返回它时服务端回复该异常码,
+// 如: return nil, modbus.ExceptionIllegalDataValue.Err()
+func (e Exception) Err() error {
+	return &ExceptionError{ExceptionCode: byte(e)}
+}
+
+// Exception 错误的异常码
+func (e *ExceptionError) Exception() Exception {
+	return Exception(e.ExceptionCode)
+}
+
+// ExceptionOf 服务端回复错误的异常码, 同服务端的处理:
+// 不是 *ExceptionError 的错误为从站设备故障(0x04), nil 为0
+func ExceptionOf(err error) Exception {
+	if err == nil {
+		return 0
+	}
+	return Exception(exceptionCode(err))
+}
+
+// ExceptionResponse 构造功能码的异常响应pdu, 功能码最高位置1, 数据域为异常码
+func ExceptionResponse(funcCode byte, code Exception) ProtocolDataUnit {
+	return ProtocolDataUnit{funcCode | 0x80, []byte{byte(code)}}
+}
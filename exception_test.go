@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestException_String(t *testing.T) {
+	tests := []struct {
+		e    Exception
+		want string
+	}{
+		{ExceptionIllegalFunction, "illegal function"},
+		{ExceptionNegativeAcknowledge, "negative acknowledge"},
+		{ExceptionGatewayPathUnavailable, "gateway path unavailable"},
+		{ExceptionGatewayTargetDeviceFailedToRespond, "gateway target device failed to respond"},
+		{Exception(0x09), "exception(0x09)"},
+	}
+	for _, tt := range tests {
+		if got := tt.e.String(); got != tt.want {
+			t.Errorf("Exception(%d).String() = %v, want %v", byte(tt.e), got, tt.want)
+		}
+	}
+}
+
+func TestExceptionOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Exception
+	}{
+		{nil, 0},
+		{ExceptionServerDeviceBusy.Err(), ExceptionServerDeviceBusy},
+		{&wrapError{"poll", ExceptionGatewayPathUnavailable.Err()}, ExceptionGatewayPathUnavailable},
+		{errors.New("other"), ExceptionServerDeviceFailure},
+	}
+	for _, tt := range tests {
+		if got := ExceptionOf(tt.err); got != tt.want {
+			t.Errorf("ExceptionOf(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestExceptionResponse(t *testing.T) {
+	want := ProtocolDataUnit{0x83, []byte{0x0b}}
+	if got := ExceptionResponse(FuncCodeReadHoldingRegisters, ExceptionGatewayTargetDeviceFailedToRespond); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExceptionResponse() = %v, want %v", got, want)
+	}
+}
+
+func TestException_CustomHandler(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	p := NewLoopbackProvider(node)
+	p.RegisterFunctionHandler(FuncCodeReadFIFOQueue, func(reg *NodeRegister, data []byte) ([]byte, error) {
+		return nil, ExceptionMemoryParityError.Err()
+	})
+	c := NewClient(p)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.ReadFIFOQueue(1, 0)
+	e, ok := AsExceptionError(err)
+	if !ok || e.Exception() != ExceptionMemoryParityError || e.FuncCode != FuncCodeReadFIFOQueue {
+		t.Errorf("ReadFIFOQueue() error = %v, want memory parity error", err)
+	}
+}
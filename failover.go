@@ -0,0 +1,215 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// DefaultFailoverTimeouts 连续超时多少次后切换到下一个端点
+const DefaultFailoverTimeouts = 3
+
+// FailoverProvider implements ClientProvider interface over the redundant endpoints,
+// such as the primary and standby PLC, the first one is preferred.
+// it switches to the next endpoint in order when the connection of the active one is lost
+// or it times out several times in a row, the failed request is not resent.
+// it stays on the new endpoint until that one fails too.
+type FailoverProvider struct {
+	mu          sync.Mutex
+	endpoints   []ClientProvider
+	active      int
+	timeouts    int
+	maxTimeouts int
+	onSwitch    func(index int, endpoint ClientProvider)
+}
+
+// check FailoverProvider implements underlying method
+var _ ClientProvider = (*FailoverProvider)(nil)
+
+// NewFailoverProvider allocates a FailoverProvider with the endpoints in order of preference
+func NewFailoverProvider(endpoints ...ClientProvider) *FailoverProvider {
+	return &FailoverProvider{endpoints: endpoints, maxTimeouts: DefaultFailoverTimeouts}
+}
+
+// NewTCPFailoverProvider allocates a FailoverProvider with the TCP addresses in order of preference,
+// the TCPClientProvider of the endpoints can be tuned by Endpoint.
+func NewTCPFailoverProvider(addresses ...string) *FailoverProvider {
+	endpoints := make([]ClientProvider, 0, len(addresses))
+	for _, address := range addresses {
+		endpoints = append(endpoints, NewTCPClientProvider(address))
+	}
+	return NewFailoverProvider(endpoints...)
+}
+
+// Endpoint return the endpoint of the index
+func (sf *FailoverProvider) Endpoint(index int) ClientProvider {
+	return sf.endpoints[index]
+}
+
+// Active return the index of the active endpoint and itself
+func (sf *FailoverProvider) Active() (int, ClientProvider) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.active, sf.endpoints[sf.active]
+}
+
+// SetMaxTimeouts switch to the next endpoint after n timeouts in a row, 0 never switch on timeout
+func (sf *FailoverProvider) SetMaxTimeouts(n int) {
+	sf.mu.Lock()
+	sf.maxTimeouts = n
+	sf.mu.Unlock()
+}
+
+// OnSwitch set the callback which called when the active endpoint changes, nil to disable it,
+// it is called with the lock held, do not call the methods of the provider in it.
+func (sf *FailoverProvider) OnSwitch(f func(index int, endpoint ClientProvider)) {
+	sf.mu.Lock()
+	sf.onSwitch = f
+	sf.mu.Unlock()
+}
+
+// Connect connect the endpoints in order of preference, the first connected one becomes active
+func (sf *FailoverProvider) Connect() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if len(sf.endpoints) == 0 {
+		return errors.New("modbus: failover has no endpoint")
+	}
+	return sf.connectFrom(0)
+}
+
+// connectFrom connect the endpoints from the index cyclically, the first connected one becomes active.
+// Caller must hold the mutex before calling this method.
+func (sf *FailoverProvider) connectFrom(start int) error {
+	var err error
+	for i := 0; i < len(sf.endpoints); i++ {
+		index := (start + i) % len(sf.endpoints)
+		if err = sf.endpoints[index].Connect(); err == nil {
+			sf.activate(index)
+			return nil
+		}
+	}
+	return err
+}
+
+// activate set the active endpoint
+// Caller must hold the mutex before calling this method.
+func (sf *FailoverProvider) activate(index int) {
+	sf.timeouts = 0
+	if index == sf.active {
+		return
+	}
+	sf.active = index
+	if sf.onSwitch != nil {
+		sf.onSwitch(index, sf.endpoints[index])
+	}
+}
+
+// IsConnected whether the active endpoint is connected
+func (sf *FailoverProvider) IsConnected() bool {
+	_, p := sf.Active()
+	return p.IsConnected()
+}
+
+// SetAutoReconnect set auto reconnect count of all endpoints
+func (sf *FailoverProvider) SetAutoReconnect(cnt byte) {
+	for _, p := range sf.endpoints {
+		p.SetAutoReconnect(cnt)
+	}
+}
+
+// LogMode set enable or disable log output of all endpoints
+func (sf *FailoverProvider) LogMode(enable bool) {
+	for _, p := range sf.endpoints {
+		p.LogMode(enable)
+	}
+}
+
+// SetLogLevel set log output level of all endpoints which implement LogLeveler
+func (sf *FailoverProvider) SetLogLevel(level LogLevel) {
+	for _, p := range sf.endpoints {
+		setLogLevel(p, level)
+	}
+}
+
+// SetLogProvider set logger provider of all endpoints
+func (sf *FailoverProvider) SetLogProvider(lp LogProvider) {
+	for _, p := range sf.endpoints {
+		p.SetLogProvider(lp)
+	}
+}
+
+// Close close all endpoints
+func (sf *FailoverProvider) Close() error {
+	var err error
+	for _, p := range sf.endpoints {
+		if e := p.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Send request through the active endpoint
+func (sf *FailoverProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	index, p := sf.Active()
+	response, err := p.Send(slaveID, request)
+	sf.check(index, err)
+	return response, err
+}
+
+// SendPdu send pdu request through the active endpoint
+func (sf *FailoverProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	index, p := sf.Active()
+	pduResponse, err := p.SendPdu(slaveID, pduRequest)
+	sf.check(index, err)
+	return pduResponse, err
+}
+
+// SendRawFrame send raw adu request frame through the active endpoint
+func (sf *FailoverProvider) SendRawFrame(aduRequest []byte) ([]byte, error) {
+	index, p := sf.Active()
+	aduResponse, err := p.SendRawFrame(aduRequest)
+	sf.check(index, err)
+	return aduResponse, err
+}
+
+// check the result of the transaction on the endpoint, switch to the next one if it failed
+func (sf *FailoverProvider) check(index int, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if index != sf.active { // switched by the others
+		return
+	}
+	switch {
+	case isConnectionLost(err):
+	case isTimeout(err):
+		if sf.timeouts++; sf.maxTimeouts <= 0 || sf.timeouts < sf.maxTimeouts {
+			return
+		}
+	default: // success, exception or corrupted frame, the endpoint is alive
+		sf.timeouts = 0
+		return
+	}
+	sf.endpoints[index].Close()
+	_ = sf.connectFrom(index + 1)
+}
+
+// isTimeout whether the error is a timeout
+func isTimeout(err error) bool {
+	e, ok := err.(net.Error)
+	return ok && e.Timeout()
+}
+
+// isConnectionLost whether the error indicates the connection is lost
+func isConnectionLost(err error) bool {
+	switch err {
+	case nil:
+		return false
+	case ErrClosedConnection, io.EOF, io.ErrUnexpectedEOF, io.ErrClosedPipe:
+		return true
+	}
+	e, ok := err.(net.Error)
+	return ok && !e.Timeout()
+}
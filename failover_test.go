@@ -0,0 +1,52 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFailoverProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		maxTimeouts int
+		requests    int
+		wantActive  int
+	}{
+		{"ok", nil, 3, 3, 0},
+		{"exception", &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}, 3, 3, 0},
+		{"connection lost", ErrClosedConnection, 3, 1, 1},
+		{"timeout below limit", ErrChaosTimeout, 3, 2, 0},
+		{"timeout", ErrChaosTimeout, 3, 3, 1},
+		{"timeout never switch", ErrChaosTimeout, 0, 5, 0},
+		{"other error", errors.New("modbus: response data is empty"), 3, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, standby := &provider{err: tt.err}, &provider{}
+			p := NewFailoverProvider(primary, standby)
+			p.SetMaxTimeouts(tt.maxTimeouts)
+			var switched []int
+			p.OnSwitch(func(index int, _ ClientProvider) { switched = append(switched, index) })
+			if err := p.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.requests; i++ {
+				_, _ = p.Send(1, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x01}})
+			}
+			if index, _ := p.Active(); index != tt.wantActive {
+				t.Errorf("Active() = %v, want %v", index, tt.wantActive)
+			}
+			if tt.wantActive != 0 && (len(switched) != 1 || switched[0] != tt.wantActive) {
+				t.Errorf("OnSwitch() called with %v, want [%v]", switched, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestNewTCPFailoverProvider(t *testing.T) {
+	p := NewTCPFailoverProvider("192.168.1.10:502", "192.168.1.11:502")
+	if got := p.Endpoint(1).(*TCPClientProvider).Address; got != "192.168.1.11:502" {
+		t.Errorf("Endpoint(1) address = %v, want 192.168.1.11:502", got)
+	}
+}
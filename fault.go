@@ -0,0 +1,129 @@
+package modbus
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Fault the fault injected on the response
+type Fault byte
+
+// fault kind
+const (
+	FaultNone      Fault = iota // reply normally, only delay if set
+	FaultException              // reply the exception code, the request is not served
+	FaultDrop                   // serve the request but not reply
+	FaultCorrupt                // flip the last byte, the crc on RTU, the last data byte on TCP
+	FaultTruncate               // reply the first half of the frame
+)
+
+// FaultRule the rule of the fault injector
+type FaultRule struct {
+	SlaveIDs      []byte        // match slave ids, empty match all
+	FuncCode      byte          // match function code, 0 match all
+	Probability   float64       // the probability of the rule hit, 0 or >= 1 always hit
+	Fault         Fault         // 故障类型
+	ExceptionCode byte          // the exception code of FaultException
+	Delay         time.Duration // delay before reply
+}
+
+// match whether the rule match the request
+func (sf *FaultRule) match(slaveID, funcCode byte) bool {
+	if sf.FuncCode != 0 && sf.FuncCode != funcCode {
+		return false
+	}
+	if len(sf.SlaveIDs) == 0 {
+		return true
+	}
+	for _, id := range sf.SlaveIDs {
+		if id == slaveID {
+			return true
+		}
+	}
+	return false
+}
+
+// mangle corrupt or truncate the response frame
+func (sf *FaultRule) mangle(adu []byte) []byte {
+	switch sf.Fault {
+	case FaultCorrupt:
+		adu[len(adu)-1] ^= 0xff
+	case FaultTruncate:
+		adu = adu[:len(adu)/2]
+	}
+	return adu
+}
+
+// FaultInjector server fault injector, it is used to validate the client against misbehaving devices,
+// the rules are evaluated in order, the first hit one is applied.
+type FaultInjector struct {
+	mu    sync.Mutex
+	rules []FaultRule
+	rnd   *rand.Rand
+}
+
+// NewFaultInjector new fault injector, the seed make the probability reproducible
+func NewFaultInjector(seed int64, rules ...FaultRule) *FaultInjector {
+	return &FaultInjector{
+		rules: rules,
+		rnd:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// AddRule append the rule
+func (sf *FaultInjector) AddRule(r FaultRule) {
+	sf.mu.Lock()
+	sf.rules = append(sf.rules, r)
+	sf.mu.Unlock()
+}
+
+// ClearRules remove all the rules
+func (sf *FaultInjector) ClearRules() {
+	sf.mu.Lock()
+	sf.rules = nil
+	sf.mu.Unlock()
+}
+
+// decide got the rule hit by the request, nil if none
+func (sf *FaultInjector) decide(slaveID, funcCode byte) *FaultRule {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	for i := range sf.rules {
+		r := &sf.rules[i]
+		if !r.match(slaveID, funcCode) {
+			continue
+		}
+		if r.Probability <= 0 || r.Probability >= 1 || sf.rnd.Float64() < r.Probability {
+			rule := *r
+			return &rule
+		}
+	}
+	return nil
+}
+
+// faultHolder atomic.Value 需要存储相同的具体类型
+type faultHolder struct {
+	*FaultInjector
+}
+
+// SetFaultInjector 设置故障注入, nil 关闭
+func (sf *serverCommon) SetFaultInjector(f *FaultInjector) {
+	sf.faults.Store(faultHolder{f})
+}
+
+// fault got the fault rule hit by the request, nil if none
+func (sf *serverCommon) fault(slaveID, funcCode byte) *FaultRule {
+	if h, ok := sf.faults.Load().(faultHolder); ok && h.FaultInjector != nil {
+		return h.decide(slaveID, funcCode)
+	}
+	return nil
+}
+
+// serveWithFault serve the request, unless the fault reply a exception
+func (sf *serverCommon) serveWithFault(fault *FaultRule, req *ServerRequest) ([]byte, error) {
+	if fault != nil && fault.Fault == FaultException {
+		return nil, &ExceptionError{ExceptionCode: fault.ExceptionCode}
+	}
+	return sf.serve(req)
+}
@@ -0,0 +1,90 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector_TCP(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10))
+	mbSrv.SetFaultInjector(NewFaultInjector(1,
+		FaultRule{FuncCode: FuncCodeReadHoldingRegisters, Fault: FaultException, ExceptionCode: ExceptionCodeServerDeviceBusy},
+		FaultRule{FuncCode: FuncCodeReadInputRegisters, Fault: FaultDrop},
+		FaultRule{FuncCode: FuncCodeWriteSingleRegister, Fault: FaultCorrupt},
+		FaultRule{FuncCode: FuncCodeReadCoils, Fault: FaultTruncate},
+		FaultRule{FuncCode: FuncCodeReadDiscreteInputs, Delay: 50 * time.Millisecond},
+	))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	p := NewTCPClientProvider(listen.Addr().String())
+	p.Timeout = 200 * time.Millisecond
+	p.SetAutoReconnect(0)
+	mbCli := NewClient(p)
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	if _, err = mbCli.ReadHoldingRegisters(testslaveID1, 0, 1); !IsServerDeviceBusy(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want server device busy", err)
+	}
+	start := time.Now()
+	if _, err = mbCli.ReadDiscreteInputs(testslaveID1, 0, 1); err != nil || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("ReadDiscreteInputs() error = %v, elapsed %v, want delayed 50ms", err, time.Since(start))
+	}
+	if err = mbCli.WriteSingleRegister(testslaveID1, 0, 0x1234); err == nil {
+		t.Errorf("WriteSingleRegister() corrupted error = nil, want error")
+	}
+	if _, err = mbCli.ReadInputRegisters(testslaveID1, 0, 1); err == nil {
+		t.Errorf("ReadInputRegisters() dropped error = nil, want timeout")
+	}
+	if _, err = mbCli.ReadCoils(testslaveID1, 0, 1); err == nil {
+		t.Errorf("ReadCoils() truncated error = nil, want error")
+	}
+}
+
+func TestFaultInjector_RTUCorrupt(t *testing.T) {
+	reqReader, reqWriter := io.Pipe()
+	rspReader, rspWriter := io.Pipe()
+	srv := NewRTUServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	srv.SetFaultInjector(NewFaultInjector(1, FaultRule{SlaveIDs: []byte{1}, Fault: FaultCorrupt}))
+	go srv.Serve(pipePort{reqReader, rspWriter})
+	defer srv.Close()
+
+	go reqWriter.Write(rtuFrame([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}))
+	want := rtuFrame([]byte{0x01, 0x03, 0x02, 0x00, 0x00})
+	want[len(want)-1] ^= 0xff
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(rspReader, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response = % x, want % x", got, want)
+	}
+}
+
+func TestFaultInjector_probability(t *testing.T) {
+	f := NewFaultInjector(42, FaultRule{SlaveIDs: []byte{2}, Probability: 0.5, Fault: FaultDrop})
+	hits := 0
+	for i := 0; i < 1000; i++ {
+		if f.decide(2, FuncCodeReadCoils) != nil {
+			hits++
+		}
+	}
+	if hits < 400 || hits > 600 {
+		t.Errorf("hits = %v, want about 500", hits)
+	}
+	if f.decide(1, FuncCodeReadCoils) != nil {
+		t.Errorf("decide() not matched slave hit")
+	}
+}
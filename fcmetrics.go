@@ -0,0 +1,151 @@
+package modbus
+
+import (
+	"sort"
+	"sync"
+)
+
+// FuncCodeStat 从机地址和功能码的请求统计
+type FuncCodeStat struct {
+	SlaveID    byte            // 从机地址
+	FuncCode   byte            // 功能码
+	Requests   uint64          // 请求数
+	Exceptions map[byte]uint64 // 异常码 -> 次数
+	BytesIn    uint64          // 请求pdu字节数
+	BytesOut   uint64          // 响应pdu字节数
+}
+
+// fcKey 统计的键
+type fcKey struct {
+	slaveID, funcCode byte
+}
+
+// FuncCodeMetrics 按从机地址和功能码统计请求, 实现 ServerMetrics,
+// 用于报告上位机实际请求了哪些数据, 需要同时接入其它监控时见 MultiMetrics
+type FuncCodeMetrics struct {
+	mu    sync.Mutex
+	conns int
+	stats map[fcKey]*FuncCodeStat
+}
+
+var _ ServerMetrics = (*FuncCodeMetrics)(nil)
+
+// NewFuncCodeMetrics 创建功能码统计
+func NewFuncCodeMetrics() *FuncCodeMetrics {
+	return &FuncCodeMetrics{stats: make(map[fcKey]*FuncCodeStat)}
+}
+
+// ConnectionOpened implements ServerMetrics
+func (sf *FuncCodeMetrics) ConnectionOpened() {
+	sf.mu.Lock()
+	sf.conns++
+	sf.mu.Unlock()
+}
+
+// ConnectionClosed implements ServerMetrics
+func (sf *FuncCodeMetrics) ConnectionClosed() {
+	sf.mu.Lock()
+	sf.conns--
+	sf.mu.Unlock()
+}
+
+// RequestHandled implements ServerMetrics
+func (sf *FuncCodeMetrics) RequestHandled(stat RequestStat) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	key := fcKey{stat.SlaveID, stat.FuncCode}
+	s, ok := sf.stats[key]
+	if !ok {
+		s = &FuncCodeStat{SlaveID: stat.SlaveID, FuncCode: stat.FuncCode}
+		sf.stats[key] = s
+	}
+	s.Requests++
+	s.BytesIn += uint64(stat.RequestSize)
+	s.BytesOut += uint64(stat.ResponseSize)
+	if stat.ExceptionCode != 0 {
+		if s.Exceptions == nil {
+			s.Exceptions = make(map[byte]uint64)
+		}
+		s.Exceptions[stat.ExceptionCode]++
+	}
+}
+
+// Connections 当前的连接数
+func (sf *FuncCodeMetrics) Connections() int {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.conns
+}
+
+// Stats 统计的快照, 按从机地址和功能码排序
+func (sf *FuncCodeMetrics) Stats() []FuncCodeStat {
+	sf.mu.Lock()
+	list := make([]FuncCodeStat, 0, len(sf.stats))
+	for _, s := range sf.stats {
+		list = append(list, s.clone())
+	}
+	sf.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].SlaveID != list[j].SlaveID {
+			return list[i].SlaveID < list[j].SlaveID
+		}
+		return list[i].FuncCode < list[j].FuncCode
+	})
+	return list
+}
+
+// Stat 从机地址和功能码的统计, ok 为false表示没有请求
+func (sf *FuncCodeMetrics) Stat(slaveID, funcCode byte) (FuncCodeStat, bool) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	s, ok := sf.stats[fcKey{slaveID, funcCode}]
+	if !ok {
+		return FuncCodeStat{}, false
+	}
+	return s.clone(), true
+}
+
+// clone 复制统计, 异常码统计不共享
+func (sf *FuncCodeStat) clone() FuncCodeStat {
+	v := *sf
+	if sf.Exceptions != nil {
+		v.Exceptions = make(map[byte]uint64, len(sf.Exceptions))
+		for k, n := range sf.Exceptions {
+			v.Exceptions[k] = n
+		}
+	}
+	return v
+}
+
+// Reset 清除请求统计, 连接数不受影响
+func (sf *FuncCodeMetrics) Reset() {
+	sf.mu.Lock()
+	sf.stats = make(map[fcKey]*FuncCodeStat)
+	sf.mu.Unlock()
+}
+
+// multiMetrics 多个统计接口
+type multiMetrics []ServerMetrics
+
+// MultiMetrics 组合多个统计接口, 依次调用
+func MultiMetrics(ms ...ServerMetrics) ServerMetrics {
+	return multiMetrics(ms)
+}
+
+func (sf multiMetrics) ConnectionOpened() {
+	for _, m := range sf {
+		m.ConnectionOpened()
+	}
+}
+
+func (sf multiMetrics) ConnectionClosed() {
+	for _, m := range sf {
+		m.ConnectionClosed()
+	}
+}
+
+func (sf multiMetrics) RequestHandled(stat RequestStat) {
+	for _, m := range sf {
+		m.RequestHandled(stat)
+	}
+}
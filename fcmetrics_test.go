@@ -0,0 +1,65 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuncCodeMetrics(t *testing.T) {
+	m := NewFuncCodeMetrics()
+	other := &testMetrics{}
+	multi := MultiMetrics(m, other)
+	multi.ConnectionOpened()
+	multi.RequestHandled(RequestStat{SlaveID: 2, FuncCode: FuncCodeReadHoldingRegisters, RequestSize: 5, ResponseSize: 6})
+	multi.RequestHandled(RequestStat{SlaveID: 1, FuncCode: FuncCodeReadCoils, RequestSize: 5, ResponseSize: 3})
+	multi.RequestHandled(RequestStat{SlaveID: 2, FuncCode: FuncCodeReadHoldingRegisters, RequestSize: 5, ResponseSize: 2,
+		ExceptionCode: ExceptionCodeIllegalDataAddress})
+
+	want := []FuncCodeStat{
+		{SlaveID: 1, FuncCode: FuncCodeReadCoils, Requests: 1, BytesIn: 5, BytesOut: 3},
+		{SlaveID: 2, FuncCode: FuncCodeReadHoldingRegisters, Requests: 2, BytesIn: 10, BytesOut: 8,
+			Exceptions: map[byte]uint64{ExceptionCodeIllegalDataAddress: 1}},
+	}
+	if got := m.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	s, ok := m.Stat(2, FuncCodeReadHoldingRegisters)
+	if !ok || !reflect.DeepEqual(s, want[1]) {
+		t.Errorf("Stat() = %+v, %v, want %+v", s, ok, want[1])
+	}
+	s.Exceptions[ExceptionCodeIllegalDataAddress] = 9
+	if s, _ = m.Stat(2, FuncCodeReadHoldingRegisters); s.Exceptions[ExceptionCodeIllegalDataAddress] != 1 {
+		t.Errorf("Stat() must return a copy")
+	}
+	if _, ok = m.Stat(3, FuncCodeReadCoils); ok {
+		t.Errorf("Stat() not requested, want not ok")
+	}
+	if m.Connections() != 1 || other.opened != 1 || len(other.stats) != 3 {
+		t.Errorf("connections = %v, other = %+v", m.Connections(), other)
+	}
+
+	multi.ConnectionClosed()
+	m.Reset()
+	if len(m.Stats()) != 0 || m.Connections() != 0 {
+		t.Errorf("after Reset() stats = %+v connections = %v", m.Stats(), m.Connections())
+	}
+}
+
+func TestFuncCodeMetrics_Server(t *testing.T) {
+	m := NewFuncCodeMetrics()
+	srv, addr := startRateLimitServer(t, func(srv *TCPServer) {
+		srv.SetMetrics(m)
+	})
+	defer srv.Close()
+	c := connectClient(t, addr)
+	defer c.Close()
+	_, _ = c.ReadHoldingRegisters(testslaveID1, 0, 2)
+	_ = c.WriteSingleRegister(testslaveID1, 100, 1)
+
+	if s, ok := m.Stat(testslaveID1, FuncCodeReadHoldingRegisters); !ok || s.Requests != 1 || s.BytesOut != 6 {
+		t.Errorf("read holding registers stat = %+v, %v", s, ok)
+	}
+	if s, ok := m.Stat(testslaveID1, FuncCodeWriteSingleRegister); !ok || s.Exceptions[ExceptionCodeIllegalDataAddress] != 1 {
+		t.Errorf("write single register stat = %+v, %v", s, ok)
+	}
+}
@@ -0,0 +1,286 @@
+package modbus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// fileRecordReference the reference type of the file record sub-request, it must be 6
+const fileRecordReference = 6
+
+// checkFileRecord check the file number and the records range
+func checkFileRecord(file, record uint16, length, max int) error {
+	if file == 0 {
+		return fmt.Errorf("modbus: file number '%v' must not be zero", file)
+	}
+	if record > FileRecordNumberMax {
+		return fmt.Errorf("modbus: record number '%v' must be between '%v' and '%v'",
+			record, 0, FileRecordNumberMax)
+	}
+	if length < 1 || length > max {
+		return fmt.Errorf("modbus: record length '%v' must be between '%v' and '%v'", length, 1, max)
+	}
+	if int(record)+length > FileRecordNumberMax+1 {
+		return fmt.Errorf("modbus: record '%v' length '%v' exceed the file", record, length)
+	}
+	return nil
+}
+
+// ReadFileRecord read length records of the file start at record,
+// only one sub-request is sent, see FileReader for the large file area.
+// Request:
+//
+//	Function code         : 1 byte (0x14)
+//	Byte count            : 1 byte (7)
+//	Reference type        : 1 byte (6)
+//	File number           : 2 bytes
+//	Record number         : 2 bytes (0x0000 to 0x270F)
+//	Record length         : 2 bytes
+//
+// Response:
+//
+//	Function code         : 1 byte (0x14)
+//	Response data length  : 1 byte
+//	File response length  : 1 byte
+//	Reference type        : 1 byte (6)
+//	Record data           : N 2-bytes
+func (sf *client) ReadFileRecord(slaveID byte, file, record, length uint16) ([]byte, error) {
+	if slaveID < AddressMin || slaveID > AddressMax {
+		return nil, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
+			slaveID, AddressMin, AddressMax)
+	}
+	if err := checkFileRecord(file, record, int(length), ReadFileRecordLengthMax); err != nil {
+		return nil, err
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{
+		FuncCode: FuncCodeReadFileRecord,
+		Data:     append([]byte{7, fileRecordReference}, pduDataBlock(file, record, length)...),
+	})
+	size := 3 + int(length)*2
+	switch {
+	case err != nil:
+		return nil, err
+	case len(response.Data) != size:
+		return nil, fmt.Errorf("modbus: response data size '%v' does not match expected '%v'",
+			len(response.Data), size)
+	case int(response.Data[0]) != size-1:
+		return nil, fmt.Errorf("modbus: response data size '%v' does not match count '%v'",
+			size-1, response.Data[0])
+	case int(response.Data[1]) != size-2:
+		return nil, fmt.Errorf("modbus: file response length '%v' does not match expected '%v'",
+			response.Data[1], size-2)
+	case response.Data[2] != fileRecordReference:
+		return nil, fmt.Errorf("modbus: response reference type '%v' does not match expected '%v'",
+			response.Data[2], fileRecordReference)
+	}
+	return response.Data[3:], nil
+}
+
+// WriteFileRecord write the value to the records of the file start at record,
+// value is the big endian bytes of the records, only one sub-request is sent,
+// see FileWriter for the large file area.
+// Request:
+//
+//	Function code         : 1 byte (0x15)
+//	Request data length   : 1 byte
+//	Reference type        : 1 byte (6)
+//	File number           : 2 bytes
+//	Record number         : 2 bytes (0x0000 to 0x270F)
+//	Record length         : 2 bytes
+//	Record data           : N 2-bytes
+//
+// Response: echo of the request
+func (sf *client) WriteFileRecord(slaveID byte, file, record uint16, value []byte) error {
+	if slaveID < AddressMin || slaveID > AddressMax {
+		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
+			slaveID, AddressMin, AddressMax)
+	}
+	if len(value)%2 != 0 {
+		return fmt.Errorf("modbus: value size '%v' must be multiple of 2", len(value))
+	}
+	length := len(value) / 2
+	if err := checkFileRecord(file, record, length, WriteFileRecordLengthMax); err != nil {
+		return err
+	}
+	data := append([]byte{byte(7 + len(value)), fileRecordReference},
+		pduDataBlock(file, record, uint16(length))...)
+	data = append(data, value...)
+	response, err := sf.Send(slaveID, ProtocolDataUnit{
+		FuncCode: FuncCodeWriteFileRecord,
+		Data:     data,
+	})
+	switch {
+	case err != nil:
+		return err
+	case !bytes.Equal(response.Data, data):
+		return fmt.Errorf("modbus: response data '% x' does not match request '% x'", response.Data, data)
+	}
+	return nil
+}
+
+// FileReader read the records of a file area as a stream by FC20,
+// the area is split into the requests of at most ReadFileRecordLengthMax records,
+// Read return io.EOF after all records are read.
+type FileReader struct {
+	c       Client
+	slaveID byte
+	file    uint16
+	record  uint16 // the next record to read
+	remain  int    // the records not read yet
+	chunk   uint16
+	buf     []byte // the bytes read but not returned
+	err     error
+}
+
+var _ io.Reader = (*FileReader)(nil)
+
+// NewFileReader new a reader of length records of the file start at record
+func NewFileReader(c Client, slaveID byte, file, record uint16, length int) *FileReader {
+	sf := &FileReader{
+		c:       c,
+		slaveID: slaveID,
+		file:    file,
+		record:  record,
+		remain:  length,
+		chunk:   ReadFileRecordLengthMax,
+	}
+	if length > 0 {
+		sf.err = checkFileRecord(file, record, length, FileRecordNumberMax+1)
+	}
+	return sf
+}
+
+// SetChunkSize set the records of every request, 0 or bigger than the protocol limit use the limit,
+// some devices accept less than the limit in a request.
+func (sf *FileReader) SetChunkSize(n uint16) {
+	if n == 0 || n > ReadFileRecordLengthMax {
+		n = ReadFileRecordLengthMax
+	}
+	sf.chunk = n
+}
+
+// Read implements io.Reader, the error of the request is returned and kept.
+func (sf *FileReader) Read(p []byte) (int, error) {
+	for len(sf.buf) == 0 {
+		if sf.err != nil {
+			return 0, sf.err
+		}
+		if sf.remain <= 0 {
+			return 0, io.EOF
+		}
+		n := sf.chunk
+		if sf.remain < int(n) {
+			n = uint16(sf.remain)
+		}
+		b, err := sf.c.ReadFileRecord(sf.slaveID, sf.file, sf.record, n)
+		if err != nil {
+			sf.err = err
+			return 0, err
+		}
+		sf.buf = b
+		sf.record += n
+		sf.remain -= int(n)
+	}
+	n := copy(p, sf.buf)
+	sf.buf = sf.buf[n:]
+	return n, nil
+}
+
+// FileWriter write the records of a file area as a stream by FC21,
+// the bytes are buffered and written in the requests of at most WriteFileRecordLengthMax records,
+// Close or Flush must be called to write the rest.
+type FileWriter struct {
+	c       Client
+	slaveID byte
+	file    uint16
+	record  uint16 // the next record to write
+	chunk   uint16
+	buf     []byte // the bytes not written yet
+	err     error
+}
+
+var _ io.WriteCloser = (*FileWriter)(nil)
+
+// NewFileWriter new a writer of the file start at record
+func NewFileWriter(c Client, slaveID byte, file, record uint16) *FileWriter {
+	return &FileWriter{
+		c:       c,
+		slaveID: slaveID,
+		file:    file,
+		record:  record,
+		chunk:   WriteFileRecordLengthMax,
+	}
+}
+
+// SetChunkSize set the records of every request, 0 or bigger than the protocol limit use the limit,
+// some devices accept less than the limit in a request.
+func (sf *FileWriter) SetChunkSize(n uint16) {
+	if n == 0 || n > WriteFileRecordLengthMax {
+		n = WriteFileRecordLengthMax
+	}
+	sf.chunk = n
+}
+
+// Write implements io.Writer, the full chunks are written immediately,
+// the error of the request is returned and kept.
+func (sf *FileWriter) Write(p []byte) (int, error) {
+	if sf.err != nil {
+		return 0, sf.err
+	}
+	sf.buf = append(sf.buf, p...)
+	for size := int(sf.chunk) * 2; len(sf.buf) >= size; {
+		if err := sf.writeChunk(sf.buf[:size]); err != nil {
+			n := len(p) - len(sf.buf)
+			if n < 0 {
+				n = 0
+			}
+			sf.buf = sf.buf[:0]
+			return n, err
+		}
+		sf.buf = sf.buf[size:]
+	}
+	return len(p), nil
+}
+
+// Flush write the buffered bytes, an odd byte left is padded with zero to a record.
+func (sf *FileWriter) Flush() error {
+	if sf.err != nil || len(sf.buf) == 0 {
+		return sf.err
+	}
+	if len(sf.buf)%2 != 0 {
+		sf.buf = append(sf.buf, 0)
+	}
+	for len(sf.buf) > 0 {
+		n := int(sf.chunk) * 2
+		if n > len(sf.buf) {
+			n = len(sf.buf)
+		}
+		if err := sf.writeChunk(sf.buf[:n]); err != nil {
+			sf.buf = sf.buf[:0]
+			return err
+		}
+		sf.buf = sf.buf[n:]
+	}
+	return nil
+}
+
+// Close implements io.Closer, it flush the buffered bytes, the client is not closed.
+func (sf *FileWriter) Close() error {
+	return sf.Flush()
+}
+
+// writeChunk write the records at the next record
+func (sf *FileWriter) writeChunk(b []byte) error {
+	length := uint16(len(b) / 2)
+	if int(sf.record)+int(length) > FileRecordNumberMax+1 {
+		sf.err = fmt.Errorf("modbus: record '%v' length '%v' exceed the file", sf.record, length)
+		return sf.err
+	}
+	if err := sf.c.WriteFileRecord(sf.slaveID, sf.file, sf.record, b); err != nil {
+		sf.err = err
+		return err
+	}
+	sf.record += length
+	return nil
+}
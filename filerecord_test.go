@@ -0,0 +1,226 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// fileDevice a provider serve FC20/21 on the records of one file
+type fileDevice struct {
+	provider
+	records  [FileRecordNumberMax + 1]uint16
+	requests int
+	maxLen   int    // the max record length of the requests
+	failAt   int    // fail the request, 0 never
+	limit    uint16 // reply illegal data value if the length exceed, 0 no limit
+}
+
+func (sf *fileDevice) Send(_ byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	sf.requests++
+	if sf.requests == sf.failAt {
+		return ProtocolDataUnit{}, errors.New("error")
+	}
+	data := request.Data
+	record, length := binary.BigEndian.Uint16(data[4:]), binary.BigEndian.Uint16(data[6:])
+	if int(length) > sf.maxLen {
+		sf.maxLen = int(length)
+	}
+	if sf.limit > 0 && length > sf.limit {
+		return ProtocolDataUnit{}, &ExceptionError{request.FuncCode, ExceptionCodeIllegalDataValue}
+	}
+	if request.FuncCode == FuncCodeWriteFileRecord {
+		for i := 0; i < int(length); i++ {
+			sf.records[int(record)+i] = binary.BigEndian.Uint16(data[8+i*2:])
+		}
+		return request, nil
+	}
+	rsp := []byte{byte(2 + length*2), byte(1 + length*2), fileRecordReference}
+	for i := 0; i < int(length); i++ {
+		rsp = append(rsp, pduDataBlock(sf.records[int(record)+i])...)
+	}
+	return ProtocolDataUnit{request.FuncCode, rsp}, nil
+}
+
+func Test_client_ReadFileRecord(t *testing.T) {
+	type args struct {
+		slaveID byte
+		file    uint16
+		record  uint16
+		length  uint16
+	}
+	tests := []struct {
+		name    string
+		provide ClientProvider
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{"slaveid不在范围1-247", &provider{}, args{248, 1, 0, 1}, nil, true},
+		{"file不能为0", &provider{}, args{1, 0, 0, 1}, nil, true},
+		{"record超范围", &provider{}, args{1, 1, 10000, 1}, nil, true},
+		{"length超范围", &provider{}, args{1, 1, 0, 125}, nil, true},
+		{"超出文件", &provider{}, args{1, 1, 9999, 2}, nil, true},
+		{"返回error", &provider{err: errors.New("error")}, args{1, 1, 0, 1}, nil, true},
+		{"返回数据长度不符", &provider{data: []byte{0x04, 0x03, 0x06, 0x00}}, args{1, 1, 0, 1}, nil, true},
+		{"响应长度不符", &provider{data: []byte{0x03, 0x03, 0x06, 0x00, 0x01}}, args{1, 1, 0, 1}, nil, true},
+		{"文件响应长度不符", &provider{data: []byte{0x04, 0x02, 0x06, 0x00, 0x01}}, args{1, 1, 0, 1}, nil, true},
+		{"参考类型不符", &provider{data: []byte{0x04, 0x03, 0x07, 0x00, 0x01}}, args{1, 1, 0, 1}, nil, true},
+		{"正确", &provider{data: []byte{0x04, 0x03, 0x06, 0x00, 0x01}}, args{1, 1, 0, 1}, []byte{0x00, 0x01}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			this := &client{
+				ClientProvider: tt.provide,
+			}
+			got, err := this.ReadFileRecord(tt.args.slaveID, tt.args.file, tt.args.record, tt.args.length)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("client.ReadFileRecord() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("client.ReadFileRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_WriteFileRecord(t *testing.T) {
+	type args struct {
+		slaveID byte
+		file    uint16
+		record  uint16
+		value   []byte
+	}
+	tests := []struct {
+		name    string
+		provide ClientProvider
+		args    args
+		wantErr bool
+	}{
+		{"slaveid不在范围1-247", &provider{}, args{248, 1, 0, []byte{0x00, 0x01}}, true},
+		{"value长度为奇数", &provider{}, args{1, 1, 0, []byte{0x00}}, true},
+		{"value为空", &provider{}, args{1, 1, 0, nil}, true},
+		{"length超范围", &provider{}, args{1, 1, 0, make([]byte, 246)}, true},
+		{"返回error", &provider{err: errors.New("error")}, args{1, 1, 0, []byte{0x00, 0x01}}, true},
+		{"响应不是回显", &provider{data: []byte{0x09, 0x06}}, args{1, 1, 0, []byte{0x00, 0x01}}, true},
+		{"正确", &provider{data: []byte{0x09, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01}},
+			args{1, 1, 0, []byte{0x00, 0x01}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			this := &client{
+				ClientProvider: tt.provide,
+			}
+			err := this.WriteFileRecord(tt.args.slaveID, tt.args.file, tt.args.record, tt.args.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("client.WriteFileRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileStream(t *testing.T) {
+	dev := &fileDevice{}
+	c := NewClient(dev)
+	want := make([]byte, 1001)
+	for i := range want {
+		want[i] = byte(i * 7)
+	}
+
+	w := NewFileWriter(c, 1, 1, 100)
+	for b := want; len(b) > 0; {
+		n := 37
+		if n > len(b) {
+			n = len(b)
+		}
+		if _, err := w.Write(b[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		b = b[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if dev.maxLen != WriteFileRecordLengthMax {
+		t.Errorf("max write length = %v, want %v", dev.maxLen, WriteFileRecordLengthMax)
+	}
+	if dev.records[100+500] != uint16(want[1000])<<8 {
+		t.Errorf("odd byte record = %#04x, want padded %#04x", dev.records[600], uint16(want[1000])<<8)
+	}
+
+	dev.maxLen = 0
+	r := NewFileReader(c, 1, 1, 100, 501)
+	got, err := readAll(r)
+	if err != nil {
+		t.Fatalf("read error = %v", err)
+	}
+	if !bytes.Equal(got[:len(want)], want) || len(got) != 1002 {
+		t.Errorf("read %v bytes, does not match the written", len(got))
+	}
+	if dev.maxLen != ReadFileRecordLengthMax {
+		t.Errorf("max read length = %v, want %v", dev.maxLen, ReadFileRecordLengthMax)
+	}
+}
+
+func TestFileStream_ChunkSize(t *testing.T) {
+	dev := &fileDevice{limit: 10}
+	c := NewClient(dev)
+
+	if _, err := readAll(NewFileReader(c, 1, 1, 0, 30)); !IsIllegalDataValue(err) {
+		t.Errorf("read without chunk size error = %v, want illegal data value", err)
+	}
+	r := NewFileReader(c, 1, 1, 0, 30)
+	r.SetChunkSize(10)
+	got, err := readAll(r)
+	if err != nil || len(got) != 60 {
+		t.Errorf("read with chunk size = %v bytes, error = %v", len(got), err)
+	}
+
+	w := NewFileWriter(c, 1, 1, 0)
+	w.SetChunkSize(10)
+	if _, err = w.Write(make([]byte, 50)); err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestFileStream_Error(t *testing.T) {
+	dev := &fileDevice{failAt: 2}
+	c := NewClient(dev)
+	w := NewFileWriter(c, 1, 1, 0)
+	n, err := w.Write(make([]byte, WriteFileRecordLengthMax*2*3))
+	if err == nil || n != WriteFileRecordLengthMax*2 {
+		t.Errorf("Write() = %v, %v, want %v and error", n, err, WriteFileRecordLengthMax*2)
+	}
+	if _, err = w.Write([]byte{1, 2}); err == nil {
+		t.Errorf("Write() after failed, want the kept error")
+	}
+
+	w = NewFileWriter(c, 1, 1, FileRecordNumberMax)
+	if _, err = w.Write([]byte{1, 2, 3, 4}); err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+	if err = w.Flush(); err == nil {
+		t.Errorf("Flush() exceed the file, want error")
+	}
+
+	if _, err = NewFileReader(c, 1, 1, 9990, 11).Read(make([]byte, 2)); err == nil {
+		t.Errorf("Read() exceed the file, want error")
+	}
+	if n, err = NewFileReader(c, 1, 1, 0, 0).Read(make([]byte, 2)); n != 0 || err != io.EOF {
+		t.Errorf("Read() empty area = %v, %v, want io.EOF", n, err)
+	}
+}
+
+// readAll read r until io.EOF
+func readAll(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
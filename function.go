@@ -2,8 +2,9 @@ package modbus
 
 import (
 	"encoding/binary"
-	"errors"
+	"net"
 	"sync"
+	"sync/atomic"
 )
 
 // pdu数据域 各功能码要求的最小长度
@@ -19,9 +20,35 @@ const (
 // data 仅pdu数据域 不含功能码, return pdu 数据域,不含功能码
 type FunctionHandler func(reg *NodeRegister, data []byte) ([]byte, error)
 
+// ServerRequest 服务端收到的请求
+type ServerRequest struct {
+	SlaveID    byte     // 从机地址
+	FuncCode   byte     // 功能码
+	Data       []byte   // pdu数据域,不含功能码,仅在处理期间有效,需保留请复制
+	RemoteAddr net.Addr // 远端地址
+}
+
+// ServerHandler 请求处理, return pdu 数据域,不含功能码,
+// 返回 ErrSlaveNotExist 时不回复, 返回 *ExceptionError 时回复异常码
+type ServerHandler func(req *ServerRequest) ([]byte, error)
+
+// ServerMiddleware 服务端pdu处理中间件,可用于审计,限流,协议一致性检查等
+type ServerMiddleware func(next ServerHandler) ServerHandler
+
 type serverCommon struct {
-	node     sync.Map
-	function map[uint8]FunctionHandler
+	node        sync.Map
+	function    map[uint8]FunctionHandler
+	mu          sync.Mutex
+	middlewares []ServerMiddleware
+	handler     atomic.Value // ServerHandler chain, nil if no middleware
+	metrics     atomic.Value // metricsHolder, nil if not set
+	downstream  atomic.Value // downstreamHolder, nil if not set
+	faults      atomic.Value // faultHolder, nil if not set
+	latencies   sync.Map     // slaveID -> Latency
+	aliases     sync.Map     // unit id -> slaveID
+	unitModes   sync.Map     // unit id -> unitIDMode
+	disabled    sync.Map     // funcCode -> struct{}, the disabled function codes
+	authorizer  atomic.Value // authorizerHolder, nil if not set
 }
 
 func newServerCommon() *serverCommon {
@@ -66,7 +93,7 @@ func (sf *serverCommon) DeleteAllNode() {
 func (sf *serverCommon) GetNode(slaveID byte) (*NodeRegister, error) {
 	v, ok := sf.node.Load(slaveID)
 	if !ok {
-		return nil, errors.New("slaveID not exist")
+		return nil, ErrSlaveNotExist
 	}
 	return v.(*NodeRegister), nil
 }
@@ -95,19 +122,58 @@ func (sf *serverCommon) RegisterFunctionHandler(funcCode uint8, function Functio
 	}
 }
 
+// Use 增加中间件,先增加的在最外层
+func (sf *serverCommon) Use(mws ...ServerMiddleware) {
+	sf.mu.Lock()
+	sf.middlewares = append(sf.middlewares, mws...)
+	h := ServerHandler(sf.dispatch)
+	for i := len(sf.middlewares) - 1; i >= 0; i-- {
+		h = sf.middlewares[i](h)
+	}
+	sf.handler.Store(h)
+	sf.mu.Unlock()
+}
+
+// serve 经过中间件处理请求
+func (sf *serverCommon) serve(req *ServerRequest) ([]byte, error) {
+	if h, ok := sf.handler.Load().(ServerHandler); ok {
+		return h(req)
+	}
+	return sf.dispatch(req)
+}
+
+// dispatch 查找节点和功能码对应的处理函数
+func (sf *serverCommon) dispatch(req *ServerRequest) ([]byte, error) {
+	if m, ok := sf.unitModes.Load(req.SlaveID); ok {
+		return sf.dispatchUnitMode(m.(unitIDMode), req)
+	}
+	node, err := sf.lookupNode(req.SlaveID)
+	if err != nil {
+		if d, ok := sf.downstream.Load().(downstreamHolder); ok && d.provider != nil {
+			return forwardRequest(d.provider, req.SlaveID, req, *d.log)
+		}
+		return nil, err
+	}
+	handle, ok := sf.functionHandler(req.FuncCode)
+	if !ok {
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}
+	}
+	return handle(node, req.Data)
+}
+
 // readBits 读位寄存器
 func readBits(reg *NodeRegister, data []byte, isCoil bool) ([]byte, error) {
 	var value []byte
 	var err error
 
 	if len(data) != FuncReadMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	address := binary.BigEndian.Uint16(data)
 	quality := binary.BigEndian.Uint16(data[2:])
 	if quality < ReadBitsQuantityMin || quality > ReadBitsQuantityMax {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 	if isCoil {
 		value, err = reg.ReadCoils(address, quality)
@@ -153,13 +219,13 @@ func funcReadCoils(reg *NodeRegister, data []byte) ([]byte, error) {
 //  Value                 : 2 byte  0xff00 or 0x0000
 func funcWriteSingleCoil(reg *NodeRegister, data []byte) ([]byte, error) {
 	if len(data) != FuncWriteMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	address := binary.BigEndian.Uint16(data)
 	newValue := binary.BigEndian.Uint16(data[2:])
 	if !(newValue == 0xFF00 || newValue == 0x0000) {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 
 	}
 	b := byte(0)
@@ -181,7 +247,7 @@ func funcWriteSingleCoil(reg *NodeRegister, data []byte) ([]byte, error) {
 //  Quantity              : 2 byte
 func funcWriteMultiCoils(reg *NodeRegister, data []byte) ([]byte, error) {
 	if len(data) < FuncWriteMultiMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	address := binary.BigEndian.Uint16(data)
@@ -189,7 +255,7 @@ func funcWriteMultiCoils(reg *NodeRegister, data []byte) ([]byte, error) {
 	byteCnt := data[4]
 	if quality < WriteBitsQuantityMin || quality > WriteBitsQuantityMax ||
 		byteCnt != byte((quality+7)/8) {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 	err := reg.WriteCoils(address, quality, data[5:])
 	return data[:4], err
@@ -201,13 +267,13 @@ func readRegisters(reg *NodeRegister, data []byte, isHolding bool) ([]byte, erro
 	var value []byte
 
 	if len(data) != FuncReadMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	address := binary.BigEndian.Uint16(data)
 	quality := binary.BigEndian.Uint16(data[2:])
 	if quality > ReadRegQuantityMax || quality < ReadRegQuantityMin {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	if isHolding {
@@ -255,7 +321,7 @@ func funcReadHoldingRegisters(reg *NodeRegister, data []byte) ([]byte, error) {
 //  Value               : 2 byte
 func funcWriteSingleRegister(reg *NodeRegister, data []byte) ([]byte, error) {
 	if len(data) != FuncWriteMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	address := binary.BigEndian.Uint16(data)
@@ -274,7 +340,7 @@ func funcWriteSingleRegister(reg *NodeRegister, data []byte) ([]byte, error) {
 //  Quantity              : 2 byte
 func funcWriteMultiHoldingRegisters(reg *NodeRegister, data []byte) ([]byte, error) {
 	if len(data) < FuncWriteMultiMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	address := binary.BigEndian.Uint16(data)
@@ -282,7 +348,7 @@ func funcWriteMultiHoldingRegisters(reg *NodeRegister, data []byte) ([]byte, err
 	byteCnt := data[4]
 	if count < WriteRegQuantityMin || count > WriteRegQuantityMax ||
 		byteCnt != uint8(count*2) {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	err := reg.WriteHoldingsBytes(address, count, data[5:])
@@ -306,7 +372,7 @@ func funcWriteMultiHoldingRegisters(reg *NodeRegister, data []byte) ([]byte, err
 //  Value                 : (Quantity read)*2 byte
 func funcReadWriteMultiHoldingRegisters(reg *NodeRegister, data []byte) ([]byte, error) {
 	if len(data) < FuncReadWriteMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	readAddress := binary.BigEndian.Uint16(data)
@@ -317,13 +383,10 @@ func funcReadWriteMultiHoldingRegisters(reg *NodeRegister, data []byte) ([]byte,
 	if readCount < ReadWriteOnReadRegQuantityMin || readCount > ReadWriteOnReadRegQuantityMax ||
 		WriteCount < ReadWriteOnWriteRegQuantityMin || WriteCount > ReadWriteOnWriteRegQuantityMax ||
 		writeByteCnt != uint8(WriteCount*2) {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
-	if err := reg.WriteHoldingsBytes(writeAddress, WriteCount, data[9:]); err != nil {
-		return nil, err
-	}
-	value, err := reg.ReadHoldingsBytes(readAddress, readCount)
+	value, err := reg.WriteReadHoldingsBytes(writeAddress, WriteCount, data[9:], readAddress, readCount)
 	if err != nil {
 		return nil, err
 	}
@@ -344,7 +407,7 @@ func funcReadWriteMultiHoldingRegisters(reg *NodeRegister, data []byte) ([]byte,
 //  Or_mask               : 2 byte
 func funcMaskWriteRegisters(reg *NodeRegister, data []byte) ([]byte, error) {
 	if len(data) != FuncMaskWriteMinSize {
-		return nil, &ExceptionError{ExceptionCodeIllegalDataValue}
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataValue}
 	}
 
 	referAddress := binary.BigEndian.Uint16(data)
@@ -318,3 +318,48 @@ func Test_funcMaskWriteRegisters(t *testing.T) {
 		})
 	}
 }
+
+func Test_serverCommon_Use(t *testing.T) {
+	sc := newServerCommon()
+	sc.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+
+	var seen []byte
+	sc.Use(func(next ServerHandler) ServerHandler {
+		return func(req *ServerRequest) ([]byte, error) {
+			seen = append(seen, req.FuncCode)
+			if req.FuncCode == FuncCodeWriteSingleRegister {
+				return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}
+			}
+			return next(req)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		req     ServerRequest
+		want    []byte
+		wantErr error
+	}{
+		{"正常读", ServerRequest{SlaveID: 1, FuncCode: FuncCodeReadHoldingRegisters, Data: []byte{0, 0, 0, 1}},
+			[]byte{0x02, 0x00, 0x00}, nil},
+		{"中间件拒绝写", ServerRequest{SlaveID: 1, FuncCode: FuncCodeWriteSingleRegister, Data: []byte{0, 0, 0, 1}},
+			nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}},
+		{"从机不存在", ServerRequest{SlaveID: 2, FuncCode: FuncCodeReadHoldingRegisters, Data: []byte{0, 0, 0, 1}},
+			nil, ErrSlaveNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sc.serve(&tt.req)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("serve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("serve() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if want := []byte{FuncCodeReadHoldingRegisters, FuncCodeWriteSingleRegister, FuncCodeReadHoldingRegisters}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("middleware seen = %v, want %v", seen, want)
+	}
+}
@@ -0,0 +1,186 @@
+package modbus
+
+import (
+	"sync"
+)
+
+// gatewayRoute the downstream of a unit id
+type gatewayRoute struct {
+	provider ClientProvider
+	slaveID  byte
+}
+
+// gatewayRouter the routes of the unit id to the downstream providers
+type gatewayRouter struct {
+	mu     sync.RWMutex
+	routes map[byte]gatewayRoute
+}
+
+// AddRoute forward the requests of the unit id to slave id of the provider,
+// the providers sharing a bus should be the same one, so the requests are serialized.
+func (sf *gatewayRouter) AddRoute(unitID byte, p ClientProvider, slaveID byte) {
+	sf.mu.Lock()
+	sf.routes[unitID] = gatewayRoute{p, slaveID}
+	sf.mu.Unlock()
+}
+
+// AddRoutes forward the requests of the unit ids to the same slave id of the provider
+func (sf *gatewayRouter) AddRoutes(p ClientProvider, unitIDs ...byte) {
+	sf.mu.Lock()
+	for _, id := range unitIDs {
+		sf.routes[id] = gatewayRoute{p, id}
+	}
+	sf.mu.Unlock()
+}
+
+// DeleteRoute delete the route of the unit id
+func (sf *gatewayRouter) DeleteRoute(unitID byte) {
+	sf.mu.Lock()
+	delete(sf.routes, unitID)
+	sf.mu.Unlock()
+}
+
+// route got the route of the unit id
+func (sf *gatewayRouter) route(unitID byte) (gatewayRoute, bool) {
+	sf.mu.RLock()
+	r, ok := sf.routes[unitID]
+	sf.mu.RUnlock()
+	return r, ok
+}
+
+// closeProviders close all the downstream providers
+func (sf *gatewayRouter) closeProviders() {
+	sf.mu.RLock()
+	closed := make(map[ClientProvider]bool)
+	for _, r := range sf.routes {
+		if !closed[r.provider] {
+			closed[r.provider] = true
+			r.provider.Close()
+		}
+	}
+	sf.mu.RUnlock()
+}
+
+// Gateway modbus tcp gateway, accept Modbus/TCP requests and forward them
+// to the downstream providers by the unit id, such as one or more serial RTU buses.
+// the unit id which has no route but has a node is served locally,
+// otherwise it reply gateway path unavailable(0x0A).
+// a downstream which can not connect reply gateway path unavailable(0x0A),
+// no response or a invalid response reply gateway target device failed to respond(0x0B),
+// exception response of the target is passed through.
+type Gateway struct {
+	*TCPServer
+	gatewayRouter
+}
+
+// NewGateway the modbus tcp gateway
+func NewGateway() *Gateway {
+	gw := &Gateway{
+		TCPServer:     NewTCPServer(),
+		gatewayRouter: gatewayRouter{routes: make(map[byte]gatewayRoute)},
+	}
+	gw.logger = newLogger("modbusGateway =>")
+	gw.Use(gw.forward)
+	return gw
+}
+
+// Close close the server, then close all the downstream providers
+func (sf *Gateway) Close() error {
+	err := sf.TCPServer.Close()
+	sf.closeProviders()
+	return err
+}
+
+// forward the server middleware route the requests
+func (sf *Gateway) forward(next ServerHandler) ServerHandler {
+	return func(req *ServerRequest) ([]byte, error) {
+		r, ok := sf.route(req.SlaveID)
+		if !ok {
+			rsp, err := next(req)
+			if err == ErrSlaveNotExist {
+				err = &ExceptionError{ExceptionCode: ExceptionCodeGatewayPathUnavailable}
+			}
+			return rsp, err
+		}
+		return forwardRequest(r.provider, r.slaveID, req, sf.logger)
+	}
+}
+
+// RTUGateway modbus rtu to tcp gateway, listen as a RTU slave on the serial port
+// and forward the requests to the downstream providers by the slave id,
+// so the legacy serial masters can reach the Modbus/TCP devices.
+// the slave id which has no route but has a node is served locally,
+// otherwise it is ignored, as the other slaves may share the bus.
+// the master's response timeout should cover the round trip of the downstream.
+type RTUGateway struct {
+	*RTUServer
+	gatewayRouter
+}
+
+// NewRTUGateway the modbus rtu to tcp gateway, it will use default /dev/ttyS0 19200 8 1 N
+func NewRTUGateway() *RTUGateway {
+	gw := &RTUGateway{
+		RTUServer:     NewRTUServer(),
+		gatewayRouter: gatewayRouter{routes: make(map[byte]gatewayRoute)},
+	}
+	gw.logger = newLogger("modbusRTUGateway =>")
+	gw.Use(gw.forward)
+	return gw
+}
+
+// Close close the server, then close all the downstream providers
+func (sf *RTUGateway) Close() error {
+	err := sf.RTUServer.Close()
+	sf.closeProviders()
+	return err
+}
+
+// forward the server middleware route the requests
+func (sf *RTUGateway) forward(next ServerHandler) ServerHandler {
+	return func(req *ServerRequest) ([]byte, error) {
+		if r, ok := sf.route(req.SlaveID); ok {
+			return forwardRequest(r.provider, r.slaveID, req, sf.logger)
+		}
+		return next(req)
+	}
+}
+
+// forwardRequest send the request to the provider, map the error to gateway exception
+func forwardRequest(p ClientProvider, slaveID byte, req *ServerRequest, log logger) ([]byte, error) {
+	if !p.IsConnected() {
+		if err := p.Connect(); err != nil {
+			log.Error("gateway connect downstream failed, %v", err)
+			return nil, &ExceptionError{ExceptionCode: ExceptionCodeGatewayPathUnavailable}
+		}
+	}
+
+	pdu := make([]byte, 0, 1+len(req.Data))
+	pdu = append(pdu, req.FuncCode)
+	pdu = append(pdu, req.Data...)
+	rsp, err := p.SendPdu(slaveID, pdu)
+	if err != nil {
+		if e, ok := AsExceptionError(err); ok {
+			return nil, &ExceptionError{ExceptionCode: e.ExceptionCode}
+		}
+		log.with("slave", slaveID).Error("gateway forward failed, %v", err)
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeGatewayTargetDeviceFailedToRespond}
+	}
+	if slaveID == AddressBroadCast { // broadcast no response
+		return nil, ErrSlaveNotExist
+	}
+	return rsp[1:], nil
+}
+
+// downstreamHolder atomic.Value 需要存储相同的具体类型
+type downstreamHolder struct {
+	provider ClientProvider
+	log      *logger
+}
+
+// SetDownstream forward the requests of the slave id which has no node to the provider,
+// such as another TCP or RTU target, so it serve some and proxy the rest.
+// the error of the downstream is replied as gateway exception, see Gateway.
+// nil disable the proxy, the provider is not closed by the server.
+func (sf *TCPServer) SetDownstream(p ClientProvider) {
+	sf.downstream.Store(downstreamHolder{p, &sf.logger})
+}
@@ -0,0 +1,141 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Gateway(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := NewTCPServer()
+	target.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	go target.Serve(listen)
+	defer target.Close()
+
+	downstream := NewTCPClientProvider(listen.Addr().String())
+	downstream.Timeout = 100 * time.Millisecond
+	gwListen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := NewGateway()
+	gw.AddRoute(5, downstream, 1)
+	gw.AddRoute(6, downstream, 2) // target has no slave 2, no response
+	gw.AddRoute(7, NewTCPClientProvider("127.0.0.1:1"), 1)
+	gw.AddNodes(NewNodeRegister(8, 0, 10, 0, 10, 0, 10, 0, 10))
+	go gw.Serve(gwListen)
+	defer gw.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(gwListen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	if err = mbCli.WriteSingleRegister(5, 1, 0x1234); err != nil {
+		t.Fatalf("WriteSingleRegister error = %v", err)
+	}
+	node, _ := target.GetNode(1)
+	if got, _ := node.ReadHoldings(1, 1); got[0] != 0x1234 {
+		t.Errorf("target holding register = %#x, want 0x1234", got[0])
+	}
+	if _, err = mbCli.ReadHoldingRegisters(5, 9, 2); !IsIllegalDataAddress(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want illegal data address", err)
+	}
+	if _, err = mbCli.ReadHoldingRegisters(6, 1, 1); !IsGatewayTargetDeviceFailedToRespond(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want gateway target device failed to respond", err)
+	}
+	if _, err = mbCli.ReadHoldingRegisters(7, 1, 1); !IsGatewayPathUnavailable(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want gateway path unavailable", err)
+	}
+	if _, err = mbCli.ReadHoldingRegisters(8, 1, 1); err != nil {
+		t.Errorf("ReadHoldingRegisters() local node error = %v", err)
+	}
+	if _, err = mbCli.ReadHoldingRegisters(9, 1, 1); !IsGatewayPathUnavailable(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want gateway path unavailable", err)
+	}
+}
+
+func Test_RTUGateway(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := NewTCPServer()
+	target.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	node, _ := target.GetNode(1)
+	node.WriteHoldings(0, []uint16{0x1234})
+	go target.Serve(listen)
+	defer target.Close()
+
+	reqReader, reqWriter := io.Pipe()
+	rspReader, rspWriter := io.Pipe()
+	gw := NewRTUGateway()
+	gw.AddRoute(3, NewTCPClientProvider(listen.Addr().String()), 1)
+	go gw.Serve(pipePort{reqReader, rspWriter})
+	defer gw.Close()
+
+	// a request to not routed slave must be ignored, then the routed one is forwarded
+	var frame []byte
+	frame = append(frame, rtuFrame([]byte{0x09, 0x03, 0x00, 0x00, 0x00, 0x01})...)
+	frame = append(frame, rtuFrame([]byte{0x03, 0x03, 0x00, 0x00, 0x00, 0x01})...)
+	go reqWriter.Write(frame)
+
+	want := rtuFrame([]byte{0x03, 0x03, 0x02, 0x12, 0x34})
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(rspReader, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response = % x, want % x", got, want)
+	}
+}
+
+func TestTCPServer_SetDownstream(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := NewTCPServer()
+	target.AddNodes(NewNodeRegister(2, 0, 10, 0, 10, 0, 10, 0, 10))
+	go target.Serve(listen)
+	defer target.Close()
+
+	downstream := NewTCPClientProvider(listen.Addr().String())
+	defer downstream.Close()
+	srvListen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	srv.SetDownstream(downstream)
+	go srv.Serve(srvListen)
+	defer srv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(srvListen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	for _, slaveID := range []byte{1, 2} {
+		if err = mbCli.WriteSingleRegister(slaveID, 3, uint16(slaveID)); err != nil {
+			t.Fatalf("WriteSingleRegister(%d) error = %v", slaveID, err)
+		}
+	}
+	local, _ := srv.GetNode(1)
+	remote, _ := target.GetNode(2)
+	if v, _ := local.ReadHoldings(3, 1); v[0] != 1 {
+		t.Errorf("local holding[3] = %v, want 1", v[0])
+	}
+	if v, _ := remote.ReadHoldings(3, 1); v[0] != 2 {
+		t.Errorf("downstream holding[3] = %v, want 2", v[0])
+	}
+}
@@ -0,0 +1,68 @@
+package modbus
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// HandshakeHandler 连接建立并调用 OnConnectHandler 后, 开始modbus服务前的握手, 如认证的挑战/应答,
+// 返回错误时关闭连接并按重连间隔重连
+type HandshakeHandler func(h *Handshake) error
+
+// Handshake 握手阶段的连接, 读写在握手超时前完成, 超时返回超时错误.
+// 读直接从连接读取, 不要读取多于握手的数据, 否则其后的modbus请求会丢失.
+type Handshake struct {
+	// Server 握手的会话
+	Server   *TCPServerSpecial
+	conn     net.Conn
+	deadline time.Time
+}
+
+// Read implements io.Reader
+func (sf *Handshake) Read(p []byte) (int, error) {
+	if err := sf.conn.SetReadDeadline(sf.deadline); err != nil {
+		return 0, err
+	}
+	return sf.conn.Read(p)
+}
+
+// ReadFull 读满 p
+func (sf *Handshake) ReadFull(p []byte) error {
+	_, err := io.ReadFull(sf, p)
+	return err
+}
+
+// Write implements io.Writer
+func (sf *Handshake) Write(p []byte) (int, error) {
+	if err := sf.conn.SetWriteDeadline(sf.deadline); err != nil {
+		return 0, err
+	}
+	return sf.conn.Write(p)
+}
+
+// Deadline 握手的截止时间
+func (sf *Handshake) Deadline() time.Time {
+	return sf.deadline
+}
+
+// SetHandshake 设置握手, 握手成功后才开始服务modbus请求, timeout 为整个握手的超时时间,
+// 0 使用 DefaultHandshakeTimeout, f 为nil时不握手
+func (sf *TCPServerSpecial) SetHandshake(timeout time.Duration, f HandshakeHandler) {
+	if timeout <= 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+	sf.handshakeTimeout, sf.handshake = timeout, f
+}
+
+// runHandshake 在连接上握手
+func (sf *TCPServerSpecial) runHandshake(conn net.Conn) error {
+	if sf.handshake == nil {
+		return nil
+	}
+	h := &Handshake{Server: sf, conn: conn, deadline: time.Now().Add(sf.handshakeTimeout)}
+	if err := sf.handshake(h); err != nil {
+		return err
+	}
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,81 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTCPServerSpecial_SetHandshake(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10)
+	_ = node.WriteHoldings(0, []uint16{0x1234, 0x5678})
+	srv := NewTCPServerSpecial()
+	srv.AddNodes(node)
+	srv.SetReconnectInterval(10 * time.Millisecond)
+	// answer the challenge with the bytes inverted
+	srv.SetHandshake(100*time.Millisecond, func(h *Handshake) error {
+		challenge := make([]byte, 4)
+		if err := h.ReadFull(challenge); err != nil {
+			return err
+		}
+		for i := range challenge {
+			challenge[i] = ^challenge[i]
+		}
+		_, err := h.Write(challenge)
+		return err
+	})
+	if err = srv.AddRemoteServer(l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// the remote never send the challenge, the handshake time out
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() silent remote error = %v, want EOF", err)
+	}
+	conn.Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if e, ok := srv.Status().LastError.(net.Error); ok && e.Timeout() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("LastError = %v, want timeout", srv.Status().LastError)
+		}
+	}
+
+	// the remote send the challenge and verify the response
+	conn, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err = conn.Write([]byte{0x00, 0x0f, 0xf0, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, 4)
+	if _, err = io.ReadFull(conn, response); err != nil || !bytes.Equal(response, []byte{0xff, 0xf0, 0x0f, 0x00}) {
+		t.Fatalf("handshake response = % x, %v", response, err)
+	}
+	defer conn.Close()
+	want := []byte{FuncCodeReadHoldingRegisters, 4, 0x12, 0x34, 0x56, 0x78}
+	if got := requestHoldings(t, conn, testslaveID1); !reflect.DeepEqual(got, want) {
+		t.Errorf("response pdu = % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,272 @@
+package modbus
+
+// 本文件提供了节点寄存器的HTTP接口, 便于测试脚本和看板直接读写仿真器的寄存器, 无需另一个modbus客户端
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// httpTables 路径中的表名
+var httpTables = map[string]Table{
+	"coils":    TableCoils,
+	"discrete": TableDiscreteInputs,
+	"input":    TableInputRegisters,
+	"holding":  TableHoldingRegisters,
+}
+
+// HTTPBlock 一个表中从 Address 开始的连续的值, 线圈和离散量为 bool, 寄存器为 uint16
+type HTTPBlock struct {
+	Address uint16          `json:"address"`
+	Values  json.RawMessage `json:"values"`
+}
+
+// HTTPNode 节点的多个表, 不存在的表为nil
+type HTTPNode struct {
+	Coils    *HTTPBlock `json:"coils,omitempty"`
+	Discrete *HTTPBlock `json:"discrete,omitempty"`
+	Input    *HTTPBlock `json:"input,omitempty"`
+	Holding  *HTTPBlock `json:"holding,omitempty"`
+}
+
+// blocks 表对应的块
+func (sf *HTTPNode) blocks() map[Table]**HTTPBlock {
+	return map[Table]**HTTPBlock{
+		TableCoils:            &sf.Coils,
+		TableDiscreteInputs:   &sf.Discrete,
+		TableInputRegisters:   &sf.Input,
+		TableHoldingRegisters: &sf.Holding,
+	}
+}
+
+// tableRange 表的起始地址和数量, 创建后不变
+func (sf *NodeRegister) tableRange(table Table) (start, quantity uint16) {
+	switch table {
+	case TableCoils:
+		return sf.coilsAddrStart, sf.coilsQuantity
+	case TableDiscreteInputs:
+		return sf.discreteAddrStart, sf.discreteQuantity
+	case TableInputRegisters:
+		return sf.inputAddrStart, uint16(len(sf.input))
+	}
+	return sf.holdingAddrStart, uint16(len(sf.holding))
+}
+
+// NewHTTPHandler 节点寄存器的HTTP接口, node 查找从机地址的节点, 如 TCPServer.GetNode,
+// 挂载到子路径时用 http.StripPrefix 去掉前缀.
+//
+//	GET /{slaveID}                                    所有表, HTTPNode
+//	PUT /{slaveID}                                    原子的写多个表, HTTPNode, 不受写保护区限制
+//	GET /{slaveID}/{table}?address=0&quantity=10      读表, HTTPBlock, 省略时为整个表
+//	PUT /{slaveID}/{table}                            写表, HTTPBlock
+//
+// table 为 coils, discrete, input 或 holding, 线圈和离散量的值为 bool, 寄存器的值为 uint16,
+// 错误时回复 {"error": "..."}
+func NewHTTPHandler(node func(slaveID byte) (*NodeRegister, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		id, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil || len(parts) > 2 {
+			httpError(w, http.StatusNotFound, errors.New("not found"))
+			return
+		}
+		n, err := node(byte(id))
+		if err != nil {
+			httpError(w, http.StatusNotFound, err)
+			return
+		}
+		if len(parts) == 1 {
+			serveHTTPNode(w, r, n)
+			return
+		}
+		table, ok := httpTables[parts[1]]
+		if !ok {
+			httpError(w, http.StatusNotFound, fmt.Errorf("unknown table '%s'", parts[1]))
+			return
+		}
+		serveHTTPTable(w, r, n, table)
+	})
+}
+
+// serveHTTPNode 读写节点的所有表
+func serveHTTPNode(w http.ResponseWriter, r *http.Request, node *NodeRegister) {
+	switch r.Method {
+	case http.MethodGet:
+		var v HTTPNode
+		for table, block := range v.blocks() {
+			start, quantity := node.tableRange(table)
+			if quantity == 0 {
+				continue
+			}
+			b, err := readHTTPBlock(node, table, start, quantity)
+			if err != nil {
+				httpError(w, http.StatusInternalServerError, err)
+				return
+			}
+			*block = b
+		}
+		writeJSON(w, http.StatusOK, v)
+	case http.MethodPut:
+		var v HTTPNode
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		err := node.Update(func(u *NodeUpdate) error {
+			for table, block := range v.blocks() {
+				if *block == nil {
+					continue
+				}
+				if err := writeHTTPBlock(u, table, *block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httpError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// serveHTTPTable 读写一个表
+func serveHTTPTable(w http.ResponseWriter, r *http.Request, node *NodeRegister, table Table) {
+	switch r.Method {
+	case http.MethodGet:
+		address, quantity := node.tableRange(table)
+		q := r.URL.Query()
+		if s := q.Get("address"); s != "" {
+			v, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				httpError(w, http.StatusBadRequest, err)
+				return
+			}
+			start, n := address, quantity
+			address, quantity = uint16(v), 1
+			if uint16(v) >= start && uint32(v) < uint32(start)+uint32(n) {
+				quantity = start + n - uint16(v)
+			}
+		}
+		if s := q.Get("quantity"); s != "" {
+			v, err := strconv.ParseUint(s, 10, 16)
+			if err != nil {
+				httpError(w, http.StatusBadRequest, err)
+				return
+			}
+			quantity = uint16(v)
+		}
+		b, err := readHTTPBlock(node, table, address, quantity)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	case http.MethodPut:
+		var b HTTPBlock
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := writeHTTPBlock(node, table, &b); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httpError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// readHTTPBlock 读表的块
+func readHTTPBlock(node *NodeRegister, table Table, address, quantity uint16) (*HTTPBlock, error) {
+	var values interface{}
+	var err error
+	switch table {
+	case TableCoils, TableDiscreteInputs:
+		var packed []byte
+		if table == TableCoils {
+			packed, err = node.ReadCoils(address, quantity)
+		} else {
+			packed, err = node.ReadDiscretes(address, quantity)
+		}
+		bits := make([]bool, quantity)
+		for i := range bits {
+			bits[i] = err == nil && packed[i/8]&(1<<uint(i%8)) != 0
+		}
+		values = bits
+	case TableInputRegisters:
+		values, err = node.ReadInputs(address, quantity)
+	default:
+		values, err = node.ReadHoldings(address, quantity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPBlock{address, raw}, nil
+}
+
+// httpBlockWriter 节点和原子更新的写方法
+type httpBlockWriter interface {
+	WriteCoils(address, quality uint16, valBuf []byte) error
+	WriteDiscretes(address, quality uint16, valBuf []byte) error
+	WriteInputs(address uint16, valBuf []uint16) error
+	WriteHoldings(address uint16, valBuf []uint16) error
+}
+
+// writeHTTPBlock 写表的块
+func writeHTTPBlock(w httpBlockWriter, table Table, b *HTTPBlock) error {
+	if table == TableCoils || table == TableDiscreteInputs {
+		var bits []bool
+		if err := json.Unmarshal(b.Values, &bits); err != nil {
+			return err
+		}
+		if len(bits) == 0 {
+			return errors.New("no values")
+		}
+		packed := make([]byte, (len(bits)+7)/8)
+		for i, on := range bits {
+			if on {
+				packed[i/8] |= 1 << uint(i%8)
+			}
+		}
+		if table == TableCoils {
+			return w.WriteCoils(b.Address, uint16(len(bits)), packed)
+		}
+		return w.WriteDiscretes(b.Address, uint16(len(bits)), packed)
+	}
+	var regs []uint16
+	if err := json.Unmarshal(b.Values, &regs); err != nil {
+		return err
+	}
+	if len(regs) == 0 {
+		return errors.New("no values")
+	}
+	if table == TableInputRegisters {
+		return w.WriteInputs(b.Address, regs)
+	}
+	return w.WriteHoldings(b.Address, regs)
+}
+
+// writeJSON 回复json
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// httpError 回复错误
+func httpError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
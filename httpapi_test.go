@@ -0,0 +1,107 @@
+package modbus
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewHTTPHandler(t *testing.T) {
+	node := NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 100, 10)
+	if err := node.Protect(TableHoldingRegisters, 108, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHTTPHandler(func(slaveID byte) (*NodeRegister, error) {
+		if slaveID != 1 {
+			return nil, errors.New("slave not exist")
+		}
+		return node, nil
+	})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{"put holding", http.MethodPut, "/1/holding", `{"address":100,"values":[1,2,3]}`, http.StatusNoContent},
+		{"put coils", http.MethodPut, "/1/coils", `{"address":2,"values":[true,false,true]}`, http.StatusNoContent},
+		{"put inputs", http.MethodPut, "/1/input", `{"address":0,"values":[7]}`, http.StatusNoContent},
+		{"put protected", http.MethodPut, "/1/holding", `{"address":107,"values":[1,2]}`, http.StatusBadRequest},
+		{"put out of range", http.MethodPut, "/1/holding", `{"address":0,"values":[1]}`, http.StatusBadRequest},
+		{"put empty", http.MethodPut, "/1/coils", `{"address":0,"values":[]}`, http.StatusBadRequest},
+		{"put bad json", http.MethodPut, "/1/coils", `{`, http.StatusBadRequest},
+		{"bulk protected ok", http.MethodPut, "/1", `{"holding":{"address":108,"values":[9]},"discrete":{"address":0,"values":[true]}}`, http.StatusNoContent},
+		{"bulk rollback", http.MethodPut, "/1", `{"input":{"address":1,"values":[5]},"holding":{"address":0,"values":[1]}}`, http.StatusBadRequest},
+		{"unknown slave", http.MethodGet, "/2", "", http.StatusNotFound},
+		{"bad slave", http.MethodGet, "/x/coils", "", http.StatusNotFound},
+		{"unknown table", http.MethodGet, "/1/foo", "", http.StatusNotFound},
+		{"bad method", http.MethodPost, "/1/coils", "", http.StatusMethodNotAllowed},
+		{"bad quantity", http.MethodGet, "/1/coils?quantity=x", "", http.StatusBadRequest},
+		{"read out of range", http.MethodGet, "/1/input?address=5&quantity=10", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.target, tt.body)
+			if w.Code != tt.code {
+				t.Fatalf("code = %v, want %v, body %s", w.Code, tt.code, w.Body)
+			}
+			if w.Code >= 400 && !strings.Contains(w.Body.String(), `"error"`) {
+				t.Errorf("body = %s, want error", w.Body)
+			}
+		})
+	}
+
+	reads := []struct {
+		target  string
+		address uint16
+		values  string
+	}{
+		{"/1/holding?address=100&quantity=3", 100, "[1,2,3]"},
+		{"/1/holding?address=108", 108, "[9,0]"},
+		{"/1/coils?address=1&quantity=4", 1, "[false,true,false,true]"},
+		{"/1/input", 0, "[7,0,0,0,0,0,0,0,0,0]"},
+	}
+	for _, tt := range reads {
+		w := do(http.MethodGet, tt.target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s code = %v, body %s", tt.target, w.Code, w.Body)
+		}
+		var b HTTPBlock
+		if err := json.Unmarshal(w.Body.Bytes(), &b); err != nil {
+			t.Fatal(err)
+		}
+		if b.Address != tt.address || string(b.Values) != tt.values {
+			t.Errorf("GET %s = %v %s, want %v %s", tt.target, b.Address, b.Values, tt.address, tt.values)
+		}
+	}
+
+	w := do(http.MethodGet, "/1", "")
+	var all HTTPNode
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if all.Coils == nil || all.Discrete == nil || all.Input == nil || all.Holding == nil {
+		t.Fatalf("GET /1 = %s, want all tables", w.Body)
+	}
+	var discrete []bool
+	if err := json.Unmarshal(all.Discrete.Values, &discrete); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(discrete[:2], []bool{true, false}) {
+		t.Errorf("discrete = %v", discrete)
+	}
+	if string(all.Input.Values) != "[7,0,0,0,0,0,0,0,0,0]" {
+		t.Errorf("input = %s, bulk write not rolled back", all.Input.Values)
+	}
+}
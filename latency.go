@@ -0,0 +1,53 @@
+package modbus
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Latency the response delay of a node, it is called per request
+type Latency func() time.Duration
+
+// FixedLatency the fixed response delay
+func FixedLatency(d time.Duration) Latency {
+	return func() time.Duration { return d }
+}
+
+// UniformLatency the response delay uniformly distributed in [min, max)
+func UniformLatency(min, max time.Duration) Latency {
+	return func() time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rand.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency the response delay normally distributed with the mean and standard deviation,
+// the negative one is 0.
+func NormalLatency(mean, stddev time.Duration) Latency {
+	return func() time.Duration {
+		d := mean + time.Duration(rand.NormFloat64()*float64(stddev))
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// SetLatency 设置节点的响应延时, 用于模拟真实设备的响应时间, nil 取消
+func (sf *serverCommon) SetLatency(slaveID byte, l Latency) {
+	if l == nil {
+		sf.latencies.Delete(slaveID)
+		return
+	}
+	sf.latencies.Store(slaveID, l)
+}
+
+// latency got the response delay of the node, 0 if not set
+func (sf *serverCommon) latency(slaveID byte) time.Duration {
+	if v, ok := sf.latencies.Load(slaveID); ok {
+		return v.(Latency)()
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package modbus
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLatency(t *testing.T) {
+	u := UniformLatency(10*time.Millisecond, 20*time.Millisecond)
+	n := NormalLatency(time.Millisecond, 10*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		if d := u(); d < 10*time.Millisecond || d >= 20*time.Millisecond {
+			t.Fatalf("UniformLatency() = %v, want in [10ms, 20ms)", d)
+		}
+		if d := n(); d < 0 {
+			t.Fatalf("NormalLatency() = %v, want not negative", d)
+		}
+	}
+	if d := FixedLatency(time.Second)(); d != time.Second {
+		t.Errorf("FixedLatency() = %v, want 1s", d)
+	}
+}
+
+func TestServer_SetLatency(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(
+		NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10),
+		NewNodeRegister(testslaveID2, 0, 10, 0, 10, 0, 10, 0, 10))
+	mbSrv.SetLatency(testslaveID1, FixedLatency(50*time.Millisecond))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	tests := []struct {
+		slaveID byte
+		min     time.Duration
+		max     time.Duration
+	}{
+		{testslaveID1, 50 * time.Millisecond, time.Second},
+		{testslaveID2, 0, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		if _, err = mbCli.ReadHoldingRegisters(tt.slaveID, 0, 1); err != nil {
+			t.Fatalf("ReadHoldingRegisters(%d) error = %v", tt.slaveID, err)
+		}
+		if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+			t.Errorf("slave %d elapsed %v, want in [%v, %v]", tt.slaveID, elapsed, tt.min, tt.max)
+		}
+	}
+	mbSrv.SetLatency(testslaveID1, nil)
+	if d := mbSrv.latency(testslaveID1); d != 0 {
+		t.Errorf("latency() after unset = %v, want 0", d)
+	}
+}
@@ -0,0 +1,88 @@
+package modbus
+
+import (
+	"sync/atomic"
+)
+
+// lifecycle 连接生命周期事件回调,
+// 回调在收发的goroutine中同步执行, 此时持有provider的锁, 不能再调用该provider的方法, 耗时的工作请另起goroutine
+type lifecycle struct {
+	onConnected    atomic.Value // func()
+	onDisconnected atomic.Value // func(error)
+	onReconnecting atomic.Value // func(int)
+}
+
+// OnConnected set the callback which called after the connection is established or reopened, nil to disable it.
+func (sf *lifecycle) OnConnected(f func()) {
+	sf.onConnected.Store(f)
+}
+
+// OnDisconnected set the callback which called after the connection is closed or lost,
+// err is nil if it is closed by Close, nil to disable it.
+func (sf *lifecycle) OnDisconnected(f func(err error)) {
+	sf.onDisconnected.Store(f)
+}
+
+// OnReconnecting set the callback which called before every reconnect attempt,
+// attempt starts from 1, nil to disable it.
+func (sf *lifecycle) OnReconnecting(f func(attempt int)) {
+	sf.onReconnecting.Store(f)
+}
+
+// emitConnected the connection is established
+func (sf *lifecycle) emitConnected() {
+	if sf == nil {
+		return
+	}
+	if f, ok := sf.onConnected.Load().(func()); ok && f != nil {
+		f()
+	}
+}
+
+// emitDisconnected the connection is closed or lost
+func (sf *lifecycle) emitDisconnected(err error) {
+	if sf == nil {
+		return
+	}
+	if f, ok := sf.onDisconnected.Load().(func(error)); ok && f != nil {
+		f(err)
+	}
+}
+
+// emitReconnecting the attempt is going to reconnect
+func (sf *lifecycle) emitReconnecting(attempt int) {
+	if sf == nil {
+		return
+	}
+	if f, ok := sf.onReconnecting.Load().(func(int)); ok && f != nil {
+		f(attempt)
+	}
+}
+
+// lifecycleNotifier the provider which support the lifecycle events
+type lifecycleNotifier interface {
+	OnConnected(f func())
+	OnDisconnected(f func(err error))
+	OnReconnecting(f func(attempt int))
+}
+
+// OnConnected set the callback of the provider, see lifecycle.OnConnected, it does nothing if not supported
+func (sf *client) OnConnected(f func()) {
+	if p, ok := sf.ClientProvider.(lifecycleNotifier); ok {
+		p.OnConnected(f)
+	}
+}
+
+// OnDisconnected set the callback of the provider, see lifecycle.OnDisconnected, it does nothing if not supported
+func (sf *client) OnDisconnected(f func(err error)) {
+	if p, ok := sf.ClientProvider.(lifecycleNotifier); ok {
+		p.OnDisconnected(f)
+	}
+}
+
+// OnReconnecting set the callback of the provider, see lifecycle.OnReconnecting, it does nothing if not supported
+func (sf *client) OnReconnecting(f func(attempt int)) {
+	if p, ok := sf.ClientProvider.(lifecycleNotifier); ok {
+		p.OnReconnecting(f)
+	}
+}
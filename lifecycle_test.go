@@ -0,0 +1,77 @@
+package modbus
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTCPClientProvider_lifecycle(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+	p := NewTCPClientProvider(ln.Addr().String())
+	p.SetAutoReconnect(1)
+	c := NewClient(p)
+	c.OnConnected(func() { record("connected") })
+	c.OnDisconnected(func(err error) {
+		if err == nil {
+			record("closed")
+		} else {
+			record("lost")
+		}
+	})
+	c.OnReconnecting(func(attempt int) { record("reconnecting") })
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// the server close the connection, the client reconnect once
+	_, _ = c.ReadHoldingRegisters(1, 0, 1)
+	c.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"connected", "lost", "reconnecting", "connected", "closed"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
+
+func TestTransportProvider_lifecycle(t *testing.T) {
+	var events []string
+	tr := &scriptTransport{err: errors.New("link lost")}
+	p := NewTransportProvider(tr, RTUCodec{})
+	p.SetAutoReconnect(1)
+	p.OnConnected(func() { events = append(events, "connected") })
+	p.OnDisconnected(func(err error) { events = append(events, "disconnected") })
+	p.OnReconnecting(func(attempt int) { events = append(events, "reconnecting") })
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = p.SendRawFrame([]byte{0x01, 0x03})
+	want := []string{"connected", "disconnected", "reconnecting", "connected"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+}
@@ -1,55 +1,86 @@
 package modbus
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"sync/atomic"
 
 	"log"
 )
 
+// LogLevel log output level
+type LogLevel uint32
+
+// log level
+const (
+	LogLevelOff   LogLevel = iota // disable log output
+	LogLevelError                 // only error
+	LogLevelDebug                 // error and debug
+)
+
 // 内部调试实现
 type logger struct {
 	provider LogProvider
-	// has log output enabled,
-	// 1: enable
-	// 0: disable
-	has uint32
+	// log output level, see LogLevel
+	level uint32
 }
 
 // newLogger new logger with prefix
 func newLogger(prefix string) logger {
 	return logger{
 		provider: defaultLogger{log.New(os.Stdout, prefix, log.LstdFlags)},
-		has:      0,
+		level:    uint32(LogLevelOff),
 	}
 }
 
 // LogMode set enable or disable log output when you has set logger
+// enable same as SetLogLevel(LogLevelDebug), disable same as SetLogLevel(LogLevelOff)
 func (sf *logger) LogMode(enable bool) {
 	if enable {
-		atomic.StoreUint32(&sf.has, 1)
+		sf.SetLogLevel(LogLevelDebug)
 	} else {
-		atomic.StoreUint32(&sf.has, 0)
+		sf.SetLogLevel(LogLevelOff)
 	}
 }
 
-// SetLogProvider overwrite log provider
+// SetLogLevel set log output level
+func (sf *logger) SetLogLevel(level LogLevel) {
+	atomic.StoreUint32(&sf.level, uint32(level))
+}
+
+// SetLogProvider overwrite log provider,
+// if it implements FieldLogProvider, the entries will be tagged with fields
+// like slave id or remote address.
 func (sf *logger) SetLogProvider(p LogProvider) {
 	if p != nil {
 		sf.provider = p
 	}
 }
 
+// with return a logger which tag the entries with key value pairs
+func (sf logger) with(keyvals ...interface{}) logger {
+	if atomic.LoadUint32(&sf.level) == uint32(LogLevelOff) {
+		return sf
+	}
+	if p, ok := sf.provider.(FieldLogProvider); ok {
+		sf.provider = p.With(keyvals...)
+	} else {
+		sf.provider = prefixLogger{sf.provider, fieldsPrefix(keyvals...)}
+	}
+	return sf
+}
+
 // Error Log ERROR level message.
 func (sf logger) Error(format string, v ...interface{}) {
-	if atomic.LoadUint32(&sf.has) == 1 {
+	if atomic.LoadUint32(&sf.level) >= uint32(LogLevelError) {
 		sf.provider.Error(format, v...)
 	}
 }
 
 // Debug Log DEBUG level message.
 func (sf logger) Debug(format string, v ...interface{}) {
-	if atomic.LoadUint32(&sf.has) == 1 {
+	if atomic.LoadUint32(&sf.level) >= uint32(LogLevelDebug) {
 		sf.provider.Debug(format, v...)
 	}
 }
@@ -71,3 +102,33 @@ func (sf defaultLogger) Error(format string, v ...interface{}) {
 func (sf defaultLogger) Debug(format string, v ...interface{}) {
 	sf.Printf("[D]: "+format, v...)
 }
+
+// prefixLogger tag the entries with fields prefix
+// for the provider which not implements FieldLogProvider
+type prefixLogger struct {
+	LogProvider
+	prefix string
+}
+
+// Error Log ERROR level message.
+func (sf prefixLogger) Error(format string, v ...interface{}) {
+	sf.LogProvider.Error(sf.prefix+format, v...)
+}
+
+// Debug Log DEBUG level message.
+func (sf prefixLogger) Debug(format string, v ...interface{}) {
+	sf.LogProvider.Debug(sf.prefix+format, v...)
+}
+
+// fieldsPrefix format key value pairs to "key=value " which safe for format
+func fieldsPrefix(keyvals ...interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "<missing>"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, "%v=%v ", keyvals[i], val)
+	}
+	return strings.Replace(b.String(), "%", "%%", -1)
+}
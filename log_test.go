@@ -0,0 +1,89 @@
+package modbus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+type recordLogger struct {
+	fields []interface{}
+	lines  *[]string
+}
+
+func (sf recordLogger) Error(format string, v ...interface{}) {
+	*sf.lines = append(*sf.lines, fmt.Sprintf("E "+format, v...))
+}
+
+func (sf recordLogger) Debug(format string, v ...interface{}) {
+	*sf.lines = append(*sf.lines, fmt.Sprintf("D "+format, v...))
+}
+
+type recordFieldLogger struct {
+	recordLogger
+}
+
+func (sf recordFieldLogger) With(keyvals ...interface{}) LogProvider {
+	return recordFieldLogger{recordLogger{append(sf.fields, keyvals...), sf.lines}}
+}
+
+func Test_logger_level(t *testing.T) {
+	var lines []string
+	l := newLogger("")
+	l.SetLogProvider(recordLogger{lines: &lines})
+
+	l.Error("e1")
+	l.Debug("d1")
+	l.SetLogLevel(LogLevelError)
+	l.Error("e2")
+	l.Debug("d2")
+	l.LogMode(true)
+	l.Error("e3")
+	l.Debug("d3")
+	l.LogMode(false)
+	l.Error("e4")
+
+	want := []string{"E e2", "E e3", "D d3"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("logger output = %v, want %v", lines, want)
+	}
+}
+
+func Test_client_SetLogLevel(t *testing.T) {
+	// the provider implements ClientProvider only, the log level is ignored
+	var bare ClientProvider = struct{ ClientProvider }{&provider{}}
+	if _, ok := bare.(LogLeveler); ok {
+		t.Fatal("bare provider should not implement LogLeveler")
+	}
+	NewClient(bare).SetLogLevel(LogLevelDebug)
+
+	// the level is passed through the wrapper
+	var lines []string
+	p := NewTCPClientProvider("127.0.0.1:502")
+	p.SetLogProvider(recordLogger{lines: &lines})
+	NewClient(NewRecordProvider(p, ioutil.Discard)).SetLogLevel(LogLevelError)
+	p.Error("e")
+	p.Debug("d")
+	if want := []string{"E e"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("logger output = %v, want %v", lines, want)
+	}
+}
+
+func Test_logger_with(t *testing.T) {
+	var lines []string
+	l := newLogger("")
+	l.SetLogProvider(recordLogger{lines: &lines})
+	l.LogMode(true)
+	l.with("slave", 1, "remote", "100%").Debug("rx %d", 2)
+	if want := []string{"D slave=1 remote=100% rx 2"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("logger output = %v, want %v", lines, want)
+	}
+
+	l.SetLogProvider(recordFieldLogger{recordLogger{lines: &lines}})
+	fl := l.with("slave", 1)
+	got := fl.provider.(recordFieldLogger).fields
+	if want := []interface{}{"slave", 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldLogProvider fields = %v, want %v", got, want)
+	}
+}
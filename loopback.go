@@ -0,0 +1,121 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrLoopbackNoReply the loopback server does not reply, such as the slave id not exist,
+// it implements net.Error as the timeout of the real link
+var ErrLoopbackNoReply error = loopbackTimeoutError{}
+
+// loopbackTimeoutError 回环服务端不回复, 等同于超时
+type loopbackTimeoutError struct{}
+
+func (loopbackTimeoutError) Error() string   { return "modbus: loopback: no reply, i/o timeout" }
+func (loopbackTimeoutError) Timeout() bool   { return true }
+func (loopbackTimeoutError) Temporary() bool { return true }
+
+// LoopbackProvider implements ClientProvider interface,
+// it dispatch the requests straight into the server core in-process, without socket or serial port,
+// the nodes, function handlers, middlewares, aliases work the same as the servers,
+// use it for fast deterministic tests or the virtual devices embedded in the application.
+type LoopbackProvider struct {
+	*serverCommon
+	logger
+	mu        sync.Mutex
+	connected bool
+}
+
+// check LoopbackProvider implements underlying method
+var _ ClientProvider = (*LoopbackProvider)(nil)
+
+// NewLoopbackProvider allocates a LoopbackProvider with the nodes,
+// the node can be shared with a real server to serve both.
+func NewLoopbackProvider(nodes ...*NodeRegister) *LoopbackProvider {
+	sf := &LoopbackProvider{
+		serverCommon: newServerCommon(),
+		logger:       newLogger("modbusLoopbackMaster =>"),
+	}
+	sf.AddNodes(nodes...)
+	return sf
+}
+
+// Connect mark the provider connected
+func (sf *LoopbackProvider) Connect() error {
+	sf.mu.Lock()
+	sf.connected = true
+	sf.mu.Unlock()
+	return nil
+}
+
+// IsConnected returns a bool signifying whether the provider is connected or not.
+func (sf *LoopbackProvider) IsConnected() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.connected
+}
+
+// SetAutoReconnect do nothing, the loopback never disconnect
+func (sf *LoopbackProvider) SetAutoReconnect(byte) {}
+
+// Close mark the provider closed
+func (sf *LoopbackProvider) Close() error {
+	sf.mu.Lock()
+	sf.connected = false
+	sf.mu.Unlock()
+	return nil
+}
+
+// Send dispatch the request to the server core and return the response,
+// the broadcast is dispatched but not replied.
+func (sf *LoopbackProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	if !sf.IsConnected() {
+		return ProtocolDataUnit{}, ErrClosedConnection
+	}
+	log := sf.with("slave", slaveID)
+	log.Debug("request [% x] % x", request.FuncCode, request.Data)
+	// the handler may modify the data in place, keep the caller's one intact
+	data, err := sf.serve(&ServerRequest{
+		SlaveID:  slaveID,
+		FuncCode: request.FuncCode,
+		Data:     append([]byte(nil), request.Data...),
+	})
+	if slaveID == AddressBroadCast {
+		return ProtocolDataUnit{}, nil
+	}
+	if err == ErrSlaveNotExist || err == errNoReply {
+		return ProtocolDataUnit{}, ErrLoopbackNoReply
+	}
+	if err != nil {
+		code := exceptionCode(err)
+		log.Debug("response exception % x", code)
+		return ExceptionResponse(request.FuncCode, Exception(code)),
+			&ExceptionError{FuncCode: request.FuncCode, ExceptionCode: code}
+	}
+	response := ProtocolDataUnit{request.FuncCode, data}
+	log.Debug("response [% x] % x", response.FuncCode, response.Data)
+	if err = verify(slaveID, slaveID, request, response); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// SendPdu send pdu request to the server core
+func (sf *LoopbackProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) < pduMinSize || len(pduRequest) > pduMaxSize {
+		return nil, fmt.Errorf("modbus: pdu size '%v' must not be between '%v' and '%v'",
+			len(pduRequest), pduMinSize, pduMaxSize)
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{pduRequest[0], pduRequest[1:]})
+	if err != nil || slaveID == AddressBroadCast {
+		return nil, err
+	}
+	return append([]byte{response.FuncCode}, response.Data...), nil
+}
+
+// SendRawFrame is not supported, the loopback has no framing
+func (sf *LoopbackProvider) SendRawFrame([]byte) ([]byte, error) {
+	return nil, errors.New("modbus: loopback does not support raw frame")
+}
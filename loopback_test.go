@@ -0,0 +1,48 @@
+package modbus
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestLoopbackProvider(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	p := NewLoopbackProvider(node)
+	c := NewClient(p)
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != ErrClosedConnection {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want %v", err, ErrClosedConnection)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteMultipleRegisters(1, 2, 2, []byte{0x12, 0x34, 0x56, 0x78}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ReadHoldingRegisters(1, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{0x1234, 0x5678}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadHoldingRegisters() = %v, want %v", got, want)
+	}
+	if v, _ := node.ReadHoldings(2, 1); v[0] != 0x1234 {
+		t.Errorf("node holding register = %#x, want 0x1234", v[0])
+	}
+
+	if _, err = c.ReadHoldingRegisters(1, 100, 1); !IsIllegalDataAddress(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want illegal data address", err)
+	}
+	_, err = c.ReadHoldingRegisters(2, 0, 1)
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Errorf("ReadHoldingRegisters() error = %v, want timeout", err)
+	}
+
+	p.SetAlias(2, 1)
+	if _, err = c.ReadHoldingRegisters(2, 2, 1); err != nil {
+		t.Errorf("ReadHoldingRegisters() alias error = %v", err)
+	}
+	if err = c.WriteSingleRegister(AddressBroadCast, 0, 1); err != nil {
+		t.Errorf("WriteSingleRegister() broadcast error = %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package mb
+
+import (
+	"time"
+)
+
+// errorRateAlpha 错误率指数加权移动平均的系数, 约最近10次请求
+const errorRateAlpha = 0.1
+
+// adaptive 自适应扫描速率
+type adaptive struct {
+	threshold float64 // 错误率高于它时放慢
+	max       int     // 最大放慢倍数
+}
+
+// WithAdaptiveScanRate 根据从机的错误率自动调整扫描速率, 错误率(最近约10次请求)高于threshold时
+// 该从机任务的扫描间隔加倍, 最多max倍, 错误率低于threshold/2时逐步恢复到配置的扫描速率,
+// 使用 Schedule 的任务不受影响, 见 Health.ErrorRate 和 Health.Slowdown
+func WithAdaptiveScanRate(threshold float64, max int) Option {
+	return func(client *Client) {
+		if threshold > 0 && max > 1 {
+			client.adaptive = &adaptive{threshold, max}
+		}
+	}
+}
+
+// adapt 根据错误率调整放慢倍数.
+// Caller must hold the mutex before calling this method.
+func (sf *adaptive) adapt(h *Health) {
+	switch {
+	case h.ErrorRate > sf.threshold && h.Slowdown < sf.max:
+		if h.Slowdown *= 2; h.Slowdown > sf.max {
+			h.Slowdown = sf.max
+		}
+	case h.ErrorRate < sf.threshold/2 && h.Slowdown > 1:
+		if h.Slowdown /= 2; h.Slowdown < 1 {
+			h.Slowdown = 1
+		}
+	}
+}
+
+// scanRate 从机任务的实际扫描间隔
+func (sf *Client) scanRate(req *Request) time.Duration {
+	if sf.adaptive == nil {
+		return req.ScanRate
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if h, ok := sf.health[req.SlaveID]; ok && h.Slowdown > 1 {
+		return req.ScanRate * time.Duration(h.Slowdown)
+	}
+	return req.ScanRate
+}
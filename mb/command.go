@@ -0,0 +1,115 @@
+package mb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// DefaultCommandQueueLength 默认写命令队列长度
+const DefaultCommandQueueLength = 32
+
+// ErrCommandQueueFull 写命令队列已满
+var ErrCommandQueueFull = errors.New("mb: command queue full")
+
+// Command 写命令, 在采集协程中执行, 与采集请求共用同一总线顺序, 优先于就绪的采集请求
+type Command struct {
+	SlaveID  byte   // 从机地址
+	FuncCode byte   // 功能码, 写单个/多个线圈, 写单个/多个寄存器, 屏蔽写寄存器
+	Address  uint16 // 地址
+	Quantity uint16 // 写多个线圈或寄存器的数量
+	// Value 写的值,
+	// 写单个线圈: 1个字节, 非0为ON;
+	// 写单个寄存器: 2个字节, 大端;
+	// 写多个线圈: 按位打包, 低位在前;
+	// 写多个寄存器: Quantity*2个字节, 大端;
+	// 屏蔽写寄存器: 4个字节, and mask 和 or mask, 大端
+	Value []byte
+	// Done 执行完成的回调, 在采集协程中调用, 不应阻塞, 可为nil
+	Done func(err error)
+}
+
+// validate 检查命令
+func (sf *Command) validate() error {
+	var size int
+	switch sf.FuncCode {
+	case modbus.FuncCodeWriteSingleCoil:
+		size = 1
+	case modbus.FuncCodeWriteSingleRegister:
+		size = 2
+	case modbus.FuncCodeWriteMultipleCoils:
+		if sf.Quantity < modbus.WriteBitsQuantityMin || sf.Quantity > modbus.WriteBitsQuantityMax {
+			return fmt.Errorf("mb: quantity '%v' must be between '%v' and '%v'",
+				sf.Quantity, modbus.WriteBitsQuantityMin, modbus.WriteBitsQuantityMax)
+		}
+		size = (int(sf.Quantity) + 7) / 8
+	case modbus.FuncCodeWriteMultipleRegisters:
+		if sf.Quantity < modbus.WriteRegQuantityMin || sf.Quantity > modbus.WriteRegQuantityMax {
+			return fmt.Errorf("mb: quantity '%v' must be between '%v' and '%v'",
+				sf.Quantity, modbus.WriteRegQuantityMin, modbus.WriteRegQuantityMax)
+		}
+		size = int(sf.Quantity) * 2
+	case modbus.FuncCodeMaskWriteRegister:
+		size = 4
+	default:
+		return errors.New("invalid function code")
+	}
+	if len(sf.Value) != size {
+		return fmt.Errorf("mb: value size '%v' does not match '%v'", len(sf.Value), size)
+	}
+	return nil
+}
+
+// Enqueue 加入写命令, 可在 Handler 的回调中调用, 如读到报警位后写确认位,
+// 命令在采集协程中优先于就绪的采集请求执行, 与采集共用同一总线顺序, 队列满时返回 ErrCommandQueueFull
+func (sf *Client) Enqueue(cmd Command) error {
+	if err := sf.ctx.Err(); err != nil {
+		return err
+	}
+	if cmd.SlaveID > modbus.AddressMax {
+		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
+			cmd.SlaveID, modbus.AddressBroadCast, modbus.AddressMax)
+	}
+	if err := cmd.validate(); err != nil {
+		return err
+	}
+	select {
+	case sf.commands <- &cmd:
+		return nil
+	default:
+		return ErrCommandQueueFull
+	}
+}
+
+// procCommand 执行写命令
+func (sf *Client) procCommand(cmd *Command) {
+	var err error
+
+	defer func() {
+		if err := recover(); err != nil {
+			sf.panicHandle(err)
+		}
+	}()
+
+	switch cmd.FuncCode {
+	case modbus.FuncCodeWriteSingleCoil:
+		err = sf.WriteSingleCoil(cmd.SlaveID, cmd.Address, cmd.Value[0] != 0)
+	case modbus.FuncCodeWriteSingleRegister:
+		err = sf.WriteSingleRegister(cmd.SlaveID, cmd.Address, binary.BigEndian.Uint16(cmd.Value))
+	case modbus.FuncCodeWriteMultipleCoils:
+		err = sf.WriteMultipleCoils(cmd.SlaveID, cmd.Address, cmd.Quantity, cmd.Value)
+	case modbus.FuncCodeWriteMultipleRegisters:
+		err = sf.WriteMultipleRegisters(cmd.SlaveID, cmd.Address, cmd.Quantity, cmd.Value)
+	case modbus.FuncCodeMaskWriteRegister:
+		err = sf.MaskWriteRegister(cmd.SlaveID, cmd.Address,
+			binary.BigEndian.Uint16(cmd.Value), binary.BigEndian.Uint16(cmd.Value[2:]))
+	}
+	if cmd.SlaveID != modbus.AddressBroadCast {
+		sf.updateHealth(cmd.SlaveID, err)
+	}
+	if cmd.Done != nil {
+		cmd.Done(err)
+	}
+}
@@ -0,0 +1,57 @@
+package mb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// IDRange 从机地址范围, 包含 From 和 To
+type IDRange struct {
+	From, To byte
+}
+
+// Discovered 探测到的从机
+type Discovered struct {
+	SlaveID byte
+	Latency time.Duration // 响应时间
+	Err     error         // 正常响应时为nil, 异常响应时为 *modbus.ExceptionError
+}
+
+// Discover 逐个探测 ids 范围内的从机, 使用 probeFC(读线圈,离散量,输入寄存器或保持寄存器)读 addr 处的1个点,
+// 正常响应或异常响应都说明从机存在, 超时等无响应的从机不在结果中, 串口和TCP都可用于调试部署.
+// ctx 取消时返回已探测到的从机和 ctx.Err()
+func (sf *Client) Discover(ctx context.Context, ids IDRange, probeFC byte, addr uint16) ([]Discovered, error) {
+	var probe func(slaveID byte) error
+	switch probeFC {
+	case modbus.FuncCodeReadCoils:
+		probe = func(id byte) error { _, err := sf.ReadCoils(id, addr, 1); return err }
+	case modbus.FuncCodeReadDiscreteInputs:
+		probe = func(id byte) error { _, err := sf.ReadDiscreteInputs(id, addr, 1); return err }
+	case modbus.FuncCodeReadInputRegisters:
+		probe = func(id byte) error { _, err := sf.ReadInputRegistersBytes(id, addr, 1); return err }
+	case modbus.FuncCodeReadHoldingRegisters:
+		probe = func(id byte) error { _, err := sf.ReadHoldingRegistersBytes(id, addr, 1); return err }
+	default:
+		return nil, fmt.Errorf("mb: invalid probe function code '%v'", probeFC)
+	}
+	if ids.From < modbus.AddressMin || ids.To > modbus.AddressMax || ids.From > ids.To {
+		return nil, fmt.Errorf("mb: slaveID range '%v'-'%v' must be between '%v' and '%v'",
+			ids.From, ids.To, modbus.AddressMin, modbus.AddressMax)
+	}
+
+	var found []Discovered
+	for id := int(ids.From); id <= int(ids.To); id++ {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		start := time.Now()
+		err := probe(byte(id))
+		if _, isException := modbus.AsExceptionError(err); err == nil || isException {
+			found = append(found, Discovered{byte(id), time.Since(start), err})
+		}
+	}
+	return found, nil
+}
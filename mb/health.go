@@ -0,0 +1,80 @@
+package mb
+
+import (
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// DefaultOfflineAfter 默认连续失败多少次后从机离线
+const DefaultOfflineAfter = 3
+
+// Health 从机的通信健康状态
+type Health struct {
+	SlaveID  byte
+	Online   bool      // 是否在线
+	FailCnt  int       // 连续失败次数
+	LastOK   time.Time // 最后一次成功的时间
+	LastFail time.Time // 最后一次失败的时间
+	LastErr  error     // 最后一次失败的错误
+	// 错误率, 最近约10次请求的指数加权移动平均, 异常响应不计为错误
+	ErrorRate float64
+	// 扫描间隔的放慢倍数, 见 WithAdaptiveScanRate
+	Slowdown int
+}
+
+// OnSlaveStateChange 设置从机上下线回调, 从机首次响应时上线,
+// 连续失败达到 WithOfflineAfter 次数时离线, 异常响应说明从机在线, 不计为失败.
+// 回调在采集协程中调用, 不应阻塞
+func (sf *Client) OnSlaveStateChange(f func(slaveID byte, online bool)) {
+	sf.mu.Lock()
+	sf.onStateChange = f
+	sf.mu.Unlock()
+}
+
+// SlaveHealth 获取所有已采集从机的健康状态
+func (sf *Client) SlaveHealth() map[byte]Health {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	m := make(map[byte]Health, len(sf.health))
+	for id, h := range sf.health {
+		m[id] = *h
+	}
+	return m
+}
+
+// updateHealth 根据请求结果更新从机的健康状态
+func (sf *Client) updateHealth(slaveID byte, err error) {
+	if _, ok := modbus.AsExceptionError(err); ok {
+		err = nil
+	}
+
+	sf.mu.Lock()
+	h, ok := sf.health[slaveID]
+	if !ok {
+		h = &Health{SlaveID: slaveID, Slowdown: 1}
+		sf.health[slaveID] = h
+	}
+	wasOnline := h.Online
+	if err == nil {
+		h.Online, h.FailCnt, h.LastOK = true, 0, time.Now()
+		h.ErrorRate *= 1 - errorRateAlpha
+	} else {
+		h.ErrorRate = h.ErrorRate*(1-errorRateAlpha) + errorRateAlpha
+		h.FailCnt++
+		h.LastFail, h.LastErr = time.Now(), err
+		if h.FailCnt >= sf.offlineAfter {
+			h.Online = false
+		}
+	}
+	if sf.adaptive != nil {
+		sf.adaptive.adapt(h)
+	}
+	changed, online := h.Online != wasOnline, h.Online
+	f := sf.onStateChange
+	sf.mu.Unlock()
+
+	if changed && f != nil {
+		f(slaveID, online)
+	}
+}
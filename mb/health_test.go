@@ -0,0 +1,247 @@
+package mb
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+func TestClient_updateHealth(t *testing.T) {
+	errTimeout := errors.New("i/o timeout")
+	exception := &modbus.ExceptionError{ExceptionCode: modbus.ExceptionCodeIllegalDataAddress}
+	type state struct {
+		online  bool
+		failCnt int
+	}
+	type change struct {
+		slaveID byte
+		online  bool
+	}
+	tests := []struct {
+		name        string
+		errs        []error
+		wantStates  []state
+		wantChanges []change
+	}{
+		{
+			"first response online",
+			[]error{nil},
+			[]state{{true, 0}},
+			[]change{{1, true}},
+		},
+		{
+			"never responded stays offline",
+			[]error{errTimeout, errTimeout, errTimeout, errTimeout},
+			[]state{{false, 1}, {false, 2}, {false, 3}, {false, 4}},
+			nil,
+		},
+		{
+			"offline after 3 failures",
+			[]error{nil, errTimeout, errTimeout, errTimeout, errTimeout},
+			[]state{{true, 0}, {true, 1}, {true, 2}, {false, 3}, {false, 4}},
+			[]change{{1, true}, {1, false}},
+		},
+		{
+			"exception is online",
+			[]error{nil, errTimeout, errTimeout, exception, errTimeout, errTimeout},
+			[]state{{true, 0}, {true, 1}, {true, 2}, {true, 0}, {true, 1}, {true, 2}},
+			[]change{{1, true}},
+		},
+		{
+			"back online",
+			[]error{nil, errTimeout, errTimeout, errTimeout, nil},
+			[]state{{true, 0}, {true, 1}, {true, 2}, {false, 3}, {true, 0}},
+			[]change{{1, true}, {1, false}, {1, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(modbus.NewLoopbackProvider())
+			defer c.Close()
+			var changes []change
+			c.OnSlaveStateChange(func(slaveID byte, online bool) {
+				changes = append(changes, change{slaveID, online})
+			})
+			for i, err := range tt.errs {
+				c.updateHealth(1, err)
+				h := c.SlaveHealth()[1]
+				if got := (state{h.Online, h.FailCnt}); got != tt.wantStates[i] {
+					t.Errorf("step %v health = %+v, want %+v", i, got, tt.wantStates[i])
+				}
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("changes = %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestClient_healthLastError(t *testing.T) {
+	c := NewClient(modbus.NewLoopbackProvider(), WithOfflineAfter(1))
+	defer c.Close()
+	errTimeout := errors.New("i/o timeout")
+	c.updateHealth(2, nil)
+	c.updateHealth(2, errTimeout)
+	h := c.SlaveHealth()[2]
+	if h.Online || h.LastErr != errTimeout || h.LastOK.IsZero() || h.LastFail.Before(h.LastOK) {
+		t.Errorf("health = %+v, want offline with the last error", h)
+	}
+	if h.ErrorRate <= 0 || h.ErrorRate >= 1 {
+		t.Errorf("ErrorRate = %v, want between 0 and 1", h.ErrorRate)
+	}
+}
+
+func TestClient_adaptiveScanRate(t *testing.T) {
+	c := NewClient(modbus.NewLoopbackProvider(), WithAdaptiveScanRate(0.2, 4))
+	defer c.Close()
+	req := &Request{SlaveID: 1, ScanRate: time.Second}
+	errTimeout := errors.New("i/o timeout")
+
+	// error rate 0.1, 0.19, 0.271, 0.344, 0.41
+	wantSlowdown := []int{1, 1, 2, 4, 4}
+	for i, want := range wantSlowdown {
+		c.updateHealth(1, errTimeout)
+		if got := c.SlaveHealth()[1].Slowdown; got != want {
+			t.Errorf("failure %v Slowdown = %v, want %v", i+1, got, want)
+		}
+	}
+	if got := c.scanRate(req); got != 4*time.Second {
+		t.Errorf("scanRate() = %v, want 4s", got)
+	}
+	if got := c.scanRate(&Request{SlaveID: 2, ScanRate: time.Second}); got != time.Second {
+		t.Errorf("other slave scanRate() = %v, want 1s", got)
+	}
+
+	// recover step by step once the error rate is below threshold/2
+	var steps []int
+	for i := 0; i < 30 && c.SlaveHealth()[1].Slowdown > 1; i++ {
+		c.updateHealth(1, nil)
+		if s := c.SlaveHealth()[1].Slowdown; len(steps) == 0 || steps[len(steps)-1] != s {
+			steps = append(steps, s)
+		}
+	}
+	if want := []int{4, 2, 1}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("recovery slowdown = %v, want %v", steps, want)
+	}
+	if got := c.scanRate(req); got != time.Second {
+		t.Errorf("recovered scanRate() = %v, want 1s", got)
+	}
+
+	// disabled
+	c = NewClient(modbus.NewLoopbackProvider())
+	defer c.Close()
+	for i := 0; i < 10; i++ {
+		c.updateHealth(1, errTimeout)
+	}
+	if got := c.scanRate(req); got != time.Second {
+		t.Errorf("not adaptive scanRate() = %v, want 1s", got)
+	}
+}
+
+func TestClient_ExportImportJobs(t *testing.T) {
+	c := NewClient(modbus.NewLoopbackProvider())
+	defer c.Close()
+	night := Windows{{Weekdays: []time.Weekday{time.Saturday}, From: 22 * time.Hour, To: 6 * time.Hour}}
+	for _, r := range []Request{
+		{SlaveID: 1, FuncCode: modbus.FuncCodeReadHoldingRegisters, Address: 10, Quantity: 200, ScanRate: time.Hour},
+		{SlaveID: 2, FuncCode: modbus.FuncCodeReadCoils, Quantity: 16, ScanRate: 90 * time.Minute,
+			Timeout: 1500 * time.Millisecond, Calendar: night},
+	} {
+		if err := c.AddGatherJob(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := c.ExportJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewClient(modbus.NewLoopbackProvider())
+	defer restored.Close()
+	if err = restored.ImportJobs(data); err != nil {
+		t.Fatal(err)
+	}
+	again, err := restored.ExportJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("round trip = %s, want %s", again, data)
+	}
+	if jobs := restored.Jobs(); len(jobs) != 3 || jobs[1].Quantity != 75 || jobs[2].ScanRate != 90*time.Minute {
+		t.Errorf("Jobs() = %+v, want the split job and the coils job", jobs)
+	}
+	if _, ok := restored.jobs[2].Calendar.(Windows); !ok || restored.jobs[2].Timeout != 1500*time.Millisecond {
+		t.Errorf("imported job = %+v, want the windows and timeout", restored.jobs[2])
+	}
+
+	// the counters are restored
+	if err = restored.ImportJobs([]byte(`[{"slaveId":3,"funcCode":3,"quantity":1,"scanRate":"1h","txCnt":7,"errCnt":2}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := restored.Jobs(); len(jobs) != 4 || jobs[3].TxCnt != 7 || jobs[3].ErrCnt != 2 {
+		t.Errorf("Jobs() = %+v, want the counters restored", jobs)
+	}
+
+	// invalid data imports nothing
+	for _, data := range []string{
+		`{`,
+		`[{"slaveId":1,"funcCode":3,"quantity":1,"scanRate":"1h"},{"slaveId":1,"funcCode":99,"quantity":1,"scanRate":"1h"}]`,
+		`[{"slaveId":1,"funcCode":3,"quantity":1,"scanRate":"1 hour"}]`,
+	} {
+		if err = restored.ImportJobs([]byte(data)); err == nil {
+			t.Errorf("ImportJobs(%s) error = nil", data)
+		}
+	}
+	if jobs := restored.Jobs(); len(jobs) != 4 {
+		t.Errorf("Jobs() after invalid import = %v jobs, want 4", len(jobs))
+	}
+}
+
+func TestClient_Discover(t *testing.T) {
+	p := modbus.NewLoopbackProvider(
+		modbus.NewNodeRegister(2, 0, 8, 0, 0, 0, 0, 0, 0),
+		modbus.NewNodeRegister(5, 0, 0, 0, 0, 0, 0, 0, 0), // no coils, reply exception
+	)
+	c := NewClient(p)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	found, err := c.Discover(context.Background(), IDRange{1, 6}, modbus.FuncCodeReadCoils, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].SlaveID != 2 || found[0].Err != nil ||
+		found[1].SlaveID != 5 || !modbus.IsIllegalDataAddress(found[1].Err) {
+		t.Errorf("Discover() = %+v, want 2 and 5 with exception", found)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if found, err = c.Discover(ctx, IDRange{1, 6}, modbus.FuncCodeReadCoils, 0); err != context.Canceled || len(found) != 0 {
+		t.Errorf("Discover() cancelled = %v, %v", found, err)
+	}
+
+	tests := []struct {
+		name string
+		ids  IDRange
+		fc   byte
+	}{
+		{"invalid function", IDRange{1, 6}, modbus.FuncCodeWriteSingleCoil},
+		{"broadcast", IDRange{0, 6}, modbus.FuncCodeReadCoils},
+		{"reversed", IDRange{6, 1}, modbus.FuncCodeReadCoils},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Discover(context.Background(), tt.ids, tt.fc, 0); err == nil {
+				t.Errorf("Discover() error = nil")
+			}
+		})
+	}
+}
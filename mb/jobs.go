@@ -0,0 +1,124 @@
+package mb
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// jobStats 任务的计数
+type jobStats struct {
+	txCnt, errCnt uint64
+	suspend       bool
+}
+
+// duration 以 time.Duration 字符串格式编码的时长, 如 "1.5s"
+type duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (sf duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(sf).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (sf *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*sf = duration(d)
+	return nil
+}
+
+// jobWindow 活动时间窗口的导出格式
+type jobWindow struct {
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+	From     duration       `json:"from"`
+	To       duration       `json:"to"`
+}
+
+// job 采集任务的导出格式
+type job struct {
+	SlaveID  byte        `json:"slaveId"`
+	FuncCode byte        `json:"funcCode"`
+	Address  uint16      `json:"address"`
+	Quantity uint16      `json:"quantity"`
+	ScanRate duration    `json:"scanRate"`
+	Timeout  duration    `json:"timeout,omitempty"`
+	Windows  []jobWindow `json:"windows,omitempty"`
+	TxCnt    uint64      `json:"txCnt"`
+	ErrCnt   uint64      `json:"errCnt"`
+	Suspend  bool        `json:"suspend,omitempty"`
+}
+
+// ExportJobs 导出所有采集任务及其计数(JSON), 用于检查点和重启后 ImportJobs 恢复,
+// Calendar 仅导出 Windows, Schedule 不能导出, 导入后按 ScanRate 扫描
+func (sf *Client) ExportJobs() ([]byte, error) {
+	sf.mu.Lock()
+	jobs := make([]job, 0, len(sf.jobs))
+	for _, req := range sf.jobs {
+		j := job{
+			SlaveID:  req.SlaveID,
+			FuncCode: req.FuncCode,
+			Address:  req.Address,
+			Quantity: req.Quantity,
+			ScanRate: duration(req.ScanRate),
+			Timeout:  duration(req.Timeout),
+			TxCnt:    req.stats.txCnt,
+			ErrCnt:   req.stats.errCnt,
+			Suspend:  req.stats.suspend,
+		}
+		if ws, ok := req.Calendar.(Windows); ok {
+			for _, w := range ws {
+				j.Windows = append(j.Windows, jobWindow{w.Weekdays, duration(w.From), duration(w.To)})
+			}
+		}
+		jobs = append(jobs, j)
+	}
+	sf.mu.Unlock()
+	return json.Marshal(jobs)
+}
+
+// ImportJobs 导入 ExportJobs 导出的采集任务并启动, 恢复计数, 挂起的任务重新开始扫描,
+// 任务追加到已有的任务, 数据有误时不导入任何任务
+func (sf *Client) ImportJobs(data []byte) error {
+	var jobs []job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return err
+	}
+	requests := make([]Request, 0, len(jobs))
+	for _, j := range jobs {
+		r := Request{
+			SlaveID:  j.SlaveID,
+			FuncCode: j.FuncCode,
+			Address:  j.Address,
+			Quantity: j.Quantity,
+			ScanRate: time.Duration(j.ScanRate),
+			Timeout:  time.Duration(j.Timeout),
+		}
+		if len(j.Windows) > 0 {
+			ws := make(Windows, 0, len(j.Windows))
+			for _, w := range j.Windows {
+				ws = append(ws, Window{w.Weekdays, time.Duration(w.From), time.Duration(w.To)})
+			}
+			r.Calendar = ws
+		}
+		if _, err := r.validate(); err != nil {
+			return err
+		}
+		requests = append(requests, r)
+	}
+	for i, r := range requests {
+		j := jobs[i]
+		err := sf.addGatherJob(r, func(req *Request) {
+			req.txCnt, req.errCnt = j.TxCnt, j.ErrCnt
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
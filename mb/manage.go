@@ -0,0 +1,86 @@
+package mb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aloncn/timing"
+)
+
+// ErrReadyQueueFull 就绪队列已满
+var ErrReadyQueueFull = errors.New("mb: ready queue full")
+
+// Jobs 所有采集任务的状态快照, 超过功能码最大数量的任务被拆分为多个, Scheduled 为零值
+func (sf *Client) Jobs() []Result {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	jobs := make([]Result, 0, len(sf.jobs))
+	for _, req := range sf.jobs {
+		jobs = append(jobs, Result{
+			SlaveID:  req.SlaveID,
+			FuncCode: req.FuncCode,
+			Address:  req.Address,
+			Quantity: req.Quantity,
+			ScanRate: req.ScanRate,
+			Timeout:  req.Timeout,
+			TxCnt:    req.stats.txCnt,
+			ErrCnt:   req.stats.errCnt,
+			Suspend:  req.stats.suspend,
+		})
+	}
+	return jobs
+}
+
+// RemoveGatherJob 删除从机 slaveID 功能码 funcCode 在 [address, address+quantity) 内的采集任务, 返回删除的任务数,
+// 使用 AddGatherJob 的参数删除其拆分的所有任务, 正在执行的任务完成本次采集后停止
+func (sf *Client) RemoveGatherJob(slaveID, funcCode byte, address, quantity uint16) int {
+	end := uint32(address) + uint32(quantity)
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	jobs := sf.jobs[:0]
+	for _, req := range sf.jobs {
+		if req.SlaveID != slaveID || req.FuncCode != funcCode ||
+			req.Address < address || uint32(req.Address)+uint32(req.Quantity) > end {
+			jobs = append(jobs, req)
+			continue
+		}
+		req.removed = true
+		delete(sf.suspended, req)
+		timing.Remove(req.tm)
+	}
+	n := len(sf.jobs) - len(jobs)
+	for i := len(jobs); i < len(sf.jobs); i++ {
+		sf.jobs[i] = nil
+	}
+	sf.jobs = jobs
+	return n
+}
+
+// ReadOnce 立即读一次, 不加入采集任务, 结果与采集任务一样交给 Handler, Result 的 ScanRate 为0,
+// 忽略 ScanRate, Schedule, Calendar 和 Retry, 数量不能超过功能码的最大数量, 就绪队列满时返回 ErrReadyQueueFull
+func (sf *Client) ReadOnce(r Request) error {
+	if err := sf.ctx.Err(); err != nil {
+		return err
+	}
+	quantityMax, err := r.validate()
+	if err != nil {
+		return err
+	}
+	if r.Quantity == 0 || int(r.Quantity) > quantityMax {
+		return fmt.Errorf("modbus: quantity '%v' must be between '%v' and '%v'", r.Quantity, 1, quantityMax)
+	}
+	req := &Request{
+		SlaveID:  r.SlaveID,
+		FuncCode: r.FuncCode,
+		Address:  r.Address,
+		Quantity: r.Quantity,
+		Timeout:  r.Timeout,
+		once:     true,
+	}
+	select {
+	case sf.ready <- req:
+		return nil
+	default:
+		return ErrReadyQueueFull
+	}
+}
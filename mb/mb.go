@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	modbus "github.com/aloncn/gomodbus"
@@ -33,11 +34,24 @@ const (
 // Client 客户端
 type Client struct {
 	modbus.Client
+	timeout        time.Duration // 请求的默认超时, 0使用 provider 的超时
 	randValue      int
 	readyQueueSize int
 	ready          chan *Request
+	commandSize    int
+	commands       chan *Command // 写命令队列, 优先于就绪的采集请求
 	handler        Handler
 	panicHandle    func(err interface{})
+	backoff        modbus.Backoff // 重试退避, nil 使用随机延迟
+	suspendAfter   int            // 连续失败多少次后挂起, 0 不挂起
+	probeInterval  time.Duration  // 挂起任务的探测周期, 0 不探测
+	mu             sync.Mutex
+	suspended      map[*Request]struct{} // 挂起且不探测的任务
+	offlineAfter   int                   // 连续失败多少次后从机离线
+	health         map[byte]*Health      // 从机健康状态
+	onStateChange  func(slaveID byte, online bool)
+	adaptive       *adaptive  // 自适应扫描速率, nil 不调整
+	jobs           []*Request // 所有采集任务
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -49,8 +63,12 @@ type Result struct {
 	Address  uint16        // 请求数据用实际地址
 	Quantity uint16        // 请求数量
 	ScanRate time.Duration // 扫描速率scan rate
+	Timeout  time.Duration // 请求超时, 0使用 WithTimeout 的默认超时
 	TxCnt    uint64        // 发送计数
 	ErrCnt   uint64        // 发送错误计数
+	Suspend  bool          // 连续失败被挂起
+	// 设置了 Schedule 时为本次采集的调度时间, 用于对齐时间戳, 重试和探测时为最初的调度时间
+	Scheduled time.Time
 }
 
 // Request 请求
@@ -60,11 +78,22 @@ type Request struct {
 	Address  uint16        // 请求数据用实际地址
 	Quantity uint16        // 请求数量
 	ScanRate time.Duration // 扫描速率scan rate
+	// Schedule 按墙上时钟调度, 设置时代替 ScanRate, 如 EveryAligned(time.Minute), DailyAt(6, 0)
+	Schedule Schedule
+	// Calendar 活动时间, 非活动时暂停采集, nil时总是活动, 如 Windows
+	Calendar Calendar
 	Retry    byte          // 失败重试次数
+	Timeout  time.Duration // 请求超时, 0使用 WithTimeout 的默认超时
 	retryCnt byte          // 重试计数
+	failCnt  int           // 连续失败计数
+	suspend  bool          // 是否挂起
 	txCnt    uint64        // 发送计数
 	errCnt   uint64        // 发送错误计数
 	tm       *timing.Entry // 时间句柄
+	stats    jobStats      // 导出用的计数, 由mu保护
+	next     time.Time     // 按 Schedule 的调度时间
+	removed  bool          // 已删除, 由mu保护
+	once     bool          // 单次读, 见 ReadOnce
 }
 
 // NewClient 创建新的client
@@ -74,8 +103,12 @@ func NewClient(p modbus.ClientProvider, opts ...Option) *Client {
 		Client:         modbus.NewClient(p),
 		randValue:      DefaultRandValue,
 		readyQueueSize: DefaultReadyQueuesLength,
+		commandSize:    DefaultCommandQueueLength,
 		handler:        &nopProc{},
 		panicHandle:    func(interface{}) {},
+		suspended:      make(map[*Request]struct{}),
+		offlineAfter:   DefaultOfflineAfter,
+		health:         make(map[byte]*Health),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -84,6 +117,7 @@ func NewClient(p modbus.ClientProvider, opts ...Option) *Client {
 		f(c)
 	}
 	c.ready = make(chan *Request, c.readyQueueSize)
+	c.commands = make(chan *Command, c.commandSize)
 	return c
 }
 
@@ -104,24 +138,17 @@ func (sf *Client) Close() error {
 
 // AddGatherJob 增加采集任务
 func (sf *Client) AddGatherJob(r Request) error {
-	var quantityMax int
+	return sf.addGatherJob(r, nil)
+}
 
+// addGatherJob 增加采集任务, init 在任务启动前初始化拆分后的每个请求
+func (sf *Client) addGatherJob(r Request, init func(req *Request)) error {
 	if err := sf.ctx.Err(); err != nil {
 		return err
 	}
-
-	if r.SlaveID < modbus.AddressMin || r.SlaveID > modbus.AddressMax {
-		return fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
-			r.SlaveID, modbus.AddressMin, modbus.AddressMax)
-	}
-
-	switch r.FuncCode {
-	case modbus.FuncCodeReadCoils, modbus.FuncCodeReadDiscreteInputs:
-		quantityMax = modbus.ReadBitsQuantityMax
-	case modbus.FuncCodeReadInputRegisters, modbus.FuncCodeReadHoldingRegisters:
-		quantityMax = modbus.ReadRegQuantityMax
-	default:
-		return errors.New("invalid function code")
+	quantityMax, err := r.validate()
+	if err != nil {
+		return err
 	}
 
 	address := r.Address
@@ -138,7 +165,17 @@ func (sf *Client) AddGatherJob(r Request) error {
 			Address:  address,
 			Quantity: uint16(count),
 			ScanRate: r.ScanRate,
+			Schedule: r.Schedule,
+			Calendar: r.Calendar,
+			Timeout:  r.Timeout,
 		}
+		if init != nil {
+			init(req)
+		}
+		sf.mu.Lock()
+		req.stats = jobStats{req.txCnt, req.errCnt, false}
+		sf.jobs = append(sf.jobs, req)
+		sf.mu.Unlock()
 
 		req.tm = timing.NewOneShotFuncEntry(func() {
 			select {
@@ -149,7 +186,12 @@ func (sf *Client) AddGatherJob(r Request) error {
 				timing.Start(req.tm, time.Duration(rand.Intn(sf.randValue))*time.Millisecond)
 			}
 		}, req.ScanRate)
-		timing.Start(req.tm)
+		if req.Schedule != nil {
+			sf.scheduleNext(req)
+		} else {
+			now := time.Now()
+			sf.startAt(req, now, now.Add(req.ScanRate))
+		}
 
 		address += uint16(count)
 		remain -= count
@@ -157,14 +199,45 @@ func (sf *Client) AddGatherJob(r Request) error {
 	return nil
 }
 
+// validate 检查任务, 返回功能码的最大请求数量
+func (sf *Request) validate() (quantityMax int, err error) {
+	if sf.SlaveID < modbus.AddressMin || sf.SlaveID > modbus.AddressMax {
+		return 0, fmt.Errorf("modbus: slaveID '%v' must be between '%v' and '%v'",
+			sf.SlaveID, modbus.AddressMin, modbus.AddressMax)
+	}
+
+	switch sf.FuncCode {
+	case modbus.FuncCodeReadCoils, modbus.FuncCodeReadDiscreteInputs:
+		quantityMax = modbus.ReadBitsQuantityMax
+	case modbus.FuncCodeReadInputRegisters, modbus.FuncCodeReadHoldingRegisters:
+		quantityMax = modbus.ReadRegQuantityMax
+	default:
+		return 0, errors.New("invalid function code")
+	}
+	if ws, ok := sf.Calendar.(Windows); ok {
+		if err = ws.validate(); err != nil {
+			return 0, err
+		}
+	}
+	return quantityMax, nil
+}
+
 // 读协程
 func (sf *Client) readPoll() {
 	var req *Request
 
 	for {
+		select { // 写命令优先
+		case cmd := <-sf.commands:
+			sf.procCommand(cmd)
+			continue
+		default:
+		}
 		select {
 		case <-sf.ctx.Done():
 			return
+		case cmd := <-sf.commands:
+			sf.procCommand(cmd)
 		case req = <-sf.ready: // 查看是否有准备好的请求
 			sf.procRequest(req)
 		}
@@ -181,18 +254,34 @@ func (sf *Client) procRequest(req *Request) {
 		}
 	}()
 
+	sf.mu.Lock()
+	removed := req.removed
+	sf.mu.Unlock()
+	if removed {
+		return
+	}
+
+	// 重试或探测可能在窗口外
+	if now := time.Now(); !req.once && !req.active(now) {
+		req.retryCnt = 0
+		sf.startAt(req, now, now)
+		return
+	}
+
+	client, cancel := sf.requestClient(req)
+	defer cancel()
 	req.txCnt++
 	switch req.FuncCode {
 	// Bit access read
 	case modbus.FuncCodeReadCoils:
-		result, err = sf.ReadCoils(req.SlaveID, req.Address, req.Quantity)
+		result, err = client.ReadCoils(req.SlaveID, req.Address, req.Quantity)
 		if err != nil {
 			req.errCnt++
 		} else {
 			sf.handler.ProcReadCoils(req.SlaveID, req.Address, req.Quantity, result)
 		}
 	case modbus.FuncCodeReadDiscreteInputs:
-		result, err = sf.ReadDiscreteInputs(req.SlaveID, req.Address, req.Quantity)
+		result, err = client.ReadDiscreteInputs(req.SlaveID, req.Address, req.Quantity)
 		if err != nil {
 			req.errCnt++
 		} else {
@@ -201,7 +290,7 @@ func (sf *Client) procRequest(req *Request) {
 
 	// 16-bit access read
 	case modbus.FuncCodeReadHoldingRegisters:
-		result, err = sf.ReadHoldingRegistersBytes(req.SlaveID, req.Address, req.Quantity)
+		result, err = client.ReadHoldingRegistersBytes(req.SlaveID, req.Address, req.Quantity)
 		if err != nil {
 			req.errCnt++
 		} else {
@@ -209,7 +298,7 @@ func (sf *Client) procRequest(req *Request) {
 		}
 
 	case modbus.FuncCodeReadInputRegisters:
-		result, err = sf.ReadInputRegistersBytes(req.SlaveID, req.Address, req.Quantity)
+		result, err = client.ReadInputRegistersBytes(req.SlaveID, req.Address, req.Quantity)
 		if err != nil {
 			req.errCnt++
 		} else {
@@ -223,27 +312,83 @@ func (sf *Client) procRequest(req *Request) {
 		//		req.errCnt++
 		//	}
 	}
-	if err != nil && req.Retry > 0 {
-		if req.retryCnt++; req.retryCnt < req.Retry {
-			timing.Start(req.tm, time.Duration(rand.Intn(sf.randValue))*time.Millisecond)
-		} else if req.ScanRate > 0 {
-			timing.Start(req.tm)
+	scheduled := req.next
+	sf.updateHealth(req.SlaveID, err)
+	if !req.once {
+		sf.schedule(req, err)
+		sf.mu.Lock()
+		req.stats = jobStats{req.txCnt, req.errCnt, req.suspend}
+		if req.removed { // 执行期间被删除
+			delete(sf.suspended, req)
+			timing.Remove(req.tm)
 		}
-	} else if req.ScanRate > 0 {
-		timing.Start(req.tm)
+		sf.mu.Unlock()
 	}
-
 	sf.handler.ProcResult(err, &Result{
 		req.SlaveID,
 		req.FuncCode,
 		req.Address,
 		req.Quantity,
 		req.ScanRate,
+		req.Timeout,
 		req.txCnt,
 		req.errCnt,
+		req.suspend,
+		scheduled,
 	})
 }
 
+// schedule 根据结果安排下一次请求, 指定的间隔会覆盖任务的间隔, 所以正常调度需恢复为扫描速率
+func (sf *Client) schedule(req *Request, err error) {
+	if err == nil {
+		req.retryCnt, req.failCnt, req.suspend = 0, 0, false
+		sf.scheduleNext(req)
+		return
+	}
+
+	req.failCnt++
+	if sf.suspendAfter > 0 && req.failCnt >= sf.suspendAfter {
+		req.suspend = true
+		if sf.probeInterval > 0 {
+			timing.Start(req.tm, sf.probeInterval)
+		} else {
+			sf.mu.Lock()
+			sf.suspended[req] = struct{}{}
+			sf.mu.Unlock()
+		}
+		return
+	}
+	if req.Retry > 0 {
+		if req.retryCnt++; req.retryCnt < req.Retry {
+			timing.Start(req.tm, sf.retryDelay(req.retryCnt))
+			return
+		}
+		req.retryCnt = 0
+	}
+	sf.scheduleNext(req)
+}
+
+// retryDelay 第attempt次重试前的延迟
+func (sf *Client) retryDelay(attempt byte) time.Duration {
+	if sf.backoff != nil {
+		return sf.backoff(int(attempt))
+	}
+	return time.Duration(rand.Intn(sf.randValue)) * time.Millisecond
+}
+
+// Resume 恢复被挂起的任务, slaveID 为 0 时恢复所有从机的任务
+func (sf *Client) Resume(slaveID byte) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	for req := range sf.suspended {
+		if slaveID == 0 || req.SlaveID == slaveID {
+			delete(sf.suspended, req)
+			req.failCnt, req.suspend = 0, false
+			timing.Start(req.tm, time.Millisecond)
+		}
+	}
+}
+
 type nopProc struct{}
 
 func (nopProc) ProcReadCoils(byte, uint16, uint16, []byte)            {}
@@ -1,5 +1,11 @@
 package mb
 
+import (
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
 // Option 可选项
 type Option func(client *Client)
 
@@ -10,6 +16,15 @@ func WithReadyQueueSize(size int) Option {
 	}
 }
 
+// WithCommandQueueSize 写命令队列长度, 默认 DefaultCommandQueueLength
+func WithCommandQueueSize(size int) Option {
+	return func(client *Client) {
+		if size > 0 {
+			client.commandSize = size
+		}
+	}
+}
+
 // WitchHandler 配置handler
 func WitchHandler(h Handler) Option {
 	return func(client *Client) {
@@ -39,3 +54,40 @@ func WitchPanicHandle(f func(interface{})) Option {
 		}
 	}
 }
+
+// WithRetryBackoff 失败重试使用指数退避, 第n次重试前等待 base*2^(n-1), 但不超过max,
+// 未设置时使用随机延迟, 见 WitchRetryRandValue
+func WithRetryBackoff(base, max time.Duration) Option {
+	return func(client *Client) {
+		if base > 0 {
+			client.backoff = modbus.ExponentialBackoff(base, max)
+		}
+	}
+}
+
+// WithSuspend 任务连续失败n次后挂起, 挂起的任务每probe探测一次, 成功后恢复扫描,
+// probe 为0时挂起的任务不再调度, 直到 Resume, 避免离线的从机占用总线时间
+func WithSuspend(n int, probe time.Duration) Option {
+	return func(client *Client) {
+		client.suspendAfter, client.probeInterval = n, probe
+	}
+}
+
+// WithOfflineAfter 从机连续失败n次后离线, 默认 DefaultOfflineAfter
+func WithOfflineAfter(n int) Option {
+	return func(client *Client) {
+		if n > 0 {
+			client.offlineAfter = n
+		}
+	}
+}
+
+// WithTimeout 请求的默认超时, 用于没有自己超时(Request.Timeout)的请求,
+// 默认0使用 provider 的超时
+func WithTimeout(d time.Duration) Option {
+	return func(client *Client) {
+		if d > 0 {
+			client.timeout = d
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package mb
+
+import (
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// newLoopbackClient 在回环 provider 上启动采集客户端, 返回回环 provider 用于增删从机
+func newLoopbackClient(t *testing.T, opts ...Option) (*Client, *modbus.LoopbackProvider, *resultHandler) {
+	t.Helper()
+	p := modbus.NewLoopbackProvider()
+	h := newResultHandler()
+	c := NewClient(p, append(opts, WitchHandler(h))...)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return c, p, h
+}
+
+// expectNoResult 等待 d 时间内没有结果
+func expectNoResult(t *testing.T, h *resultHandler, d time.Duration) {
+	t.Helper()
+	select {
+	case r := <-h.results:
+		<-h.errs
+		t.Fatalf("unexpected result %+v", r)
+	case <-time.After(d):
+	}
+}
+
+func TestClient_RetryBackoff(t *testing.T) {
+	base := 30 * time.Millisecond
+	c, _, h := newLoopbackClient(t, WithRetryBackoff(base, time.Second))
+	defer c.Close()
+	// the slave does not exist, every request times out
+	if err := c.AddGatherJob(Request{
+		SlaveID:  1,
+		FuncCode: modbus.FuncCodeReadHoldingRegisters,
+		Quantity: 1,
+		ScanRate: 200 * time.Millisecond,
+		Retry:    4,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var last time.Time
+	for i := 0; i < 4; i++ {
+		r, err := h.next(t)
+		if err == nil {
+			t.Fatalf("result %+v error = nil, want timeout", r)
+		}
+		now := time.Now()
+		if i > 0 {
+			// the n-th retry waits base*2^(n-1), the timer never fires early
+			want := base << uint(i-1)
+			if gap := now.Sub(last); gap < want-5*time.Millisecond {
+				t.Errorf("retry %v after %v, want at least %v", i, gap, want)
+			}
+		}
+		last = now
+	}
+	if r, _ := h.next(t); r.ErrCnt != 5 || r.TxCnt != 5 {
+		t.Errorf("next cycle result = %+v, want 5 tx 5 err", r)
+	}
+}
+
+func TestClient_SuspendResume(t *testing.T) {
+	c, p, h := newLoopbackClient(t, WithSuspend(2, 0))
+	defer c.Close()
+	if err := c.AddGatherJob(Request{
+		SlaveID:  1,
+		FuncCode: modbus.FuncCodeReadCoils,
+		Quantity: 8,
+		ScanRate: 10 * time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if r, _ := h.next(t); r.Suspend {
+		t.Errorf("first failure suspended, want 2 failures")
+	}
+	if r, _ := h.next(t); !r.Suspend {
+		t.Errorf("second failure result = %+v, want suspended", r)
+	}
+	if jobs := c.Jobs(); len(jobs) != 1 || !jobs[0].Suspend {
+		t.Errorf("Jobs() = %+v, want suspended", jobs)
+	}
+	// no probe, the suspended job is not scheduled
+	expectNoResult(t, h, 100*time.Millisecond)
+
+	p.AddNodes(modbus.NewNodeRegister(1, 0, 8, 0, 0, 0, 0, 0, 0))
+	c.Resume(2) // other slave, not resumed
+	expectNoResult(t, h, 50*time.Millisecond)
+	c.Resume(1)
+	r, err := h.next(t)
+	if err != nil || r.Suspend {
+		t.Errorf("resumed result = %+v, %v, want ok", r, err)
+	}
+}
+
+func TestClient_SuspendProbe(t *testing.T) {
+	c, p, h := newLoopbackClient(t, WithSuspend(1, 50*time.Millisecond))
+	defer c.Close()
+	if err := c.AddGatherJob(Request{
+		SlaveID:  1,
+		FuncCode: modbus.FuncCodeReadHoldingRegisters,
+		Quantity: 1,
+		ScanRate: 10 * time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := h.next(t); !r.Suspend {
+		t.Fatalf("result = %+v, want suspended", r)
+	}
+	// the probe runs at the probe interval rather than the scan rate
+	start := time.Now()
+	if r, _ := h.next(t); !r.Suspend || time.Since(start) < 40*time.Millisecond {
+		t.Errorf("probe result = %+v after %v, want suspended after the probe interval", r, time.Since(start))
+	}
+	p.AddNodes(modbus.NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 1))
+	for {
+		r, err := h.next(t)
+		if err == nil {
+			if r.Suspend {
+				t.Errorf("recovered result = %+v, want not suspended", r)
+			}
+			break
+		}
+	}
+}
+
+func TestClient_RemoveGatherJob(t *testing.T) {
+	c, p, h := newLoopbackClient(t)
+	defer c.Close()
+	p.AddNodes(modbus.NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 300))
+	// split into 125 + 125 + 50
+	if err := c.AddGatherJob(Request{
+		SlaveID:  1,
+		FuncCode: modbus.FuncCodeReadHoldingRegisters,
+		Quantity: 300,
+		ScanRate: 10 * time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.AddGatherJob(Request{
+		SlaveID:  1,
+		FuncCode: modbus.FuncCodeReadInputRegisters,
+		Quantity: 1,
+		ScanRate: time.Hour,
+	})
+	if _, err := h.next(t); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := c.RemoveGatherJob(1, modbus.FuncCodeReadHoldingRegisters, 0, 100); n != 0 {
+		t.Errorf("RemoveGatherJob() part of a job = %v, want 0", n)
+	}
+	if n := c.RemoveGatherJob(1, modbus.FuncCodeReadHoldingRegisters, 0, 300); n != 3 {
+		t.Errorf("RemoveGatherJob() = %v, want 3", n)
+	}
+	if jobs := c.Jobs(); len(jobs) != 1 || jobs[0].FuncCode != modbus.FuncCodeReadInputRegisters {
+		t.Errorf("Jobs() = %+v, want the input register job", jobs)
+	}
+	// the running requests complete, then no more
+	time.Sleep(30 * time.Millisecond)
+	for len(h.results) > 0 {
+		<-h.results
+		<-h.errs
+	}
+	expectNoResult(t, h, 100*time.Millisecond)
+}
@@ -0,0 +1,88 @@
+package mb
+
+import (
+	"time"
+)
+
+// Schedule 按墙上时钟的调度, 如每分钟整点, 每天06:00, 用于需要时间戳对齐的计量抄表
+type Schedule interface {
+	// Next 返回t之后的下一次调度时间, 必须晚于t
+	Next(t time.Time) time.Time
+}
+
+// ScheduleFunc is an adapter to allow the use of ordinary functions as Schedule.
+type ScheduleFunc func(t time.Time) time.Time
+
+// Next implements Schedule, calls f(t).
+func (f ScheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+// EveryAligned 从当天0点(本地时间)起按interval对齐的调度, 如 time.Minute 在每分钟的:00,
+// 15*time.Minute 在每小时的:00,:15,:30,:45, interval 应能整除一天, 不能整除时每天0点重新对齐.
+// 按墙上时钟对齐, 夏令时跳过的时刻不调度, 重复的时刻调度两次
+func EveryAligned(interval time.Duration) Schedule {
+	if interval <= 0 || interval > 24*time.Hour {
+		interval = 24 * time.Hour
+	}
+	return ScheduleFunc(func(t time.Time) time.Time {
+		clock := clockOf(t)
+		n := (clock/interval + 1) * interval
+		if n >= 24*time.Hour {
+			return atClock(t, 1, 0)
+		}
+		// 中间没有时区变化时, 或变化后的时钟仍对齐(如回拨重复的小时)
+		next := t.Add(n - clock)
+		if clockOf(next)%interval != 0 {
+			next = atClock(t, 0, n)
+		}
+		for !next.After(t) {
+			next = next.Add(interval)
+		}
+		return next
+	})
+}
+
+// DailyAt 每天hour:minute(本地时间)的调度, 夏令时跳过该时刻时推后到跳变之后
+func DailyAt(hour, minute int) Schedule {
+	clock := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+	return ScheduleFunc(func(t time.Time) time.Time {
+		next := atClock(t, 0, clock)
+		if !next.After(t) {
+			next = atClock(t, 1, clock)
+		}
+		return next
+	})
+}
+
+// clockOf t的墙上时钟, 距当天0点的时间, 不受夏令时影响
+func clockOf(t time.Time) time.Duration {
+	hour, min, sec := t.Clock()
+	return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute +
+		time.Duration(sec)*time.Second + time.Duration(t.Nanosecond())
+}
+
+// atClock t之后第days天墙上时钟为clock的时间, 夏令时跳过的时钟推后到跳变之后
+func atClock(t time.Time, days int, clock time.Duration) time.Time {
+	y, m, d := t.Date()
+	at := time.Date(y, m, d+days, int(clock/time.Hour), int(clock%time.Hour/time.Minute),
+		int(clock%time.Minute/time.Second), int(clock%time.Second), t.Location())
+	if c := clockOf(at); c < clock && at.Day() == time.Date(y, m, d+days, 0, 0, 0, 0, t.Location()).Day() {
+		at = at.Add(clock - c)
+	}
+	return at
+}
+
+// scheduleNext 安排任务的下一次正常采集, 设置了 Schedule 时按墙上时钟, 否则按扫描速率,
+// 设置了 Calendar 时推迟到活动时间内
+func (sf *Client) scheduleNext(req *Request) {
+	now := time.Now()
+	switch {
+	case req.Schedule != nil:
+		sf.startAt(req, now, req.Schedule.Next(now))
+	case req.ScanRate > 0:
+		sf.startAt(req, now, now.Add(sf.scanRate(req)))
+	default:
+		req.next = time.Time{}
+	}
+}
@@ -0,0 +1,186 @@
+package mb
+
+import (
+	"testing"
+	"time"
+)
+
+// newYork 有夏令时的时区, 2021-03-14 02:00 跳到 03:00, 2021-11-07 02:00 回到 01:00
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	return loc
+}
+
+func TestEveryAligned(t *testing.T) {
+	loc := newYork(t)
+	utc := time.UTC
+	tests := []struct {
+		name     string
+		interval time.Duration
+		t        time.Time
+		want     time.Time
+	}{
+		{"minute", time.Minute, time.Date(2021, 6, 1, 10, 0, 30, 0, utc), time.Date(2021, 6, 1, 10, 1, 0, 0, utc)},
+		{"on the boundary", time.Minute, time.Date(2021, 6, 1, 10, 1, 0, 0, utc), time.Date(2021, 6, 1, 10, 2, 0, 0, utc)},
+		{"quarter", 15 * time.Minute, time.Date(2021, 6, 1, 10, 7, 0, 0, utc), time.Date(2021, 6, 1, 10, 15, 0, 0, utc)},
+		{"before midnight", 15 * time.Minute, time.Date(2021, 6, 1, 23, 50, 0, 0, utc), time.Date(2021, 6, 2, 0, 0, 0, 0, utc)},
+		{"year end", time.Hour, time.Date(2021, 12, 31, 23, 0, 0, 0, utc), time.Date(2022, 1, 1, 0, 0, 0, 0, utc)},
+		{"not dividing the day", 7 * time.Hour, time.Date(2021, 6, 1, 22, 0, 0, 0, utc), time.Date(2021, 6, 2, 0, 0, 0, 0, utc)},
+		{"invalid is daily", 0, time.Date(2021, 6, 1, 10, 0, 0, 0, utc), time.Date(2021, 6, 2, 0, 0, 0, 0, utc)},
+		{"spring forward hourly", time.Hour, time.Date(2021, 3, 14, 1, 30, 0, 0, loc), time.Date(2021, 3, 14, 3, 0, 0, 0, loc)},
+		{"spring forward 6h", 6 * time.Hour, time.Date(2021, 3, 14, 1, 0, 0, 0, loc), time.Date(2021, 3, 14, 6, 0, 0, 0, loc)},
+		{"fall back 6h", 6 * time.Hour, time.Date(2021, 11, 7, 1, 0, 0, 0, loc), time.Date(2021, 11, 7, 6, 0, 0, 0, loc)},
+		{"fall back daily", 24 * time.Hour, time.Date(2021, 11, 7, 12, 0, 0, 0, loc), time.Date(2021, 11, 8, 0, 0, 0, 0, loc)},
+		{"fall back repeated hour", time.Hour, time.Date(2021, 11, 7, 1, 30, 0, 0, loc), time.Date(2021, 11, 7, 1, 30, 0, 0, loc).Add(30 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EveryAligned(tt.interval).Next(tt.t); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEveryAligned_repeatedHour(t *testing.T) {
+	loc := newYork(t)
+	// every hour of the fall back day is scheduled once, the repeated 01:00 twice
+	s := EveryAligned(time.Hour)
+	next := time.Date(2021, 11, 7, 0, 0, 0, 0, loc)
+	n := 0
+	for end := time.Date(2021, 11, 8, 0, 0, 0, 0, loc); next.Before(end); n++ {
+		after := s.Next(next)
+		if !after.After(next) {
+			t.Fatalf("Next(%v) = %v, not after", next, after)
+		}
+		next = after
+	}
+	if n != 25 {
+		t.Errorf("runs on the fall back day = %v, want 25", n)
+	}
+}
+
+func TestDailyAt(t *testing.T) {
+	loc := newYork(t)
+	utc := time.UTC
+	tests := []struct {
+		name         string
+		hour, minute int
+		t            time.Time
+		want         time.Time
+	}{
+		{"later today", 6, 0, time.Date(2021, 6, 1, 5, 59, 0, 0, utc), time.Date(2021, 6, 1, 6, 0, 0, 0, utc)},
+		{"exactly now", 6, 0, time.Date(2021, 6, 1, 6, 0, 0, 0, utc), time.Date(2021, 6, 2, 6, 0, 0, 0, utc)},
+		{"tomorrow", 6, 0, time.Date(2021, 6, 1, 7, 0, 0, 0, utc), time.Date(2021, 6, 2, 6, 0, 0, 0, utc)},
+		{"midnight", 0, 0, time.Date(2021, 6, 30, 23, 59, 59, 0, utc), time.Date(2021, 7, 1, 0, 0, 0, 0, utc)},
+		{"month end", 6, 0, time.Date(2021, 2, 28, 7, 0, 0, 0, utc), time.Date(2021, 3, 1, 6, 0, 0, 0, utc)},
+		{"spring forward", 6, 0, time.Date(2021, 3, 14, 0, 30, 0, 0, loc), time.Date(2021, 3, 14, 6, 0, 0, 0, loc)},
+		{"skipped time", 2, 30, time.Date(2021, 3, 14, 0, 30, 0, 0, loc), time.Date(2021, 3, 14, 3, 30, 0, 0, loc)},
+		{"fall back", 6, 0, time.Date(2021, 11, 6, 7, 0, 0, 0, loc), time.Date(2021, 11, 7, 6, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DailyAt(tt.hour, tt.minute).Next(tt.t); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindows(t *testing.T) {
+	loc := newYork(t)
+	utc := time.UTC
+	day := Windows{{From: 6 * time.Hour, To: 22 * time.Hour}}
+	night := Windows{{From: 22 * time.Hour, To: 6 * time.Hour}}
+	// 2021-06-04 is Friday
+	fridayNight := Windows{{Weekdays: []time.Weekday{time.Friday}, From: 22 * time.Hour, To: 2 * time.Hour}}
+	weekdays := Windows{{Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		From: 8 * time.Hour, To: 24 * time.Hour}}
+	tests := []struct {
+		name       string
+		w          Windows
+		t          time.Time
+		active     bool
+		nextActive time.Time
+	}{
+		{"day inside", day, time.Date(2021, 6, 1, 12, 0, 0, 0, utc), true, time.Date(2021, 6, 2, 6, 0, 0, 0, utc)},
+		{"day from", day, time.Date(2021, 6, 1, 6, 0, 0, 0, utc), true, time.Date(2021, 6, 2, 6, 0, 0, 0, utc)},
+		{"day to", day, time.Date(2021, 6, 1, 22, 0, 0, 0, utc), false, time.Date(2021, 6, 2, 6, 0, 0, 0, utc)},
+		{"day before", day, time.Date(2021, 6, 1, 5, 0, 0, 0, utc), false, time.Date(2021, 6, 1, 6, 0, 0, 0, utc)},
+		{"night before midnight", night, time.Date(2021, 6, 1, 23, 0, 0, 0, utc), true, time.Date(2021, 6, 2, 22, 0, 0, 0, utc)},
+		{"night after midnight", night, time.Date(2021, 6, 2, 1, 0, 0, 0, utc), true, time.Date(2021, 6, 2, 22, 0, 0, 0, utc)},
+		{"night midnight", night, time.Date(2021, 6, 2, 0, 0, 0, 0, utc), true, time.Date(2021, 6, 2, 22, 0, 0, 0, utc)},
+		{"night noon", night, time.Date(2021, 6, 2, 12, 0, 0, 0, utc), false, time.Date(2021, 6, 2, 22, 0, 0, 0, utc)},
+		{"friday night on friday", fridayNight, time.Date(2021, 6, 4, 23, 0, 0, 0, utc), true, time.Date(2021, 6, 11, 22, 0, 0, 0, utc)},
+		{"friday night on saturday", fridayNight, time.Date(2021, 6, 5, 1, 0, 0, 0, utc), true, time.Date(2021, 6, 11, 22, 0, 0, 0, utc)},
+		{"friday night ended", fridayNight, time.Date(2021, 6, 5, 2, 0, 0, 0, utc), false, time.Date(2021, 6, 11, 22, 0, 0, 0, utc)},
+		{"friday night on thursday", fridayNight, time.Date(2021, 6, 3, 1, 0, 0, 0, utc), false, time.Date(2021, 6, 4, 22, 0, 0, 0, utc)},
+		{"weekdays to midnight", weekdays, time.Date(2021, 6, 4, 23, 59, 0, 0, utc), true, time.Date(2021, 6, 7, 8, 0, 0, 0, utc)},
+		{"weekend", weekdays, time.Date(2021, 6, 5, 12, 0, 0, 0, utc), false, time.Date(2021, 6, 7, 8, 0, 0, 0, utc)},
+		{"spring forward from", day, time.Date(2021, 3, 14, 6, 0, 0, 0, loc), true, time.Date(2021, 3, 15, 6, 0, 0, 0, loc)},
+		{"spring forward before", day, time.Date(2021, 3, 14, 5, 30, 0, 0, loc), false, time.Date(2021, 3, 14, 6, 0, 0, 0, loc)},
+		{"fall back to", day, time.Date(2021, 11, 7, 21, 30, 0, 0, loc), true, time.Date(2021, 11, 8, 6, 0, 0, 0, loc)},
+		{"fall back night", night, time.Date(2021, 11, 7, 5, 30, 0, 0, loc), true, time.Date(2021, 11, 7, 22, 0, 0, 0, loc)},
+		{"fall back after night", night, time.Date(2021, 11, 7, 6, 30, 0, 0, loc), false, time.Date(2021, 11, 7, 22, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.w.Active(tt.t); got != tt.active {
+				t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.active)
+			}
+			if got := tt.w.NextActive(tt.t); !got.Equal(tt.nextActive) {
+				t.Errorf("NextActive(%v) = %v, want %v", tt.t, got, tt.nextActive)
+			}
+		})
+	}
+}
+
+func TestWindow_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		w       Window
+		wantErr bool
+	}{
+		{"day", Window{From: 6 * time.Hour, To: 22 * time.Hour}, false},
+		{"crossing midnight", Window{From: 22 * time.Hour, To: 6 * time.Hour}, false},
+		{"to midnight", Window{From: 22 * time.Hour, To: 24 * time.Hour}, false},
+		{"empty", Window{From: 6 * time.Hour, To: 6 * time.Hour}, true},
+		{"from a day", Window{From: 24 * time.Hour, To: 6 * time.Hour}, true},
+		{"negative", Window{From: -time.Hour, To: 6 * time.Hour}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.w.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequest_activeAt(t *testing.T) {
+	utc := time.UTC
+	night := Windows{{From: 22 * time.Hour, To: 6 * time.Hour}}
+	tests := []struct {
+		name string
+		req  Request
+		t    time.Time
+		want time.Time
+	}{
+		{"no calendar", Request{}, time.Date(2021, 6, 1, 12, 0, 0, 0, utc), time.Date(2021, 6, 1, 12, 0, 0, 0, utc)},
+		{"inside", Request{Calendar: night}, time.Date(2021, 6, 1, 23, 0, 0, 0, utc), time.Date(2021, 6, 1, 23, 0, 0, 0, utc)},
+		{"deferred", Request{Calendar: night}, time.Date(2021, 6, 1, 12, 0, 0, 0, utc), time.Date(2021, 6, 1, 22, 0, 0, 0, utc)},
+		{"aligned in window", Request{Calendar: Windows{{From: 6*time.Hour + 10*time.Minute, To: 22 * time.Hour}}, Schedule: EveryAligned(time.Hour)},
+			time.Date(2021, 6, 1, 3, 0, 0, 0, utc), time.Date(2021, 6, 1, 7, 0, 0, 0, utc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.req.activeAt(tt.t); !got.Equal(tt.want) {
+				t.Errorf("activeAt(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package mb
+
+import (
+	"context"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// requestClient 请求使用的客户端, 事务在请求的超时内完成, 超时由上下文传给 provider,
+// 不修改 provider 的超时, 同时使用该 provider 的其它调用不受影响. 客户端停止时中止事务
+func (sf *Client) requestClient(req *Request) (modbus.Client, context.CancelFunc) {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = sf.timeout
+	}
+	if timeout <= 0 {
+		return sf.WithContext(sf.ctx), func() {}
+	}
+	ctx, cancel := context.WithTimeout(sf.ctx, timeout)
+	return sf.WithContext(ctx), cancel
+}
@@ -0,0 +1,87 @@
+package mb
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// resultHandler 记录每个请求的结果
+type resultHandler struct {
+	nopProc
+	results chan Result
+	errs    chan error
+}
+
+func newResultHandler() *resultHandler {
+	return &resultHandler{results: make(chan Result, 64), errs: make(chan error, 64)}
+}
+
+func (sf *resultHandler) ProcResult(err error, result *Result) {
+	select {
+	case sf.results <- *result:
+		sf.errs <- err
+	default:
+	}
+}
+
+// next 等待下一个结果
+func (sf *resultHandler) next(t *testing.T) (Result, error) {
+	t.Helper()
+	select {
+	case r := <-sf.results:
+		return r, <-sf.errs
+	case <-time.After(3 * time.Second):
+		t.Fatal("no result")
+	}
+	return Result{}, nil
+}
+
+func TestClient_RequestTimeout(t *testing.T) {
+	// the server accept the connection but never reply
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	p := modbus.NewTCPClientProvider(ln.Addr().String())
+	h := newResultHandler()
+	c := NewClient(p, WitchHandler(h))
+	// the provider timeout set after the client is created is kept
+	p.SetTimeout(time.Minute)
+	if err = c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err = c.AddGatherJob(Request{
+		SlaveID:  1,
+		FuncCode: modbus.FuncCodeReadHoldingRegisters,
+		Quantity: 1,
+		ScanRate: 50 * time.Millisecond,
+		Timeout:  100 * time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = h.next(t); err == nil {
+		t.Fatal("ProcResult() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v, want the request timeout", elapsed)
+	}
+	if p.Timeout != time.Minute {
+		t.Errorf("provider Timeout = %v, want unchanged", p.Timeout)
+	}
+}
@@ -0,0 +1,128 @@
+package mb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aloncn/timing"
+)
+
+// Calendar 任务的活动时间, 非活动时任务暂停, 用于夜间断电的从机等
+type Calendar interface {
+	// Active t是否活动
+	Active(t time.Time) bool
+	// NextActive t之后最早的活动开始时间, 没有时为零值
+	NextActive(t time.Time) time.Time
+}
+
+// Window 活动时间窗口(本地时间)
+type Window struct {
+	Weekdays []time.Weekday // 窗口开始的星期, 为空时每天
+	// 开始和结束的墙上时钟(距当天0点), 包含From不包含To, To小于From时跨过午夜,
+	// 如 From: 6*time.Hour, To: 22*time.Hour 为 06:00~22:00
+	From, To time.Duration
+}
+
+// validate 检查窗口
+func (sf Window) validate() error {
+	if sf.From < 0 || sf.From >= 24*time.Hour || sf.To < 0 || sf.To > 24*time.Hour || sf.From == sf.To {
+		return errors.New("mb: invalid window")
+	}
+	return nil
+}
+
+// onDay 窗口是否在星期day开始
+func (sf Window) onDay(day time.Weekday) bool {
+	if len(sf.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range sf.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// contains t是否在窗口内
+func (sf Window) contains(t time.Time) bool {
+	offset := clockOf(t)
+	if sf.From < sf.To {
+		return sf.onDay(t.Weekday()) && offset >= sf.From && offset < sf.To
+	}
+	// 跨过午夜, 前一天开始的窗口
+	return sf.onDay(t.Weekday()) && offset >= sf.From ||
+		sf.onDay((t.Weekday()+6)%7) && offset < sf.To
+}
+
+// Windows 多个活动时间窗口, 在任一窗口内为活动, 实现 Calendar,
+// 如 mb.Windows{{Weekdays: weekdays, From: 6 * time.Hour, To: 22 * time.Hour}}
+type Windows []Window
+
+// validate 检查窗口
+func (sf Windows) validate() error {
+	for _, w := range sf {
+		if err := w.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Active implements Calendar
+func (sf Windows) Active(t time.Time) bool {
+	for _, w := range sf {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextActive implements Calendar
+func (sf Windows) NextActive(t time.Time) time.Time {
+	var start time.Time
+	for i := 0; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, t.Location())
+		for _, w := range sf {
+			if at := atClock(t, i, w.From); w.onDay(day.Weekday()) && at.After(t) && (start.IsZero() || at.Before(start)) {
+				start = at
+			}
+		}
+		if !start.IsZero() {
+			return start
+		}
+	}
+	return start
+}
+
+// active t任务是否活动
+func (sf *Request) active(t time.Time) bool {
+	return sf.Calendar == nil || sf.Calendar.Active(t)
+}
+
+// activeAt 不早于t且活动的采集时间, 设置了 Schedule 时仍按其对齐
+func (sf *Request) activeAt(t time.Time) time.Time {
+	for i := 0; i < 1000 && !sf.active(t); i++ {
+		start := sf.Calendar.NextActive(t)
+		if start.IsZero() {
+			break
+		}
+		if sf.Schedule == nil {
+			return start
+		}
+		t = sf.Schedule.Next(start.Add(-time.Nanosecond))
+	}
+	return t
+}
+
+// startAt 在next之后的活动时间启动任务
+func (sf *Client) startAt(req *Request, now, next time.Time) {
+	next = req.activeAt(next)
+	if req.Schedule != nil {
+		req.next = next
+	} else {
+		req.next = time.Time{}
+	}
+	timing.Start(req.tm, next.Sub(now))
+}
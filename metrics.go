@@ -0,0 +1,51 @@
+package modbus
+
+import (
+	"time"
+)
+
+// ServerMetrics 服务端统计接口,可适配prometheus等监控系统
+type ServerMetrics interface {
+	// ConnectionOpened 连接建立
+	ConnectionOpened()
+	// ConnectionClosed 连接断开
+	ConnectionClosed()
+	// RequestHandled 请求处理完成,不存在的从机地址的请求不回复,也不统计
+	RequestHandled(stat RequestStat)
+}
+
+// RequestStat 请求处理统计
+type RequestStat struct {
+	SlaveID       byte          // 从机地址
+	FuncCode      byte          // 请求的功能码
+	ExceptionCode byte          // 异常码,0表示正常响应
+	RequestSize   int           // 请求pdu字节数
+	ResponseSize  int           // 响应pdu字节数
+	Latency       time.Duration // 处理耗时
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ConnectionOpened()          {}
+func (nopMetrics) ConnectionClosed()          {}
+func (nopMetrics) RequestHandled(RequestStat) {}
+
+// SetMetrics 设置统计接口
+func (sf *serverCommon) SetMetrics(m ServerMetrics) {
+	if m != nil {
+		sf.metrics.Store(metricsHolder{m})
+	}
+}
+
+// serverMetrics 获取统计接口
+func (sf *serverCommon) serverMetrics() ServerMetrics {
+	if v, ok := sf.metrics.Load().(metricsHolder); ok {
+		return v.ServerMetrics
+	}
+	return nopMetrics{}
+}
+
+// metricsHolder atomic.Value 需要存储相同的具体类型
+type metricsHolder struct {
+	ServerMetrics
+}
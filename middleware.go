@@ -0,0 +1,62 @@
+package modbus
+
+import (
+	"context"
+)
+
+// Doer does a modbus transaction, send the request and return the response.
+type Doer interface {
+	Do(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+}
+
+// DoerFunc is an adapter to allow the use of ordinary functions as Doer.
+type DoerFunc func(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+
+// Do implements Doer,calls f(slaveID, request).
+func (f DoerFunc) Do(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return f(slaveID, request)
+}
+
+// ContextDoer a Doer which accept the context of the transaction, see Client.WithContext,
+// the deadline of the context bound the transaction instead of the provider timeout.
+type ContextDoer interface {
+	Doer
+	DoContext(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+}
+
+// ContextDoerFunc is an adapter to allow the use of ordinary functions as ContextDoer.
+type ContextDoerFunc func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
+
+// Do implements Doer, calls f(context.Background(), slaveID, request).
+func (f ContextDoerFunc) Do(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return f(context.Background(), slaveID, request)
+}
+
+// DoContext implements ContextDoer, calls f(ctx, slaveID, request).
+func (f ContextDoerFunc) DoContext(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return f(ctx, slaveID, request)
+}
+
+// DoContext do the transaction with the context if d is a ContextDoer,
+// otherwise the context is dropped and d.Do is called.
+func DoContext(ctx context.Context, d Doer, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	if cd, ok := d.(ContextDoer); ok {
+		return cd.DoContext(ctx, slaveID, request)
+	}
+	return d.Do(slaveID, request)
+}
+
+// Middleware client request/response interceptor,
+// it can see the slave id, function code, pdu data and error of every transaction,
+// enabling metrics, tracing, and request rewriting.
+// the middleware should return a ContextDoer and call DoContext on next to pass
+// the context of Client.WithContext on, the one return a plain Doer drop it.
+type Middleware func(next Doer) Doer
+
+// chain build the middleware chain, the first middleware is the outermost.
+func chain(d Doer, mws ...Middleware) Doer {
+	for i := len(mws) - 1; i >= 0; i-- {
+		d = mws[i](d)
+	}
+	return d
+}
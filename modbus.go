@@ -98,6 +98,10 @@ const (
 	ReadWriteOnReadRegQuantityMax  = 125 // 0x007d
 	ReadWriteOnWriteRegQuantityMin = 1   // 1
 	ReadWriteOnWriteRegQuantityMax = 121 // 0x0079
+	// File record
+	FileRecordNumberMax      = 9999 // 0x270f
+	ReadFileRecordLengthMax  = 124  // 0x007c
+	WriteFileRecordLengthMax = 122  // 0x007a
 )
 
 // Function Code
@@ -116,6 +120,8 @@ const (
 	FuncCodeReadWriteMultipleRegisters = 23
 	FuncCodeMaskWriteRegister          = 22
 	FuncCodeReadFIFOQueue              = 24
+	FuncCodeReadFileRecord             = 20
+	FuncCodeWriteFileRecord            = 21
 	FuncCodeOtherReportSlaveID         = 17
 	// FuncCodeDiagReadException          = 7
 	// FuncCodeDiagDiagnostic             = 8
@@ -138,40 +144,43 @@ const (
 )
 
 // ExceptionError implements error interface.
+// it is returned by client calls when the slave reply a exception response,
+// use AsExceptionError or the IsXXX helpers to branch on it.
 type ExceptionError struct {
+	FuncCode      byte // function code of the request, without 0x80, 0 if unknown
 	ExceptionCode byte
 }
 
 // Error converts known modbus exception code to error message.
 func (e *ExceptionError) Error() string {
-	var name string
-	switch e.ExceptionCode {
-	case ExceptionCodeIllegalFunction:
-		name = "illegal function"
-	case ExceptionCodeIllegalDataAddress:
-		name = "illegal data address"
-	case ExceptionCodeIllegalDataValue:
-		name = "illegal data value"
-	case ExceptionCodeServerDeviceFailure:
-		name = "server device failure"
-	case ExceptionCodeAcknowledge:
-		name = "acknowledge"
-	case ExceptionCodeServerDeviceBusy:
-		name = "server device busy"
-	case ExceptionCodeNegativeAcknowledge:
-		name = "Negative Acknowledge"
-	case ExceptionCodeMemoryParityError:
-		name = "memory parity error"
-	case ExceptionCodeGatewayPathUnavailable:
-		name = "gateway path unavailable"
-	case ExceptionCodeGatewayTargetDeviceFailedToRespond:
-		name = "gateway target device failed to respond"
-	default:
+	name := Exception(e.ExceptionCode).name()
+	if name == "" {
 		name = "unknown"
 	}
+	if e.FuncCode != 0 {
+		return fmt.Sprintf("modbus: function '%v' exception '%v' (%s)", e.FuncCode, e.ExceptionCode, name)
+	}
 	return fmt.Sprintf("modbus: exception '%v' (%s)", e.ExceptionCode, name)
 }
 
+// Is reports whether target is a *ExceptionError with the same exception code,
+// and the same function code if the target's function code is not 0.
+// it make errors.Is work with the *ExceptionError target.
+func (e *ExceptionError) Is(target error) bool {
+	t, ok := target.(*ExceptionError)
+	return ok && t.ExceptionCode == e.ExceptionCode &&
+		(t.FuncCode == 0 || t.FuncCode == e.FuncCode)
+}
+
+// exceptionCode got the exception code of the error,
+// if it is not *ExceptionError, it is server device failure.
+func exceptionCode(err error) byte {
+	if e, ok := AsExceptionError(err); ok {
+		return e.ExceptionCode
+	}
+	return ExceptionCodeServerDeviceFailure
+}
+
 // protocolTCPHeader independent of underlying communication layers.
 type protocolTCPHeader struct {
 	transactionID uint16
@@ -210,7 +219,9 @@ type ClientProvider interface {
 	Close() error
 	// Send request to the remote server,it implements on SendRawFrame
 	Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error)
-	// SendPdu send pdu request to the remote server
+	// SendPdu send pdu request to the remote server, the pdu can be crafted arbitrary,
+	// it reuse the framing, CRC/LRC, timeout and logging of the provider,
+	// the response pdu is returned, exception response is returned as *ExceptionError
 	SendPdu(slaveID byte, pduRequest []byte) (pduResponse []byte, err error)
 	// SendRawFrame send raw frame to the remote server
 	SendRawFrame(aduRequest []byte) (aduResponse []byte, err error)
@@ -222,6 +233,28 @@ type LogProvider interface {
 	Debug(format string, v ...interface{})
 }
 
+// LogLeveler ClientProvider which support the log output level, the providers of the package implement it,
+// it is not a part of ClientProvider, so check it by type assertion.
+type LogLeveler interface {
+	// SetLogLevel set log output level
+	SetLogLevel(level LogLevel)
+}
+
+// setLogLevel set the log output level of p if it implements LogLeveler
+func setLogLevel(p ClientProvider, level LogLevel) {
+	if l, ok := p.(LogLeveler); ok {
+		l.SetLogLevel(level)
+	}
+}
+
+// FieldLogProvider LogProvider which support tag the entries with fields,
+// such as "slave" and "remote", so it can flow into structured logging pipeline.
+type FieldLogProvider interface {
+	LogProvider
+	// With return a LogProvider carry the key value pairs
+	With(keyvals ...interface{}) LogProvider
+}
+
 func SetSpecialAddressMax(addr byte) {
 	AddressMax = addr
 }
@@ -0,0 +1,257 @@
+// Package modbustest provides utilities for testing the modbus applications,
+// the Provider is a programmable mock modbus.ClientProvider, the expected requests
+// are replied with the canned responses or errors, without real sockets or serial ports.
+//
+//	p := modbustest.NewProvider()
+//	p.Expect(1, modbus.FuncCodeReadHoldingRegisters, 0x00, 0x00, 0x00, 0x02).ReturnRegisters(1, 2)
+//	c := modbus.NewClient(p)
+//	... // the code under test use c
+//	if err := p.Verify(); err != nil {
+//		t.Error(err)
+//	}
+package modbustest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// ErrUnexpected the request does not match any expectation
+var ErrUnexpected = errors.New("modbustest: unexpected request")
+
+// Call a request received by the provider
+type Call struct {
+	SlaveID byte
+	Request modbus.ProtocolDataUnit
+}
+
+// Expectation an expected request and its reply
+type Expectation struct {
+	slaveID  byte
+	funcCode byte
+	data     []byte // nil match any data
+	response []byte // response data, with the request function code
+	err      error
+	times    int // remaining times, -1 unlimited
+}
+
+// Return reply the response data, the function code is the same as the request
+func (sf *Expectation) Return(data ...byte) *Expectation {
+	sf.response, sf.err = data, nil
+	return sf
+}
+
+// ReturnRegisters reply the registers read, with the byte count
+func (sf *Expectation) ReturnRegisters(values ...uint16) *Expectation {
+	data := make([]byte, 1+len(values)*2)
+	data[0] = byte(len(values) * 2)
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[1+i*2:], v)
+	}
+	return sf.Return(data...)
+}
+
+// ReturnBits reply the coils or discrete inputs read, with the byte count
+func (sf *Expectation) ReturnBits(values ...bool) *Expectation {
+	data := make([]byte, 1+(len(values)+7)/8)
+	data[0] = byte(len(data) - 1)
+	for i, v := range values {
+		if v {
+			data[1+i/8] |= 1 << uint(i%8)
+		}
+	}
+	return sf.Return(data...)
+}
+
+// ReturnException reply the exception response with the code
+func (sf *Expectation) ReturnException(code byte) *Expectation {
+	sf.response, sf.err = nil, &modbus.ExceptionError{FuncCode: sf.funcCode, ExceptionCode: code}
+	return sf
+}
+
+// ReturnError fail the request with the error, such as timeout or crc error
+func (sf *Expectation) ReturnError(err error) *Expectation {
+	sf.response, sf.err = nil, err
+	return sf
+}
+
+// Times the expectation is matched n times, default 1, n <= 0 means unlimited
+func (sf *Expectation) Times(n int) *Expectation {
+	if n <= 0 {
+		n = -1
+	}
+	sf.times = n
+	return sf
+}
+
+// match whether the request match the expectation
+func (sf *Expectation) match(slaveID byte, request modbus.ProtocolDataUnit) bool {
+	return sf.times != 0 && sf.slaveID == slaveID && sf.funcCode == request.FuncCode &&
+		(sf.data == nil || bytes.Equal(sf.data, request.Data))
+}
+
+// Provider a programmable mock modbus.ClientProvider,
+// the request is matched against the expectations in the order they are added,
+// the unmatched one fail with ErrUnexpected. it is safe for concurrent use.
+type Provider struct {
+	mu           sync.Mutex
+	connected    bool
+	expectations []*Expectation
+	script       []error
+	calls        []Call
+	unexpected   []Call
+}
+
+// check implements ClientProvider interface
+var _ modbus.ClientProvider = (*Provider)(nil)
+
+// NewProvider create a mock provider which is connected
+func NewProvider() *Provider {
+	return &Provider{connected: true}
+}
+
+// Expect add the expected request, data nil match any data of the function code,
+// the default reply is an empty response, set it with the Return methods.
+func (sf *Provider) Expect(slaveID, funcCode byte, data ...byte) *Expectation {
+	e := &Expectation{slaveID: slaveID, funcCode: funcCode, data: data, times: 1}
+	sf.mu.Lock()
+	sf.expectations = append(sf.expectations, e)
+	sf.mu.Unlock()
+	return e
+}
+
+// Script the next transactions fail with the errors in order before matching the expectations,
+// nil in errs let the transaction go on matching, it simulates the link errors such as timeout.
+func (sf *Provider) Script(errs ...error) {
+	sf.mu.Lock()
+	sf.script = append(sf.script, errs...)
+	sf.mu.Unlock()
+}
+
+// Calls return the requests received in order
+func (sf *Provider) Calls() []Call {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return append([]Call(nil), sf.calls...)
+}
+
+// Verify return an error if some expectations are not met or some requests are unexpected,
+// the unlimited expectations are not required.
+func (sf *Provider) Verify() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if len(sf.unexpected) > 0 {
+		c := sf.unexpected[0]
+		return fmt.Errorf("modbustest: %d unexpected requests, the first is slave '%v' function '%v' data [% x]",
+			len(sf.unexpected), c.SlaveID, c.Request.FuncCode, c.Request.Data)
+	}
+	for _, e := range sf.expectations {
+		if e.times > 0 {
+			return fmt.Errorf("modbustest: expected slave '%v' function '%v' data [% x] %d more times",
+				e.slaveID, e.funcCode, e.data, e.times)
+		}
+	}
+	return nil
+}
+
+// Connect mark the provider connected
+func (sf *Provider) Connect() error {
+	sf.mu.Lock()
+	sf.connected = true
+	sf.mu.Unlock()
+	return nil
+}
+
+// IsConnected whether the provider is connected
+func (sf *Provider) IsConnected() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.connected
+}
+
+// Close mark the provider closed, the requests fail with modbus.ErrClosedConnection
+func (sf *Provider) Close() error {
+	sf.mu.Lock()
+	sf.connected = false
+	sf.mu.Unlock()
+	return nil
+}
+
+// SetAutoReconnect do nothing
+func (*Provider) SetAutoReconnect(byte) {}
+
+// LogMode do nothing
+func (*Provider) LogMode(bool) {}
+
+// SetLogLevel do nothing
+func (*Provider) SetLogLevel(modbus.LogLevel) {}
+
+// SetLogProvider do nothing
+func (*Provider) SetLogProvider(modbus.LogProvider) {}
+
+// Send reply the request with the matched expectation
+func (sf *Provider) Send(slaveID byte, request modbus.ProtocolDataUnit) (modbus.ProtocolDataUnit, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if !sf.connected {
+		return modbus.ProtocolDataUnit{}, modbus.ErrClosedConnection
+	}
+	call := Call{slaveID, modbus.ProtocolDataUnit{
+		FuncCode: request.FuncCode,
+		Data:     append([]byte(nil), request.Data...),
+	}}
+	sf.calls = append(sf.calls, call)
+	if len(sf.script) > 0 {
+		err := sf.script[0]
+		sf.script = sf.script[1:]
+		if err != nil {
+			return modbus.ProtocolDataUnit{}, err
+		}
+	}
+	for _, e := range sf.expectations {
+		if !e.match(slaveID, request) {
+			continue
+		}
+		if e.times > 0 {
+			e.times--
+		}
+		if e.err != nil {
+			if ee, ok := e.err.(*modbus.ExceptionError); ok {
+				return modbus.ProtocolDataUnit{
+					FuncCode: request.FuncCode | 0x80,
+					Data:     []byte{ee.ExceptionCode},
+				}, e.err
+			}
+			return modbus.ProtocolDataUnit{}, e.err
+		}
+		return modbus.ProtocolDataUnit{
+			FuncCode: request.FuncCode,
+			Data:     append([]byte(nil), e.response...),
+		}, nil
+	}
+	sf.unexpected = append(sf.unexpected, call)
+	return modbus.ProtocolDataUnit{}, ErrUnexpected
+}
+
+// SendPdu reply the pdu request with the matched expectation
+func (sf *Provider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) == 0 {
+		return nil, fmt.Errorf("modbustest: pdu is empty")
+	}
+	response, err := sf.Send(slaveID, modbus.ProtocolDataUnit{FuncCode: pduRequest[0], Data: pduRequest[1:]})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{response.FuncCode}, response.Data...), nil
+}
+
+// SendRawFrame is not supported, the mock has no framing
+func (*Provider) SendRawFrame([]byte) ([]byte, error) {
+	return nil, errors.New("modbustest: raw frame is not supported")
+}
@@ -0,0 +1,64 @@
+package modbustest
+
+import (
+	"reflect"
+	"testing"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+func TestProvider(t *testing.T) {
+	p := NewProvider()
+	p.Expect(1, modbus.FuncCodeReadHoldingRegisters, 0x00, 0x00, 0x00, 0x02).ReturnRegisters(1, 2)
+	p.Expect(1, modbus.FuncCodeReadCoils).ReturnBits(true, false, true).Times(0)
+	p.Expect(2, modbus.FuncCodeWriteSingleRegister).ReturnException(modbus.ExceptionCodeIllegalDataAddress)
+	c := modbus.NewClient(p)
+
+	regs, err := c.ReadHoldingRegisters(1, 0, 2)
+	if err != nil || !reflect.DeepEqual(regs, []uint16{1, 2}) {
+		t.Errorf("ReadHoldingRegisters() = %v, %v, want [1 2]", regs, err)
+	}
+	for i := 0; i < 2; i++ {
+		coils, err := c.ReadCoils(1, 10, 3)
+		if err != nil || !reflect.DeepEqual(coils, []byte{0x05}) {
+			t.Errorf("ReadCoils() = %v, %v, want [5]", coils, err)
+		}
+	}
+	if err = c.WriteSingleRegister(2, 0, 1); !modbus.IsIllegalDataAddress(err) {
+		t.Errorf("WriteSingleRegister() error = %v, want illegal data address", err)
+	}
+	if err = p.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if _, err = c.ReadHoldingRegisters(1, 0, 2); err != ErrUnexpected {
+		t.Errorf("ReadHoldingRegisters() error = %v, want %v", err, ErrUnexpected)
+	}
+	if err = p.Verify(); err == nil {
+		t.Errorf("Verify() want unexpected request error")
+	}
+	if n := len(p.Calls()); n != 5 {
+		t.Errorf("Calls() = %v, want 5", n)
+	}
+}
+
+func TestProvider_Script(t *testing.T) {
+	p := NewProvider()
+	p.Expect(1, modbus.FuncCodeReadInputRegisters).ReturnRegisters(7).Times(0)
+	p.Script(modbus.ErrClosedConnection, nil)
+	c := modbus.NewClient(p)
+	if _, err := c.ReadInputRegisters(1, 0, 1); err != modbus.ErrClosedConnection {
+		t.Errorf("ReadInputRegisters() error = %v, want %v", err, modbus.ErrClosedConnection)
+	}
+	if _, err := c.ReadInputRegisters(1, 0, 1); err != nil {
+		t.Errorf("ReadInputRegisters() error = %v", err)
+	}
+	// the unlimited expectation is not required
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	p.Expect(1, modbus.FuncCodeWriteSingleCoil)
+	if err := p.Verify(); err == nil {
+		t.Errorf("Verify() want unmet expectation error")
+	}
+}
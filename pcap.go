@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// link type of the pcap file
+const (
+	// LinkTypeRaw raw IPv4, the ADU is wrapped in IPv4/TCP header with port 502,
+	// so Wireshark decode it as Modbus/TCP directly, use it for TCP client.
+	LinkTypeRaw uint32 = 101
+	// LinkTypeUser0 DLT_USER0, the ADU is written as is, use it for RTU/ASCII client,
+	// config Wireshark "DLT_USER" protocol preference with "mbrtu" payload to decode it.
+	LinkTypeUser0 uint32 = 147
+)
+
+// pcap file format constant
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 65535
+
+	ipv4HeaderSize = 20
+	tcpHeaderSize  = 20
+	pcapMasterPort = 49152
+	pcapSlavePort  = 502
+)
+
+var (
+	pcapMasterIP = [4]byte{10, 0, 0, 1}
+	pcapSlaveIP  = [4]byte{10, 0, 0, 2}
+)
+
+// PcapWriter write every sent and received ADU with timestamp into a pcap file,
+// it is safe for concurrent use.
+type PcapWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	linkType uint32
+	seq      [2]uint32 // tcp sequence number of master and slave
+}
+
+// NewPcapWriter create a pcap writer, and write the pcap file header
+func NewPcapWriter(w io.Writer, linkType uint32) (*PcapWriter, error) {
+	var head [24]byte
+
+	binary.LittleEndian.PutUint32(head[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(head[4:], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(head[6:], pcapVersionMinor)
+	// thiszone and sigfigs are zero
+	binary.LittleEndian.PutUint32(head[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(head[20:], linkType)
+	if _, err := w.Write(head[:]); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w, linkType: linkType, seq: [2]uint32{1, 1}}, nil
+}
+
+// WriteFrame write a ADU record, isSend indicate the ADU is sent by master
+func (sf *PcapWriter) WriteFrame(ts time.Time, isSend bool, adu []byte) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	data := adu
+	if sf.linkType == LinkTypeRaw {
+		data = sf.wrapTCP(isSend, adu)
+	}
+	var head [16]byte
+	binary.LittleEndian.PutUint32(head[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(head[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(head[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(head[12:], uint32(len(data)))
+	if _, err := sf.w.Write(head[:]); err != nil {
+		return err
+	}
+	_, err := sf.w.Write(data)
+	return err
+}
+
+// wrapTCP wrap the adu with IPv4 and TCP header
+func (sf *PcapWriter) wrapTCP(isSend bool, adu []byte) []byte {
+	srcIP, dstIP := pcapMasterIP, pcapSlaveIP
+	srcPort, dstPort := uint16(pcapMasterPort), uint16(pcapSlavePort)
+	seq, ack := &sf.seq[0], sf.seq[1]
+	if !isSend {
+		srcIP, dstIP = dstIP, srcIP
+		srcPort, dstPort = dstPort, srcPort
+		seq, ack = &sf.seq[1], sf.seq[0]
+	}
+
+	pkt := make([]byte, ipv4HeaderSize+tcpHeaderSize+len(adu))
+	// IPv4 header
+	ip := pkt[:ipv4HeaderSize]
+	ip[0] = 0x45 // version 4, header length 5 words
+	binary.BigEndian.PutUint16(ip[2:], uint16(len(pkt)))
+	ip[8] = 64 // ttl
+	ip[9] = 6  // protocol tcp
+	copy(ip[12:], srcIP[:])
+	copy(ip[16:], dstIP[:])
+	binary.BigEndian.PutUint16(ip[10:], checksum(0, ip))
+
+	// TCP header
+	tcp := pkt[ipv4HeaderSize:]
+	binary.BigEndian.PutUint16(tcp[0:], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], *seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = 5 << 4 // data offset 5 words
+	tcp[13] = 0x18   // PSH ACK
+	binary.BigEndian.PutUint16(tcp[14:], 0xffff)
+	copy(tcp[tcpHeaderSize:], adu)
+	// pseudo header
+	var pseudo [12]byte
+	copy(pseudo[0:], srcIP[:])
+	copy(pseudo[4:], dstIP[:])
+	pseudo[9] = 6
+	binary.BigEndian.PutUint16(pseudo[10:], uint16(len(tcp)))
+	binary.BigEndian.PutUint16(tcp[16:], checksum(sum16(0, pseudo[:]), tcp))
+
+	*seq += uint32(len(adu))
+	return pkt
+}
+
+// sum16 ones' complement sum of the 16-bit words
+func sum16(sum uint32, b []byte) uint32 {
+	for ; len(b) >= 2; b = b[2:] {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	return sum
+}
+
+// checksum internet checksum
+func checksum(sum uint32, b []byte) uint16 {
+	sum = sum16(sum, b)
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
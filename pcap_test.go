@@ -0,0 +1,72 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestPcapWriter(t *testing.T) {
+	ts := time.Unix(1500000000, 123456000)
+	adu := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x02}
+
+	tests := []struct {
+		name     string
+		linkType uint32
+		wantLen  int
+	}{
+		{"raw ipv4", LinkTypeRaw, ipv4HeaderSize + tcpHeaderSize + len(adu)},
+		{"user0", LinkTypeUser0, len(adu)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewPcapWriter(&buf, tt.linkType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteFrame(ts, true, adu); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.WriteFrame(ts, false, adu); err != nil {
+				t.Fatal(err)
+			}
+
+			b := buf.Bytes()
+			if got := binary.LittleEndian.Uint32(b); got != pcapMagic {
+				t.Fatalf("magic = %#x, want %#x", got, pcapMagic)
+			}
+			if got := binary.LittleEndian.Uint32(b[20:]); got != tt.linkType {
+				t.Fatalf("link type = %d, want %d", got, tt.linkType)
+			}
+			b = b[24:]
+			for i := 0; i < 2; i++ {
+				if got := binary.LittleEndian.Uint32(b[4:]); got != 123456 {
+					t.Errorf("usec = %d, want 123456", got)
+				}
+				n := int(binary.LittleEndian.Uint32(b[8:]))
+				if n != tt.wantLen {
+					t.Fatalf("record length = %d, want %d", n, tt.wantLen)
+				}
+				data := b[16 : 16+n]
+				if !bytes.Equal(data[n-len(adu):], adu) {
+					t.Errorf("payload = % x, want % x", data[n-len(adu):], adu)
+				}
+				if tt.linkType == LinkTypeRaw {
+					if checksum(0, data[:ipv4HeaderSize]) != 0 {
+						t.Errorf("invalid ipv4 header checksum")
+					}
+					port := binary.BigEndian.Uint16(data[ipv4HeaderSize+2:])
+					if i == 1 {
+						port = binary.BigEndian.Uint16(data[ipv4HeaderSize:])
+					}
+					if port != pcapSlavePort {
+						t.Errorf("slave port = %d, want %d", port, pcapSlavePort)
+					}
+				}
+				b = b[16+n:]
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Package profile describe a device model by data: the named registers, their types,
+// scaling and poll groups, so supporting a new device model is a profile, not code.
+// a Device instantiate a profile on a slave into the tags points, the mb gather jobs
+// and the handler decoding the polled blocks.
+//
+// the profile is usually written in YAML, such as
+//
+//	name: acme-pm100
+//	manufacturer: Acme
+//	model: PM100
+//	groups:
+//	  - {name: fast, scanRate: 1s}
+//	  - {name: energy, scanRate: 1m}
+//	registers:
+//	  - {name: voltage, table: input, address: 0, type: uint16, scale: 0.1, unit: V, group: fast}
+//	  - {name: energy, table: input, address: 10, type: uint32, order: CDAB, unit: kWh, group: energy}
+//
+// the package does not depend on a YAML library, pass the Unmarshal of the library you use,
+// such as gopkg.in/yaml.v2, to Parse or Registry.Load, or nil for JSON, which is also valid YAML.
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+	"github.com/aloncn/gomodbus/tags"
+)
+
+// Unmarshal decode the profile document, such as yaml.Unmarshal
+type Unmarshal func(data []byte, v interface{}) error
+
+// Duration a time.Duration in the text form, such as "1.5s"
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Register a named register of the device model
+type Register struct {
+	Name    string  `json:"name" yaml:"name"`
+	Table   string  `json:"table" yaml:"table"` // coils, discrete, input or holding
+	Address uint16  `json:"address" yaml:"address"`
+	Type    string  `json:"type,omitempty" yaml:"type,omitempty"`   // see tags.Type, default bool on the bit tables and uint16 on the registers
+	Scale   float64 `json:"scale,omitempty" yaml:"scale,omitempty"` // 0 means 1
+	Offset  float64 `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Order   string  `json:"order,omitempty" yaml:"order,omitempty"` // ABCD, DCBA, BADC or CDAB, default ABCD
+	Unit    string  `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Group   string  `json:"group,omitempty" yaml:"group,omitempty"` // the poll group, empty for the profile scan rate
+}
+
+// Group a poll group, the registers of the group are polled at the scan rate
+type Group struct {
+	Name     string   `json:"name" yaml:"name"`
+	ScanRate Duration `json:"scanRate" yaml:"scanRate"`
+}
+
+// Profile a device model
+type Profile struct {
+	Name         string     `json:"name" yaml:"name"`
+	Manufacturer string     `json:"manufacturer,omitempty" yaml:"manufacturer,omitempty"`
+	Model        string     `json:"model,omitempty" yaml:"model,omitempty"`
+	ScanRate     Duration   `json:"scanRate,omitempty" yaml:"scanRate,omitempty"` // the scan rate of the registers not in a group, 0 poll once
+	Groups       []Group    `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Registers    []Register `json:"registers" yaml:"registers"`
+}
+
+// tables the table names
+var tables = map[string]modbus.Table{
+	"coils":    modbus.TableCoils,
+	"discrete": modbus.TableDiscreteInputs,
+	"input":    modbus.TableInputRegisters,
+	"holding":  modbus.TableHoldingRegisters,
+}
+
+// Parse decode and validate a profile, unmarshal nil for JSON
+func Parse(data []byte, unmarshal Unmarshal) (*Profile, error) {
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	p := &Profile{}
+	if err := unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate check the profile
+func (sf *Profile) Validate() error {
+	if sf.Name == "" {
+		return errors.New("profile: name is empty")
+	}
+	_, err := sf.points(1)
+	return err
+}
+
+// scanRates the scan rate of the groups, "" for the profile scan rate
+func (sf *Profile) scanRates() (map[string]time.Duration, error) {
+	rates := map[string]time.Duration{"": time.Duration(sf.ScanRate)}
+	for _, g := range sf.Groups {
+		if _, ok := rates[g.Name]; ok || g.Name == "" {
+			return nil, fmt.Errorf("profile '%s': invalid or duplicate group '%s'", sf.Name, g.Name)
+		}
+		if g.ScanRate <= 0 {
+			return nil, fmt.Errorf("profile '%s': group '%s' scan rate must be positive", sf.Name, g.Name)
+		}
+		rates[g.Name] = time.Duration(g.ScanRate)
+	}
+	return rates, nil
+}
+
+// points the points of the registers on the slave
+func (sf *Profile) points(slaveID byte) ([]tags.Point, error) {
+	rates, err := sf.scanRates()
+	if err != nil {
+		return nil, err
+	}
+	points := make([]tags.Point, 0, len(sf.Registers))
+	for _, r := range sf.Registers {
+		p, err := r.point(slaveID)
+		if err == nil {
+			if _, ok := rates[r.Group]; !ok {
+				err = fmt.Errorf("unknown group '%s'", r.Group)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("profile '%s' register '%s': %v", sf.Name, r.Name, err)
+		}
+		points = append(points, p)
+	}
+	if _, err = tags.NewSet(points...); err != nil {
+		return nil, fmt.Errorf("profile '%s': %v", sf.Name, err)
+	}
+	return points, nil
+}
+
+// point the tags point of the register on the slave
+func (sf *Register) point(slaveID byte) (tags.Point, error) {
+	table, ok := tables[sf.Table]
+	if !ok {
+		return tags.Point{}, fmt.Errorf("unknown table '%s'", sf.Table)
+	}
+	p := tags.Point{
+		Name:    sf.Name,
+		SlaveID: slaveID,
+		Table:   table,
+		Address: sf.Address,
+		Type:    tags.Uint16,
+		Scale:   sf.Scale,
+		Offset:  sf.Offset,
+	}
+	if table == modbus.TableCoils || table == modbus.TableDiscreteInputs {
+		p.Type = tags.Bool
+	}
+	if sf.Type != "" {
+		if p.Type, ok = parseType(sf.Type); !ok {
+			return p, fmt.Errorf("unknown type '%s'", sf.Type)
+		}
+	}
+	if sf.Order != "" {
+		if p.Order, ok = parseOrder(sf.Order); !ok {
+			return p, fmt.Errorf("unknown order '%s'", sf.Order)
+		}
+	}
+	return p, p.Validate()
+}
+
+// parseType the type of the name
+func parseType(name string) (tags.Type, bool) {
+	for t := tags.Bool; t <= tags.Float64; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// parseOrder the order of the name
+func parseOrder(name string) (modbus.Order, bool) {
+	for o := modbus.OrderABCD; o <= modbus.OrderCDAB; o++ {
+		if o.String() == name {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// Device a profile instantiated on a slave
+type Device struct {
+	Profile *Profile
+	SlaveID byte
+	// Points the points named by the registers, read and write them by name
+	Points *tags.Set
+	units  map[string]string
+}
+
+// NewDevice instantiate the profile on the slave
+func (sf *Profile) NewDevice(slaveID byte) (*Device, error) {
+	points, err := sf.points(slaveID)
+	if err != nil {
+		return nil, err
+	}
+	set, err := tags.NewSet(points...)
+	if err != nil {
+		return nil, err
+	}
+	units := make(map[string]string)
+	for _, r := range sf.Registers {
+		units[r.Name] = r.Unit
+	}
+	return &Device{sf, slaveID, set, units}, nil
+}
+
+// Unit the unit of the register
+func (sf *Device) Unit(name string) string {
+	return sf.units[name]
+}
+
+// GatherJobs the mb gather jobs of the poll groups, adjacent registers in a group share a job,
+// add them by mb.Client.AddGatherJob, and handle the result by Handler.
+func (sf *Device) GatherJobs() []mb.Request {
+	rates, _ := sf.Profile.scanRates()
+	groups := make(map[string][]tags.Point)
+	for _, r := range sf.Profile.Registers {
+		if p, ok := sf.Points.Point(r.Name); ok {
+			groups[r.Group] = append(groups[r.Group], p)
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var jobs []mb.Request
+	for _, name := range names {
+		set, err := tags.NewSet(groups[name]...)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, set.GatherJobs(rates[name])...)
+	}
+	return jobs
+}
+
+// Handler the mb poller handler decode the registers of the device,
+// onValue is called with the engineering value, see tags.Set.Handler.
+func (sf *Device) Handler(onValue func(p tags.Point, value float64)) mb.Handler {
+	return sf.Points.Handler(onValue)
+}
+
+// Registry the profiles by name, it is safe for concurrent use
+type Registry struct {
+	mu       sync.RWMutex
+	profiles map[string]*Profile
+}
+
+// NewRegistry new an empty registry
+func NewRegistry() *Registry {
+	return &Registry{profiles: make(map[string]*Profile)}
+}
+
+// Add validate and add the profile, the name must be unique
+func (sf *Registry) Add(p *Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if _, ok := sf.profiles[p.Name]; ok {
+		return fmt.Errorf("profile '%s' already exist", p.Name)
+	}
+	sf.profiles[p.Name] = p
+	return nil
+}
+
+// Load parse and add the profile document, unmarshal nil for JSON
+func (sf *Registry) Load(data []byte, unmarshal Unmarshal) (*Profile, error) {
+	p, err := Parse(data, unmarshal)
+	if err != nil {
+		return nil, err
+	}
+	return p, sf.Add(p)
+}
+
+// Get the profile by name
+func (sf *Registry) Get(name string) (*Profile, bool) {
+	sf.mu.RLock()
+	p, ok := sf.profiles[name]
+	sf.mu.RUnlock()
+	return p, ok
+}
+
+// Names the names of the profiles sorted
+func (sf *Registry) Names() []string {
+	sf.mu.RLock()
+	names := make([]string, 0, len(sf.profiles))
+	for name := range sf.profiles {
+		names = append(names, name)
+	}
+	sf.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// NewDevice instantiate the profile of the name on the slave
+func (sf *Registry) NewDevice(name string, slaveID byte) (*Device, error) {
+	p, ok := sf.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("profile '%s' not found", name)
+	}
+	return p.NewDevice(slaveID)
+}
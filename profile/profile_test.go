@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+	"github.com/aloncn/gomodbus/tags"
+)
+
+const meter = `{
+	"name": "acme-pm100",
+	"manufacturer": "Acme",
+	"model": "PM100",
+	"scanRate": "10ms",
+	"groups": [{"name": "energy", "scanRate": "1h"}],
+	"registers": [
+		{"name": "voltage", "table": "input", "address": 0, "scale": 0.1, "unit": "V"},
+		{"name": "current", "table": "input", "address": 1, "type": "int16", "scale": 0.01, "unit": "A"},
+		{"name": "energy", "table": "input", "address": 10, "type": "uint32", "order": "CDAB", "unit": "kWh", "group": "energy"},
+		{"name": "relay", "table": "coils", "address": 0}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{"meter", meter, false},
+		{"no name", `{"registers": []}`, true},
+		{"unknown table", `{"name": "a", "registers": [{"name": "x", "table": "foo"}]}`, true},
+		{"unknown type", `{"name": "a", "registers": [{"name": "x", "table": "input", "type": "int8"}]}`, true},
+		{"bool on registers", `{"name": "a", "registers": [{"name": "x", "table": "input", "type": "bool"}]}`, true},
+		{"unknown order", `{"name": "a", "registers": [{"name": "x", "table": "input", "order": "XYZW"}]}`, true},
+		{"unknown group", `{"name": "a", "registers": [{"name": "x", "table": "input", "group": "g"}]}`, true},
+		{"duplicate register", `{"name": "a", "registers": [{"name": "x", "table": "input"}, {"name": "x", "table": "input"}]}`, true},
+		{"zero group rate", `{"name": "a", "groups": [{"name": "g", "scanRate": "0s"}], "registers": []}`, true},
+		{"bad duration", `{"name": "a", "scanRate": "soon", "registers": []}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.doc), nil); (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	calls := 0
+	unmarshal := func(data []byte, v interface{}) error {
+		calls++
+		return json.Unmarshal(data, v)
+	}
+	if _, err := reg.Load([]byte(meter), unmarshal); err != nil || calls != 1 {
+		t.Fatalf("Load() error = %v, unmarshal calls %v", err, calls)
+	}
+	if _, err := reg.Load([]byte(meter), nil); err == nil {
+		t.Errorf("Load() duplicate, want error")
+	}
+	if names := reg.Names(); !reflect.DeepEqual(names, []string{"acme-pm100"}) {
+		t.Errorf("Names() = %v", names)
+	}
+	if _, err := reg.NewDevice("unknown", 1); err == nil {
+		t.Errorf("NewDevice() unknown profile, want error")
+	}
+
+	dev, err := reg.NewDevice("acme-pm100", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.Unit("energy") != "kWh" {
+		t.Errorf("Unit() = %v", dev.Unit("energy"))
+	}
+	want := []mb.Request{
+		{SlaveID: 3, FuncCode: modbus.FuncCodeReadCoils, Address: 0, Quantity: 1, ScanRate: 10 * time.Millisecond},
+		{SlaveID: 3, FuncCode: modbus.FuncCodeReadInputRegisters, Address: 0, Quantity: 2, ScanRate: 10 * time.Millisecond},
+		{SlaveID: 3, FuncCode: modbus.FuncCodeReadInputRegisters, Address: 10, Quantity: 2, ScanRate: time.Hour},
+	}
+	if jobs := dev.GatherJobs(); !reflect.DeepEqual(jobs, want) {
+		t.Errorf("GatherJobs() = %+v, want %+v", jobs, want)
+	}
+
+	node := modbus.NewNodeRegister(3, 0, 8, 0, 0, 0, 16, 0, 0)
+	_ = node.WriteInputs(0, []uint16{2301, 0xff9c})
+	_ = node.WriteInputs(10, []uint16{0x0001, 0x0002}) // CDAB 0x00020001
+	var mu sync.Mutex
+	values := make(map[string]float64)
+	poller := mb.NewClient(modbus.NewLoopbackProvider(node), mb.WitchHandler(dev.Handler(func(p tags.Point, v float64) {
+		mu.Lock()
+		values[p.Name] = v
+		mu.Unlock()
+	})))
+	if err = poller.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+	for _, job := range dev.GatherJobs() {
+		job.ScanRate = time.Millisecond
+		if err = poller.AddGatherJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantValues := map[string]float64{"voltage": 230.1, "current": -1, "energy": 0x00020001, "relay": 0}
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		got := len(values)
+		ok := got == len(wantValues)
+		for name, v := range wantValues {
+			ok = ok && math.Abs(values[name]-v) < 1e-9
+		}
+		mu.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("values = %v, want %v", values, wantValues)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,77 @@
+package modbus
+
+// 本文件提供了线圈和保持寄存器的写保护区, 模拟真实设备对配置区的保护
+
+import (
+	"fmt"
+)
+
+// protectedRange 写保护区
+type protectedRange struct {
+	address, quantity uint16
+	exceptionCode     byte
+}
+
+// overlap 是否与 [address, address+quantity) 重叠
+func (r protectedRange) overlap(address, quantity uint16) bool {
+	return int(address) < int(r.address)+int(r.quantity) && int(r.address) < int(address)+int(quantity)
+}
+
+// protectRanges 表的写保护区和锁
+func (sf *NodeRegister) protectRanges(table Table) (*[]protectedRange, func(), error) {
+	switch table {
+	case TableCoils:
+		sf.coilsRW.Lock()
+		return &sf.coilsProtect, sf.coilsRW.Unlock, nil
+	case TableHoldingRegisters:
+		sf.holdingRW.Lock()
+		return &sf.holdingProtect, sf.holdingRW.Unlock, nil
+	}
+	return nil, nil, fmt.Errorf("modbus: table '%v' is not writable by the protocol", table)
+}
+
+// Protect 设置线圈或保持寄存器的写保护区, 写请求与保护区重叠时整个请求被拒绝, 回复异常码 exceptionCode,
+// exceptionCode 为0时使用 非法数据地址(0x02), 真实设备也常用 非法数据值(0x03).
+// 应用通过 Update 写入不受保护区限制.
+func (sf *NodeRegister) Protect(table Table, address, quantity uint16, exceptionCode byte) error {
+	if quantity == 0 {
+		return fmt.Errorf("modbus: quantity '%v' must not be zero", quantity)
+	}
+	if exceptionCode == 0 {
+		exceptionCode = ExceptionCodeIllegalDataAddress
+	}
+	ranges, unlock, err := sf.protectRanges(table)
+	if err != nil {
+		return err
+	}
+	*ranges = append(*ranges, protectedRange{address, quantity, exceptionCode})
+	unlock()
+	return nil
+}
+
+// Unprotect 取消与 [address, address+quantity) 重叠的写保护区
+func (sf *NodeRegister) Unprotect(table Table, address, quantity uint16) error {
+	ranges, unlock, err := sf.protectRanges(table)
+	if err != nil {
+		return err
+	}
+	kept := (*ranges)[:0]
+	for _, r := range *ranges {
+		if !r.overlap(address, quantity) {
+			kept = append(kept, r)
+		}
+	}
+	*ranges = kept
+	unlock()
+	return nil
+}
+
+// checkProtect 检查写入是否与保护区重叠, 调用者需持有表的锁
+func checkProtect(ranges []protectedRange, address, quantity uint16) error {
+	for _, r := range ranges {
+		if r.overlap(address, quantity) {
+			return &ExceptionError{ExceptionCode: r.exceptionCode}
+		}
+	}
+	return nil
+}
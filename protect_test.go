@@ -0,0 +1,78 @@
+package modbus
+
+import (
+	"testing"
+)
+
+func TestNodeRegister_Protect(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	if err := node.Protect(TableHoldingRegisters, 4, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.Protect(TableCoils, 8, 8, ExceptionCodeIllegalDataValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.Protect(TableInputRegisters, 0, 1, 0); err == nil {
+		t.Errorf("Protect() input registers, want error")
+	}
+	if err := node.Protect(TableHoldingRegisters, 0, 0, 0); err == nil {
+		t.Errorf("Protect() zero quantity, want error")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		code byte
+	}{
+		{"保护区前", node.WriteHoldings(0, []uint16{1, 2, 3, 4}), 0},
+		{"跨保护区", node.WriteHoldings(3, []uint16{1, 2}), ExceptionCodeIllegalDataAddress},
+		{"保护区内", node.WriteHoldingsBytes(5, 1, []byte{0x00, 0x01}), ExceptionCodeIllegalDataAddress},
+		{"屏蔽写", node.MaskWriteHolding(7, 0, 1), ExceptionCodeIllegalDataAddress},
+		{"保护区后", node.WriteHoldings(8, []uint16{1}), 0},
+		{"线圈保护区", node.WriteSingleCoil(9, true), ExceptionCodeIllegalDataValue},
+		{"线圈保护区前", node.WriteCoils(0, 8, []byte{0xff}), 0},
+	}
+	for _, tt := range tests {
+		if tt.code == 0 && tt.err != nil {
+			t.Errorf("%v: error = %v", tt.name, tt.err)
+		}
+		if tt.code != 0 && !IsException(tt.err, tt.code) {
+			t.Errorf("%v: error = %v, want exception %v", tt.name, tt.err, tt.code)
+		}
+	}
+	if _, err := node.WriteReadHoldingsBytes(4, 1, []byte{0x00, 0x01}, 0, 1); !IsIllegalDataAddress(err) {
+		t.Errorf("WriteReadHoldingsBytes() protected error = %v, want illegal data address", err)
+	}
+	if v, _ := node.ReadHoldings(4, 4); v[0]+v[1]+v[2]+v[3] != 0 {
+		t.Errorf("protected registers = %v, want not written", v)
+	}
+
+	// 应用的更新不受限制
+	if err := node.Update(func(u *NodeUpdate) error {
+		return u.WriteHoldings(4, []uint16{9})
+	}); err != nil {
+		t.Errorf("Update() protected error = %v", err)
+	}
+
+	if err := node.Unprotect(TableHoldingRegisters, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.WriteHoldings(4, []uint16{1, 2, 3, 4}); err != nil {
+		t.Errorf("WriteHoldings() unprotected error = %v", err)
+	}
+}
+
+func TestServer_Protect(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	_ = node.Protect(TableHoldingRegisters, 10, 6, ExceptionCodeIllegalDataValue)
+	c := NewClient(NewLoopbackProvider(node))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteMultipleRegisters(1, 8, 4, make([]byte, 8)); !IsIllegalDataValue(err) {
+		t.Errorf("WriteMultipleRegisters() protected error = %v, want illegal data value", err)
+	}
+	if err := c.WriteSingleRegister(1, 9, 1); err != nil {
+		t.Errorf("WriteSingleRegister() error = %v", err)
+	}
+}
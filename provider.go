@@ -0,0 +1,60 @@
+package modbus
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RawHandler 收发原始帧的回调, adu 为线路上的原始字节,
+// 仅在回调期间有效,需保留请复制
+type RawHandler func(adu []byte)
+
+// providerCommon 各客户端提供者共用的部分
+type providerCommon struct {
+	capture   atomic.Value // *PcapWriter
+	onSendRaw atomic.Value // RawHandler
+	onRecvRaw atomic.Value // RawHandler
+	quirks    uint32       // Quirks
+	limiter   atomic.Value // *rateLimiter
+	bytes     byteStats
+	lifecycle
+}
+
+// SetCapture dump every sent and received ADU into the pcap writer, nil to disable it.
+func (sf *providerCommon) SetCapture(w *PcapWriter) {
+	sf.capture.Store(w)
+}
+
+// OnSendRaw set the callback which called with the exact bytes sent on the wire,
+// independent of the logger, nil to disable it.
+func (sf *providerCommon) OnSendRaw(f RawHandler) {
+	sf.onSendRaw.Store(f)
+}
+
+// OnRecvRaw set the callback which called with the exact bytes received on the wire,
+// independent of the logger, nil to disable it.
+func (sf *providerCommon) OnRecvRaw(f RawHandler) {
+	sf.onRecvRaw.Store(f)
+}
+
+// tapSend the ADU is sent
+func (sf *providerCommon) tapSend(adu []byte) {
+	sf.bytes.add(0, len(adu))
+	if w, ok := sf.capture.Load().(*PcapWriter); ok && w != nil {
+		w.WriteFrame(time.Now(), true, adu)
+	}
+	if f, ok := sf.onSendRaw.Load().(RawHandler); ok && f != nil {
+		f(adu)
+	}
+}
+
+// tapReceived the ADU is received
+func (sf *providerCommon) tapReceived(adu []byte) {
+	sf.bytes.add(len(adu), 0)
+	if w, ok := sf.capture.Load().(*PcapWriter); ok && w != nil {
+		w.WriteFrame(time.Now(), false, adu)
+	}
+	if f, ok := sf.onRecvRaw.Load().(RawHandler); ok && f != nil {
+		f(adu)
+	}
+}
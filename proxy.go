@@ -0,0 +1,265 @@
+package modbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ProxyDialer 通过代理拨号的拨号器, 用于 TCPClientProvider.SetDialer 和 TCPServerSpecial.SetDialer,
+// proxyURL 为 socks5://[user:password@]host:port 或 http://[user:password@]host:port(HTTP CONNECT),
+// socks5 在本地解析目标主机名后发送IP, socks5h 将主机名发给代理解析,
+// forward 为连接代理的拨号器, nil 使用 net.Dialer
+func ProxyDialer(proxyURL string, forward Dialer) (Dialer, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	p := &proxyDialer{scheme: u.Scheme, address: u.Host, forward: forward}
+	if u.User != nil {
+		p.username = u.User.Username()
+		p.password, _ = u.User.Password()
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		p.address = withDefaultPort(u.Host, "1080")
+	case "http":
+		p.address = withDefaultPort(u.Host, "80")
+	default:
+		return nil, fmt.Errorf("modbus: unsupported proxy scheme '%s'", u.Scheme)
+	}
+	return p, nil
+}
+
+// withDefaultPort address 没有端口时加上 port
+func withDefaultPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, port)
+	}
+	return address
+}
+
+// proxyDialer 通过 SOCKS5 或 HTTP CONNECT 代理拨号
+type proxyDialer struct {
+	scheme   string
+	address  string // 代理地址
+	username string
+	password string
+	forward  Dialer
+}
+
+// Dial implements Dialer
+func (sf *proxyDialer) Dial(network, address string) (net.Conn, error) {
+	return sf.DialContext(context.Background(), network, address)
+}
+
+// DialContext dial the proxy and connect to the address through it until the context is done
+func (sf *proxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("modbus: proxy not support network '%s'", network)
+	}
+	conn, err := dialContext(ctx, sf.forward, "tcp", sf.address, 0)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tunnel := conn
+	if sf.scheme == "http" {
+		tunnel, err = sf.connectHTTP(conn, address)
+	} else {
+		err = sf.connectSOCKS5(ctx, conn, network, address)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// socks5 的回复码
+var socks5Errors = []string{
+	"",
+	"general failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// connectSOCKS5 通过 SOCKS5(RFC 1928) 连接到 address, 用户名密码认证见 RFC 1929,
+// socks5 在本地解析主机名, socks5h 由代理解析
+func (sf *proxyDialer) connectSOCKS5(ctx context.Context, conn net.Conn, network, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("modbus: invalid port '%s'", portStr)
+	}
+	if sf.scheme == "socks5" && net.ParseIP(host) == nil {
+		var ip net.IP
+		if ip, err = resolveIP(ctx, network, host); err != nil {
+			return err
+		}
+		host = ip.String()
+	}
+
+	method := byte(0x00) // 无需认证
+	if sf.username != "" {
+		method = 0x02 // 用户名密码
+	}
+	if _, err = conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return err
+	}
+	b := make([]byte, 262)
+	if _, err = io.ReadFull(conn, b[:2]); err != nil {
+		return err
+	}
+	if b[0] != 0x05 || b[1] != method {
+		return errors.New("modbus: socks5 proxy refused the authentication method")
+	}
+	if method == 0x02 {
+		if len(sf.username) > 255 || len(sf.password) > 255 {
+			return errors.New("modbus: socks5 username or password too long")
+		}
+		req := append([]byte{0x01, byte(len(sf.username))}, sf.username...)
+		req = append(append(req, byte(len(sf.password))), sf.password...)
+		if _, err = conn.Write(req); err != nil {
+			return err
+		}
+		if _, err = io.ReadFull(conn, b[:2]); err != nil {
+			return err
+		}
+		if b[1] != 0x00 {
+			return errors.New("modbus: socks5 proxy authentication failed")
+		}
+	}
+
+	req := []byte{0x05, 0x01, 0x00} // CONNECT
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(append(req, 0x01), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 0x04), ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return errors.New("modbus: socks5 host name too long")
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err = conn.Write(req); err != nil {
+		return err
+	}
+	if _, err = io.ReadFull(conn, b[:4]); err != nil {
+		return err
+	}
+	if b[0] != 0x05 {
+		return errors.New("modbus: invalid socks5 proxy response")
+	}
+	if rep := b[1]; rep != 0x00 {
+		if int(rep) < len(socks5Errors) {
+			return fmt.Errorf("modbus: socks5 proxy connect '%s': %s", address, socks5Errors[rep])
+		}
+		return fmt.Errorf("modbus: socks5 proxy connect '%s': unknown error %d", address, rep)
+	}
+	// 跳过绑定地址和端口
+	var n int
+	switch b[3] {
+	case 0x01:
+		n = net.IPv4len
+	case 0x04:
+		n = net.IPv6len
+	case 0x03:
+		if _, err = io.ReadFull(conn, b[:1]); err != nil {
+			return err
+		}
+		n = int(b[0])
+	default:
+		return errors.New("modbus: invalid socks5 proxy address type")
+	}
+	_, err = io.ReadFull(conn, b[:n+2])
+	return err
+}
+
+// resolveIP 解析主机名, tcp4 和 tcp6 只用对应的地址, tcp 优先使用IPv4地址
+func resolveIP(ctx context.Context, network, host string) (net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var v6 net.IP
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			if network != "tcp6" {
+				return a.IP, nil
+			}
+		} else if v6 == nil {
+			v6 = a.IP
+		}
+	}
+	if v6 == nil || network == "tcp4" {
+		return nil, fmt.Errorf("modbus: no suitable address found for '%s'", host)
+	}
+	return v6, nil
+}
+
+// bufferedConn 读取时先读缓存中的数据
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read implements io.Reader
+func (sf *bufferedConn) Read(p []byte) (int, error) {
+	return sf.r.Read(p)
+}
+
+// connectHTTP 通过 HTTP CONNECT 连接到 address, 任何 2xx 响应都表示隧道已建立
+func (sf *proxyDialer) connectHTTP(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if sf.username != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(sf.username + ":" + sf.password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("modbus: http proxy connect '%s': %s", address, resp.Status)
+	}
+	if r.Buffered() == 0 {
+		return conn, nil
+	}
+	return &bufferedConn{conn, r}, nil
+}
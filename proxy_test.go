@@ -0,0 +1,242 @@
+package modbus
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// startTestProxy start a SOCKS5 or HTTP CONNECT proxy require the user:pass, the targets are sent to the channel
+func startTestProxy(t *testing.T, scheme string) (net.Listener, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				var target string
+				if scheme == "http" {
+					target = httpProxyHandshake(r, conn)
+				} else {
+					target = socks5ProxyHandshake(r, conn)
+				}
+				if target == "" {
+					return
+				}
+				targets <- target
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				go func() {
+					_, _ = io.Copy(upstream, r)
+					upstream.Close()
+				}()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return l, targets
+}
+
+func socks5ProxyHandshake(r *bufio.Reader, conn net.Conn) string {
+	b := make([]byte, 256)
+	if _, err := io.ReadFull(r, b[:3]); err != nil || b[2] != 0x02 {
+		_, _ = conn.Write([]byte{0x05, 0xff})
+		return ""
+	}
+	_, _ = conn.Write([]byte{0x05, 0x02})
+	_, _ = io.ReadFull(r, b[:2])
+	user := make([]byte, b[1])
+	_, _ = io.ReadFull(r, user)
+	_, _ = io.ReadFull(r, b[:1])
+	pass := make([]byte, b[0])
+	_, _ = io.ReadFull(r, pass)
+	if string(user) != "user" || string(pass) != "pass" {
+		_, _ = conn.Write([]byte{0x01, 0x01})
+		return ""
+	}
+	_, _ = conn.Write([]byte{0x01, 0x00})
+	_, _ = io.ReadFull(r, b[:4])
+	var host string
+	switch b[3] {
+	case 0x01:
+		_, _ = io.ReadFull(r, b[:4])
+		host = net.IP(b[:4]).String()
+	case 0x04:
+		_, _ = io.ReadFull(r, b[:16])
+		host = net.IP(b[:16]).String()
+	case 0x03:
+		_, _ = io.ReadFull(r, b[:1])
+		name := make([]byte, b[0])
+		_, _ = io.ReadFull(r, name)
+		host = string(name)
+	}
+	_, _ = io.ReadFull(r, b[:2])
+	_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(b[0])<<8|int(b[1])))
+}
+
+func httpProxyHandshake(r *bufio.Reader, conn net.Conn) string {
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return ""
+	}
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != auth {
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return ""
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return req.Host
+}
+
+func TestProxyDialer(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPServer()
+	node := NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10)
+	_ = node.WriteHoldings(0, []uint16{0x1234})
+	srv.AddNodes(node)
+	go srv.Serve(listen)
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(listen.Addr().String())
+
+	for _, scheme := range []string{"socks5", "socks5h", "http"} {
+		proxy, targets := startTestProxy(t, scheme)
+		defer proxy.Close()
+		// socks5 resolve the host name locally, the others send it to the proxy
+		hostTarget := "localhost:" + port
+		if scheme == "socks5" {
+			hostTarget = "127.0.0.1:" + port
+		}
+		tests := []struct {
+			name       string
+			user       string
+			target     string
+			wantTarget string
+			wantErr    bool
+		}{
+			{"ip", "user:pass@", listen.Addr().String(), listen.Addr().String(), false},
+			{"host name", "user:pass@", "localhost:" + port, hostTarget, false},
+			{"bad password", "user:bad@", listen.Addr().String(), "", true},
+		}
+		for _, tt := range tests {
+			t.Run(scheme+" "+tt.name, func(t *testing.T) {
+				d, err := ProxyDialer(scheme+"://"+tt.user+proxy.Addr().String(), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				p := NewTCPClientProvider(tt.target)
+				p.SetDialer(d)
+				c := NewClient(p)
+				err = c.Connect()
+				if err == nil {
+					defer c.Close()
+					var regs []uint16
+					regs, err = c.ReadHoldingRegisters(1, 0, 1)
+					if err == nil && !reflect.DeepEqual(regs, []uint16{0x1234}) {
+						t.Errorf("ReadHoldingRegisters() = %v", regs)
+					}
+				}
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr {
+					if got := <-targets; got != tt.wantTarget {
+						t.Errorf("proxy target = %v, want %v", got, tt.wantTarget)
+					}
+				}
+			})
+		}
+	}
+
+	if _, err = ProxyDialer("ftp://127.0.0.1:21", nil); err == nil {
+		t.Errorf("ProxyDialer() unsupported scheme, want error")
+	}
+}
+
+func Test_proxyDialer_connectHTTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		wantErr bool
+	}{
+		{"200", "200 Connection established", false},
+		{"other 2xx", "204 No Content", false},
+		{"auth required", "407 Proxy Authentication Required", true},
+		{"forbidden", "403 Forbidden", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			go func() {
+				if _, err := http.ReadRequest(bufio.NewReader(server)); err == nil {
+					_, _ = io.WriteString(server, "HTTP/1.1 "+tt.status+"\r\n\r\n")
+				}
+			}()
+			d := &proxyDialer{scheme: "http"}
+			_, err := d.connectHTTP(client, "127.0.0.1:502")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("connectHTTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTCPServerSpecial_SetProxy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	proxy, targets := startTestProxy(t, "http")
+	defer proxy.Close()
+
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10)
+	_ = node.WriteHoldings(0, []uint16{0x1234, 0x5678})
+	srv := NewTCPServerSpecial()
+	srv.AddNodes(node)
+	if err = srv.SetProxy("http://user:pass@" + proxy.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.AddRemoteServer(l.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	want := []byte{FuncCodeReadHoldingRegisters, 4, 0x12, 0x34, 0x56, 0x78}
+	if got := acceptAndRead(t, l, testslaveID1); !reflect.DeepEqual(got, want) {
+		t.Errorf("response pdu = % x, want % x", got, want)
+	}
+	select {
+	case target := <-targets:
+		if target != l.Addr().String() {
+			t.Errorf("proxy target = %v, want %v", target, l.Addr())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("not connected through the proxy")
+	}
+}
@@ -0,0 +1,90 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// Quirks the tolerance of the devices which violate the spec, selected per provider
+type Quirks uint32
+
+// quirks
+const (
+	// QuirkWrongByteCount accept the wrong byte count of the read response
+	// if the length of the frame is consistent with the quantity requested, the byte count is corrected.
+	QuirkWrongByteCount Quirks = 1 << iota
+	// QuirkUnitIDZero accept the response with unit id(slave id) 0
+	QuirkUnitIDZero
+	// QuirkTrailingGarbage ignore the garbage bytes after the response
+	QuirkTrailingGarbage
+)
+
+// SetQuirks set the tolerance of the non-conforming devices, 0 to conform the spec
+func (sf *providerCommon) SetQuirks(q Quirks) {
+	atomic.StoreUint32(&sf.quirks, uint32(q))
+}
+
+// Quirks got the tolerance of the non-conforming devices
+func (sf *providerCommon) Quirks() Quirks {
+	return Quirks(atomic.LoadUint32(&sf.quirks))
+}
+
+// tolerate apply the quirks on the response before it is verified,
+// the response data is corrected in place, return the slave id of the response to verify.
+func (sf *providerCommon) tolerate(reqSlaveID, rspSlaveID byte, request ProtocolDataUnit, response *ProtocolDataUnit) byte {
+	q := sf.Quirks()
+	if q == 0 {
+		return rspSlaveID
+	}
+	if q&QuirkUnitIDZero != 0 && rspSlaveID == 0 {
+		rspSlaveID = reqSlaveID
+	}
+	if response.FuncCode != request.FuncCode {
+		return rspSlaveID
+	}
+	want := expectResponseLength(request)
+	if want < 0 {
+		return rspSlaveID
+	}
+	data := response.Data
+	if q&QuirkTrailingGarbage != 0 && len(data) > want {
+		data = data[:want]
+	}
+	if q&QuirkWrongByteCount != 0 && len(data) == want && hasByteCount(request.FuncCode) {
+		data[0] = byte(want - 1)
+	}
+	response.Data = data
+	return rspSlaveID
+}
+
+// expectResponseLength the length of the response data except function code, -1 if undetermined
+func expectResponseLength(request ProtocolDataUnit) int {
+	switch request.FuncCode {
+	case FuncCodeReadDiscreteInputs, FuncCodeReadCoils:
+		if len(request.Data) < 4 {
+			return -1
+		}
+		return 1 + (int(binary.BigEndian.Uint16(request.Data[2:]))+7)/8
+	case FuncCodeReadInputRegisters, FuncCodeReadHoldingRegisters, FuncCodeReadWriteMultipleRegisters:
+		if len(request.Data) < 4 {
+			return -1
+		}
+		return 1 + int(binary.BigEndian.Uint16(request.Data[2:]))*2
+	case FuncCodeWriteSingleCoil, FuncCodeWriteMultipleCoils,
+		FuncCodeWriteSingleRegister, FuncCodeWriteMultipleRegisters:
+		return 4
+	case FuncCodeMaskWriteRegister:
+		return 6
+	}
+	return -1
+}
+
+// hasByteCount whether the response data lead by the byte count
+func hasByteCount(funcCode byte) bool {
+	switch funcCode {
+	case FuncCodeReadDiscreteInputs, FuncCodeReadCoils,
+		FuncCodeReadInputRegisters, FuncCodeReadHoldingRegisters, FuncCodeReadWriteMultipleRegisters:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProviderCommon_tolerate(t *testing.T) {
+	readHolding := ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x01}}
+	tests := []struct {
+		name      string
+		quirks    Quirks
+		rspSlave  byte
+		data      []byte
+		wantSlave byte
+		wantData  []byte
+	}{
+		{"conform", 0, 0, []byte{0x03, 0x00, 0x01, 0xff}, 0, []byte{0x03, 0x00, 0x01, 0xff}},
+		{"unit id zero", QuirkUnitIDZero, 0, []byte{0x02, 0x00, 0x01}, 1, []byte{0x02, 0x00, 0x01}},
+		{"unit id other", QuirkUnitIDZero, 2, []byte{0x02, 0x00, 0x01}, 2, []byte{0x02, 0x00, 0x01}},
+		{"wrong byte count", QuirkWrongByteCount, 1, []byte{0x01, 0x00, 0x01}, 1, []byte{0x02, 0x00, 0x01}},
+		{"wrong byte count inconsistent", QuirkWrongByteCount, 1, []byte{0x01, 0x00}, 1, []byte{0x01, 0x00}},
+		{"trailing garbage", QuirkTrailingGarbage, 1, []byte{0x02, 0x00, 0x01, 0xff}, 1, []byte{0x02, 0x00, 0x01}},
+		{"all", QuirkWrongByteCount | QuirkTrailingGarbage | QuirkUnitIDZero, 0, []byte{0x04, 0x00, 0x01, 0xff}, 1, []byte{0x02, 0x00, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p providerCommon
+			p.SetQuirks(tt.quirks)
+			response := ProtocolDataUnit{FuncCodeReadHoldingRegisters, tt.data}
+			gotSlave := p.tolerate(1, tt.rspSlave, readHolding, &response)
+			if gotSlave != tt.wantSlave || !bytes.Equal(response.Data, tt.wantData) {
+				t.Errorf("tolerate() = %v [% x], want %v [% x]", gotSlave, response.Data, tt.wantSlave, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestTCPClientProvider_Quirks(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listen.Close()
+	go func() {
+		conn, err := listen.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req := make([]byte, 12)
+		for {
+			if _, err := io.ReadFull(conn, req); err != nil {
+				return
+			}
+			// unit id 0, wrong byte count, and the garbage after the frame
+			conn.Write([]byte{req[0], req[1], 0x00, 0x00, 0x00, 0x05, 0x00, FuncCodeReadHoldingRegisters, 0x01, 0x12, 0x34, 0xde, 0xad})
+		}
+	}()
+
+	p := NewTCPClientProvider(listen.Addr().String())
+	p.Timeout = time.Second
+	mbCli := NewClient(p)
+	if err = mbCli.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer mbCli.Close()
+
+	p.SetQuirks(QuirkUnitIDZero | QuirkWrongByteCount | QuirkTrailingGarbage)
+	for i := 0; i < 2; i++ {
+		got, err := mbCli.ReadHoldingRegisters(1, 0, 1)
+		if err != nil || got[0] != 0x1234 {
+			t.Errorf("ReadHoldingRegisters() = %v, %v, want [4660]", got, err)
+		}
+	}
+	p.SetQuirks(0)
+	if _, err = mbCli.ReadHoldingRegisters(1, 0, 1); err == nil {
+		t.Errorf("ReadHoldingRegisters() error = nil, want unit id mismatch")
+	}
+}
@@ -0,0 +1,79 @@
+package modbus
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter token bucket, the token is reserved before waiting
+// so the concurrent requests are paced in order.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter a full bucket
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// refill add the tokens generated since the last time, it must be called with the lock held
+func (sf *rateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(sf.last); elapsed > 0 {
+		sf.tokens += elapsed.Seconds() * sf.rate
+		if sf.tokens > sf.burst {
+			sf.tokens = sf.burst
+		}
+		sf.last = now
+	}
+}
+
+// reserve take a token, return the time to wait before it is available
+func (sf *rateLimiter) reserve(now time.Time) time.Duration {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.refill(now)
+	sf.tokens--
+	if sf.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-sf.tokens / sf.rate * float64(time.Second))
+}
+
+// allow take a token if it is available now, it never waits
+func (sf *rateLimiter) allow(now time.Time) bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.refill(now)
+	if sf.tokens < 1 {
+		return false
+	}
+	sf.tokens--
+	return true
+}
+
+// SetRateLimit bound the request rate to rate requests per second with burst,
+// the requests from all goroutines wait for the token before transmitting,
+// it protects the fragile devices and radio links, rate <= 0 to disable it.
+func (sf *providerCommon) SetRateLimit(rate float64, burst int) {
+	if rate <= 0 {
+		sf.limiter.Store((*rateLimiter)(nil))
+		return
+	}
+	sf.limiter.Store(newRateLimiter(rate, burst))
+}
+
+// pace wait for the token of the rate limit before transmitting
+func (sf *providerCommon) pace() {
+	if l, ok := sf.limiter.Load().(*rateLimiter); ok && l != nil {
+		if d := l.reserve(time.Now()); d > 0 {
+			time.Sleep(d)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_reserve(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(10, 2)
+	l.last = now
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, w := range want {
+		if got := l.reserve(now); got != w {
+			t.Errorf("reserve() #%d = %v, want %v", i, got, w)
+		}
+	}
+	// refilled after a while, but not more than burst
+	if got := l.reserve(now.Add(time.Second)); got != 0 {
+		t.Errorf("reserve() after refill = %v, want 0", got)
+	}
+}
+
+func TestRateLimiter_allow(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(10, 2)
+	l.last = now
+	want := []bool{true, true, false, false}
+	for i, w := range want {
+		if got := l.allow(now); got != w {
+			t.Errorf("allow() #%d = %v, want %v", i, got, w)
+		}
+	}
+	// the rejected does not take the token
+	if !l.allow(now.Add(100 * time.Millisecond)) {
+		t.Errorf("allow() after refill = false, want true")
+	}
+}
+
+func TestProviderCommon_SetRateLimit(t *testing.T) {
+	tr := &scriptTransport{}
+	p := NewTransportProvider(tr, RTUCodec{})
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	p.SetRateLimit(50, 1)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, _ = p.SendRawFrame([]byte{0x01, 0x03})
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("4 requests at 50/s take %v, want about 60ms", elapsed)
+	}
+
+	p.SetRateLimit(0, 0)
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		_, _ = p.SendRawFrame([]byte{0x01, 0x03})
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("4 requests without limit take %v", elapsed)
+	}
+}
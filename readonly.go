@@ -0,0 +1,45 @@
+package modbus
+
+// writeFuncCodes 会修改寄存器的功能码
+var writeFuncCodes = []uint8{
+	FuncCodeWriteSingleCoil,
+	FuncCodeWriteMultipleCoils,
+	FuncCodeWriteSingleRegister,
+	FuncCodeWriteMultipleRegisters,
+	FuncCodeReadWriteMultipleRegisters,
+	FuncCodeMaskWriteRegister,
+	FuncCodeWriteFileRecord,
+}
+
+// DisableFuncCodes 禁用功能码, 请求回复异常码 非法功能(0x01), 包括 RegisterFunctionHandler 注册的功能码,
+// 转发到下游设备的请求不受影响
+func (sf *serverCommon) DisableFuncCodes(funcCodes ...uint8) {
+	for _, fc := range funcCodes {
+		sf.disabled.Store(fc, struct{}{})
+	}
+}
+
+// EnableFuncCodes 重新启用被禁用的功能码
+func (sf *serverCommon) EnableFuncCodes(funcCodes ...uint8) {
+	for _, fc := range funcCodes {
+		sf.disabled.Delete(fc)
+	}
+}
+
+// SetReadOnly 只读模式, 禁用所有写功能码, 用于只发布数据的仿真, false 重新启用所有写功能码
+func (sf *serverCommon) SetReadOnly(readOnly bool) {
+	if readOnly {
+		sf.DisableFuncCodes(writeFuncCodes...)
+	} else {
+		sf.EnableFuncCodes(writeFuncCodes...)
+	}
+}
+
+// functionHandler 获取功能码的处理函数, 未注册或被禁用时 ok 为false
+func (sf *serverCommon) functionHandler(funcCode uint8) (FunctionHandler, bool) {
+	if _, disabled := sf.disabled.Load(funcCode); disabled {
+		return nil, false
+	}
+	handle, ok := sf.function[funcCode]
+	return handle, ok
+}
@@ -0,0 +1,57 @@
+package modbus
+
+import (
+	"testing"
+)
+
+func TestServer_DisableFuncCodes(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	p := NewLoopbackProvider(node)
+	c := NewClient(p)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetReadOnly(true)
+	writes := []struct {
+		name string
+		err  error
+	}{
+		{"WriteSingleCoil", c.WriteSingleCoil(1, 0, true)},
+		{"WriteMultipleCoils", c.WriteMultipleCoils(1, 0, 2, []byte{0x03})},
+		{"WriteSingleRegister", c.WriteSingleRegister(1, 0, 1)},
+		{"WriteMultipleRegisters", c.WriteMultipleRegisters(1, 0, 1, []byte{0x00, 0x01})},
+		{"MaskWriteRegister", c.MaskWriteRegister(1, 0, 0xff, 0x01)},
+	}
+	for _, tt := range writes {
+		if !IsIllegalFunction(tt.err) {
+			t.Errorf("%v() read only error = %v, want illegal function", tt.name, tt.err)
+		}
+	}
+	if _, err := c.ReadWriteMultipleRegisters(1, 0, 1, 0, 1, []byte{0x00, 0x01}); !IsIllegalFunction(err) {
+		t.Errorf("ReadWriteMultipleRegisters() read only error = %v, want illegal function", err)
+	}
+	if v, _ := node.ReadHoldings(0, 1); v[0] != 0 {
+		t.Errorf("holding register = %v, want not written", v[0])
+	}
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Errorf("ReadHoldingRegisters() read only error = %v", err)
+	}
+
+	p.SetReadOnly(false)
+	if err := c.WriteSingleRegister(1, 0, 1); err != nil {
+		t.Errorf("WriteSingleRegister() error = %v", err)
+	}
+
+	p.DisableFuncCodes(FuncCodeReadCoils, FuncCodeReadDiscreteInputs)
+	if _, err := c.ReadCoils(1, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("ReadCoils() disabled error = %v, want illegal function", err)
+	}
+	if _, err := c.ReadDiscreteInputs(1, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("ReadDiscreteInputs() disabled error = %v, want illegal function", err)
+	}
+	p.EnableFuncCodes(FuncCodeReadCoils)
+	if _, err := c.ReadCoils(1, 0, 1); err != nil {
+		t.Errorf("ReadCoils() enabled error = %v", err)
+	}
+}
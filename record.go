@@ -0,0 +1,221 @@
+package modbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrReplayNotFound 回放记录中没有匹配的请求
+var ErrReplayNotFound = errors.New("modbus: request not found in recording")
+
+// Record a request/response pair, it is a line of json in the recording
+type Record struct {
+	SlaveID  byte   `json:"slaveId"`
+	Request  string `json:"request"`            // request pdu, hex encoded
+	Response string `json:"response,omitempty"` // response pdu, hex encoded
+	// Exception exception code if the response is a modbus exception
+	Exception byte `json:"exception,omitempty"`
+	// Error the error message if the transaction failed not by a modbus exception
+	Error string `json:"error,omitempty"`
+}
+
+// RecordProvider wrap a ClientProvider, record all request/response pairs
+// which send through Send or SendPdu to the writer,
+// SendRawFrame is not recorded, because the raw frame is dependent on the transport.
+// the failure of writing the recording does not fail the transaction, it stops the recording, see Err.
+type RecordProvider struct {
+	ClientProvider
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error // the error of writing the recording
+}
+
+// check implements ClientProvider interface
+var _ ClientProvider = (*RecordProvider)(nil)
+
+// NewRecordProvider create a record provider which write the recording to w
+func NewRecordProvider(p ClientProvider, w io.Writer) *RecordProvider {
+	return &RecordProvider{ClientProvider: p, enc: json.NewEncoder(w)}
+}
+
+// SetLogLevel set log output level of the wrapped provider if it implements LogLeveler
+func (sf *RecordProvider) SetLogLevel(level LogLevel) {
+	setLogLevel(sf.ClientProvider, level)
+}
+
+// Send request to the remote server and record it
+func (sf *RecordProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	response, err := sf.ClientProvider.Send(slaveID, request)
+	r := Record{
+		SlaveID: slaveID,
+		Request: hex.EncodeToString(append([]byte{request.FuncCode}, request.Data...)),
+	}
+	if err == nil || response.FuncCode != 0 {
+		r.Response = hex.EncodeToString(append([]byte{response.FuncCode}, response.Data...))
+	}
+	if err != nil {
+		if e, ok := AsExceptionError(err); ok {
+			r.Exception = e.ExceptionCode
+		} else {
+			r.Error = err.Error()
+		}
+	}
+	sf.mu.Lock()
+	if sf.err == nil {
+		sf.err = sf.enc.Encode(r)
+	}
+	sf.mu.Unlock()
+	return response, err
+}
+
+// Err return the error of writing the recording, nil if all the transactions are recorded,
+// the transactions after the error are not recorded.
+func (sf *RecordProvider) Err() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.err
+}
+
+// SendPdu send pdu request to the remote server and record it
+func (sf *RecordProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) < pduMinSize || len(pduRequest) > pduMaxSize {
+		return nil, fmt.Errorf("modbus: pdu size '%v' must not be between '%v' and '%v'",
+			len(pduRequest), pduMinSize, pduMaxSize)
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{pduRequest[0], pduRequest[1:]})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{response.FuncCode}, response.Data...), nil
+}
+
+// ReplayProvider answer the requests from the recording which created by RecordProvider,
+// the same request is answered in the recorded order, when exhausted, the last one repeat.
+// it enable deterministic integration tests without hardware.
+type ReplayProvider struct {
+	logger
+	mu      sync.Mutex
+	records map[string][]Record
+	closed  bool
+}
+
+// check implements ClientProvider interface
+var _ ClientProvider = (*ReplayProvider)(nil)
+
+// NewReplayProvider create a replay provider which load the recording from r
+func NewReplayProvider(r io.Reader) (*ReplayProvider, error) {
+	sf := &ReplayProvider{
+		logger:  newLogger("modbusReplayMaster =>"),
+		records: make(map[string][]Record),
+		closed:  true,
+	}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("modbus: recording line %d, %v", line, err)
+		}
+		key := replayKey(rec.SlaveID, rec.Request)
+		sf.records[key] = append(sf.records[key], rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// replayKey the key of the request
+func replayKey(slaveID byte, request string) string {
+	return fmt.Sprintf("%d:%s", slaveID, request)
+}
+
+// Connect do nothing but mark it connected
+func (sf *ReplayProvider) Connect() error {
+	sf.mu.Lock()
+	sf.closed = false
+	sf.mu.Unlock()
+	return nil
+}
+
+// IsConnected returns a bool signifying whether the client is connected or not.
+func (sf *ReplayProvider) IsConnected() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return !sf.closed
+}
+
+// SetAutoReconnect do nothing
+func (sf *ReplayProvider) SetAutoReconnect(byte) {}
+
+// Close mark it closed
+func (sf *ReplayProvider) Close() error {
+	sf.mu.Lock()
+	sf.closed = true
+	sf.mu.Unlock()
+	return nil
+}
+
+// Send answer the request from the recording
+func (sf *ReplayProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	var response ProtocolDataUnit
+
+	req := hex.EncodeToString(append([]byte{request.FuncCode}, request.Data...))
+	sf.mu.Lock()
+	if sf.closed {
+		sf.mu.Unlock()
+		return response, ErrClosedConnection
+	}
+	key := replayKey(slaveID, req)
+	recs := sf.records[key]
+	if len(recs) == 0 {
+		sf.mu.Unlock()
+		return response, ErrReplayNotFound
+	}
+	rec := recs[0]
+	if len(recs) > 1 {
+		sf.records[key] = recs[1:]
+	}
+	sf.mu.Unlock()
+
+	sf.with("slave", slaveID).Debug("replay [%s] -> [%s]", req, rec.Response)
+	if rec.Response != "" {
+		pdu, err := hex.DecodeString(rec.Response)
+		if err != nil || len(pdu) == 0 {
+			return response, fmt.Errorf("modbus: invalid recorded response '%s'", rec.Response)
+		}
+		response = ProtocolDataUnit{pdu[0], pdu[1:]}
+	}
+	switch {
+	case rec.Exception != 0:
+		return response, &ExceptionError{FuncCode: request.FuncCode, ExceptionCode: rec.Exception}
+	case rec.Error != "":
+		return response, errors.New(rec.Error)
+	}
+	return response, nil
+}
+
+// SendPdu answer the pdu request from the recording
+func (sf *ReplayProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) < pduMinSize || len(pduRequest) > pduMaxSize {
+		return nil, fmt.Errorf("modbus: pdu size '%v' must not be between '%v' and '%v'",
+			len(pduRequest), pduMinSize, pduMaxSize)
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{pduRequest[0], pduRequest[1:]})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{response.FuncCode}, response.Data...), nil
+}
+
+// SendRawFrame not supported, the recording is transport independent
+func (sf *ReplayProvider) SendRawFrame([]byte) ([]byte, error) {
+	return nil, errors.New("modbus: replay provider not support raw frame")
+}
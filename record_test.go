@@ -0,0 +1,91 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+
+	recorder := NewClient(NewRecordProvider(&provider{data: []byte{0x04, 0x12, 0x34, 0x56, 0x78}}, &buf))
+	want, err := recorder.ReadHoldingRegisters(1, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := NewClient(NewRecordProvider(&provider{err: &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}}, &buf))
+	if _, err = failed.ReadHoldingRegisters(1, 100, 2); err == nil {
+		t.Fatal("want exception error")
+	}
+	failed = NewClient(NewRecordProvider(&provider{err: errors.New("timeout")}, &buf))
+	if _, err = failed.ReadCoils(2, 0, 8); err == nil {
+		t.Fatal("want error")
+	}
+
+	p, err := NewReplayProvider(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewClient(p)
+	if _, err = replay.ReadHoldingRegisters(1, 0, 2); err != ErrClosedConnection {
+		t.Fatalf("error = %v, want %v", err, ErrClosedConnection)
+	}
+	replay.Connect()
+
+	for i := 0; i < 2; i++ {
+		got, err := replay.ReadHoldingRegisters(1, 0, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadHoldingRegisters() = %v, want %v", got, want)
+		}
+	}
+	_, err = replay.ReadHoldingRegisters(1, 100, 2)
+	if e, ok := err.(*ExceptionError); !ok || e.ExceptionCode != ExceptionCodeIllegalDataAddress {
+		t.Errorf("error = %v, want illegal data address exception", err)
+	}
+	if _, err = replay.ReadCoils(2, 0, 8); err == nil || err.Error() != "timeout" {
+		t.Errorf("error = %v, want timeout", err)
+	}
+	if _, err = replay.ReadCoils(3, 0, 8); err != ErrReplayNotFound {
+		t.Errorf("error = %v, want %v", err, ErrReplayNotFound)
+	}
+}
+
+// failWriter fail every write
+type failWriter struct{ err error }
+
+func (w *failWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestRecordProvider(t *testing.T) {
+	exc := &ExceptionError{FuncCode: FuncCodeReadHoldingRegisters, ExceptionCode: ExceptionCodeIllegalDataAddress}
+	var buf bytes.Buffer
+	p := NewRecordProvider(&provider{err: &wrapError{"read", exc}}, &buf)
+	if _, err := NewClient(p).ReadHoldingRegisters(1, 0, 2); err == nil {
+		t.Fatal("want exception error")
+	}
+	var rec Record
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Exception != ExceptionCodeIllegalDataAddress || rec.Error != "" {
+		t.Errorf("Record = %+v, want the wrapped exception recorded", rec)
+	}
+	if err := p.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+
+	// the transaction succeed even if the recording failed
+	werr := errors.New("disk full")
+	p = NewRecordProvider(&provider{data: []byte{0x02, 0x12, 0x34}}, &failWriter{werr})
+	if got, err := NewClient(p).ReadHoldingRegisters(1, 0, 1); err != nil || !reflect.DeepEqual(got, []uint16{0x1234}) {
+		t.Errorf("ReadHoldingRegisters() = %v, %v, want [0x1234]", got, err)
+	}
+	if err := p.Err(); err != werr {
+		t.Errorf("Err() = %v, want %v", err, werr)
+	}
+}
@@ -0,0 +1,83 @@
+package modbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reference 首位数字对应的表
+var referenceTable = map[byte]Table{
+	'0': TableCoils,
+	'1': TableDiscreteInputs,
+	'3': TableInputRegisters,
+	'4': TableHoldingRegisters,
+}
+
+// ParseReference parse the classic modicon reference used by the vendor register tables
+// into the table and the 0-based protocol address, the leading digit is the table,
+// 0 coils, 1 discrete inputs, 3 input registers, 4 holding registers,
+// the rest is the 1-based number, 4 digits(1-9999) or 5 digits(1-65536),
+// such as 00001, 10001, 30001, 40001, 400001, 465536,
+// the "4x0001" form is accepted too.
+func ParseReference(ref string) (Table, uint16, error) {
+	ref = strings.TrimSpace(ref)
+	if len(ref) < 2 {
+		return 0, 0, fmt.Errorf("modbus: invalid reference '%v'", ref)
+	}
+	table, ok := referenceTable[ref[0]]
+	if !ok {
+		return 0, 0, fmt.Errorf("modbus: reference '%v' has unknown table '%c'", ref, ref[0])
+	}
+	number := ref[1:]
+	switch {
+	case number[0] == 'x' || number[0] == 'X':
+		number = number[1:]
+	case len(ref) != 5 && len(ref) != 6:
+		return 0, 0, fmt.Errorf("modbus: reference '%v' must be 5 or 6 digits", ref)
+	}
+	n, err := strconv.ParseUint(number, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("modbus: invalid reference '%v'", ref)
+	}
+	if n < 1 || n > 65536 {
+		return 0, 0, fmt.Errorf("modbus: reference '%v' number must be between '1' and '65536'", ref)
+	}
+	return table, uint16(n - 1), nil
+}
+
+// FormatReference format the table and the 0-based protocol address into the classic modicon reference,
+// it is 5 digits if the number is not bigger than 9999, otherwise 6 digits.
+func FormatReference(table Table, address uint16) string {
+	var prefix int
+	switch table {
+	case TableCoils:
+		prefix = 0
+	case TableDiscreteInputs:
+		prefix = 1
+	case TableInputRegisters:
+		prefix = 3
+	case TableHoldingRegisters:
+		prefix = 4
+	default:
+		return "unknown"
+	}
+	n := int(address) + 1
+	if n <= 9999 {
+		return fmt.Sprintf("%d%04d", prefix, n)
+	}
+	return fmt.Sprintf("%d%05d", prefix, n)
+}
+
+// ReferenceSpec the ReadSpec of quantity points start at the classic modicon reference,
+// such as ReferenceSpec(1, "40001", 10) read 10 holding registers start at address 0.
+func ReferenceSpec(slaveID byte, ref string, quantity uint16) (ReadSpec, error) {
+	table, address, err := ParseReference(ref)
+	if err != nil {
+		return ReadSpec{}, err
+	}
+	if int(address)+int(quantity) > 65536 {
+		return ReadSpec{}, fmt.Errorf("modbus: reference '%v' quantity '%v' exceed the address space", ref, quantity)
+	}
+	return ReadSpec{slaveID, table, address, quantity}, nil
+}
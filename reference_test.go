@@ -0,0 +1,75 @@
+package modbus
+
+import (
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref         string
+		wantTable   Table
+		wantAddress uint16
+		wantErr     bool
+	}{
+		{"00001", TableCoils, 0, false},
+		{"10010", TableDiscreteInputs, 9, false},
+		{"30001", TableInputRegisters, 0, false},
+		{"40001", TableHoldingRegisters, 0, false},
+		{"49999", TableHoldingRegisters, 9998, false},
+		{"400001", TableHoldingRegisters, 0, false},
+		{"465536", TableHoldingRegisters, 65535, false},
+		{"4x0100", TableHoldingRegisters, 99, false},
+		{" 30002 ", TableInputRegisters, 1, false},
+		{"40000", 0, 0, true},
+		{"465537", 0, 0, true},
+		{"20001", 0, 0, true},
+		{"4001", 0, 0, true},
+		{"4000001", 0, 0, true},
+		{"4a001", 0, 0, true},
+		{"", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			table, address, err := ParseReference(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if table != tt.wantTable || address != tt.wantAddress {
+				t.Errorf("ParseReference() = %v %v, want %v %v", table, address, tt.wantTable, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestFormatReference(t *testing.T) {
+	tests := []struct {
+		table   Table
+		address uint16
+		want    string
+	}{
+		{TableCoils, 0, "00001"},
+		{TableDiscreteInputs, 9, "10010"},
+		{TableInputRegisters, 9998, "39999"},
+		{TableHoldingRegisters, 9999, "410000"},
+		{TableHoldingRegisters, 65535, "465536"},
+	}
+	for _, tt := range tests {
+		got := FormatReference(tt.table, tt.address)
+		if got != tt.want {
+			t.Errorf("FormatReference() = %v, want %v", got, tt.want)
+		}
+		if table, address, err := ParseReference(got); err != nil || table != tt.table || address != tt.address {
+			t.Errorf("ParseReference(%v) = %v %v %v, want round trip", got, table, address, err)
+		}
+	}
+}
+
+func TestReferenceSpec(t *testing.T) {
+	spec, err := ReferenceSpec(1, "30011", 2)
+	if err != nil || spec != (ReadSpec{1, TableInputRegisters, 10, 2}) {
+		t.Errorf("ReferenceSpec() = %+v, %v", spec, err)
+	}
+	if _, err = ReferenceSpec(1, "465536", 2); err == nil {
+		t.Errorf("ReferenceSpec() error = nil, want exceed the address space")
+	}
+}
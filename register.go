@@ -1,6 +1,7 @@
 package modbus
 
 // 本文件提供了寄存器的底层封装,并且是线程安全的,丰富的api满足基本需求
+// 每个表独立的读写锁, 不同表的读写互不阻塞, 同一个表的读并发执行
 
 import (
 	"bytes"
@@ -10,7 +11,9 @@ import (
 
 // NodeRegister 节点寄存器
 type NodeRegister struct {
-	rw                                  sync.RWMutex // 读写锁
+	rw                                  sync.RWMutex // 从站地址读写锁
+	coilsRW, discreteRW                 sync.RWMutex // 线圈, 离散量读写锁
+	inputRW, holdingRW                  sync.RWMutex // 输入寄存器, 保持寄存器读写锁
 	slaveID                             byte
 	coilsAddrStart, coilsQuantity       uint16
 	coils                               []uint8
@@ -20,6 +23,11 @@ type NodeRegister struct {
 	input                               []uint16
 	holdingAddrStart                    uint16
 	holding                             []uint16
+	coilsProtect, holdingProtect        []protectedRange     // 写保护区, 由对应表的锁保护
+	backends                            [4]RegisterBackend   // 虚拟寄存器后端, 由对应表的锁保护
+	computed                            [4][]*computedRegion // 计算区, 由对应表的锁保护
+	watchMu                             sync.Mutex           // 订阅者锁
+	watchers                            map[*changeWatcher]struct{}
 }
 
 // NewNodeRegister 创建一个modbus子节点寄存器列表
@@ -100,9 +108,25 @@ func setBits(buf []byte, start, nBits uint16, value byte) {
 
 // WriteCoils 写线圈
 func (sf *NodeRegister) WriteCoils(address, quality uint16, valBuf []byte) error {
-	sf.rw.Lock()
+	sf.coilsRW.Lock()
+	err := checkProtect(sf.coilsProtect, address, quality)
+	if err == nil {
+		err = sf.writeCoils(address, quality, valBuf)
+	}
+	if err == nil {
+		sf.notify(TableCoils, address, quality)
+	}
+	sf.coilsRW.Unlock()
+	return err
+}
+
+// writeCoils 写线圈, 调用者需持有锁
+func (sf *NodeRegister) writeCoils(address, quality uint16, valBuf []byte) error {
 	if len(valBuf)*8 >= int(quality) && (address >= sf.coilsAddrStart) &&
 		((address + quality) <= (sf.coilsAddrStart + sf.coilsQuantity)) {
+		if b := sf.backends[TableCoils]; b != nil {
+			return b.WriteRegs(address, unpackBits(valBuf, quality))
+		}
 		start := address - sf.coilsAddrStart
 		nCoils := int16(quality)
 		for idx := 0; nCoils > 0; idx++ {
@@ -114,11 +138,9 @@ func (sf *NodeRegister) WriteCoils(address, quality uint16, valBuf []byte) error
 			start += 8
 			nCoils -= 8
 		}
-		sf.rw.Unlock()
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // WriteSingleCoil 写单个线圈
@@ -132,9 +154,29 @@ func (sf *NodeRegister) WriteSingleCoil(address uint16, val bool) error {
 
 // ReadCoils 读线圈,返回值
 func (sf *NodeRegister) ReadCoils(address, quality uint16) ([]byte, error) {
-	sf.rw.RLock()
+	sf.coilsRW.RLock()
+	v, err := sf.readCoils(address, quality)
+	if err == nil {
+		err = sf.overlayBits(TableCoils, address, quality, v)
+	}
+	sf.coilsRW.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// readCoils 读线圈, 调用者需持有锁
+func (sf *NodeRegister) readCoils(address, quality uint16) ([]byte, error) {
 	if (address >= sf.coilsAddrStart) &&
 		((address + quality) <= (sf.coilsAddrStart + sf.coilsQuantity)) {
+		if b := sf.backends[TableCoils]; b != nil {
+			v, err := readBackend(b, address, quality)
+			if err != nil {
+				return nil, err
+			}
+			return packBits(v), nil
+		}
 		start := address - sf.coilsAddrStart
 		nCoils := int16(quality)
 		result := make([]byte, 0, (quality+7)/8)
@@ -146,11 +188,9 @@ func (sf *NodeRegister) ReadCoils(address, quality uint16) ([]byte, error) {
 			result = append(result, getBits(sf.coils, start, uint16(num)))
 			start += 8
 		}
-		sf.rw.RUnlock()
 		return result, nil
 	}
-	sf.rw.RUnlock()
-	return nil, &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // ReadSingleCoil 读单个线圈
@@ -164,9 +204,22 @@ func (sf *NodeRegister) ReadSingleCoil(address uint16) (bool, error) {
 
 // WriteDiscretes 写离散量
 func (sf *NodeRegister) WriteDiscretes(address, quality uint16, valBuf []byte) error {
-	sf.rw.Lock()
+	sf.discreteRW.Lock()
+	err := sf.writeDiscretes(address, quality, valBuf)
+	if err == nil {
+		sf.notify(TableDiscreteInputs, address, quality)
+	}
+	sf.discreteRW.Unlock()
+	return err
+}
+
+// writeDiscretes 写离散量, 调用者需持有锁
+func (sf *NodeRegister) writeDiscretes(address, quality uint16, valBuf []byte) error {
 	if len(valBuf)*8 >= int(quality) && (address >= sf.discreteAddrStart) &&
 		((address + quality) <= (sf.discreteAddrStart + sf.discreteQuantity)) {
+		if b := sf.backends[TableDiscreteInputs]; b != nil {
+			return b.WriteRegs(address, unpackBits(valBuf, quality))
+		}
 		start := address - sf.discreteAddrStart
 		nCoils := int16(quality)
 		for idx := 0; nCoils > 0; idx++ {
@@ -178,11 +231,9 @@ func (sf *NodeRegister) WriteDiscretes(address, quality uint16, valBuf []byte) e
 			start += 8
 			nCoils -= 8
 		}
-		sf.rw.Unlock()
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // WriteSingleDiscrete 写单个离散量
@@ -196,9 +247,29 @@ func (sf *NodeRegister) WriteSingleDiscrete(address uint16, val bool) error {
 
 // ReadDiscretes 读离散量
 func (sf *NodeRegister) ReadDiscretes(address, quality uint16) ([]byte, error) {
-	sf.rw.RLock()
+	sf.discreteRW.RLock()
+	v, err := sf.readDiscretes(address, quality)
+	if err == nil {
+		err = sf.overlayBits(TableDiscreteInputs, address, quality, v)
+	}
+	sf.discreteRW.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// readDiscretes 读离散量, 调用者需持有锁
+func (sf *NodeRegister) readDiscretes(address, quality uint16) ([]byte, error) {
 	if (address >= sf.discreteAddrStart) &&
 		((address + quality) <= (sf.discreteAddrStart + sf.discreteQuantity)) {
+		if b := sf.backends[TableDiscreteInputs]; b != nil {
+			v, err := readBackend(b, address, quality)
+			if err != nil {
+				return nil, err
+			}
+			return packBits(v), nil
+		}
 		start := address - sf.discreteAddrStart
 		nCoils := int16(quality)
 		result := make([]byte, 0, (quality+7)/8)
@@ -210,11 +281,9 @@ func (sf *NodeRegister) ReadDiscretes(address, quality uint16) ([]byte, error) {
 			result = append(result, getBits(sf.discrete, start, uint16(num)))
 			start += 8
 		}
-		sf.rw.RUnlock()
 		return result, nil
 	}
-	sf.rw.RUnlock()
-	return nil, &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // ReadSingleDiscrete 读单个离散量
@@ -228,156 +297,288 @@ func (sf *NodeRegister) ReadSingleDiscrete(address uint16) (bool, error) {
 
 // WriteHoldingsBytes 写保持寄存器
 func (sf *NodeRegister) WriteHoldingsBytes(address, quality uint16, valBuf []byte) error {
-	sf.rw.Lock()
+	sf.holdingRW.Lock()
+	err := checkProtect(sf.holdingProtect, address, quality)
+	if err == nil {
+		err = sf.writeHoldingsBytes(address, quality, valBuf)
+	}
+	if err == nil {
+		sf.notify(TableHoldingRegisters, address, quality)
+	}
+	sf.holdingRW.Unlock()
+	return err
+}
+
+// writeHoldingsBytes 写保持寄存器, 调用者需持有锁
+func (sf *NodeRegister) writeHoldingsBytes(address, quality uint16, valBuf []byte) error {
 	if len(valBuf) == int(quality*2) &&
 		(address >= sf.holdingAddrStart) &&
 		((address + quality) <= (sf.holdingAddrStart + uint16(len(sf.holding)))) {
+		if b := sf.backends[TableHoldingRegisters]; b != nil {
+			return b.WriteRegs(address, bytes2Uint16(valBuf))
+		}
 		start := address - sf.holdingAddrStart
 		end := start + quality
 		buf := bytes.NewBuffer(valBuf)
 		err := binary.Read(buf, binary.BigEndian, sf.holding[start:end])
-		sf.rw.Unlock()
 		if err != nil {
-			return &ExceptionError{ExceptionCodeServerDeviceFailure}
+			return &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceFailure}
 		}
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // WriteHoldings 写保持寄存器
 func (sf *NodeRegister) WriteHoldings(address uint16, valBuf []uint16) error {
+	sf.holdingRW.Lock()
+	err := checkProtect(sf.holdingProtect, address, uint16(len(valBuf)))
+	if err == nil {
+		err = sf.writeHoldings(address, valBuf)
+	}
+	if err == nil {
+		sf.notify(TableHoldingRegisters, address, uint16(len(valBuf)))
+	}
+	sf.holdingRW.Unlock()
+	return err
+}
+
+// writeHoldings 写保持寄存器, 调用者需持有锁
+func (sf *NodeRegister) writeHoldings(address uint16, valBuf []uint16) error {
 	quality := uint16(len(valBuf))
-	sf.rw.Lock()
 	if (address >= sf.holdingAddrStart) &&
 		((address + quality) <= (sf.holdingAddrStart + uint16(len(sf.holding)))) {
+		if b := sf.backends[TableHoldingRegisters]; b != nil {
+			return b.WriteRegs(address, append([]uint16(nil), valBuf...))
+		}
 		start := address - sf.holdingAddrStart
 		end := start + quality
 		copy(sf.holding[start:end], valBuf)
-		sf.rw.Unlock()
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // ReadHoldingsBytes 读保持寄存器,仅返回寄存器值
 func (sf *NodeRegister) ReadHoldingsBytes(address, quality uint16) ([]byte, error) {
-	sf.rw.RLock()
-	if (address >= sf.holdingAddrStart) &&
-		((address + quality) <= (sf.holdingAddrStart + uint16(len(sf.holding)))) {
-		start := address - sf.holdingAddrStart
-		end := start + quality
-		buf := new(bytes.Buffer)
-		err := binary.Write(buf, binary.BigEndian, sf.holding[start:end])
-		sf.rw.RUnlock()
-		if err != nil {
-			return nil, &ExceptionError{ExceptionCodeServerDeviceFailure}
-		}
-		return buf.Bytes(), nil
+	sf.holdingRW.RLock()
+	v, err := sf.readHoldingsBytes(address, quality)
+	sf.holdingRW.RUnlock()
+	return v, err
+}
+
+// readHoldingsBytes 读保持寄存器, 调用者需持有锁
+func (sf *NodeRegister) readHoldingsBytes(address, quality uint16) ([]byte, error) {
+	v, err := sf.readHoldings(address, quality)
+	if err != nil {
+		return nil, err
 	}
-	sf.rw.RUnlock()
-	return nil, &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return uint162Bytes(v), nil
 }
 
 // ReadHoldings 读保持寄存器,仅返回寄存器值
 func (sf *NodeRegister) ReadHoldings(address, quality uint16) ([]uint16, error) {
-	sf.rw.RLock()
+	sf.holdingRW.RLock()
+	v, err := sf.readHoldings(address, quality)
+	sf.holdingRW.RUnlock()
+	return v, err
+}
+
+// readHoldings 读保持寄存器, 调用者需持有锁
+func (sf *NodeRegister) readHoldings(address, quality uint16) ([]uint16, error) {
 	if (address >= sf.holdingAddrStart) &&
 		((address + quality) <= (sf.holdingAddrStart + uint16(len(sf.holding)))) {
-		start := address - sf.holdingAddrStart
-		end := start + quality
-		result := make([]uint16, quality)
-		copy(result, sf.holding[start:end])
-		sf.rw.RUnlock()
+		var result []uint16
+		if b := sf.backends[TableHoldingRegisters]; b != nil {
+			v, err := readBackend(b, address, quality)
+			if err != nil {
+				return nil, err
+			}
+			result = v
+		} else {
+			start := address - sf.holdingAddrStart
+			end := start + quality
+			result = make([]uint16, quality)
+			copy(result, sf.holding[start:end])
+		}
+		if err := sf.overlayRegs(TableHoldingRegisters, address, result); err != nil {
+			return nil, err
+		}
 		return result, nil
 	}
-	sf.rw.RUnlock()
-	return nil, &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // WriteInputsBytes 写输入寄存器
 func (sf *NodeRegister) WriteInputsBytes(address, quality uint16, regBuf []byte) error {
-	sf.rw.Lock()
+	sf.inputRW.Lock()
+	err := sf.writeInputsBytes(address, quality, regBuf)
+	if err == nil {
+		sf.notify(TableInputRegisters, address, quality)
+	}
+	sf.inputRW.Unlock()
+	return err
+}
+
+// writeInputsBytes 写输入寄存器, 调用者需持有锁
+func (sf *NodeRegister) writeInputsBytes(address, quality uint16, regBuf []byte) error {
 	if len(regBuf) == int(quality*2) &&
 		(address >= sf.inputAddrStart) &&
 		((address + quality) <= (sf.inputAddrStart + uint16(len(sf.input)))) {
+		if b := sf.backends[TableInputRegisters]; b != nil {
+			return b.WriteRegs(address, bytes2Uint16(regBuf))
+		}
 		start := address - sf.inputAddrStart
 		end := start + quality
 		buf := bytes.NewBuffer(regBuf)
 		err := binary.Read(buf, binary.BigEndian, sf.input[start:end])
-		sf.rw.Unlock()
 		if err != nil {
-			return &ExceptionError{ExceptionCodeServerDeviceFailure}
+			return &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceFailure}
 		}
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // WriteInputs 写输入寄存器
 func (sf *NodeRegister) WriteInputs(address uint16, valBuf []uint16) error {
+	sf.inputRW.Lock()
+	err := sf.writeInputs(address, valBuf)
+	if err == nil {
+		sf.notify(TableInputRegisters, address, uint16(len(valBuf)))
+	}
+	sf.inputRW.Unlock()
+	return err
+}
+
+// writeInputs 写输入寄存器, 调用者需持有锁
+func (sf *NodeRegister) writeInputs(address uint16, valBuf []uint16) error {
 	quality := uint16(len(valBuf))
-	sf.rw.Lock()
 	if (address >= sf.inputAddrStart) &&
 		((address + quality) <= (sf.inputAddrStart + uint16(len(sf.input)))) {
+		if b := sf.backends[TableInputRegisters]; b != nil {
+			return b.WriteRegs(address, append([]uint16(nil), valBuf...))
+		}
 		start := address - sf.inputAddrStart
 		end := start + quality
 		copy(sf.input[start:end], valBuf)
-		sf.rw.Unlock()
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // ReadInputsBytes 读输入寄存器
 func (sf *NodeRegister) ReadInputsBytes(address, quality uint16) ([]byte, error) {
-	sf.rw.RLock()
-	if (address >= sf.inputAddrStart) &&
-		((address + quality) <= (sf.inputAddrStart + uint16(len(sf.input)))) {
-		start := address - sf.inputAddrStart
-		end := start + quality
-		buf := new(bytes.Buffer)
-		err := binary.Write(buf, binary.BigEndian, sf.input[start:end])
-		sf.rw.RUnlock()
-		if err != nil {
-			return nil, &ExceptionError{ExceptionCodeServerDeviceFailure}
-		}
-		return buf.Bytes(), nil
+	sf.inputRW.RLock()
+	v, err := sf.readInputs(address, quality)
+	sf.inputRW.RUnlock()
+	if err != nil {
+		return nil, err
 	}
-	sf.rw.RUnlock()
-	return nil, &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return uint162Bytes(v), nil
 }
 
 // ReadInputs 读输入寄存器
 func (sf *NodeRegister) ReadInputs(address, quality uint16) ([]uint16, error) {
-	sf.rw.RLock()
+	sf.inputRW.RLock()
+	v, err := sf.readInputs(address, quality)
+	sf.inputRW.RUnlock()
+	return v, err
+}
+
+// readInputs 读输入寄存器, 调用者需持有锁
+func (sf *NodeRegister) readInputs(address, quality uint16) ([]uint16, error) {
 	if (address >= sf.inputAddrStart) &&
 		((address + quality) <= (sf.inputAddrStart + uint16(len(sf.input)))) {
-		start := address - sf.inputAddrStart
-		end := start + quality
-		result := make([]uint16, quality)
-		copy(result, sf.input[start:end])
-		sf.rw.RUnlock()
+		var result []uint16
+		if b := sf.backends[TableInputRegisters]; b != nil {
+			v, err := readBackend(b, address, quality)
+			if err != nil {
+				return nil, err
+			}
+			result = v
+		} else {
+			start := address - sf.inputAddrStart
+			end := start + quality
+			result = make([]uint16, quality)
+			copy(result, sf.input[start:end])
+		}
+		if err := sf.overlayRegs(TableInputRegisters, address, result); err != nil {
+			return nil, err
+		}
 		return result, nil
 	}
-	sf.rw.RUnlock()
-	return nil, &ExceptionError{ExceptionCodeIllegalDataAddress}
+	return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
 }
 
 // MaskWriteHolding 屏蔽写保持寄存器 (val & andMask) | (orMask & ^andMask)
 func (sf *NodeRegister) MaskWriteHolding(address, andMask, orMask uint16) error {
-	sf.rw.Lock()
+	sf.holdingRW.Lock()
+	if err := checkProtect(sf.holdingProtect, address, 1); err != nil {
+		sf.holdingRW.Unlock()
+		return err
+	}
 	if (address >= sf.holdingAddrStart) &&
 		((address + 1) <= (sf.holdingAddrStart + uint16(len(sf.holding)))) {
-		sf.holding[address] &= andMask
-		sf.holding[address] |= orMask & ^andMask
-		sf.rw.Unlock()
+		if b := sf.backends[TableHoldingRegisters]; b != nil {
+			err := maskWriteBackend(b, address, andMask, orMask)
+			if err == nil {
+				sf.notify(TableHoldingRegisters, address, 1)
+			}
+			sf.holdingRW.Unlock()
+			return err
+		}
+		start := address - sf.holdingAddrStart
+		sf.holding[start] &= andMask
+		sf.holding[start] |= orMask & ^andMask
+		sf.notify(TableHoldingRegisters, address, 1)
+		sf.holdingRW.Unlock()
 		return nil
 	}
-	sf.rw.Unlock()
-	return &ExceptionError{ExceptionCodeIllegalDataAddress}
+	sf.holdingRW.Unlock()
+	return &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
+}
+
+// ReadString 读保持寄存器中的字符串, swap 为寄存器内字节交换, 去除尾部的NUL和空格填充
+func (sf *NodeRegister) ReadString(address, quantity uint16, swap bool) (string, error) {
+	b, err := sf.ReadHoldingsBytes(address, quantity)
+	if err != nil {
+		return "", err
+	}
+	return decodeString(b, swap), nil
+}
+
+// WriteString 写字符串到保持寄存器, 以NUL填充, swap 为寄存器内字节交换
+func (sf *NodeRegister) WriteString(address, quantity uint16, s string, swap bool) error {
+	b, err := encodeString(s, quantity, swap)
+	if err != nil {
+		return err
+	}
+	return sf.WriteHoldingsBytes(address, quantity, b)
+}
+
+// Table 寄存器表
+type Table byte
+
+// 寄存器表
+const (
+	TableCoils            Table = iota // 线圈
+	TableDiscreteInputs                // 离散量输入
+	TableInputRegisters                // 输入寄存器
+	TableHoldingRegisters              // 保持寄存器
+)
+
+// String 表名
+func (t Table) String() string {
+	switch t {
+	case TableCoils:
+		return "coils"
+	case TableDiscreteInputs:
+		return "discrete inputs"
+	case TableInputRegisters:
+		return "input registers"
+	case TableHoldingRegisters:
+		return "holding registers"
+	}
+	return "unknown"
 }
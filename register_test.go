@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+	"time"
 )
 
 const (
@@ -662,6 +663,10 @@ func TestNodeRegister_MaskWriteHolding(t *testing.T) {
 		holdingAddrStart: 0,
 		holding:          []uint16{0x0000, 0x0012, 0x0000},
 	}
+	offsetReg := &NodeRegister{
+		holdingAddrStart: 100,
+		holding:          []uint16{0x0000, 0x0012, 0x0000},
+	}
 	type args struct {
 		address uint16
 		andMask uint16
@@ -677,14 +682,17 @@ func TestNodeRegister_MaskWriteHolding(t *testing.T) {
 		{"掩码", nodeReg, args{1, 0xf2, 0x25}, 0x0017, false},
 		{"超始始地址", nodeReg, args{address: wordQuantity + 1}, 0x0012, true},
 		{"超地址范围", nodeReg, args{address: wordQuantity}, 0x0012, true},
+		{"非零起始地址", offsetReg, args{101, 0xf2, 0x25}, 0x0017, false},
+		{"非零起始地址之前", offsetReg, args{address: 99}, 0x0012, true},
+		{"非零起始地址超范围", offsetReg, args{address: 103}, 0x0012, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if err := tt.this.MaskWriteHolding(tt.args.address, tt.args.andMask, tt.args.orMask); (err != nil) != tt.wantErr {
 				t.Errorf("NodeRegister.MaskWriteHolding() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if !tt.wantErr && tt.this.holding[int(tt.args.address)] != tt.want {
-				t.Errorf("NodeRegister.MaskWriteHolding() got = %#v, want %#v", tt.this.holding[tt.args.address], tt.want)
+			if i := tt.args.address - tt.this.holdingAddrStart; !tt.wantErr && tt.this.holding[i] != tt.want {
+				t.Errorf("NodeRegister.MaskWriteHolding() got = %#v, want %#v", tt.this.holding[i], tt.want)
 			}
 		})
 	}
@@ -703,3 +711,31 @@ func Benchmark_setBits(b *testing.B) {
 		setBits(val, 12, 8, 0xaa)
 	}
 }
+
+func TestNodeRegister_TableLock(t *testing.T) {
+	node := newNodeReg()
+	node.holdingRW.Lock()
+	defer node.holdingRW.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = node.ReadCoils(0, 8)
+		_ = node.WriteInputs(0, []uint16{1})
+		_, _ = node.ReadDiscretes(0, 8)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("other tables are blocked by the holding registers lock")
+	}
+}
+
+func Benchmark_NodeRegister_ReadHoldingsParallel(b *testing.B) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 125)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = node.ReadHoldingsBytes(0, 125)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package modbus
+
+// 本文件提供了节点寄存器跨2个或4个寄存器的32/64位值的读写, 字序由 Order 选择,
+// 整个值在一次加锁内读写, 主站不会读到只更新了一半的值
+
+import (
+	"fmt"
+	"math"
+)
+
+// readRegs 在一次加锁内读取输入寄存器或保持寄存器的字节
+func (sf *NodeRegister) readRegs(table Table, address, quantity uint16) ([]byte, error) {
+	switch table {
+	case TableInputRegisters:
+		sf.inputRW.RLock()
+		v, err := sf.readInputs(address, quantity)
+		sf.inputRW.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		return uint162Bytes(v), nil
+	case TableHoldingRegisters:
+		return sf.ReadHoldingsBytes(address, quantity)
+	}
+	return nil, fmt.Errorf("modbus: table '%v' is not a register table", table)
+}
+
+// modifyRegs 在一次加锁内修改输入寄存器或保持寄存器, modify 为true时先读取当前值,
+// fn 在读取的字节上修改, 否则在零值上填写, 然后写回. 保持寄存器受写保护区限制
+func (sf *NodeRegister) modifyRegs(table Table, address, quantity uint16, modify bool, fn func(b []byte)) error {
+	var read func(address, quantity uint16) ([]uint16, error)
+	var write func(address, quantity uint16, b []byte) error
+	switch table {
+	case TableInputRegisters:
+		sf.inputRW.Lock()
+		defer sf.inputRW.Unlock()
+		read, write = sf.readInputs, sf.writeInputsBytes
+	case TableHoldingRegisters:
+		sf.holdingRW.Lock()
+		defer sf.holdingRW.Unlock()
+		if err := checkProtect(sf.holdingProtect, address, quantity); err != nil {
+			return err
+		}
+		read, write = sf.readHoldings, sf.writeHoldingsBytes
+	default:
+		return fmt.Errorf("modbus: table '%v' is not a register table", table)
+	}
+
+	b := make([]byte, quantity*2)
+	if modify {
+		v, err := read(address, quantity)
+		if err != nil {
+			return err
+		}
+		b = uint162Bytes(v)
+	}
+	fn(b)
+	if err := write(address, quantity, b); err != nil {
+		return err
+	}
+	sf.notify(table, address, quantity)
+	return nil
+}
+
+// ReadUint32 读2个寄存器为uint32, table 为输入寄存器或保持寄存器
+func (sf *NodeRegister) ReadUint32(table Table, address uint16, order Order) (uint32, error) {
+	b, err := sf.readRegs(table, address, 2)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint32(b), nil
+}
+
+// ReadInt32 读2个寄存器为int32
+func (sf *NodeRegister) ReadInt32(table Table, address uint16, order Order) (int32, error) {
+	v, err := sf.ReadUint32(table, address, order)
+	return int32(v), err
+}
+
+// ReadFloat32 读2个寄存器为IEEE 754 float32
+func (sf *NodeRegister) ReadFloat32(table Table, address uint16, order Order) (float32, error) {
+	v, err := sf.ReadUint32(table, address, order)
+	return math.Float32frombits(v), err
+}
+
+// ReadUint64 读4个寄存器为uint64, table 为输入寄存器或保持寄存器
+func (sf *NodeRegister) ReadUint64(table Table, address uint16, order Order) (uint64, error) {
+	b, err := sf.readRegs(table, address, 4)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint64(b), nil
+}
+
+// ReadInt64 读4个寄存器为int64
+func (sf *NodeRegister) ReadInt64(table Table, address uint16, order Order) (int64, error) {
+	v, err := sf.ReadUint64(table, address, order)
+	return int64(v), err
+}
+
+// ReadFloat64 读4个寄存器为IEEE 754 float64
+func (sf *NodeRegister) ReadFloat64(table Table, address uint16, order Order) (float64, error) {
+	v, err := sf.ReadUint64(table, address, order)
+	return math.Float64frombits(v), err
+}
+
+// WriteUint32 写uint32到2个寄存器, table 为输入寄存器或保持寄存器
+func (sf *NodeRegister) WriteUint32(table Table, address uint16, value uint32, order Order) error {
+	return sf.modifyRegs(table, address, 2, false, func(b []byte) {
+		order.PutUint32(b, value)
+	})
+}
+
+// WriteInt32 写int32到2个寄存器
+func (sf *NodeRegister) WriteInt32(table Table, address uint16, value int32, order Order) error {
+	return sf.WriteUint32(table, address, uint32(value), order)
+}
+
+// WriteFloat32 写IEEE 754 float32到2个寄存器
+func (sf *NodeRegister) WriteFloat32(table Table, address uint16, value float32, order Order) error {
+	return sf.WriteUint32(table, address, math.Float32bits(value), order)
+}
+
+// WriteUint64 写uint64到4个寄存器, table 为输入寄存器或保持寄存器
+func (sf *NodeRegister) WriteUint64(table Table, address uint16, value uint64, order Order) error {
+	return sf.modifyRegs(table, address, 4, false, func(b []byte) {
+		order.PutUint64(b, value)
+	})
+}
+
+// WriteInt64 写int64到4个寄存器
+func (sf *NodeRegister) WriteInt64(table Table, address uint16, value int64, order Order) error {
+	return sf.WriteUint64(table, address, uint64(value), order)
+}
+
+// WriteFloat64 写IEEE 754 float64到4个寄存器
+func (sf *NodeRegister) WriteFloat64(table Table, address uint16, value float64, order Order) error {
+	return sf.WriteUint64(table, address, math.Float64bits(value), order)
+}
+
+// UpdateUint32 读-改-写2个寄存器的uint32, 如累加计数, fn 执行期间持有表的写锁,
+// fn 内不可调用该节点的方法, 否则死锁
+func (sf *NodeRegister) UpdateUint32(table Table, address uint16, order Order, fn func(v uint32) uint32) error {
+	return sf.modifyRegs(table, address, 2, true, func(b []byte) {
+		order.PutUint32(b, fn(order.Uint32(b)))
+	})
+}
+
+// UpdateFloat32 读-改-写2个寄存器的float32, 见 UpdateUint32
+func (sf *NodeRegister) UpdateFloat32(table Table, address uint16, order Order, fn func(v float32) float32) error {
+	return sf.UpdateUint32(table, address, order, func(v uint32) uint32 {
+		return math.Float32bits(fn(math.Float32frombits(v)))
+	})
+}
+
+// UpdateUint64 读-改-写4个寄存器的uint64, 见 UpdateUint32
+func (sf *NodeRegister) UpdateUint64(table Table, address uint16, order Order, fn func(v uint64) uint64) error {
+	return sf.modifyRegs(table, address, 4, true, func(b []byte) {
+		order.PutUint64(b, fn(order.Uint64(b)))
+	})
+}
+
+// UpdateFloat64 读-改-写4个寄存器的float64, 见 UpdateUint32
+func (sf *NodeRegister) UpdateFloat64(table Table, address uint16, order Order, fn func(v float64) float64) error {
+	return sf.UpdateUint64(table, address, order, func(v uint64) uint64 {
+		return math.Float64bits(fn(math.Float64frombits(v)))
+	})
+}
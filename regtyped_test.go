@@ -0,0 +1,124 @@
+package modbus
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNodeRegister_Typed(t *testing.T) {
+	tests := []struct {
+		order Order
+		want  []uint16
+	}{
+		{OrderABCD, []uint16{0x0102, 0x0304}},
+		{OrderDCBA, []uint16{0x0403, 0x0201}},
+		{OrderBADC, []uint16{0x0201, 0x0403}},
+		{OrderCDAB, []uint16{0x0304, 0x0102}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order.String(), func(t *testing.T) {
+			node := NewNodeRegister(1, 0, 0, 0, 0, 0, 4, 0, 4)
+			for _, table := range []Table{TableInputRegisters, TableHoldingRegisters} {
+				if err := node.WriteUint32(table, 1, 0x01020304, tt.order); err != nil {
+					t.Fatal(err)
+				}
+				got, err := node.ReadUint32(table, 1, tt.order)
+				if err != nil || got != 0x01020304 {
+					t.Errorf("%v ReadUint32() = %#x, %v", table, got, err)
+				}
+			}
+			if regs, _ := node.ReadHoldings(1, 2); !reflect.DeepEqual(regs, tt.want) {
+				t.Errorf("holdings = %#x, want %#x", regs, tt.want)
+			}
+			if regs, _ := node.ReadInputs(1, 2); !reflect.DeepEqual(regs, tt.want) {
+				t.Errorf("inputs = %#x, want %#x", regs, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeRegister_Typed64(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 8)
+	if err := node.WriteFloat64(TableHoldingRegisters, 0, math.Pi, OrderCDAB); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := node.ReadFloat64(TableHoldingRegisters, 0, OrderCDAB); err != nil || v != math.Pi {
+		t.Errorf("ReadFloat64() = %v, %v", v, err)
+	}
+	if err := node.WriteInt64(TableHoldingRegisters, 4, -2, OrderABCD); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := node.ReadInt64(TableHoldingRegisters, 4, OrderABCD); v != -2 {
+		t.Errorf("ReadInt64() = %v, want -2", v)
+	}
+	if err := node.WriteInt32(TableHoldingRegisters, 4, -3, OrderABCD); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := node.ReadInt32(TableHoldingRegisters, 4, OrderABCD); v != -3 {
+		t.Errorf("ReadInt32() = %v, want -3", v)
+	}
+	if err := node.WriteFloat32(TableHoldingRegisters, 6, 1.5, OrderDCBA); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := node.ReadFloat32(TableHoldingRegisters, 6, OrderDCBA); v != 1.5 {
+		t.Errorf("ReadFloat32() = %v, want 1.5", v)
+	}
+	if _, err := node.ReadUint64(TableHoldingRegisters, 6, OrderABCD); !IsIllegalDataAddress(err) {
+		t.Errorf("ReadUint64() out of range error = %v, want illegal data address", err)
+	}
+	if err := node.WriteUint32(TableCoils, 0, 1, OrderABCD); err == nil {
+		t.Errorf("WriteUint32() coils error = nil")
+	}
+	if _, err := node.ReadUint32(TableDiscreteInputs, 0, OrderABCD); err == nil {
+		t.Errorf("ReadUint32() discrete inputs error = nil")
+	}
+}
+
+func TestNodeRegister_TypedProtectAndNotify(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 8)
+	_ = node.Protect(TableHoldingRegisters, 3, 1, ExceptionCodeIllegalDataValue)
+	ch, cancel := node.Subscribe(TableHoldingRegisters, 0, 8, 4)
+	defer cancel()
+	if err := node.WriteUint64(TableHoldingRegisters, 0, 1, OrderABCD); ExceptionOf(err) != ExceptionCodeIllegalDataValue {
+		t.Errorf("WriteUint64() error = %v, want illegal data value", err)
+	}
+	if err := node.WriteUint32(TableHoldingRegisters, 4, 1, OrderABCD); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := drainChanges(ch), []ChangeEvent{{1, TableHoldingRegisters, 4, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestNodeRegister_UpdateTyped(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 8, 0, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = node.UpdateUint32(TableInputRegisters, 0, OrderCDAB, func(v uint32) uint32 { return v + 0x10001 })
+				_ = node.UpdateFloat64(TableHoldingRegisters, 0, OrderABCD, func(v float64) float64 { return v + 0.5 })
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := node.ReadUint32(TableInputRegisters, 0, OrderCDAB); v != 800*0x10001 {
+		t.Errorf("ReadUint32() = %#x, want %#x", v, 800*0x10001)
+	}
+	if v, _ := node.ReadFloat64(TableHoldingRegisters, 0, OrderABCD); v != 400 {
+		t.Errorf("ReadFloat64() = %v, want 400", v)
+	}
+	if err := node.UpdateUint64(TableInputRegisters, 4, OrderABCD, func(v uint64) uint64 { return v + 1 }); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.UpdateFloat32(TableInputRegisters, 2, OrderABCD, func(v float32) float32 { return v - 1 }); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := node.ReadFloat32(TableInputRegisters, 2, OrderABCD); v != -1 {
+		t.Errorf("ReadFloat32() = %v, want -1", v)
+	}
+}
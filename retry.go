@@ -0,0 +1,80 @@
+package modbus
+
+import (
+	"context"
+	"time"
+)
+
+// Backoff 重试退避策略, attempt 为重试次数,从1开始, 返回重试前的等待时间
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff 固定间隔
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration { return d }
+}
+
+// ExponentialBackoff 指数退避, base*2^(attempt-1), 但不超过max
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// ShouldRetry is the default retry condition, the transient errors such as
+// timeout and CRC/LRC mismatch are retried, but modbus exception responses
+// and closed connection are not, as retrying them gets the same result.
+func ShouldRetry(err error) bool {
+	if err == nil || err == ErrClosedConnection {
+		return false
+	}
+	_, isException := AsExceptionError(err)
+	return !isException
+}
+
+// retryPolicy 重试策略
+type retryPolicy struct {
+	n           int
+	shouldRetry func(error) bool
+	backoff     Backoff
+}
+
+// wrap the doer with retry
+func (sf retryPolicy) wrap(next Doer) Doer {
+	return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		response, err := DoContext(ctx, next, slaveID, request)
+		for attempt := 1; attempt <= sf.n && sf.shouldRetry(err); attempt++ {
+			if sf.backoff != nil {
+				if e := sleepContext(ctx, sf.backoff(attempt)); e != nil {
+					return response, err
+				}
+			}
+			if ctx.Err() != nil {
+				return response, err
+			}
+			response, err = DoContext(ctx, next, slaveID, request)
+		}
+		return response, err
+	})
+}
+
+// sleepContext sleep d, return the error of the context if it is done before that
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
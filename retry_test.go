@@ -0,0 +1,65 @@
+package modbus
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyProvider fail the first n transactions
+type flakyProvider struct {
+	provider
+	fails int
+	calls int
+}
+
+func (sf *flakyProvider) Send(_ byte, _ ProtocolDataUnit) (ProtocolDataUnit, error) {
+	sf.calls++
+	if sf.calls <= sf.fails {
+		return ProtocolDataUnit{}, sf.err
+	}
+	return ProtocolDataUnit{FuncCode: FuncCodeWriteSingleRegister, Data: []byte{0x00, 0x01, 0x00, 0x02}}, nil
+}
+
+func TestWithRetry(t *testing.T) {
+	timeout := errors.New("timeout")
+	exception := &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
+	tests := []struct {
+		name      string
+		err       error
+		fails     int
+		n         int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"no error", timeout, 0, 3, 1, false},
+		{"recovered", timeout, 2, 3, 3, false},
+		{"exhausted", timeout, 5, 3, 4, true},
+		{"exception not retried", exception, 5, 3, 1, true},
+		{"closed not retried", ErrClosedConnection, 5, 3, 1, true},
+		{"retry disabled", timeout, 1, 0, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &flakyProvider{provider: provider{err: tt.err}, fails: tt.fails}
+			c := NewClient(p, WithRetry(tt.n, nil, ConstantBackoff(time.Millisecond)))
+			err := c.WriteSingleRegister(1, 1, 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteSingleRegister() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p.calls != tt.wantCalls {
+				t.Errorf("calls = %v, want %v", p.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := b(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package modbus
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -15,7 +16,13 @@ const (
 type RTUClientProvider struct {
 	serialPort
 	logger
+	providerCommon
 	*pool // 请求池,所有RTU客户端共用一个请求池
+	// silent interval, 0 means calculated by the baud rate
+	charDelay, frameDelay time.Duration
+	counters              rtuCounters
+	// the bound of the resynchronization search, 0 use the serial timeout, < 0 disable it
+	resyncTimeout time.Duration
 }
 
 // check RTUClientProvider implements underlying method
@@ -31,6 +38,7 @@ func NewRTUClientProvider() *RTUClientProvider {
 		logger: newLogger("modbusRTUMaster =>"),
 		pool:   rtuPool,
 	}
+	p.events = &p.lifecycle
 	p.Timeout = SerialDefaultTimeout
 	p.autoReconnect = SerialDefaultAutoReconnect
 	return p
@@ -58,7 +66,7 @@ func decodeRTUFrame(adu []byte) (uint8, []byte, error) {
 	crc := crc16(adu[0 : len(adu)-2])
 	expect := binary.LittleEndian.Uint16(adu[len(adu)-2:])
 	if crc != expect {
-		return 0, nil, fmt.Errorf("modbus: response crc '%x' does not match expected '%x'", expect, crc)
+		return 0, nil, &ChecksumError{"crc", expect, crc}
 	}
 	// slaveID & PDU but pass crc
 	return adu[0], adu[1 : len(adu)-2], nil
@@ -66,24 +74,44 @@ func decodeRTUFrame(adu []byte) (uint8, []byte, error) {
 
 // Send request to the remote server,it implements on SendRawFrame
 func (sf *RTUClientProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.sendContext(context.Background(), nil, slaveID, request)
+}
+
+// SendContext send the request like Send, the response is read until the deadline of ctx
+// instead of the serial timeout, and the read is aborted when ctx is done.
+func (sf *RTUClientProvider) SendContext(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.sendContext(ctx, nil, slaveID, request)
+}
+
+// sendInto send the request and decode the response data into dst,
+// the frame buffers come from the pool, so it is allocation free when dst is large enough.
+func (sf *RTUClientProvider) sendInto(dst []byte, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.sendContext(context.Background(), dst, slaveID, request)
+}
+
+// sendContext send the request with the context and decode the response data into dst
+func (sf *RTUClientProvider) sendContext(ctx context.Context, dst []byte, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
 	var response ProtocolDataUnit
 
 	frame := sf.pool.get()
 	defer sf.pool.put(frame)
+	rspFrame := sf.pool.get()
+	defer sf.pool.put(rspFrame)
 
 	aduRequest, err := frame.encodeRTUFrame(slaveID, request)
 	if err != nil {
 		return response, err
 	}
-	aduResponse, err := sf.SendRawFrame(aduRequest)
-	if err != nil {
+	aduResponse, err := sf.sendRawFrame(ctx, rspFrame.adu[:rtuAduMaxSize], aduRequest)
+	if err != nil || slaveID == AddressBroadCast {
 		return response, err
 	}
-	rspSlaveID, pdu, err := decodeRTUFrame(aduResponse)
+	rspSlaveID, pdu, err := sf.decodeFrame(aduResponse)
 	if err != nil {
 		return response, err
 	}
-	response = ProtocolDataUnit{pdu[0], pdu[1:]}
+	response = ProtocolDataUnit{pdu[0], append(dst[:0], pdu[1:]...)}
+	rspSlaveID = sf.tolerate(slaveID, rspSlaveID, request, &response)
 	if err = verify(slaveID, rspSlaveID, request, response); err != nil {
 		return response, err
 	}
@@ -107,43 +135,65 @@ func (sf *RTUClientProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, e
 	}
 
 	aduResponse, err := sf.SendRawFrame(requestAdu)
-	if err != nil {
+	if err != nil || slaveID == AddressBroadCast {
 		return nil, err
 	}
-	rspSlaveID, pdu, err := decodeRTUFrame(aduResponse)
+	rspSlaveID, pdu, err := sf.decodeFrame(aduResponse)
 	if err != nil {
 		return nil, err
 	}
 	response := ProtocolDataUnit{pdu[0], pdu[1:]}
+	rspSlaveID = sf.tolerate(slaveID, rspSlaveID, request, &response)
 	if err = verify(slaveID, rspSlaveID, request, response); err != nil {
 		return nil, err
 	}
+	pdu = pdu[:1+len(response.Data)]
 	//  PDU pass slaveID & crc
 	return pdu, nil
 }
 
 // SendRawFrame send Adu frame
 func (sf *RTUClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte, err error) {
+	return sf.sendRawFrame(context.Background(), make([]byte, rtuAduMaxSize), aduRequest)
+}
+
+// sendRawFrame send Adu frame, the response is read into data,
+// len(data) must be rtuAduMaxSize. the response is read until the deadline of ctx.
+func (sf *RTUClientProvider) sendRawFrame(ctx context.Context, data []byte, aduRequest []byte) (aduResponse []byte, err error) {
 	sf.mu.Lock()
 	defer sf.mu.Unlock()
+	defer func() {
+		if err != nil {
+			sf.checkGone(err)
+		}
+	}()
 
 	// check  port is connected
 	if !sf.isConnected() {
 		return nil, ErrClosedConnection
 	}
 
+	// enforce the minimum inter-frame idle before transmitting
+	_, frameDelay := sf.silentInterval()
+	sf.waitIdle(frameDelay)
+	defer func() { sf.lastFrame = time.Now() }()
+
 	// Send the request
-	sf.Debug("sending [% x]", aduRequest)
+	sf.pace()
+	sf.with("slave", aduRequest[0]).Debug("sending [% x]", aduRequest)
+	sf.tapSend(aduRequest)
 	var tryCnt byte
 	for {
-		_, err = sf.port.Write(aduRequest)
+		_, err = sf.write(aduRequest)
 		if err == nil { // success
 			break
 		}
 		if sf.autoReconnect == 0 {
 			return
 		}
+		sf.events.emitDisconnected(err)
 		for {
+			sf.events.emitReconnecting(int(tryCnt) + 1)
 			err = sf.connect()
 			if err == nil {
 				break
@@ -153,18 +203,23 @@ func (sf *RTUClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte
 			}
 		}
 	}
+	if aduRequest[0] == AddressBroadCast { // broadcast no response
+		time.Sleep(sf.calculateDelay(len(aduRequest)))
+		return nil, nil
+	}
 	function := aduRequest[1]
-	functionFail := aduRequest[1] & 0x80
+	functionFail := aduRequest[1] | 0x80
 	bytesToRead := calculateResponseLength(aduRequest)
 	time.Sleep(sf.calculateDelay(len(aduRequest) + bytesToRead))
 
 	var n int
 	var n1 int
-	var data [rtuAduMaxSize]byte
+	port := serialReader(ctx, sf.port)
 	//We first read the minimum length and then read either the full package
 	//or the error package, depending on the error status (byte 2 of the response)
-	n, err = io.ReadAtLeast(sf.port, data[:], rtuAduMinSize)
+	n, err = io.ReadAtLeast(port, data, rtuAduMinSize)
 	if err != nil {
+		sf.counters.framingError(n)
 		return
 	}
 
@@ -175,41 +230,88 @@ func (sf *RTUClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte
 		if n < bytesToRead {
 			if bytesToRead > rtuAduMinSize && bytesToRead <= rtuAduMaxSize {
 				if bytesToRead > n {
-					n1, err = io.ReadFull(sf.port, data[n:bytesToRead])
+					n1, err = io.ReadFull(port, data[n:bytesToRead])
 					n += n1
 				}
 			}
 		}
+		// the response length of the function code is undetermined,
+		// such as FIFO queue or user defined one, read until the crc is ok
+		for !rtuResponseDetermined(function) && err == nil && n < rtuAduMaxSize &&
+			crc16(data[:n-2]) != binary.LittleEndian.Uint16(data[n-2:n]) {
+			n1, err = port.Read(data[n:])
+			n += n1
+		}
 	case data[1] == functionFail:
 		//for error we need to read 5 bytes
 		if n < rtuExceptionSize {
-			n1, err = io.ReadFull(sf.port, data[n:rtuExceptionSize])
+			n1, err = io.ReadFull(port, data[n:rtuExceptionSize])
 		}
 		n += n1
 	default:
 		err = fmt.Errorf("modbus: unknown function code % x", data[1])
 	}
+	// noise ahead of or inside the frame, search the frame after it,
+	// a valid frame followed by garbage is left to QuirkTrailingGarbage
+	if rtuResponseDetermined(function) && (err != nil && n >= rtuAduMinSize ||
+		err == nil && crc16(data[:n-2]) != binary.LittleEndian.Uint16(data[n-2:n]) &&
+			!(data[1] == function && n > bytesToRead &&
+				crc16(data[:bytesToRead-2]) == binary.LittleEndian.Uint16(data[bytesToRead-2:]))) {
+		if start, end, e := sf.resync(port, data, n, aduRequest); e == nil {
+			sf.counters.resynced(start)
+			n, err = copy(data, data[start:end]), nil
+		}
+	}
 	if err != nil {
+		sf.counters.framingError(n)
 		return
 	}
+	// discard the garbage received after the frame
+	if sf.Quirks()&QuirkTrailingGarbage != 0 && data[1] == function && rtuResponseDetermined(function) &&
+		n > bytesToRead && crc16(data[:bytesToRead-2]) == binary.LittleEndian.Uint16(data[bytesToRead-2:]) {
+		sf.counters.discard(n - bytesToRead)
+		n = bytesToRead
+	}
 	aduResponse = data[:n]
-	sf.Debug("received [% x]", aduResponse)
+	sf.with("slave", aduResponse[0]).Debug("received [% x]", aduResponse)
+	sf.tapReceived(aduResponse)
 	return
 }
 
-// calculateDelay roughly calculates time needed for the next frame.
-// See MODBUS over Serial Line - Specification and Implementation Guide (page 13).
-func (sf *RTUClientProvider) calculateDelay(chars int) time.Duration {
-	var characterDelay, frameDelay int // us
+// SetSilentInterval set the inter-character(t1.5) and inter-frame(t3.5) silent interval,
+// 0 means calculated by the baud rate as the spec, which is fixed 750us and 1750us above 19200.
+// the provider keep the bus idle for at least t3.5 before transmitting,
+// some slow slaves need a longer one to reject frames sent back-to-back.
+func (sf *RTUClientProvider) SetSilentInterval(t15, t35 time.Duration) {
+	sf.mu.Lock()
+	sf.charDelay, sf.frameDelay = t15, t35
+	sf.mu.Unlock()
+}
 
+// silentInterval return the inter-character(t1.5) and inter-frame(t3.5) silent interval.
+// See MODBUS over Serial Line - Specification and Implementation Guide (page 13).
+// Caller must hold the mutex before calling this method.
+func (sf *RTUClientProvider) silentInterval() (t15, t35 time.Duration) {
 	if sf.BaudRate <= 0 || sf.BaudRate > 19200 {
-		characterDelay = 750
-		frameDelay = 1750
+		t15, t35 = 750*time.Microsecond, 1750*time.Microsecond
 	} else {
-		characterDelay = 15000000 / sf.BaudRate
-		frameDelay = 35000000 / sf.BaudRate
+		t15 = time.Duration(15000000/sf.BaudRate) * time.Microsecond
+		t35 = time.Duration(35000000/sf.BaudRate) * time.Microsecond
+	}
+	if sf.charDelay > 0 {
+		t15 = sf.charDelay
 	}
-	return time.Duration(characterDelay*chars+frameDelay) * time.Microsecond
+	if sf.frameDelay > 0 {
+		t35 = sf.frameDelay
+	}
+	return
+}
+
+// calculateDelay roughly calculates time needed for the next frame.
+// See MODBUS over Serial Line - Specification and Implementation Guide (page 13).
+func (sf *RTUClientProvider) calculateDelay(chars int) time.Duration {
+	characterDelay, frameDelay := sf.silentInterval()
+	return characterDelay*time.Duration(chars) + frameDelay
 }
 
 func calculateResponseLength(adu []byte) int {
@@ -234,13 +336,33 @@ func calculateResponseLength(adu []byte) int {
 		length += 4
 	case FuncCodeMaskWriteRegister:
 		length += 6
-	case FuncCodeReadFIFOQueue:
+	case FuncCodeWriteFileRecord:
+		length = len(adu)
+	case FuncCodeReadFIFOQueue, FuncCodeReadFileRecord:
 		// undetermined
 	default:
 	}
 	return length
 }
 
+// rtuResponseDetermined whether the response length of the function code can be calculated
+func rtuResponseDetermined(funcCode byte) bool {
+	switch funcCode {
+	case FuncCodeReadDiscreteInputs,
+		FuncCodeReadCoils,
+		FuncCodeReadInputRegisters,
+		FuncCodeReadHoldingRegisters,
+		FuncCodeReadWriteMultipleRegisters,
+		FuncCodeWriteSingleCoil,
+		FuncCodeWriteMultipleCoils,
+		FuncCodeWriteSingleRegister,
+		FuncCodeWriteMultipleRegisters,
+		FuncCodeMaskWriteRegister:
+		return true
+	}
+	return false
+}
+
 // helper
 
 // verify confirms vaild data(including slaveID,funcCode,response data)
@@ -248,7 +370,7 @@ func verify(reqSlaveID, rspSlaveID uint8, reqPDU, rspPDU ProtocolDataUnit) error
 	switch {
 	case reqSlaveID != rspSlaveID:
 		// Check slaveid same
-		return fmt.Errorf("modbus: response slave id '%v' does not match request '%v'", rspSlaveID, reqSlaveID)
+		return &HeaderError{"slave id", uint16(rspSlaveID), uint16(reqSlaveID)}
 	case rspPDU.FuncCode != reqPDU.FuncCode:
 		// Check correct function code returned (exception)
 		return responseError(rspPDU)
@@ -3,6 +3,9 @@ package modbus
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/goburrow/serial"
 )
 
 func TestRTUClientProvider_encodeRTUFrame(t *testing.T) {
@@ -186,3 +189,156 @@ func BenchmarkRTUClientProvider_decodeRTUFrame(b *testing.B) {
 		}
 	}
 }
+
+// chunkPort a serial port which reply the chunks one by one
+type chunkPort struct {
+	written []byte
+	chunks  [][]byte
+}
+
+func (sf *chunkPort) Write(b []byte) (int, error) {
+	sf.written = append(sf.written, b...)
+	return len(b), nil
+}
+
+func (sf *chunkPort) Read(b []byte) (int, error) {
+	if len(sf.chunks) == 0 {
+		return 0, serial.ErrTimeout
+	}
+	n := copy(b, sf.chunks[0])
+	sf.chunks = sf.chunks[1:]
+	return n, nil
+}
+
+func (sf *chunkPort) Close() error { return nil }
+
+func TestRTUClientProvider_SendPdu_userDefined(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, 0x41, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05})
+	port := &chunkPort{chunks: [][]byte{rsp[:4], rsp[4:7], rsp[7:]}}
+
+	p := NewRTUClientProvider()
+	p.port = port
+	got, err := p.SendPdu(0x01, []byte{0x41, 0xaa})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := rsp[1 : len(rsp)-2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("SendPdu() = % x, want % x", got, want)
+	}
+	if want := rtuFrame([]byte{0x01, 0x41, 0xaa}); !reflect.DeepEqual(port.written, want) {
+		t.Errorf("written = % x, want % x", port.written, want)
+	}
+
+	port.chunks = [][]byte{rtuFrame([]byte{0x01, 0xc1, ExceptionCodeIllegalFunction})}
+	_, err = p.SendPdu(0x01, []byte{0x41, 0xaa})
+	if e, ok := AsExceptionError(err); !ok || e.FuncCode != 0x41 || e.ExceptionCode != ExceptionCodeIllegalFunction {
+		t.Errorf("SendPdu() error = %v, want illegal function exception", err)
+	}
+}
+
+func TestRTUClientProvider_OnRaw(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})
+	port := &chunkPort{chunks: [][]byte{rsp}}
+
+	var sent, received []byte
+	p := NewRTUClientProvider()
+	p.port = port
+	p.OnSendRaw(func(adu []byte) { sent = append([]byte{}, adu...) })
+	p.OnRecvRaw(func(adu []byte) { received = append([]byte{}, adu...) })
+	if _, err := p.SendPdu(0x01, []byte{0x06, 0x00, 0x01, 0x00, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sent, port.written) {
+		t.Errorf("OnSendRaw() got % x, want % x", sent, port.written)
+	}
+	if !reflect.DeepEqual(received, rsp) {
+		t.Errorf("OnRecvRaw() got % x, want % x", received, rsp)
+	}
+}
+
+func TestRTUClientProvider_SetDriverEnable(t *testing.T) {
+	port := &chunkPort{chunks: [][]byte{rtuFrame([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})}}
+
+	var states []bool
+	p := NewRTUClientProvider()
+	p.port = port
+	p.SetDriverEnable(func(transmit bool) error {
+		states = append(states, transmit)
+		return nil
+	})
+	if _, err := p.SendPdu(0x01, []byte{0x06, 0x00, 0x01, 0x00, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, false}; !reflect.DeepEqual(states, want) {
+		t.Errorf("driver enable states = %v, want %v", states, want)
+	}
+}
+
+func TestRTUClientProvider_silentInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		baudRate int
+		t15, t35 time.Duration
+		want15   time.Duration
+		want35   time.Duration
+	}{
+		{"9600", 9600, 0, 0, 1562 * time.Microsecond, 3645 * time.Microsecond},
+		{"above 19200", 115200, 0, 0, 750 * time.Microsecond, 1750 * time.Microsecond},
+		{"configured", 9600, time.Millisecond, 10 * time.Millisecond, time.Millisecond, 10 * time.Millisecond},
+		{"configured t3.5 only", 115200, 0, 5 * time.Millisecond, 750 * time.Microsecond, 5 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRTUClientProvider()
+			p.BaudRate = tt.baudRate
+			p.SetSilentInterval(tt.t15, tt.t35)
+			got15, got35 := p.silentInterval()
+			if got15 != tt.want15 || got35 != tt.want35 {
+				t.Errorf("silentInterval() = %v, %v, want %v, %v", got15, got35, tt.want15, tt.want35)
+			}
+		})
+	}
+}
+
+func TestRTUClientProvider_interFrameIdle(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})
+	port := &chunkPort{chunks: [][]byte{rsp, rsp}}
+
+	p := NewRTUClientProvider()
+	p.port = port
+	p.SetSilentInterval(0, 30*time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := p.SendPdu(0x01, []byte{0x06, 0x00, 0x01, 0x00, 0x03}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// each request wait t3.5 before reading, and the second one wait t3.5 idle before sending
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("elapsed %v, want at least 90ms", elapsed)
+	}
+}
+
+func TestRTUClientProvider_SetTurnaroundDelay(t *testing.T) {
+	port := &chunkPort{chunks: [][]byte{rtuFrame([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})}}
+
+	p := NewRTUClientProvider()
+	p.port = port
+	p.SetTurnaroundDelay(50 * time.Millisecond)
+
+	// broadcast no response
+	got, err := p.SendPdu(AddressBroadCast, []byte{0x06, 0x00, 0x01, 0x00, 0x03})
+	if err != nil || got != nil {
+		t.Fatalf("SendPdu() broadcast = % x, %v, want no response", got, err)
+	}
+	if len(port.chunks) != 1 {
+		t.Fatalf("broadcast should not read the response")
+	}
+	start := time.Now()
+	if _, err = p.SendPdu(0x01, []byte{0x06, 0x00, 0x01, 0x00, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("elapsed %v, want turnaround delay 50ms after broadcast", elapsed)
+	}
+}
@@ -0,0 +1,63 @@
+package modbus
+
+import (
+	"errors"
+)
+
+// ErrNotDetected 所有候选的串口参数都没有收到有效的响应
+var ErrNotDetected = errors.New("modbus: serial settings not detected")
+
+// DefaultDetectCandidates 默认的候选串口参数, 常用的波特率依次尝试 偶校验, 无校验, 奇校验
+func DefaultDetectCandidates() []SerialConfig {
+	bauds := []int{9600, 19200, 38400, 57600, 115200, 4800, 2400, 1200}
+	list := make([]SerialConfig, 0, len(bauds)*3)
+	for _, baud := range bauds {
+		for _, parity := range []string{"E", "N", "O"} {
+			list = append(list, SerialConfig{BaudRate: baud, DataBits: 8, StopBits: 1, Parity: parity})
+		}
+	}
+	return list
+}
+
+// Detect 自动检测从机的串口参数, 依次切换到候选的参数, 读从机地址0的1个保持寄存器,
+// 直到收到crc正确的响应(包括异常响应), 返回检测到的参数, 端口保持在该参数.
+// candidates 为空时使用 DefaultDetectCandidates, 串口须已打开, 每次尝试的等待时间为串口超时,
+// 都没有响应时恢复原来的参数, 返回 ErrNotDetected
+func (sf *RTUClientProvider) Detect(slaveID byte, candidates []SerialConfig) (SerialConfig, error) {
+	if !sf.IsConnected() {
+		return SerialConfig{}, ErrClosedConnection
+	}
+	if len(candidates) == 0 {
+		candidates = DefaultDetectCandidates()
+	}
+	sf.mu.Lock()
+	old := SerialConfig{sf.BaudRate, sf.DataBits, sf.StopBits, sf.Parity}
+	sf.mu.Unlock()
+
+	cfg, err := detect(candidates, sf.Reconfigure, func() error {
+		_, err := sf.Send(slaveID, ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0, 0, 0, 1}})
+		return err
+	})
+	if err == ErrNotDetected {
+		if e := sf.Reconfigure(old); e != nil {
+			return SerialConfig{}, e
+		}
+	}
+	return cfg, err
+}
+
+// detect 依次应用候选参数并探测, 探测成功或收到异常响应为检测到,
+// 应用参数失败时中止
+func detect(candidates []SerialConfig, apply func(SerialConfig) error, probe func() error) (SerialConfig, error) {
+	for _, cfg := range candidates {
+		if err := apply(cfg); err != nil {
+			return SerialConfig{}, err
+		}
+		if err := probe(); err == nil {
+			return cfg, nil
+		} else if _, ok := AsExceptionError(err); ok {
+			return cfg, nil
+		}
+	}
+	return SerialConfig{}, ErrNotDetected
+}
@@ -0,0 +1,54 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_detect(t *testing.T) {
+	errApply := errors.New("apply")
+	tests := []struct {
+		name     string
+		match    SerialConfig
+		probeErr error
+		applyErr error
+		want     SerialConfig
+		wantErr  error
+	}{
+		{"response", SerialConfig{19200, 8, 1, "N"}, nil, nil, SerialConfig{19200, 8, 1, "N"}, nil},
+		{"exception response", SerialConfig{38400, 8, 1, "O"},
+			&ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}, nil, SerialConfig{38400, 8, 1, "O"}, nil},
+		{"not detected", SerialConfig{300, 8, 1, "N"}, nil, nil, SerialConfig{}, ErrNotDetected},
+		{"apply failed", SerialConfig{19200, 8, 1, "N"}, nil, errApply, SerialConfig{}, errApply},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var current SerialConfig
+			var tried int
+			got, err := detect(DefaultDetectCandidates(), func(cfg SerialConfig) error {
+				current = cfg
+				return tt.applyErr
+			}, func() error {
+				tried++
+				if current == tt.match {
+					return tt.probeErr
+				}
+				return &ChecksumError{"crc", 0, 1}
+			})
+			if err != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detect() = %+v, %v, want %+v, %v", got, err, tt.want, tt.wantErr)
+			}
+			if tt.wantErr == ErrNotDetected && tried != len(DefaultDetectCandidates()) {
+				t.Errorf("detect() tried %v, want all candidates", tried)
+			}
+		})
+	}
+}
+
+func TestRTUClientProvider_DetectClosed(t *testing.T) {
+	p := NewRTUClientProvider()
+	if _, err := p.Detect(1, nil); err != ErrClosedConnection {
+		t.Errorf("Detect() error = %v, want %v", err, ErrClosedConnection)
+	}
+}
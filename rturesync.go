@@ -0,0 +1,97 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// errResyncFailed the response frame is not found after the noise
+var errResyncFailed = errors.New("modbus: rtu resync failed")
+
+// SetResyncTimeout set the bound of the resynchronization after the noise,
+// when the response is preceded or corrupted by stray bytes, the receiver slides the window
+// byte by byte to search a frame with the slave id, function code and crc expected,
+// instead of failing the whole transaction. 0 use the serial timeout, < 0 disable it.
+func (sf *RTUClientProvider) SetResyncTimeout(d time.Duration) {
+	sf.mu.Lock()
+	sf.resyncTimeout = d
+	sf.mu.Unlock()
+}
+
+// resync search the response frame of the request after the noise, data[:n] is received,
+// more bytes are read as needed, the window slides one byte on every mismatch of slave id,
+// function code or crc. the bytes before a silence longer than t3.5 can not be part of
+// the frame, so the window jumps over them. it gives up when the buffer is full, the read
+// failed or the search lasts longer than the resync timeout.
+// port is the reader of the transaction, so the context of it bound the search too.
+// Caller must hold the mutex before calling this method.
+func (sf *RTUClientProvider) resync(port io.Reader, data []byte, n int, aduRequest []byte) (start, end int, err error) {
+	bound := sf.resyncTimeout
+	if bound == 0 {
+		bound = sf.Timeout
+	}
+	if bound < 0 {
+		return 0, 0, errResyncFailed
+	}
+	slaveID, function := aduRequest[0], aduRequest[1]
+	length := calculateResponseLength(aduRequest)
+	t15, t35 := sf.silentInterval()
+	deadline := time.Now().Add(bound)
+	last, boundary := time.Now(), 0
+
+	// fill read until want bytes received
+	fill := func(want int) error {
+		for n < want {
+			if time.Now().After(deadline) {
+				return errResyncFailed
+			}
+			m, err := port.Read(data[n:])
+			now := time.Now()
+			if m > 0 && now.Sub(last)-time.Duration(m)*t15 > t35 { // a new frame after the silence
+				boundary = n
+			}
+			last, n = now, n+m
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for start = 1; ; start++ {
+		if start < boundary {
+			start = boundary
+		}
+		if start+rtuAduMinSize > len(data) {
+			return 0, 0, errResyncFailed
+		}
+		if err = fill(start + 2); err != nil {
+			return 0, 0, err
+		}
+		size := length
+		switch {
+		case data[start] != slaveID:
+			continue
+		case data[start+1] == function:
+		case data[start+1] == function|0x80:
+			size = rtuExceptionSize
+		default:
+			continue
+		}
+		end = start + size
+		if end > len(data) {
+			return 0, 0, errResyncFailed
+		}
+		if err = fill(end); err != nil {
+			return 0, 0, err
+		}
+		if start < boundary { // the silence is inside the candidate
+			continue
+		}
+		if crc16(data[start:end-2]) == binary.LittleEndian.Uint16(data[end-2:end]) {
+			return start, end, nil
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package modbus
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/goburrow/serial"
+)
+
+func TestRTUClientProvider_resync(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, 0x03, 0x02, 0x12, 0x34})
+	exception := rtuFrame([]byte{0x01, 0x83, ExceptionCodeIllegalDataAddress})
+	bad := append([]byte{}, rsp...)
+	bad[3] ^= 0xff
+	join := func(b ...[]byte) []byte {
+		var r []byte
+		for _, v := range b {
+			r = append(r, v...)
+		}
+		return r
+	}
+	tests := []struct {
+		name     string
+		chunks   [][]byte
+		resync   time.Duration
+		want     []byte
+		wantErr  bool
+		wantStat RTUStats
+	}{
+		{"clean", [][]byte{rsp}, 0, rsp, false, RTUStats{}},
+		{"noise ahead", [][]byte{join([]byte{0x00, 0xff}, rsp)}, 0, rsp, false, RTUStats{Resyncs: 1, DiscardedBytes: 2}},
+		{"noise split", [][]byte{{0x55, 0x01}, rsp}, 0, rsp, false, RTUStats{Resyncs: 1, DiscardedBytes: 2}},
+		{"noise ahead of exception", [][]byte{join([]byte{0x7f}, exception)}, 0, nil, true, RTUStats{Resyncs: 1, DiscardedBytes: 1}},
+		{"corrupted then repeated", [][]byte{bad, rsp}, 0, rsp, false, RTUStats{Resyncs: 1, DiscardedBytes: 7}},
+		{"corrupted", [][]byte{bad}, 0, nil, true, RTUStats{CRCErrors: 1, DiscardedBytes: 7}},
+		{"disabled", [][]byte{join([]byte{0x00}, rsp)}, -1, nil, true, RTUStats{FramingErrors: 1, DiscardedBytes: 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRTUClientProvider()
+			p.port = &chunkPort{chunks: tt.chunks}
+			p.SetResyncTimeout(tt.resync)
+			got, err := p.SendPdu(0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendPdu() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want[1:len(tt.want)-2]) {
+				t.Errorf("SendPdu() = % x, want % x", got, tt.want[1:len(tt.want)-2])
+			}
+			if s := p.RTUStats(); s != tt.wantStat {
+				t.Errorf("RTUStats() = %+v, want %+v", s, tt.wantStat)
+			}
+		})
+	}
+}
+
+// blockPort a serial port which reply the reads in order, nil is a serial timeout,
+// when the reads are exhausted it blocks until the timeout as a real port does
+type blockPort struct {
+	chunkPort
+	timeout time.Duration
+}
+
+func (sf *blockPort) Read(b []byte) (int, error) {
+	if len(sf.chunks) == 0 {
+		time.Sleep(sf.timeout)
+		return 0, serial.ErrTimeout
+	}
+	if sf.chunks[0] == nil {
+		sf.chunks = sf.chunks[1:]
+		return 0, serial.ErrTimeout
+	}
+	return sf.chunkPort.Read(b)
+}
+
+func TestRTUClientProvider_resyncBlocking(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, 0x03, 0x02, 0x12, 0x34})
+	request := ProtocolDataUnit{0x03, []byte{0x00, 0x00, 0x00, 0x01}}
+	tests := []struct {
+		name     string
+		chunks   [][]byte
+		quirks   Quirks
+		wantStat RTUStats
+	}{
+		// the valid frame is not searched again, so it does not wait the timeout
+		{"trailing garbage", [][]byte{append(append([]byte{}, rsp...), 0x55, 0xaa)}, QuirkTrailingGarbage,
+			RTUStats{DiscardedBytes: 2}},
+		// the search retry the serial timeout until the deadline of the context
+		{"frame after serial timeout", [][]byte{append([]byte{0x55, 0x01}, rsp[:3]...), nil, rsp[3:]}, 0,
+			RTUStats{Resyncs: 1, DiscardedBytes: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRTUClientProvider()
+			p.port = &blockPort{chunkPort{chunks: tt.chunks}, time.Second}
+			p.SetQuirks(tt.quirks)
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			got, err := p.SendContext(ctx, 0x01, request)
+			if err != nil {
+				t.Fatalf("SendContext() error = %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+				t.Errorf("SendContext() took %v, want no wait for the timeout", elapsed)
+			}
+			if want := rsp[2 : len(rsp)-2]; !reflect.DeepEqual(got.Data, want) {
+				t.Errorf("SendContext() = % x, want % x", got.Data, want)
+			}
+			if s := p.RTUStats(); s != tt.wantStat {
+				t.Errorf("RTUStats() = %+v, want %+v", s, tt.wantStat)
+			}
+		})
+	}
+}
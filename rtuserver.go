@@ -0,0 +1,252 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/goburrow/serial"
+)
+
+// RTUServerDefaultTimeout RTU Server default read timeout,
+// when the bus idle for it, the incomplete frame will be dropped.
+const RTUServerDefaultTimeout = 50 * time.Millisecond
+
+// RTUServer modbus rtu server(slave)
+type RTUServer struct {
+	// Serial port configuration, Timeout is the read timeout
+	serial.Config
+	mu     sync.Mutex
+	port   io.ReadWriteCloser
+	opener SerialOpener // serial port backend, nil use DefaultSerialOpener
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	*serverCommon
+	logger
+}
+
+// NewRTUServer the modbus rtu server, it will use default /dev/ttyS0 19200 8 1 N
+func NewRTUServer() *RTUServer {
+	sf := &RTUServer{
+		serverCommon: newServerCommon(),
+		logger:       newLogger("modbusRTUServer =>"),
+	}
+	sf.Address = "/dev/ttyS0"
+	sf.BaudRate = 19200
+	sf.DataBits = 8
+	sf.StopBits = 1
+	sf.Parity = "N"
+	sf.Timeout = RTUServerDefaultTimeout
+	return sf
+}
+
+// Close close the server until the server stopped
+func (sf *RTUServer) Close() error {
+	var err error
+
+	sf.mu.Lock()
+	if sf.port != nil {
+		sf.cancel()
+		err = sf.port.Close()
+		sf.port = nil
+	}
+	sf.mu.Unlock()
+	sf.wg.Wait()
+	return err
+}
+
+// ListenAndServe open the serial port and serve
+func (sf *RTUServer) ListenAndServe() error {
+	sf.mu.Lock()
+	opener := sf.opener
+	sf.mu.Unlock()
+	if opener == nil {
+		opener = DefaultSerialOpener
+	}
+	port, err := opener.Open(&sf.Config)
+	if err != nil {
+		return err
+	}
+	return sf.Serve(port)
+}
+
+// Serve serve on the port until Close, it close the port when return
+func (sf *RTUServer) Serve(port io.ReadWriteCloser) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	sf.mu.Lock()
+	if sf.port != nil {
+		sf.mu.Unlock()
+		cancel()
+		port.Close()
+		return errors.New("modbus: server already serving")
+	}
+	sf.port = port
+	sf.cancel = cancel
+	sf.wg.Add(1)
+	sf.mu.Unlock()
+
+	sf.Debug("server started, serve on %s", sf.Address)
+	defer func() {
+		sf.wg.Done()
+		sf.Close()
+		sf.Debug("server stopped")
+	}()
+
+	var buf [rtuAduMaxSize]byte
+	pending := make([]byte, 0, 2*rtuAduMaxSize)
+	for {
+		n, err := port.Read(buf[:])
+		if n > 0 {
+			pending = sf.handlePending(port, append(pending, buf[:n]...))
+		}
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+			}
+			if !isSerialTimeout(err) {
+				return err
+			}
+			// bus idle, drop the incomplete frame
+			if len(pending) > 0 {
+				sf.Debug("drop incomplete frame [% x]", pending)
+				pending = pending[:0]
+			}
+		}
+	}
+}
+
+// handlePending 处理缓冲区中完整的帧, 返回剩余未处理的数据
+func (sf *RTUServer) handlePending(w io.Writer, pending []byte) []byte {
+	for len(pending) >= rtuAduMinSize {
+		length := rtuRequestLength(pending)
+		if length < 0 { // unknown function code, find the frame by crc
+			if length = rtuCrcLength(pending); length == 0 {
+				if _, ok := sf.function[pending[1]]; ok {
+					break // wait more data
+				}
+				length = 1 // not a request, skip a byte
+			}
+		}
+		if length == 0 || len(pending) < length {
+			break
+		}
+		adu := pending[:length]
+		if length < rtuAduMinSize ||
+			crc16(adu[:length-2]) != binary.LittleEndian.Uint16(adu[length-2:]) {
+			// lost synchronization, skip a byte and try again
+			length = 1
+		} else if err := sf.frameHandler(w, adu); err != nil {
+			sf.Error("write response failed, %v", err)
+		}
+		pending = pending[:copy(pending, pending[length:])]
+	}
+	if len(pending) > rtuAduMaxSize {
+		pending = pending[:0]
+	}
+	return pending
+}
+
+// rtuRequestLength calculate the length of the request adu by function code,
+// return 0 if it need more data, -1 if the function code is unknown.
+func rtuRequestLength(adu []byte) int {
+	switch adu[1] {
+	case FuncCodeReadDiscreteInputs,
+		FuncCodeReadCoils,
+		FuncCodeReadInputRegisters,
+		FuncCodeReadHoldingRegisters,
+		FuncCodeWriteSingleCoil,
+		FuncCodeWriteSingleRegister:
+		return 8
+	case FuncCodeMaskWriteRegister:
+		return 10
+	case FuncCodeWriteMultipleCoils,
+		FuncCodeWriteMultipleRegisters:
+		if len(adu) < 7 {
+			return 0
+		}
+		return 9 + int(adu[6])
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(adu) < 11 {
+			return 0
+		}
+		return 13 + int(adu[10])
+	case FuncCodeReadFIFOQueue:
+		return 6
+	}
+	return -1
+}
+
+// rtuCrcLength return the length of the shortest frame which crc is ok, 0 if not found
+func rtuCrcLength(adu []byte) int {
+	for length := rtuAduMinSize; length <= len(adu) && length <= rtuAduMaxSize; length++ {
+		if crc16(adu[:length-2]) == binary.LittleEndian.Uint16(adu[length-2:]) {
+			return length
+		}
+	}
+	return 0
+}
+
+// modbus 包处理
+func (sf *RTUServer) frameHandler(w io.Writer, requestAdu []byte) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			sf.Error("painc happen,%v", e)
+		}
+	}()
+
+	slaveID, funcCode := requestAdu[0], requestAdu[1]
+	log := sf.with("slave", slaveID)
+	log.Debug("RX Raw[% x]", requestAdu)
+
+	start := time.Now()
+	fault := sf.fault(slaveID, funcCode)
+	rspPduData, err := sf.serveWithFault(fault, &ServerRequest{
+		SlaveID:  slaveID,
+		FuncCode: funcCode,
+		Data:     requestAdu[2 : len(requestAdu)-2],
+	})
+	if err == ErrSlaveNotExist { // slave id not exit, ignore it
+		return nil
+	}
+	stat := RequestStat{
+		SlaveID:     slaveID,
+		FuncCode:    funcCode,
+		RequestSize: len(requestAdu) - 3,
+	}
+	if err != nil {
+		funcCode |= 0x80
+		rspPduData = []byte{exceptionCode(err)}
+		stat.ExceptionCode = rspPduData[0]
+	}
+	stat.ResponseSize = len(rspPduData) + 1
+	stat.Latency = time.Since(start)
+	sf.serverMetrics().RequestHandled(stat)
+	if slaveID == AddressBroadCast { // broadcast no response
+		return nil
+	}
+
+	responseAdu := make([]byte, 0, len(rspPduData)+4)
+	responseAdu = append(responseAdu, slaveID, funcCode)
+	responseAdu = append(responseAdu, rspPduData...)
+	checksum := crc16(responseAdu)
+	responseAdu = append(responseAdu, byte(checksum), byte(checksum>>8))
+	if d := sf.latency(slaveID); d > 0 {
+		time.Sleep(d)
+	}
+	if fault != nil {
+		time.Sleep(fault.Delay)
+		if fault.Fault == FaultDrop {
+			log.Debug("fault injected, response dropped")
+			return nil
+		}
+		responseAdu = fault.mangle(responseAdu)
+	}
+	log.Debug("TX Raw[% x]", responseAdu)
+	_, err = w.Write(responseAdu)
+	return err
+}
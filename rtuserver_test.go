@@ -0,0 +1,94 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// pipePort a serial port on pipes
+type pipePort struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (sf pipePort) Close() error {
+	sf.PipeWriter.Close()
+	return sf.PipeReader.Close()
+}
+
+func TestRTUServer(t *testing.T) {
+	reqReader, reqWriter := io.Pipe()
+	rspReader, rspWriter := io.Pipe()
+
+	srv := NewRTUServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	node, _ := srv.GetNode(1)
+	node.WriteHoldings(0, []uint16{0x1234, 0x5678})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(pipePort{reqReader, rspWriter})
+	}()
+
+	tests := []struct {
+		name    string
+		request []byte
+		want    []byte
+	}{
+		{
+			"read holding registers",
+			[]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02},
+			[]byte{0x01, 0x03, 0x04, 0x12, 0x34, 0x56, 0x78},
+		},
+		{
+			"illegal data address",
+			[]byte{0x01, 0x03, 0x00, 0x20, 0x00, 0x02},
+			[]byte{0x01, 0x83, 0x02},
+		},
+		{
+			"write multiple registers",
+			[]byte{0x01, 0x10, 0x00, 0x02, 0x00, 0x01, 0x02, 0xab, 0xcd},
+			[]byte{0x01, 0x10, 0x00, 0x02, 0x00, 0x01},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// leading garbage and a request to a not exist slave must be skipped
+			var frame []byte
+			frame = append(frame, 0xff)
+			frame = append(frame, rtuFrame([]byte{0x09, 0x03, 0x00, 0x00, 0x00, 0x01})...)
+			frame = append(frame, rtuFrame(tt.request)...)
+			go reqWriter.Write(frame)
+
+			want := rtuFrame(tt.want)
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(rspReader, got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("response = % x, want % x", got, want)
+			}
+		})
+	}
+	if v, _ := node.ReadHoldings(2, 1); v[0] != 0xabcd {
+		t.Errorf("holding[2] = %#x, want 0xabcd", v[0])
+	}
+
+	srv.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve() not return after Close()")
+	}
+}
+
+// rtuFrame append crc to the slaveID and pdu
+func rtuFrame(b []byte) []byte {
+	checksum := crc16(b)
+	return append(append([]byte{}, b...), byte(checksum), byte(checksum>>8))
+}
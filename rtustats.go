@@ -0,0 +1,76 @@
+package modbus
+
+import (
+	"sync"
+)
+
+// RTUStats RTU 串口的帧错误统计, 是接线与终端电阻问题的主要指标
+type RTUStats struct {
+	CRCErrors      uint64 // crc 校验失败的响应帧数
+	FramingErrors  uint64 // 不完整或错位的响应帧数, 需重新同步
+	Resyncs        uint64 // 跳过噪声后重新同步到的响应帧数
+	DiscardedBytes uint64 // 丢弃的字节数, 含错误帧与帧后的垃圾字节
+}
+
+// rtuCounters the frame error counters of the port
+type rtuCounters struct {
+	mu sync.Mutex
+	s  RTUStats
+}
+
+// crcError a frame of n bytes failed the crc check
+func (sf *rtuCounters) crcError(n int) {
+	sf.mu.Lock()
+	sf.s.CRCErrors++
+	sf.s.DiscardedBytes += uint64(n)
+	sf.mu.Unlock()
+}
+
+// framingError an incomplete or misaligned frame of n bytes, nothing received is not counted
+func (sf *rtuCounters) framingError(n int) {
+	if n <= 0 {
+		return
+	}
+	sf.mu.Lock()
+	sf.s.FramingErrors++
+	sf.s.DiscardedBytes += uint64(n)
+	sf.mu.Unlock()
+}
+
+// discard n garbage bytes
+func (sf *rtuCounters) discard(n int) {
+	sf.mu.Lock()
+	sf.s.DiscardedBytes += uint64(n)
+	sf.mu.Unlock()
+}
+
+// RTUStats return the frame error counters of the port since created or last reset
+func (sf *RTUClientProvider) RTUStats() RTUStats {
+	sf.counters.mu.Lock()
+	defer sf.counters.mu.Unlock()
+	return sf.counters.s
+}
+
+// ResetRTUStats clear the frame error counters of the port
+func (sf *RTUClientProvider) ResetRTUStats() {
+	sf.counters.mu.Lock()
+	sf.counters.s = RTUStats{}
+	sf.counters.mu.Unlock()
+}
+
+// decodeFrame decode the response frame and count the crc error
+func (sf *RTUClientProvider) decodeFrame(adu []byte) (uint8, []byte, error) {
+	slaveID, pdu, err := decodeRTUFrame(adu)
+	if _, ok := err.(*ChecksumError); ok {
+		sf.counters.crcError(len(adu))
+	}
+	return slaveID, pdu, err
+}
+
+// resynced the frame is found after n noise bytes
+func (sf *rtuCounters) resynced(n int) {
+	sf.mu.Lock()
+	sf.s.Resyncs++
+	sf.s.DiscardedBytes += uint64(n)
+	sf.mu.Unlock()
+}
@@ -0,0 +1,39 @@
+package modbus
+
+import (
+	"testing"
+)
+
+func TestRTUClientProvider_RTUStats(t *testing.T) {
+	rsp := rtuFrame([]byte{0x01, 0x06, 0x00, 0x01, 0x00, 0x03})
+	bad := append([]byte{}, rsp...)
+	bad[len(bad)-1] ^= 0xff
+	tests := []struct {
+		name   string
+		chunks [][]byte
+		quirks Quirks
+		want   RTUStats
+	}{
+		{"ok", [][]byte{rsp}, 0, RTUStats{}},
+		{"no response", nil, 0, RTUStats{}},
+		{"crc error", [][]byte{bad}, 0, RTUStats{CRCErrors: 1, DiscardedBytes: 8}},
+		{"incomplete", [][]byte{rsp[:5]}, 0, RTUStats{FramingErrors: 1, DiscardedBytes: 5}},
+		{"misaligned", [][]byte{rsp[1:]}, 0, RTUStats{FramingErrors: 1, DiscardedBytes: 7}},
+		{"trailing garbage", [][]byte{append(append([]byte{}, rsp...), 0x55, 0xaa)}, QuirkTrailingGarbage, RTUStats{DiscardedBytes: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRTUClientProvider()
+			p.port = &chunkPort{chunks: tt.chunks}
+			p.SetQuirks(tt.quirks)
+			_, _ = p.SendPdu(0x01, []byte{0x06, 0x00, 0x01, 0x00, 0x03})
+			if got := p.RTUStats(); got != tt.want {
+				t.Errorf("RTUStats() = %+v, want %+v", got, tt.want)
+			}
+			p.ResetRTUStats()
+			if got := p.RTUStats(); got != (RTUStats{}) {
+				t.Errorf("RTUStats() after reset = %+v, want zero", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package modbus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+)
+
+// SecurityDefaultPort Modbus/TCP Security 的默认端口
+const SecurityDefaultPort = "802"
+
+// withSecurityPort address 没有端口时加上 SecurityDefaultPort
+func withSecurityPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, SecurityDefaultPort)
+	}
+	return address
+}
+
+// SecurityServerConfig Modbus/TCP Security 规范的服务端TLS配置:
+// TLS1.2及以上, 必须双向认证, 客户端证书由 roots 验证.
+// 配合 SetAuthorizer 按客户端证书的角色授权.
+func SecurityServerConfig(cert tls.Certificate, roots *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// SecurityClientConfig Modbus/TCP Security 规范的客户端TLS配置:
+// TLS1.2及以上, 禁止重协商, 提供客户端证书(角色在其扩展 OIDModbusRole 中), 服务端证书由 roots 验证,
+// serverName 为空时使用拨号地址的主机名.
+func SecurityClientConfig(cert tls.Certificate, roots *x509.CertPool, serverName string) *tls.Config {
+	return &tls.Config{
+		Certificates:  []tls.Certificate{cert},
+		RootCAs:       roots,
+		ServerName:    serverName,
+		MinVersion:    tls.VersionTLS12,
+		Renegotiation: tls.RenegotiateNever,
+	}
+}
+
+// securityDialer 在下层连接上进行TLS握手
+type securityDialer struct {
+	dialer Dialer
+	config *tls.Config
+}
+
+// SecurityDialer Modbus/TCP Security 的拨号器, 用于 TCPClientProvider.SetDialer,
+// 在 dialer 的连接上进行TLS握手, dialer 为nil时使用 net.Dialer, 地址没有端口时使用 SecurityDefaultPort,
+// config 见 SecurityClientConfig.
+func SecurityDialer(dialer Dialer, config *tls.Config) Dialer {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	return &securityDialer{dialer, config}
+}
+
+// Dial implements Dialer
+func (sf *securityDialer) Dial(network, address string) (net.Conn, error) {
+	return sf.DialContext(context.Background(), network, address)
+}
+
+// DialContext dial and handshake until the context is done
+func (sf *securityDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	address = withSecurityPort(address)
+	conn, err := dialContext(ctx, sf.dialer, network, address, 0)
+	if err != nil {
+		return nil, err
+	}
+	config := sf.config
+	if config == nil {
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return tlsHandshake(ctx, conn, address, config)
+}
+
+// tlsHandshake 在连接 conn 上进行TLS客户端握手, 直到 ctx 的截止时间, 失败时关闭 conn,
+// config 为nil时使用默认配置, 没有 ServerName 时使用 address 的主机名
+func tlsHandshake(ctx context.Context, conn net.Conn, address string, config *tls.Config) (net.Conn, error) {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+	tc := tls.Client(conn, config)
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = tc.SetDeadline(deadline)
+	}
+	if err := tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = tc.SetDeadline(time.Time{})
+	return tc, nil
+}
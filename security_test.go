@@ -0,0 +1,104 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func Test_withSecurityPort(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"127.0.0.1", "127.0.0.1:802"},
+		{"plc.local", "plc.local:802"},
+		{"127.0.0.1:8802", "127.0.0.1:8802"},
+		{"::1", "[::1]:802"},
+		{"[::1]:502", "[::1]:502"},
+	}
+	for _, tt := range tests {
+		if got := withSecurityPort(tt.address); got != tt.want {
+			t.Errorf("withSecurityPort(%v) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	ca := newTestCA(t)
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	var mu sync.Mutex
+	var roles []string
+	srv.SetAuthorizer(RoleHook(nil, func(role string, slaveID, funcCode byte) error {
+		mu.Lock()
+		roles = append(roles, role)
+		mu.Unlock()
+		if role != "operator" && funcCode == FuncCodeWriteSingleRegister {
+			return &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}
+		}
+		return nil
+	}))
+	go srv.Serve(tls.NewListener(listen, SecurityServerConfig(ca.issue(t, 2, "", ""), ca.pool)))
+	defer srv.Close()
+
+	connect := func(config *tls.Config) (Client, error) {
+		p := NewTCPClientProvider(listen.Addr().String())
+		p.SetDialer(SecurityDialer(nil, config))
+		c := NewClient(p)
+		if err := c.Connect(); err != nil {
+			return nil, err
+		}
+		// the server verify the client certificate after the client handshake complete
+		if _, err := c.ReadHoldingRegisters(1, 0, 1); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+
+	operator, err := connect(SecurityClientConfig(ca.issue(t, 3, "operator", ""), ca.pool, ""))
+	if err != nil {
+		t.Fatalf("operator connect error = %v", err)
+	}
+	defer operator.Close()
+	if err = operator.WriteSingleRegister(1, 0, 1); err != nil {
+		t.Errorf("operator WriteSingleRegister() error = %v", err)
+	}
+
+	viewer, err := connect(SecurityClientConfig(ca.issue(t, 4, "viewer", ""), ca.pool, ""))
+	if err != nil {
+		t.Fatalf("viewer connect error = %v", err)
+	}
+	defer viewer.Close()
+	if err = viewer.WriteSingleRegister(1, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("viewer WriteSingleRegister() error = %v, want illegal function", err)
+	}
+	mu.Lock()
+	if want := []string{"operator", "operator", "viewer", "viewer"}; !reflect.DeepEqual(roles, want) {
+		t.Errorf("roles = %v, want %v", roles, want)
+	}
+	mu.Unlock()
+
+	if c, err := connect(&tls.Config{RootCAs: ca.pool}); err == nil {
+		c.Close()
+		t.Errorf("connect without client certificate, want error")
+	}
+	old := SecurityClientConfig(ca.issue(t, 5, "operator", ""), ca.pool, "")
+	old.MaxVersion = tls.VersionTLS11
+	if c, err := connect(old); err == nil {
+		c.Close()
+		t.Errorf("connect with TLS1.1, want error")
+	}
+	if c, err := connect(SecurityClientConfig(ca.issue(t, 6, "operator", ""), x509.NewCertPool(), "")); err == nil {
+		c.Close()
+		t.Errorf("connect with unknown server CA, want error")
+	}
+}
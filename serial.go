@@ -1,8 +1,12 @@
 package modbus
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"os"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/goburrow/serial"
@@ -15,6 +19,14 @@ const (
 	SerialDefaultAutoReconnect = 0
 )
 
+// RS485Config Linux RS485 ioctl configuration, the kernel driver
+// control the RTS pin as driver enable when send, with the delays before and after.
+type RS485Config = serial.RS485Config
+
+// DriverEnableFunc 半双工收发器的驱动使能(DE/RE)控制回调, 如翻转GPIO,
+// transmit 为 true 时切换到发送, 为 false 时切换回接收
+type DriverEnableFunc func(transmit bool) error
+
 // serialPort has configuration and I/O controller.
 type serialPort struct {
 	// Serial port configuration.
@@ -25,6 +37,166 @@ type serialPort struct {
 	// but if we active close self,it will not to reconncet
 	// if == 0 auto reconnect not active
 	autoReconnect byte
+	// driver enable control, nil if not set
+	driverEnable DriverEnableFunc
+	// delay between receiving a response and sending the next request
+	turnaround time.Duration
+	// the time of last frame on the bus
+	lastFrame time.Time
+	// hot-replug recovery, nil if disabled
+	replug     *ReplugConfig
+	replugStop chan struct{} // not nil when it is recovering
+	// lifecycle events of the provider, nil if not set
+	events *lifecycle
+	// serial port backend, nil use DefaultSerialOpener
+	opener SerialOpener
+}
+
+// ReplugConfig 串口热插拔恢复配置
+type ReplugConfig struct {
+	// backoff between reopen attempts, it doubles from MinBackoff up to MaxBackoff,
+	// default 100ms and 5s
+	MinBackoff, MaxBackoff time.Duration
+	// Resolve re-enumerate the port address, such as find the USB-serial adapter
+	// by its serial number, nil to reopen the Address
+	Resolve func() (address string, err error)
+	// OnReconnect called after every reopen attempt, err is nil if the port is reopened
+	OnReconnect func(address string, err error)
+}
+
+// SetRS485 set the Linux RS485 ioctl configuration, it takes effect on next Connect
+func (sf *serialPort) SetRS485(cfg RS485Config) {
+	sf.mu.Lock()
+	sf.RS485 = cfg
+	sf.mu.Unlock()
+}
+
+// SetDriverEnable set the driver enable control for the half-duplex transceiver
+// which need explicit DE/RE control, such as a GPIO toggle, nil to disable it.
+// it is called with true before send, and false after the frame is transmitted.
+func (sf *serialPort) SetDriverEnable(f DriverEnableFunc) {
+	sf.mu.Lock()
+	sf.driverEnable = f
+	sf.mu.Unlock()
+}
+
+// SetTurnaroundDelay set the delay between receiving a response and sending the next request,
+// and after broadcasts, some RS-485 slaves need dozens of milliseconds to switch back to receive mode.
+func (sf *serialPort) SetTurnaroundDelay(d time.Duration) {
+	sf.mu.Lock()
+	sf.turnaround = d
+	sf.mu.Unlock()
+}
+
+// SerialConfig the serial line parameters can be changed on an open port, 0 or empty keep the current
+type SerialConfig struct {
+	BaudRate int    // 波特率
+	DataBits int    // 数据位 5, 6, 7, 8
+	StopBits int    // 停止位 1, 2
+	Parity   string // 校验 N, E, O
+}
+
+// validate check the serial line parameters
+func (sf SerialConfig) validate() error {
+	if sf.BaudRate < 0 {
+		return fmt.Errorf("modbus: invalid baud rate '%v'", sf.BaudRate)
+	}
+	if sf.DataBits != 0 && (sf.DataBits < 5 || sf.DataBits > 8) {
+		return fmt.Errorf("modbus: invalid data bits '%v'", sf.DataBits)
+	}
+	if sf.StopBits != 0 && sf.StopBits != 1 && sf.StopBits != 2 {
+		return fmt.Errorf("modbus: invalid stop bits '%v'", sf.StopBits)
+	}
+	switch sf.Parity {
+	case "", "N", "E", "O":
+	default:
+		return fmt.Errorf("modbus: invalid parity '%v'", sf.Parity)
+	}
+	return nil
+}
+
+// Reconfigure change the baud rate, data bits, stop bits or parity, it waits the
+// current transaction complete, and reopen the port with the new parameters if it is open,
+// the next transaction use them. if the reopen fails, the previous parameters are restored
+// and reopened, the error is returned.
+func (sf *serialPort) Reconfigure(cfg SerialConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	old := sf.Config
+	if cfg.BaudRate != 0 {
+		sf.BaudRate = cfg.BaudRate
+	}
+	if cfg.DataBits != 0 {
+		sf.DataBits = cfg.DataBits
+	}
+	if cfg.StopBits != 0 {
+		sf.StopBits = cfg.StopBits
+	}
+	if cfg.Parity != "" {
+		sf.Parity = cfg.Parity
+	}
+	if sf.port == nil {
+		return nil
+	}
+	sf.port.Close()
+	port, err := sf.open(&sf.Config)
+	if err == nil {
+		sf.port = port
+		return nil
+	}
+	sf.Config = old
+	if port, e := sf.open(&sf.Config); e == nil {
+		sf.port = port
+	} else {
+		sf.port = nil
+		sf.events.emitDisconnected(e)
+	}
+	return err
+}
+
+// waitIdle wait the bus idle for at least min and the turnaround delay since last frame.
+// Caller must hold the mutex before calling this method.
+func (sf *serialPort) waitIdle(min time.Duration) {
+	if min < sf.turnaround {
+		min = sf.turnaround
+	}
+	if idle := time.Since(sf.lastFrame); idle < min {
+		time.Sleep(min - idle)
+	}
+}
+
+// characterTime the time to transmit a character on the wire
+func (sf *serialPort) characterTime() time.Duration {
+	if sf.BaudRate <= 0 {
+		return 0
+	}
+	bits := 1 + sf.DataBits + sf.StopBits // start bit, data bits, stop bits
+	if sf.Parity != "" && sf.Parity != "N" {
+		bits++
+	}
+	return time.Duration(bits) * time.Second / time.Duration(sf.BaudRate)
+}
+
+// write the frame with the driver enable control.
+// Caller must hold the mutex before calling this method.
+func (sf *serialPort) write(b []byte) (int, error) {
+	if sf.driverEnable == nil {
+		return sf.port.Write(b)
+	}
+	if err := sf.driverEnable(true); err != nil {
+		return 0, err
+	}
+	n, err := sf.port.Write(b)
+	// the write return when the data is in the driver buffer, wait for it be transmitted
+	time.Sleep(time.Duration(n) * sf.characterTime())
+	if e := sf.driverEnable(false); err == nil {
+		err = e
+	}
+	return n, err
 }
 
 // Connect try to connect the remote server
@@ -35,13 +207,51 @@ func (sf *serialPort) Connect() error {
 	return err
 }
 
+// ConnectContext open the serial port like Connect,
+// it returns when the context is done, the port opened after that is closed.
+func (sf *serialPort) ConnectContext(ctx context.Context) error {
+	if ctx.Done() == nil {
+		return sf.Connect()
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	type result struct {
+		port io.ReadWriteCloser
+		err  error
+	}
+	cfg, opener := sf.Config, sf.serialOpener()
+	ch := make(chan result, 1)
+	go func() {
+		port, err := opener.Open(&cfg)
+		ch <- result{port, err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return r.err
+		}
+		sf.port = r.port
+		sf.events.emitConnected()
+		return nil
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.port != nil {
+				r.port.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
 // Caller must hold the mutex before calling this method.
 func (sf *serialPort) connect() error {
-	port, err := serial.Open(&sf.Config)
+	port, err := sf.open(&sf.Config)
 	if err != nil {
 		return err
 	}
 	sf.port = port
+	sf.events.emitConnected()
 	return nil
 }
 
@@ -70,13 +280,114 @@ func (sf *serialPort) SetAutoReconnect(cnt byte) {
 	sf.mu.Unlock()
 }
 
+// SetReplug enable the hot-replug recovery, nil to disable it.
+// when the adapter disappears(EIO/ENODEV), the port is closed, and reopen
+// in background with backoff, the requests fail with ErrClosedConnection until it is reopened.
+func (sf *serialPort) SetReplug(cfg *ReplugConfig) {
+	sf.mu.Lock()
+	if cfg != nil {
+		c := *cfg
+		if c.MinBackoff <= 0 {
+			c.MinBackoff = 100 * time.Millisecond
+		}
+		if c.MaxBackoff < c.MinBackoff {
+			c.MaxBackoff = 5 * time.Second
+			if c.MaxBackoff < c.MinBackoff {
+				c.MaxBackoff = c.MinBackoff
+			}
+		}
+		cfg = &c
+	}
+	sf.replug = cfg
+	sf.mu.Unlock()
+}
+
+// isDeviceGone whether the error indicates the device disappeared
+func isDeviceGone(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+	errno, ok := err.(syscall.Errno)
+	return ok && (errno == syscall.EIO || errno == syscall.ENODEV || errno == syscall.ENXIO)
+}
+
+// checkGone close the port and start recovering if the device disappeared.
+// Caller must hold the mutex before calling this method.
+func (sf *serialPort) checkGone(err error) {
+	if sf.replug == nil || sf.replugStop != nil || !isDeviceGone(err) {
+		return
+	}
+	if sf.port != nil {
+		sf.port.Close()
+		sf.port = nil
+		sf.events.emitDisconnected(err)
+	}
+	sf.replugStop = make(chan struct{})
+	go sf.recover(*sf.replug, sf.replugStop)
+}
+
+// recover reopen the port with backoff until it is reopened or closed
+func (sf *serialPort) recover(cfg ReplugConfig, stop chan struct{}) {
+	backoff := cfg.MinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		var address string
+		var err error
+		if cfg.Resolve != nil {
+			address, err = cfg.Resolve()
+		}
+		sf.mu.Lock()
+		if sf.replugStop != stop { // closed
+			sf.mu.Unlock()
+			return
+		}
+		if err == nil {
+			if address != "" {
+				sf.Address = address
+			}
+			address = sf.Address
+			if sf.port == nil { // may be connected by Connect
+				sf.events.emitReconnecting(attempt)
+				err = sf.connect()
+			}
+		}
+		if err == nil {
+			sf.replugStop = nil
+		}
+		sf.mu.Unlock()
+
+		if cfg.OnReconnect != nil {
+			cfg.OnReconnect(address, err)
+		}
+		if err == nil {
+			return
+		}
+		if backoff *= 2; backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
 // Close close current connection.
 func (sf *serialPort) Close() error {
 	var err error
 	sf.mu.Lock()
+	if sf.replugStop != nil {
+		close(sf.replugStop)
+		sf.replugStop = nil
+	}
 	if sf.port != nil {
 		err = sf.port.Close()
 		sf.port = nil
+		sf.events.emitDisconnected(nil)
 	}
 	sf.mu.Unlock()
 	return err
@@ -1 +1,95 @@
 package modbus
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// gonePort a serial port which the device disappeared
+type gonePort struct{ closed bool }
+
+func (*gonePort) Write(b []byte) (int, error) { return len(b), nil }
+func (*gonePort) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: "/dev/ttyUSB0", Err: syscall.EIO}
+}
+func (sf *gonePort) Close() error { sf.closed = true; return nil }
+
+func Test_serialPort_SetReplug(t *testing.T) {
+	events := make(chan error, 10)
+	resolved := make(chan struct{}, 10)
+
+	port := &gonePort{}
+	p := NewRTUClientProvider()
+	p.port = port
+	p.SetReplug(&ReplugConfig{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Resolve: func() (string, error) {
+			resolved <- struct{}{}
+			return "/dev/not-exist-modbus-port", nil
+		},
+		OnReconnect: func(address string, err error) { events <- err },
+	})
+	if _, err := p.SendPdu(0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01}); !isDeviceGone(err) {
+		t.Fatalf("SendPdu() error = %v, want device gone", err)
+	}
+	if !port.closed || p.IsConnected() {
+		t.Fatalf("port should be closed when device gone")
+	}
+	if _, err := p.SendPdu(0x01, []byte{0x03, 0x00, 0x00, 0x00, 0x01}); err != ErrClosedConnection {
+		t.Fatalf("SendPdu() error = %v, want %v", err, ErrClosedConnection)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-events:
+			if err == nil {
+				t.Fatalf("reopen not exist port should fail")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no reconnect event")
+		}
+	}
+	p.Close()
+	// drain, no more attempt after closed
+	time.Sleep(20 * time.Millisecond)
+	for len(resolved) > 0 {
+		<-resolved
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(resolved) != 0 {
+		t.Errorf("recovering should stop after Close")
+	}
+}
+
+func Test_serialPort_Reconfigure(t *testing.T) {
+	p := NewRTUClientProvider()
+	p.BaudRate, p.DataBits, p.StopBits, p.Parity = 19200, 8, 1, "N"
+	if err := p.Reconfigure(SerialConfig{BaudRate: 9600, Parity: "E"}); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	if p.BaudRate != 9600 || p.DataBits != 8 || p.StopBits != 1 || p.Parity != "E" {
+		t.Errorf("Reconfigure() config = %+v", p.Config)
+	}
+	if t15, _ := p.silentInterval(); t15 != 1562*time.Microsecond {
+		t.Errorf("silentInterval() t1.5 = %v, want by the new baud rate", t15)
+	}
+
+	for _, cfg := range []SerialConfig{{BaudRate: -1}, {DataBits: 9}, {StopBits: 3}, {Parity: "M"}} {
+		if err := p.Reconfigure(cfg); err == nil {
+			t.Errorf("Reconfigure(%+v) error = nil, want invalid", cfg)
+		}
+	}
+
+	// reopen failed, the previous parameters are restored
+	port := &gonePort{}
+	p.Address = "/dev/not-exist-modbus-port"
+	p.port = port
+	if err := p.Reconfigure(SerialConfig{BaudRate: 115200}); err == nil {
+		t.Fatalf("Reconfigure() reopen not exist port error = nil")
+	}
+	if !port.closed || p.IsConnected() || p.BaudRate != 9600 {
+		t.Errorf("Reconfigure() failed, closed = %v, connected = %v, baud rate = %v", port.closed, p.IsConnected(), p.BaudRate)
+	}
+}
@@ -0,0 +1,70 @@
+package modbus
+
+import (
+	"io"
+
+	"github.com/goburrow/serial"
+)
+
+// SerialPortConfig the configuration to open the serial port
+type SerialPortConfig = serial.Config
+
+// SerialOpener open the serial port, implement it to use other serial library,
+// such as go.bug.st/serial, tarm/serial, or the RS-485 HAL of an embedded board.
+// the read of the port should return after cfg.Timeout without data, with a error
+// which has Timeout() bool method return true or serial.ErrTimeout.
+type SerialOpener interface {
+	Open(cfg *SerialPortConfig) (io.ReadWriteCloser, error)
+}
+
+// SerialOpenerFunc is an adapter to allow the use of ordinary functions as SerialOpener.
+type SerialOpenerFunc func(cfg *SerialPortConfig) (io.ReadWriteCloser, error)
+
+// Open implements SerialOpener, calls f(cfg).
+func (f SerialOpenerFunc) Open(cfg *SerialPortConfig) (io.ReadWriteCloser, error) {
+	return f(cfg)
+}
+
+// DefaultSerialOpener open the serial port with github.com/goburrow/serial
+var DefaultSerialOpener SerialOpener = SerialOpenerFunc(func(cfg *SerialPortConfig) (io.ReadWriteCloser, error) {
+	return serial.Open(cfg)
+})
+
+// SetSerialOpener set the serial port backend, nil to use DefaultSerialOpener,
+// it takes effect on next Connect.
+func (sf *serialPort) SetSerialOpener(o SerialOpener) {
+	sf.mu.Lock()
+	sf.opener = o
+	sf.mu.Unlock()
+}
+
+// serialOpener the serial port backend.
+// Caller must hold the mutex before calling this method.
+func (sf *serialPort) serialOpener() SerialOpener {
+	if sf.opener == nil {
+		return DefaultSerialOpener
+	}
+	return sf.opener
+}
+
+// open the serial port with the backend.
+// Caller must hold the mutex before calling this method.
+func (sf *serialPort) open(cfg *SerialPortConfig) (io.ReadWriteCloser, error) {
+	return sf.serialOpener().Open(cfg)
+}
+
+// SetSerialOpener set the serial port backend used by ListenAndServe, nil to use DefaultSerialOpener
+func (sf *RTUServer) SetSerialOpener(o SerialOpener) {
+	sf.mu.Lock()
+	sf.opener = o
+	sf.mu.Unlock()
+}
+
+// isSerialTimeout whether the error is the read timeout of the serial port
+func isSerialTimeout(err error) bool {
+	if err == serial.ErrTimeout {
+		return true
+	}
+	e, ok := err.(interface{ Timeout() bool })
+	return ok && e.Timeout()
+}
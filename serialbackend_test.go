@@ -0,0 +1,70 @@
+package modbus
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+// baudPort a serial port which the slave only reply at the baud rate and parity
+type baudPort struct {
+	chunkPort
+	cfg     SerialPortConfig
+	matched bool
+	closed  bool
+}
+
+func (sf *baudPort) Write(b []byte) (int, error) {
+	if sf.matched {
+		sf.chunks = [][]byte{rtuFrame([]byte{b[0], FuncCodeReadHoldingRegisters, 0x02, 0x00, 0x2a})}
+	}
+	return len(b), nil
+}
+
+func (sf *baudPort) Close() error { sf.closed = true; return nil }
+
+func TestSerialOpener(t *testing.T) {
+	var opened []*baudPort
+	fail := false
+	p := NewRTUClientProvider()
+	p.Address, p.BaudRate, p.DataBits, p.StopBits, p.Parity = "/dev/ttyUSB0", 9600, 8, 1, "E"
+	p.SetSerialOpener(SerialOpenerFunc(func(cfg *SerialPortConfig) (io.ReadWriteCloser, error) {
+		if fail {
+			return nil, errors.New("open failed")
+		}
+		port := &baudPort{cfg: *cfg, matched: cfg.BaudRate == 38400 && cfg.Parity == "N"}
+		opened = append(opened, port)
+		return port, nil
+	}))
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if len(opened) != 1 || opened[0].cfg.Address != "/dev/ttyUSB0" {
+		t.Fatalf("Connect() opened = %+v", opened)
+	}
+
+	if err := p.Reconfigure(SerialConfig{BaudRate: 19200}); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	if len(opened) != 2 || !opened[0].closed || opened[1].cfg.BaudRate != 19200 || opened[1].cfg.Parity != "E" {
+		t.Errorf("Reconfigure() reopened = %+v", opened[1])
+	}
+
+	cfg, err := p.Detect(1, []SerialConfig{
+		{BaudRate: 19200, Parity: "N"}, {BaudRate: 38400, Parity: "E"}, {BaudRate: 38400, Parity: "N"},
+	})
+	if err != nil || cfg.BaudRate != 38400 || cfg.Parity != "N" {
+		t.Errorf("Detect() = %+v, %v, want 38400 N", cfg, err)
+	}
+	if p.BaudRate != 38400 || p.Parity != "N" {
+		t.Errorf("Detect() port config = %v %v, want kept at the detected", p.BaudRate, p.Parity)
+	}
+	if _, err = p.Detect(1, []SerialConfig{{BaudRate: 1200}}); err != ErrNotDetected || p.BaudRate != 38400 {
+		t.Errorf("Detect() error = %v, baud rate = %v, want not detected and restored", err, p.BaudRate)
+	}
+
+	fail = true
+	if err = p.Reconfigure(SerialConfig{BaudRate: 115200}); err == nil || p.IsConnected() {
+		t.Errorf("Reconfigure() error = %v, connected = %v, want failed and closed", err, p.IsConnected())
+	}
+}
@@ -0,0 +1,61 @@
+package modbus
+
+import (
+	"sort"
+	"strings"
+)
+
+// SerialPortInfo 串口信息, USB串口有 VID/PID 等信息
+type SerialPortInfo struct {
+	Name         string // 串口地址, 如 /dev/ttyUSB0, COM3, 可直接用于 Address
+	Description  string // 描述, 如USB设备的产品名, 可能为空
+	USB          bool   // 是否是USB串口
+	VID, PID     uint16 // USB vendor id 和 product id
+	SerialNumber string // USB序列号, 可用于 ReplugConfig.Resolve 查找重新插入的适配器
+	Manufacturer string // USB厂商
+}
+
+// SerialPorts 列出系统可用的串口, 按名称排序, 名称中的数字按数值排序, 如 COM2 在 COM10 之前,
+// Linux 上读取 sysfs 的USB信息, Windows 上读取注册表 HKLM\HARDWARE\DEVICEMAP\SERIALCOMM, 其它系统列出 /dev 下的串口设备.
+func SerialPorts() ([]SerialPortInfo, error) {
+	list, err := serialPorts()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return naturalLess(list[i].Name, list[j].Name) })
+	return list, nil
+}
+
+// naturalLess 自然排序比较, 数字部分按数值比较
+func naturalLess(a, b string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, nb := digitsLen(a), digitsLen(b)
+			x, y := strings.TrimLeft(a[:na], "0"), strings.TrimLeft(b[:nb], "0")
+			if len(x) != len(y) {
+				return len(x) < len(y)
+			}
+			if x != y {
+				return x < y
+			}
+			a, b = a[na:], b[nb:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// digitsLen 开头的数字长度
+func digitsLen(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
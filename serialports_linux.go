@@ -0,0 +1,70 @@
+package modbus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// the sysfs class of tty and the directory of device files, variables for test
+var (
+	sysClassTTY = "/sys/class/tty"
+	devDir      = "/dev"
+)
+
+// serialPorts list the tty with a hardware device, except the unused legacy platform ports
+func serialPorts() ([]SerialPortInfo, error) {
+	entries, err := ioutil.ReadDir(sysClassTTY)
+	if err != nil {
+		return nil, err
+	}
+	var list []SerialPortInfo
+	for _, e := range entries {
+		device, err := filepath.EvalSymlinks(filepath.Join(sysClassTTY, e.Name(), "device"))
+		if err != nil { // virtual terminal, pty
+			continue
+		}
+		if subsystem, _ := os.Readlink(filepath.Join(device, "subsystem")); filepath.Base(subsystem) == "platform" {
+			continue
+		}
+		info := SerialPortInfo{Name: filepath.Join(devDir, e.Name())}
+		usbInfo(&info, device)
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+// usbInfo fill the usb information of the device which is an interface of a usb device,
+// the usb device is the nearest ancestor which has idVendor.
+func usbInfo(info *SerialPortInfo, device string) {
+	dir := device
+	for i := 0; i < 3; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "idVendor")); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	vid, err := strconv.ParseUint(sysfsAttr(dir, "idVendor"), 16, 16)
+	if err != nil {
+		return
+	}
+	pid, _ := strconv.ParseUint(sysfsAttr(dir, "idProduct"), 16, 16)
+	info.USB = true
+	info.VID, info.PID = uint16(vid), uint16(pid)
+	info.SerialNumber = sysfsAttr(dir, "serial")
+	info.Manufacturer = sysfsAttr(dir, "manufacturer")
+	if info.Description = sysfsAttr(dir, "product"); info.Description == "" {
+		info.Description = sysfsAttr(filepath.Dir(device), "interface")
+	}
+}
+
+// sysfsAttr read the attribute, empty if not exist
+func sysfsAttr(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
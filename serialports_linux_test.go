@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSerialPorts(t *testing.T) {
+	root, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	mkdir := func(dir string, attrs map[string]string) string {
+		dir = filepath.Join(root, dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range attrs {
+			if err := ioutil.WriteFile(filepath.Join(dir, k), []byte(v+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	link := func(target, name string) {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkdir("bus/platform", nil)
+	mkdir("bus/usb-serial", nil)
+	mkdir("devices/usb1/1-1", map[string]string{
+		"idVendor": "0403", "idProduct": "6001", "serial": "A50285BI", "manufacturer": "FTDI", "product": "FT232R USB UART"})
+	mkdir("devices/usb1/1-1/1-1:1.0/ttyUSB0", nil)
+	link(filepath.Join(root, "bus/usb-serial"), "devices/usb1/1-1/1-1:1.0/ttyUSB0/subsystem")
+	mkdir("devices/pnp0/00:01", nil)
+	mkdir("devices/platform/serial8250", nil)
+	link(filepath.Join(root, "bus/platform"), "devices/platform/serial8250/subsystem")
+
+	mkdir("class/tty/ttyUSB0", nil)
+	link(filepath.Join(root, "devices/usb1/1-1/1-1:1.0/ttyUSB0"), "class/tty/ttyUSB0/device")
+	mkdir("class/tty/ttyS0", nil)
+	link(filepath.Join(root, "devices/pnp0/00:01"), "class/tty/ttyS0/device")
+	mkdir("class/tty/ttyS1", nil)
+	link(filepath.Join(root, "devices/platform/serial8250"), "class/tty/ttyS1/device")
+	mkdir("class/tty/tty0", nil)
+
+	defer func(class string) { sysClassTTY = class }(sysClassTTY)
+	sysClassTTY = filepath.Join(root, "class/tty")
+	got, err := SerialPorts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SerialPortInfo{
+		{Name: "/dev/ttyS0"},
+		{Name: "/dev/ttyUSB0", Description: "FT232R USB UART", USB: true, VID: 0x0403, PID: 0x6001,
+			SerialNumber: "A50285BI", Manufacturer: "FTDI"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SerialPorts() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package modbus
+
+import (
+	"path/filepath"
+)
+
+// serialPorts list the serial device files, such as /dev/cu.usbserial-* on macOS
+func serialPorts() ([]SerialPortInfo, error) {
+	var list []SerialPortInfo
+	for _, pattern := range []string{"/dev/cu.*", "/dev/ttyU*", "/dev/cuaU*", "/dev/ttyS*"} {
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			list = append(list, SerialPortInfo{Name: name})
+		}
+	}
+	return list, nil
+}
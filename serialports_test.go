@@ -0,0 +1,26 @@
+package modbus
+
+import (
+	"testing"
+)
+
+func Test_naturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"COM2", "COM10", true},
+		{"COM10", "COM2", false},
+		{"COM3", "COM3", false},
+		{"COM03", "COM4", true},
+		{"COM1", "COM10", true},
+		{"/dev/ttyS1", "/dev/ttyUSB0", true},
+		{"/dev/ttyUSB2", "/dev/ttyUSB10", true},
+		{"COM", "COM1", true},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
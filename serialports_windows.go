@@ -0,0 +1,58 @@
+package modbus
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// errorNoMoreItems ERROR_NO_MORE_ITEMS, not defined by syscall
+const errorNoMoreItems syscall.Errno = 259
+
+var procRegEnumValueW = syscall.NewLazyDLL("advapi32.dll").NewProc("RegEnumValueW")
+
+// serialPorts list the ports in the registry HKLM\HARDWARE\DEVICEMAP\SERIALCOMM,
+// the value data is the port name and the value name is the device, such as \Device\Serial0
+func serialPorts() ([]SerialPortInfo, error) {
+	path, err := syscall.UTF16PtrFromString(`HARDWARE\DEVICEMAP\SERIALCOMM`)
+	if err != nil {
+		return nil, err
+	}
+	var key syscall.Handle
+	err = syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, path, 0, syscall.KEY_READ, &key)
+	if err == syscall.ERROR_FILE_NOT_FOUND { // the key exists only when there is a port
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.RegCloseKey(key)
+
+	var list []SerialPortInfo
+	name := make([]uint16, 256)
+	data := make([]uint16, 256)
+	for i := uint32(0); ; i++ {
+		nameLen := uint32(len(name))
+		dataLen := uint32(len(data) * 2) // in bytes
+		var typ uint32
+		r, _, _ := procRegEnumValueW.Call(uintptr(key), uintptr(i),
+			uintptr(unsafe.Pointer(&name[0])), uintptr(unsafe.Pointer(&nameLen)), 0,
+			uintptr(unsafe.Pointer(&typ)), uintptr(unsafe.Pointer(&data[0])), uintptr(unsafe.Pointer(&dataLen)))
+		switch e := syscall.Errno(r); e {
+		case 0:
+		case errorNoMoreItems:
+			return list, nil
+		case syscall.ERROR_MORE_DATA: // not a port name
+			continue
+		default:
+			return nil, e
+		}
+		if typ != syscall.REG_SZ {
+			continue
+		}
+		port := syscall.UTF16ToString(data[:dataLen/2])
+		if port == "" {
+			continue
+		}
+		list = append(list, SerialPortInfo{Name: port, Description: syscall.UTF16ToString(name[:nameLen])})
+	}
+}
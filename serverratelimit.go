@@ -0,0 +1,106 @@
+package modbus
+
+import (
+	"net"
+	"time"
+)
+
+// RateLimitMode the handling of the request exceed the rate limit on the server
+type RateLimitMode byte
+
+// rate limit mode
+const (
+	// RateLimitBusy reply the exception server device busy (0x06) immediately
+	RateLimitBusy RateLimitMode = iota
+	// RateLimitDelay delay the request until the rate allows, the client may time out
+	RateLimitDelay
+)
+
+// rateLimitConfig the rate limit of the server
+type rateLimitConfig struct {
+	rate  float64
+	burst int
+	mode  RateLimitMode
+}
+
+// sessionLimit a rate limiter of the session
+type sessionLimit struct {
+	limiter *rateLimiter
+	mode    RateLimitMode
+}
+
+// ipLimiter the rate limiter shared by the connections from the same ip
+type ipLimiter struct {
+	limiter *rateLimiter
+	conns   int
+}
+
+// SetConnRateLimit cap the requests per second of every client connection with burst,
+// it protects the register store from the runaway pollers, rate <= 0 to disable it.
+// it applies to the connections accepted after it is set.
+func (sf *TCPServer) SetConnRateLimit(rate float64, burst int, mode RateLimitMode) {
+	sf.connLimit.Store(rateLimitConfig{rate, burst, mode})
+}
+
+// SetIPRateLimit cap the requests per second of every source ip with burst,
+// shared by all the connections from the ip, rate <= 0 to disable it.
+// it applies to the connections accepted after it is set.
+func (sf *TCPServer) SetIPRateLimit(rate float64, burst int, mode RateLimitMode) {
+	sf.ipLimit.Store(rateLimitConfig{rate, burst, mode})
+}
+
+// sessionLimits got the rate limiters of the new session from the remote address,
+// release must be called when the session ends.
+func (sf *TCPServer) sessionLimits(remote string) (limits []sessionLimit, release func()) {
+	release = func() {}
+	if c, ok := sf.connLimit.Load().(rateLimitConfig); ok && c.rate > 0 {
+		limits = append(limits, sessionLimit{newRateLimiter(c.rate, c.burst), c.mode})
+	}
+	c, ok := sf.ipLimit.Load().(rateLimitConfig)
+	if !ok || c.rate <= 0 {
+		return limits, release
+	}
+	ip := remote
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		ip = host
+	}
+	sf.ipMu.Lock()
+	if sf.ipLimiters == nil {
+		sf.ipLimiters = make(map[string]*ipLimiter)
+	}
+	l, ok := sf.ipLimiters[ip]
+	if !ok {
+		l = &ipLimiter{limiter: newRateLimiter(c.rate, c.burst)}
+		sf.ipLimiters[ip] = l
+	}
+	l.conns++
+	sf.ipMu.Unlock()
+	release = func() {
+		sf.ipMu.Lock()
+		if l.conns--; l.conns == 0 {
+			delete(sf.ipLimiters, ip)
+		}
+		sf.ipMu.Unlock()
+	}
+	return append(limits, sessionLimit{l.limiter, c.mode}), release
+}
+
+// throttle apply the rate limits of the session before serving the request,
+// return the exception server device busy if it is rejected.
+func (sf *ServerSession) throttle() error {
+	now := time.Now()
+	var wait time.Duration
+	for _, l := range sf.limits {
+		if l.mode == RateLimitBusy {
+			if !l.limiter.allow(now) {
+				return &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceBusy}
+			}
+		} else if d := l.limiter.reserve(now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package modbus
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// startRateLimitServer start a tcp server on a random port with the rate limits set by setup
+func startRateLimitServer(t *testing.T, setup func(srv *TCPServer)) (*TCPServer, string) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPServer()
+	srv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10))
+	setup(srv)
+	go srv.Serve(listen)
+	return srv, listen.Addr().String()
+}
+
+func connectClient(t *testing.T, addr string) Client {
+	c := NewClient(NewTCPClientProvider(addr))
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	return c
+}
+
+func Test_TCPServerConnRateLimit(t *testing.T) {
+	srv, addr := startRateLimitServer(t, func(srv *TCPServer) {
+		srv.SetConnRateLimit(1, 2, RateLimitBusy)
+	})
+	defer srv.Close()
+
+	c1 := connectClient(t, addr)
+	defer c1.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c1.ReadHoldingRegisters(testslaveID1, 0, 1); err != nil {
+			t.Fatalf("ReadHoldingRegisters() burst error = %v", err)
+		}
+	}
+	if _, err := c1.ReadHoldingRegisters(testslaveID1, 0, 1); !IsServerDeviceBusy(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want server device busy", err)
+	}
+
+	// 每个连接独立的限制
+	c2 := connectClient(t, addr)
+	defer c2.Close()
+	if _, err := c2.ReadHoldingRegisters(testslaveID1, 0, 1); err != nil {
+		t.Errorf("ReadHoldingRegisters() other connection error = %v", err)
+	}
+}
+
+func Test_TCPServerIPRateLimit(t *testing.T) {
+	srv, addr := startRateLimitServer(t, func(srv *TCPServer) {
+		srv.SetIPRateLimit(1, 2, RateLimitBusy)
+	})
+	defer srv.Close()
+
+	c1, c2 := connectClient(t, addr), connectClient(t, addr)
+	defer c2.Close()
+	for _, c := range []Client{c1, c2} {
+		if _, err := c.ReadHoldingRegisters(testslaveID1, 0, 1); err != nil {
+			t.Fatalf("ReadHoldingRegisters() burst error = %v", err)
+		}
+	}
+	if _, err := c2.ReadHoldingRegisters(testslaveID1, 0, 1); !IsServerDeviceBusy(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want server device busy shared by ip", err)
+	}
+
+	c1.Close()
+	c2.Close()
+	time.Sleep(50 * time.Millisecond)
+	srv.ipMu.Lock()
+	n := len(srv.ipLimiters)
+	srv.ipMu.Unlock()
+	if n != 0 {
+		t.Errorf("ip limiters = %v after all connections closed, want 0", n)
+	}
+}
+
+func Test_TCPServerRateLimitDelay(t *testing.T) {
+	srv, addr := startRateLimitServer(t, func(srv *TCPServer) {
+		srv.SetConnRateLimit(20, 1, RateLimitDelay)
+	})
+	defer srv.Close()
+
+	c := connectClient(t, addr)
+	defer c.Close()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := c.ReadHoldingRegisters(testslaveID1, 0, 1); err != nil {
+			t.Fatalf("ReadHoldingRegisters() error = %v", err)
+		}
+	}
+	if d := time.Since(start); d < 140*time.Millisecond {
+		t.Errorf("4 requests at 20/s took %v, want delayed", d)
+	}
+}
@@ -0,0 +1,198 @@
+package modbus
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SimulatorDefaultInterval 模拟器默认更新周期
+const SimulatorDefaultInterval = time.Second
+
+// Generator 值发生器, elapsed 模拟器启动后经过的时间
+type Generator interface {
+	Next(elapsed time.Duration) uint16
+}
+
+// GeneratorFunc is an adapter to allow the use of ordinary functions as Generator.
+type GeneratorFunc func(elapsed time.Duration) uint16
+
+// Next implements Generator, calls f(elapsed).
+func (f GeneratorFunc) Next(elapsed time.Duration) uint16 {
+	return f(elapsed)
+}
+
+// Ramp 锯齿波, 在一个周期内从min线性增加到max, 然后回到min
+func Ramp(min, max uint16, period time.Duration) Generator {
+	return GeneratorFunc(func(elapsed time.Duration) uint16 {
+		if period <= 0 || max <= min {
+			return min
+		}
+		phase := float64(elapsed%period) / float64(period)
+		return min + uint16(phase*float64(max-min))
+	})
+}
+
+// Sine 正弦波, offset + amplitude*sin(2π*elapsed/period), 结果限制在 0-65535
+func Sine(offset, amplitude float64, period time.Duration) Generator {
+	return GeneratorFunc(func(elapsed time.Duration) uint16 {
+		v := offset
+		if period > 0 {
+			v += amplitude * math.Sin(2*math.Pi*float64(elapsed%period)/float64(period))
+		}
+		return uint16(math.Max(0, math.Min(math.MaxUint16, math.Round(v))))
+	})
+}
+
+// RandomWalk 随机游走, 从start开始每次随机增加或减少不超过step, 结果限制在 min-max
+func RandomWalk(start, step, min, max uint16) Generator {
+	var mu sync.Mutex
+	value := int(start)
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return GeneratorFunc(func(time.Duration) uint16 {
+		mu.Lock()
+		defer mu.Unlock()
+		value += rnd.Intn(2*int(step)+1) - int(step)
+		if value < int(min) {
+			value = int(min)
+		} else if value > int(max) {
+			value = int(max)
+		}
+		return uint16(value)
+	})
+}
+
+// Sequence 脚本序列, 每个interval依次输出values, 结束后循环
+func Sequence(interval time.Duration, values ...uint16) Generator {
+	return GeneratorFunc(func(elapsed time.Duration) uint16 {
+		if len(values) == 0 {
+			return 0
+		}
+		if interval <= 0 {
+			return values[0]
+		}
+		return values[int(elapsed/interval)%len(values)]
+	})
+}
+
+// generatorBinding 发生器与寄存器区间的绑定
+type generatorBinding struct {
+	node     *NodeRegister
+	table    Table
+	address  uint16
+	quantity uint16
+	gen      Generator
+}
+
+// Simulator 模拟器, 周期性的将值发生器产生的值写入节点寄存器区间,
+// 配合 TCPServer 等可作为独立的模拟从站, 位表中非0值为ON
+type Simulator struct {
+	mu       sync.Mutex
+	bindings []generatorBinding
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	logger
+}
+
+// NewSimulator 创建模拟器, interval 更新周期, 小于等于0时使用默认周期
+func NewSimulator(interval time.Duration) *Simulator {
+	if interval <= 0 {
+		interval = SimulatorDefaultInterval
+	}
+	return &Simulator{
+		interval: interval,
+		logger:   newLogger("modbusSimulator =>"),
+	}
+}
+
+// Attach 绑定值发生器到节点寄存器区间, 区间内所有寄存器写入相同的值
+func (sf *Simulator) Attach(node *NodeRegister, table Table, address, quantity uint16, gen Generator) {
+	sf.mu.Lock()
+	sf.bindings = append(sf.bindings, generatorBinding{node, table, address, quantity, gen})
+	sf.mu.Unlock()
+}
+
+// Step 以指定的经过时间更新一次所有绑定的寄存器区间
+func (sf *Simulator) Step(elapsed time.Duration) {
+	sf.mu.Lock()
+	bindings := sf.bindings
+	sf.mu.Unlock()
+
+	for _, b := range bindings {
+		if err := b.update(elapsed); err != nil {
+			sf.Error("slave %d update %v [%d,%d) failed, %v",
+				b.node.SlaveID(), b.table, b.address, int(b.address)+int(b.quantity), err)
+		}
+	}
+}
+
+// update 更新寄存器区间
+func (sf generatorBinding) update(elapsed time.Duration) error {
+	v := sf.gen.Next(elapsed)
+	switch sf.table {
+	case TableCoils, TableDiscreteInputs:
+		var bit byte
+		if v != 0 {
+			bit = 0xff
+		}
+		buf := make([]byte, (int(sf.quantity)+7)/8)
+		for i := range buf {
+			buf[i] = bit
+		}
+		if sf.table == TableCoils {
+			return sf.node.WriteCoils(sf.address, sf.quantity, buf)
+		}
+		return sf.node.WriteDiscretes(sf.address, sf.quantity, buf)
+	default:
+		buf := make([]uint16, sf.quantity)
+		for i := range buf {
+			buf[i] = v
+		}
+		if sf.table == TableInputRegisters {
+			return sf.node.WriteInputs(sf.address, buf)
+		}
+		return sf.node.WriteHoldings(sf.address, buf)
+	}
+}
+
+// Start 启动模拟器, 立即更新一次, 然后周期性更新
+func (sf *Simulator) Start() {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sf.cancel = cancel
+	sf.wg.Add(1)
+	go func() {
+		defer sf.wg.Done()
+		start := time.Now()
+		ticker := time.NewTicker(sf.interval)
+		defer ticker.Stop()
+		for {
+			sf.Step(time.Since(start))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	sf.Debug("simulator started, interval %v", sf.interval)
+}
+
+// Close 停止模拟器
+func (sf *Simulator) Close() error {
+	sf.mu.Lock()
+	if sf.cancel != nil {
+		sf.cancel()
+		sf.cancel = nil
+	}
+	sf.mu.Unlock()
+	sf.wg.Wait()
+	return nil
+}
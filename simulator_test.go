@@ -0,0 +1,80 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name    string
+		gen     Generator
+		elapsed time.Duration
+		want    uint16
+	}{
+		{"ramp start", Ramp(10, 110, 10*time.Second), 0, 10},
+		{"ramp half", Ramp(10, 110, 10*time.Second), 5 * time.Second, 60},
+		{"ramp wrap", Ramp(10, 110, 10*time.Second), 12 * time.Second, 30},
+		{"sine zero", Sine(100, 50, 4*time.Second), 0, 100},
+		{"sine peak", Sine(100, 50, 4*time.Second), time.Second, 150},
+		{"sine trough", Sine(100, 50, 4*time.Second), 3 * time.Second, 50},
+		{"sine clamp", Sine(10, 50, 4*time.Second), 3 * time.Second, 0},
+		{"sequence", Sequence(time.Second, 1, 2, 3), 1500 * time.Millisecond, 2},
+		{"sequence loop", Sequence(time.Second, 1, 2, 3), 3 * time.Second, 1},
+		{"sequence empty", Sequence(time.Second), time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.gen.Next(tt.elapsed); got != tt.want {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomWalk(t *testing.T) {
+	g := RandomWalk(50, 5, 40, 60)
+	prev := uint16(50)
+	for i := 0; i < 1000; i++ {
+		v := g.Next(0)
+		if v < 40 || v > 60 {
+			t.Fatalf("Next() = %v, out of range [40,60]", v)
+		}
+		if d := int(v) - int(prev); d > 5 || d < -5 {
+			t.Fatalf("Next() step = %v, want at most 5", d)
+		}
+		prev = v
+	}
+}
+
+func TestSimulator_Step(t *testing.T) {
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 10, 0, 10)
+	sim := NewSimulator(0)
+	sim.Attach(node, TableHoldingRegisters, 2, 3, Sequence(time.Second, 7, 8))
+	sim.Attach(node, TableInputRegisters, 0, 2, Ramp(0, 100, 10*time.Second))
+	sim.Attach(node, TableCoils, 4, 4, Sequence(time.Second, 0, 1))
+	sim.Step(time.Second)
+
+	holding, _ := node.ReadHoldings(0, 6)
+	if want := []uint16{0, 0, 8, 8, 8, 0}; !reflect.DeepEqual(holding, want) {
+		t.Errorf("holding = %v, want %v", holding, want)
+	}
+	input, _ := node.ReadInputs(0, 3)
+	if want := []uint16{10, 10, 0}; !reflect.DeepEqual(input, want) {
+		t.Errorf("input = %v, want %v", input, want)
+	}
+	coils, _ := node.ReadCoils(0, 16)
+	if want := []byte{0xf0, 0x00}; !reflect.DeepEqual(coils, want) {
+		t.Errorf("coils = % x, want % x", coils, want)
+	}
+
+	sim = NewSimulator(10 * time.Millisecond)
+	sim.Attach(node, TableHoldingRegisters, 9, 1, Sequence(0, 99))
+	sim.Start()
+	time.Sleep(50 * time.Millisecond)
+	sim.Close()
+	if v, _ := node.ReadHoldings(9, 1); v[0] != 99 {
+		t.Errorf("holding[9] = %v, want 99", v[0])
+	}
+}
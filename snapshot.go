@@ -0,0 +1,106 @@
+package modbus
+
+// 本文件提供了节点寄存器的原子更新, 应用的一次更新可以跨多个寄存器和表,
+// 读请求的响应只会包含更新前或更新后的值, 不会混合两次更新的值
+
+// NodeUpdate 原子更新中的节点寄存器视图, 仅在 Update 的回调内有效
+type NodeUpdate struct {
+	node    *NodeRegister
+	changes []changeRange // 已写入的范围, 更新成功后通知订阅者
+}
+
+// changed 记录写入成功的范围
+func (sf *NodeUpdate) changed(err error, table Table, address, quantity uint16) error {
+	if err == nil {
+		sf.changes = append(sf.changes, changeRange{table, address, quantity})
+	}
+	return err
+}
+
+// WriteCoils 写线圈
+func (sf *NodeUpdate) WriteCoils(address, quality uint16, valBuf []byte) error {
+	return sf.changed(sf.node.writeCoils(address, quality, valBuf), TableCoils, address, quality)
+}
+
+// WriteDiscretes 写离散量
+func (sf *NodeUpdate) WriteDiscretes(address, quality uint16, valBuf []byte) error {
+	return sf.changed(sf.node.writeDiscretes(address, quality, valBuf), TableDiscreteInputs, address, quality)
+}
+
+// WriteHoldingsBytes 写保持寄存器
+func (sf *NodeUpdate) WriteHoldingsBytes(address, quality uint16, valBuf []byte) error {
+	return sf.changed(sf.node.writeHoldingsBytes(address, quality, valBuf), TableHoldingRegisters, address, quality)
+}
+
+// WriteHoldings 写保持寄存器
+func (sf *NodeUpdate) WriteHoldings(address uint16, valBuf []uint16) error {
+	return sf.changed(sf.node.writeHoldings(address, valBuf), TableHoldingRegisters, address, uint16(len(valBuf)))
+}
+
+// ReadHoldings 读保持寄存器, 包含本次更新已写入的值
+func (sf *NodeUpdate) ReadHoldings(address, quality uint16) ([]uint16, error) {
+	return sf.node.readHoldings(address, quality)
+}
+
+// WriteInputsBytes 写输入寄存器
+func (sf *NodeUpdate) WriteInputsBytes(address, quality uint16, regBuf []byte) error {
+	return sf.changed(sf.node.writeInputsBytes(address, quality, regBuf), TableInputRegisters, address, quality)
+}
+
+// WriteInputs 写输入寄存器
+func (sf *NodeUpdate) WriteInputs(address uint16, valBuf []uint16) error {
+	return sf.changed(sf.node.writeInputs(address, valBuf), TableInputRegisters, address, uint16(len(valBuf)))
+}
+
+// ReadInputs 读输入寄存器, 包含本次更新已写入的值
+func (sf *NodeUpdate) ReadInputs(address, quality uint16) ([]uint16, error) {
+	return sf.node.readInputs(address, quality)
+}
+
+// Update 原子更新, fn 执行期间持有所有表的写锁, 并发的读请求只会看到fn执行前或执行后的值,
+// fn 返回错误时所有表恢复到执行前的值, fn 内不可调用该节点的其它方法, 否则死锁.
+// 应用的更新不受写保护区限制, 见 Protect
+func (sf *NodeRegister) Update(fn func(u *NodeUpdate) error) error {
+	// 固定的加锁顺序, 避免死锁
+	sf.coilsRW.Lock()
+	defer sf.coilsRW.Unlock()
+	sf.discreteRW.Lock()
+	defer sf.discreteRW.Unlock()
+	sf.inputRW.Lock()
+	defer sf.inputRW.Unlock()
+	sf.holdingRW.Lock()
+	defer sf.holdingRW.Unlock()
+
+	coils := append([]byte(nil), sf.coils...)
+	discrete := append([]byte(nil), sf.discrete...)
+	input := append([]uint16(nil), sf.input...)
+	holding := append([]uint16(nil), sf.holding...)
+	u := &NodeUpdate{node: sf}
+	if err := fn(u); err != nil {
+		copy(sf.coils, coils)
+		copy(sf.discrete, discrete)
+		copy(sf.input, input)
+		copy(sf.holding, holding)
+		return err
+	}
+	for _, c := range u.changes {
+		sf.notify(c.table, c.address, c.quantity)
+	}
+	return nil
+}
+
+// WriteReadHoldingsBytes 写保持寄存器后读保持寄存器, 写和读在同一次加锁内完成,
+// 读到的值不会包含其它并发写入的值, 用于读写多个寄存器功能码
+func (sf *NodeRegister) WriteReadHoldingsBytes(writeAddress, writeQuantity uint16, valBuf []byte,
+	readAddress, readQuantity uint16) ([]byte, error) {
+	sf.holdingRW.Lock()
+	defer sf.holdingRW.Unlock()
+	if err := checkProtect(sf.holdingProtect, writeAddress, writeQuantity); err != nil {
+		return nil, err
+	}
+	if err := sf.writeHoldingsBytes(writeAddress, writeQuantity, valBuf); err != nil {
+		return nil, err
+	}
+	sf.notify(TableHoldingRegisters, writeAddress, writeQuantity)
+	return sf.readHoldingsBytes(readAddress, readQuantity)
+}
@@ -0,0 +1,105 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNodeRegister_Update(t *testing.T) {
+	node := NewNodeRegister(1, 0, 8, 0, 8, 0, 2, 0, 4)
+	err := node.Update(func(u *NodeUpdate) error {
+		if err := u.WriteHoldings(0, []uint16{1, 2}); err != nil {
+			return err
+		}
+		if err := u.WriteCoils(0, 8, []byte{0x0f}); err != nil {
+			return err
+		}
+		v, err := u.ReadHoldings(0, 2)
+		if err != nil {
+			return err
+		}
+		return u.WriteInputs(0, v)
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if got, _ := node.ReadInputs(0, 2); !reflect.DeepEqual(got, []uint16{1, 2}) {
+		t.Errorf("inputs = %v, want the holdings written in the update", got)
+	}
+
+	want := errors.New("abort")
+	err = node.Update(func(u *NodeUpdate) error {
+		_ = u.WriteHoldings(0, []uint16{7, 7, 7, 7})
+		_ = u.WriteDiscretes(0, 8, []byte{0xff})
+		_ = u.WriteCoils(0, 8, []byte{0x00})
+		return want
+	})
+	if err != want {
+		t.Errorf("Update() error = %v, want %v", err, want)
+	}
+	if got, _ := node.ReadHoldings(0, 4); !reflect.DeepEqual(got, []uint16{1, 2, 0, 0}) {
+		t.Errorf("holdings = %v, want rolled back", got)
+	}
+	if got, _ := node.ReadCoils(0, 8); got[0] != 0x0f {
+		t.Errorf("coils = %#x, want rolled back", got[0])
+	}
+	if got, _ := node.ReadDiscretes(0, 8); got[0] != 0x00 {
+		t.Errorf("discretes = %#x, want rolled back", got[0])
+	}
+
+	if err = node.Update(func(u *NodeUpdate) error {
+		return u.WriteHoldings(3, []uint16{1, 2})
+	}); !IsIllegalDataAddress(err) {
+		t.Errorf("Update() out of range error = %v, want illegal data address", err)
+	}
+}
+
+func TestNodeRegister_UpdateConsistent(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 4)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := uint16(0); ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// 两次写入组成一次应用更新
+			_ = node.Update(func(u *NodeUpdate) error {
+				if err := u.WriteHoldings(0, []uint16{i, i}); err != nil {
+					return err
+				}
+				return u.WriteHoldings(2, []uint16{i, i})
+			})
+		}
+	}()
+	for n := 0; n < 2000; n++ {
+		v, err := node.ReadHoldings(0, 4)
+		if err != nil {
+			t.Fatalf("ReadHoldings() error = %v", err)
+		}
+		if v[0] != v[3] {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("ReadHoldings() = %v, mixed values of two updates", v)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestNodeRegister_WriteReadHoldingsBytes(t *testing.T) {
+	node := NewNodeRegister(1, 0, 0, 0, 0, 0, 0, 0, 4)
+	got, err := node.WriteReadHoldingsBytes(1, 2, []byte{0x00, 0x01, 0x00, 0x02}, 0, 3)
+	if err != nil || !reflect.DeepEqual(got, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x02}) {
+		t.Errorf("WriteReadHoldingsBytes() = % x, %v", got, err)
+	}
+	if _, err = node.WriteReadHoldingsBytes(4, 1, []byte{0x00, 0x01}, 0, 1); !IsIllegalDataAddress(err) {
+		t.Errorf("WriteReadHoldingsBytes() out of range error = %v, want illegal data address", err)
+	}
+}
@@ -0,0 +1,134 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+)
+
+// ClientStats 客户端通信统计, 用于观察链路质量
+type ClientStats struct {
+	Transactions   uint64          // 发起的事务数, 每次重试计一次
+	Timeouts       uint64          // 超时的事务数
+	ChecksumErrors uint64          // crc 或 lrc 校验失败的事务数
+	Exceptions     map[byte]uint64 // 异常响应数, 按异常码
+	OtherErrors    uint64          // 其它失败的事务数
+	BytesOut       uint64          // 发送的字节数, 含帧头与校验
+	BytesIn        uint64          // 接收的字节数, 含帧头与校验
+}
+
+// clientStats the counters of the client
+type clientStats struct {
+	mu sync.Mutex
+	s  ClientStats
+}
+
+// record count the result of a transaction
+func (sf *clientStats) record(err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.s.Transactions++
+	if err == nil {
+		return
+	}
+	if e, ok := AsExceptionError(err); ok {
+		if sf.s.Exceptions == nil {
+			sf.s.Exceptions = make(map[byte]uint64)
+		}
+		sf.s.Exceptions[e.ExceptionCode]++
+		return
+	}
+	// the network timeout, the read timeout of the serial port and the deadline of the context
+	if isSerialTimeout(err) {
+		sf.s.Timeouts++
+		return
+	}
+	if _, ok := err.(*ChecksumError); ok {
+		sf.s.ChecksumErrors++
+		return
+	}
+	sf.s.OtherErrors++
+}
+
+// snapshot copy the counters
+func (sf *clientStats) snapshot() ClientStats {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	s := sf.s
+	s.Exceptions = make(map[byte]uint64, len(sf.s.Exceptions))
+	for k, v := range sf.s.Exceptions {
+		s.Exceptions[k] = v
+	}
+	return s
+}
+
+// reset clear the counters
+func (sf *clientStats) reset() {
+	sf.mu.Lock()
+	sf.s = ClientStats{}
+	sf.mu.Unlock()
+}
+
+// byteStats the bytes sent and received on the wire
+type byteStats struct {
+	mu      sync.Mutex
+	in, out uint64
+}
+
+func (sf *byteStats) add(in, out int) {
+	sf.mu.Lock()
+	sf.in += uint64(in)
+	sf.out += uint64(out)
+	sf.mu.Unlock()
+}
+
+// byteCounter the provider which count the bytes on the wire
+type byteCounter interface {
+	byteCount() (in, out uint64)
+	resetByteCount()
+}
+
+// byteCount the bytes received and sent
+func (sf *providerCommon) byteCount() (in, out uint64) {
+	sf.bytes.mu.Lock()
+	defer sf.bytes.mu.Unlock()
+	return sf.bytes.in, sf.bytes.out
+}
+
+// resetByteCount clear the bytes count
+func (sf *providerCommon) resetByteCount() {
+	sf.bytes.mu.Lock()
+	sf.bytes.in, sf.bytes.out = 0, 0
+	sf.bytes.mu.Unlock()
+}
+
+// statsDoer count every transaction sent by the provider
+func (sf *client) statsDoer(d Doer) Doer {
+	return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+		response, err := DoContext(ctx, d, slaveID, request)
+		sf.stats.record(err)
+		return response, err
+	})
+}
+
+// Stats return the communication counters since created or last reset,
+// the bytes are counted if the provider support it, such as the built-in ones.
+func (sf *client) Stats() ClientStats {
+	if sf.stats == nil {
+		return ClientStats{Exceptions: map[byte]uint64{}}
+	}
+	s := sf.stats.snapshot()
+	if p, ok := sf.ClientProvider.(byteCounter); ok {
+		s.BytesIn, s.BytesOut = p.byteCount()
+	}
+	return s
+}
+
+// ResetStats clear the communication counters
+func (sf *client) ResetStats() {
+	if sf.stats != nil {
+		sf.stats.reset()
+	}
+	if p, ok := sf.ClientProvider.(byteCounter); ok {
+		p.resetByteCount()
+	}
+}
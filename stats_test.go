@@ -0,0 +1,93 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/goburrow/serial"
+)
+
+func TestClient_Stats(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ClientStats
+	}{
+		{"ok", nil, ClientStats{Transactions: 2}},
+		{"timeout", ErrChaosTimeout, ClientStats{Transactions: 2, Timeouts: 1}},
+		{"serial timeout", serial.ErrTimeout, ClientStats{Transactions: 2, Timeouts: 1}},
+		{"context deadline", context.DeadlineExceeded, ClientStats{Transactions: 2, Timeouts: 1}},
+		{"checksum", &ChecksumError{"crc", 1, 2}, ClientStats{Transactions: 2, ChecksumErrors: 1}},
+		{"exception", &ExceptionError{ExceptionCode: ExceptionCodeServerDeviceBusy}, ClientStats{Transactions: 2,
+			Exceptions: map[byte]uint64{ExceptionCodeServerDeviceBusy: 1}}},
+		{"other", errors.New("other"), ClientStats{Transactions: 2, OtherErrors: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fails := 0
+			if tt.err != nil {
+				fails = 1
+			}
+			c := NewClient(&flakyProvider{provider: provider{err: tt.err}, fails: fails})
+			_ = c.WriteSingleRegister(1, 1, 2)
+			_ = c.WriteSingleRegister(1, 1, 2)
+			got := c.Stats()
+			if got.Transactions != tt.want.Transactions || got.Timeouts != tt.want.Timeouts ||
+				got.ChecksumErrors != tt.want.ChecksumErrors || got.OtherErrors != tt.want.OtherErrors ||
+				len(got.Exceptions) != len(tt.want.Exceptions) {
+				t.Fatalf("Stats() = %+v, want %+v", got, tt.want)
+			}
+			for k, v := range tt.want.Exceptions {
+				if got.Exceptions[k] != v {
+					t.Errorf("Stats().Exceptions[%v] = %v, want %v", k, got.Exceptions[k], v)
+				}
+			}
+			c.ResetStats()
+			if got = c.Stats(); got.Transactions != 0 || len(got.Exceptions) != 0 {
+				t.Errorf("Stats() after reset = %+v, want zero", got)
+			}
+		})
+	}
+}
+
+func TestClient_StatsRetry(t *testing.T) {
+	p := &flakyProvider{provider: provider{err: ErrChaosTimeout}, fails: 2}
+	c := NewClient(p, WithRetry(3, nil, nil))
+	if err := c.WriteSingleRegister(1, 1, 2); err != nil {
+		t.Fatalf("WriteSingleRegister() error = %v", err)
+	}
+	if got := c.Stats(); got.Transactions != 3 || got.Timeouts != 2 {
+		t.Errorf("Stats() = %+v, want 3 transactions 2 timeouts", got)
+	}
+}
+
+func TestClient_StatsSerialTimeout(t *testing.T) {
+	rtu := NewRTUClientProvider()
+	rtu.port = &chunkPort{}
+	ascii := NewASCIIClientProvider()
+	ascii.port = &chunkPort{}
+	for _, p := range []ClientProvider{rtu, ascii} {
+		c := NewClient(p)
+		if _, err := c.ReadHoldingRegisters(1, 0, 1); err != serial.ErrTimeout {
+			t.Fatalf("%T ReadHoldingRegisters() error = %v, want serial timeout", p, err)
+		}
+		if got := c.Stats(); got.Timeouts != 1 || got.OtherErrors != 0 {
+			t.Errorf("%T Stats() = %+v, want 1 timeout", p, got)
+		}
+	}
+}
+
+func TestProviderCommon_byteCount(t *testing.T) {
+	var p providerCommon
+	p.tapSend(make([]byte, 8))
+	p.tapReceived(make([]byte, 7))
+	p.tapReceived(make([]byte, 5))
+	if in, out := p.byteCount(); in != 12 || out != 8 {
+		t.Errorf("byteCount() = %v, %v, want 12, 8", in, out)
+	}
+	p.resetByteCount()
+	if in, out := p.byteCount(); in != 0 || out != 0 {
+		t.Errorf("byteCount() after reset = %v, %v, want 0, 0", in, out)
+	}
+}
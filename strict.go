@@ -0,0 +1,230 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// WithStrict enable the strict protocol validation, every request is checked
+// against the quantity limits and every response is checked rigorously
+// for the byte count, the quantity and the echoed fields, the violation is
+// returned as *QuantityError, *ByteCountError, *EchoError or *PaddingError.
+// the raw pdu sent by SendPdu is validated too.
+func WithStrict() ClientOption {
+	return func(c *client) {
+		c.strict = true
+	}
+}
+
+// QuantityError the quantity is out of the limit of the function
+type QuantityError struct {
+	FuncCode byte
+	Quantity int
+	Min, Max int
+}
+
+// Error implements error interface.
+func (e *QuantityError) Error() string {
+	return fmt.Sprintf("modbus: function '%v' quantity '%v' must be between '%v' and '%v'",
+		e.FuncCode, e.Quantity, e.Min, e.Max)
+}
+
+// ByteCountError the byte count or the length of the pdu does not match
+type ByteCountError struct {
+	FuncCode byte
+	Response bool // 响应还是请求
+	Count    int
+	Want     int
+}
+
+// Error implements error interface.
+func (e *ByteCountError) Error() string {
+	dir := "request"
+	if e.Response {
+		dir = "response"
+	}
+	return fmt.Sprintf("modbus: function '%v' %v byte count '%v' does not match expected '%v'",
+		e.FuncCode, dir, e.Count, e.Want)
+}
+
+// EchoError the field echoed by the write response does not match the request
+type EchoError struct {
+	FuncCode byte
+	Field    string // address, value, quantity, and-mask or or-mask
+	Got      uint16
+	Want     uint16
+}
+
+// Error implements error interface.
+func (e *EchoError) Error() string {
+	return fmt.Sprintf("modbus: function '%v' response %v '%v' does not match request '%v'",
+		e.FuncCode, e.Field, e.Got, e.Want)
+}
+
+// PaddingError the unused bits of the last byte of the bits response are not zero
+type PaddingError struct {
+	FuncCode byte
+	Quantity uint16
+	Last     byte
+}
+
+// Error implements error interface.
+func (e *PaddingError) Error() string {
+	return fmt.Sprintf("modbus: function '%v' response unused bits of last byte '%#02x' must be zero for quantity '%v'",
+		e.FuncCode, e.Last, e.Quantity)
+}
+
+// checkQuantity check the quantity is in [min,max]
+func checkQuantity(funcCode byte, quantity uint16, min, max int) error {
+	if int(quantity) < min || int(quantity) > max {
+		return &QuantityError{funcCode, int(quantity), min, max}
+	}
+	return nil
+}
+
+// checkLength check the length of the pdu data
+func checkLength(funcCode byte, response bool, data []byte, want int) error {
+	if len(data) != want {
+		return &ByteCountError{funcCode, response, len(data), want}
+	}
+	return nil
+}
+
+// checkByteCount check the leading byte count match the rest of data and want
+func checkByteCount(funcCode byte, response bool, data []byte, want int) error {
+	if len(data) == 0 {
+		return &ByteCountError{funcCode, response, 0, want + 1}
+	}
+	if int(data[0]) != len(data)-1 {
+		return &ByteCountError{funcCode, response, int(data[0]), len(data) - 1}
+	}
+	if int(data[0]) != want {
+		return &ByteCountError{funcCode, response, int(data[0]), want}
+	}
+	return nil
+}
+
+// checkEcho check the echoed fields of the write response
+func checkEcho(funcCode byte, request, response []byte, fields ...string) error {
+	if err := checkLength(funcCode, true, response, len(fields)*2); err != nil {
+		return err
+	}
+	for i, field := range fields {
+		got, want := binary.BigEndian.Uint16(response[i*2:]), binary.BigEndian.Uint16(request[i*2:])
+		if got != want {
+			return &EchoError{funcCode, field, got, want}
+		}
+	}
+	return nil
+}
+
+// validateRequest validate the request pdu in strict mode,
+// the function codes not known are not checked.
+func validateRequest(request ProtocolDataUnit) error {
+	fc, data := request.FuncCode, request.Data
+	switch fc {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		if err := checkLength(fc, false, data, 4); err != nil {
+			return err
+		}
+		return checkQuantity(fc, binary.BigEndian.Uint16(data[2:]), ReadBitsQuantityMin, ReadBitsQuantityMax)
+	case FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters:
+		if err := checkLength(fc, false, data, 4); err != nil {
+			return err
+		}
+		return checkQuantity(fc, binary.BigEndian.Uint16(data[2:]), ReadRegQuantityMin, ReadRegQuantityMax)
+	case FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister:
+		return checkLength(fc, false, data, 4)
+	case FuncCodeMaskWriteRegister:
+		return checkLength(fc, false, data, 6)
+	case FuncCodeReadFIFOQueue:
+		return checkLength(fc, false, data, 2)
+	case FuncCodeWriteMultipleCoils:
+		if len(data) < 5 {
+			return &ByteCountError{fc, false, len(data), 5}
+		}
+		quantity := binary.BigEndian.Uint16(data[2:])
+		if err := checkQuantity(fc, quantity, WriteBitsQuantityMin, WriteBitsQuantityMax); err != nil {
+			return err
+		}
+		return checkByteCount(fc, false, data[4:], int(quantity+7)/8)
+	case FuncCodeWriteMultipleRegisters:
+		if len(data) < 5 {
+			return &ByteCountError{fc, false, len(data), 5}
+		}
+		quantity := binary.BigEndian.Uint16(data[2:])
+		if err := checkQuantity(fc, quantity, WriteRegQuantityMin, WriteRegQuantityMax); err != nil {
+			return err
+		}
+		return checkByteCount(fc, false, data[4:], int(quantity)*2)
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(data) < 9 {
+			return &ByteCountError{fc, false, len(data), 9}
+		}
+		readQuantity, writeQuantity := binary.BigEndian.Uint16(data[2:]), binary.BigEndian.Uint16(data[6:])
+		if err := checkQuantity(fc, readQuantity, ReadWriteOnReadRegQuantityMin, ReadWriteOnReadRegQuantityMax); err != nil {
+			return err
+		}
+		if err := checkQuantity(fc, writeQuantity, ReadWriteOnWriteRegQuantityMin, ReadWriteOnWriteRegQuantityMax); err != nil {
+			return err
+		}
+		return checkByteCount(fc, false, data[8:], int(writeQuantity)*2)
+	}
+	return nil
+}
+
+// validateResponse validate the response pdu against the request in strict mode,
+// the function codes not known are not checked.
+func validateResponse(request, response ProtocolDataUnit) error {
+	fc, data := request.FuncCode, response.Data
+	switch fc {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs:
+		quantity := binary.BigEndian.Uint16(request.Data[2:])
+		if err := checkByteCount(fc, true, data, int(quantity+7)/8); err != nil {
+			return err
+		}
+		if rem := quantity % 8; rem != 0 {
+			if last := data[len(data)-1]; last>>rem != 0 {
+				return &PaddingError{fc, quantity, last}
+			}
+		}
+	case FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters:
+		return checkByteCount(fc, true, data, int(binary.BigEndian.Uint16(request.Data[2:]))*2)
+	case FuncCodeReadWriteMultipleRegisters:
+		return checkByteCount(fc, true, data, int(binary.BigEndian.Uint16(request.Data[2:]))*2)
+	case FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister:
+		return checkEcho(fc, request.Data, data, "address", "value")
+	case FuncCodeWriteMultipleCoils, FuncCodeWriteMultipleRegisters:
+		return checkEcho(fc, request.Data, data, "address", "quantity")
+	case FuncCodeMaskWriteRegister:
+		return checkEcho(fc, request.Data, data, "address", "and-mask", "or-mask")
+	case FuncCodeReadFIFOQueue:
+		if len(data) < 4 {
+			return &ByteCountError{fc, true, len(data), 4}
+		}
+		byteCount, fifoCount := int(binary.BigEndian.Uint16(data)), int(binary.BigEndian.Uint16(data[2:]))
+		if byteCount != len(data)-2 {
+			return &ByteCountError{fc, true, byteCount, len(data) - 2}
+		}
+		if fifoCount > 31 {
+			return &QuantityError{fc, fifoCount, 0, 31}
+		}
+		if byteCount != fifoCount*2+2 {
+			return &ByteCountError{fc, true, byteCount, fifoCount*2 + 2}
+		}
+	}
+	return nil
+}
+
+// HeaderError the identifier in the response header does not match the request,
+// it is returned by the providers whether strict or not.
+type HeaderError struct {
+	Field string // transaction id, protocol id, unit id or slave id
+	Got   uint16
+	Want  uint16
+}
+
+// Error implements error interface.
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("modbus: response %v '%v' does not match request '%v'", e.Field, e.Got, e.Want)
+}
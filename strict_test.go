@@ -0,0 +1,116 @@
+package modbus
+
+import (
+	"testing"
+)
+
+// pduProvider reply the fixed response pdu
+type pduProvider struct {
+	provider
+	response ProtocolDataUnit
+}
+
+func (sf *pduProvider) Send(_ byte, _ ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.response, nil
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ProtocolDataUnit
+		want    interface{}
+	}{
+		{"read coils", ProtocolDataUnit{FuncCodeReadCoils, []byte{0x00, 0x00, 0x00, 0x08}}, nil},
+		{"read coils quantity", ProtocolDataUnit{FuncCodeReadCoils, []byte{0x00, 0x00, 0x07, 0xd1}}, &QuantityError{}},
+		{"read holding length", ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00}}, &ByteCountError{}},
+		{"read holding quantity", ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x00}}, &QuantityError{}},
+		{"write coils", ProtocolDataUnit{FuncCodeWriteMultipleCoils, []byte{0x00, 0x00, 0x00, 0x0a, 0x02, 0xff, 0x03}}, nil},
+		{"write coils byte count", ProtocolDataUnit{FuncCodeWriteMultipleCoils, []byte{0x00, 0x00, 0x00, 0x0a, 0x01, 0xff}}, &ByteCountError{}},
+		{"write registers", ProtocolDataUnit{FuncCodeWriteMultipleRegisters, []byte{0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x01}}, nil},
+		{"write registers value", ProtocolDataUnit{FuncCodeWriteMultipleRegisters, []byte{0x00, 0x00, 0x00, 0x02, 0x02, 0x00, 0x01}}, &ByteCountError{}},
+		{"read write quantity", ProtocolDataUnit{FuncCodeReadWriteMultipleRegisters, []byte{0x00, 0x00, 0x00, 0x7e, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x01}}, &QuantityError{}},
+		{"unknown", ProtocolDataUnit{0x41, nil}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrorType(t, validateRequest(tt.request), tt.want)
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	readCoils := ProtocolDataUnit{FuncCodeReadCoils, []byte{0x00, 0x00, 0x00, 0x0a}}
+	readHolding := ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x00, 0x00, 0x00, 0x02}}
+	writeSingle := ProtocolDataUnit{FuncCodeWriteSingleRegister, []byte{0x00, 0x01, 0x12, 0x34}}
+	fifo := ProtocolDataUnit{FuncCodeReadFIFOQueue, []byte{0x00, 0x01}}
+	tests := []struct {
+		name     string
+		request  ProtocolDataUnit
+		response []byte
+		want     interface{}
+	}{
+		{"read coils", readCoils, []byte{0x02, 0xff, 0x03}, nil},
+		{"read coils empty", readCoils, []byte{}, &ByteCountError{}},
+		{"read coils count", readCoils, []byte{0x01, 0xff}, &ByteCountError{}},
+		{"read coils padding", readCoils, []byte{0x02, 0xff, 0x07}, &PaddingError{}},
+		{"read holding", readHolding, []byte{0x04, 0x00, 0x01, 0x00, 0x02}, nil},
+		{"read holding count mismatch", readHolding, []byte{0x04, 0x00, 0x01}, &ByteCountError{}},
+		{"read holding quantity", readHolding, []byte{0x02, 0x00, 0x01}, &ByteCountError{}},
+		{"write single", writeSingle, []byte{0x00, 0x01, 0x12, 0x34}, nil},
+		{"write single address", writeSingle, []byte{0x00, 0x02, 0x12, 0x34}, &EchoError{}},
+		{"write single value", writeSingle, []byte{0x00, 0x01, 0x12, 0x35}, &EchoError{}},
+		{"write single length", writeSingle, []byte{0x00, 0x01}, &ByteCountError{}},
+		{"fifo", fifo, []byte{0x00, 0x06, 0x00, 0x02, 0x00, 0x01, 0x00, 0x02}, nil},
+		{"fifo count", fifo, []byte{0x00, 0x06, 0x00, 0x01, 0x00, 0x01, 0x00, 0x02}, &ByteCountError{}},
+		{"fifo too many", fifo, []byte{0x00, 0x02, 0x00, 0x20}, &QuantityError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErrorType(t, validateResponse(tt.request, ProtocolDataUnit{tt.request.FuncCode, tt.response}), tt.want)
+		})
+	}
+}
+
+func checkErrorType(t *testing.T, err error, want interface{}) {
+	t.Helper()
+	var ok bool
+	switch want.(type) {
+	case nil:
+		ok = err == nil
+	case *QuantityError:
+		_, ok = err.(*QuantityError)
+	case *ByteCountError:
+		_, ok = err.(*ByteCountError)
+	case *EchoError:
+		_, ok = err.(*EchoError)
+	case *PaddingError:
+		_, ok = err.(*PaddingError)
+	}
+	if !ok {
+		t.Errorf("error = %v, want %T", err, want)
+	}
+}
+
+func TestWithStrict(t *testing.T) {
+	// the padding bits slip through without strict mode
+	p := &pduProvider{response: ProtocolDataUnit{FuncCodeReadCoils, []byte{0x01, 0xff}}}
+	if _, err := NewClient(p).ReadCoils(1, 0, 4); err != nil {
+		t.Errorf("ReadCoils() error = %v, want nil", err)
+	}
+	if _, err := NewClient(p, WithStrict()).ReadCoils(1, 0, 4); err == nil {
+		t.Errorf("ReadCoils() strict error = nil, want *PaddingError")
+	} else if _, ok := err.(*PaddingError); !ok {
+		t.Errorf("ReadCoils() strict error = %v, want *PaddingError", err)
+	}
+
+	p = &pduProvider{response: ProtocolDataUnit{FuncCodeReadWriteMultipleRegisters, []byte{0x02, 0x00, 0x01}}}
+	if _, err := NewClient(p, WithStrict()).ReadWriteMultipleRegisters(1, 0, 2, 0, 1, []byte{0x00, 0x01}); err == nil {
+		t.Errorf("ReadWriteMultipleRegisters() strict error = nil, want *ByteCountError")
+	}
+
+	// raw pdu is validated too
+	p = &pduProvider{response: ProtocolDataUnit{FuncCodeReadHoldingRegisters, []byte{0x02, 0x00, 0x01}}}
+	if _, err := NewClient(p, WithStrict()).SendPdu(1, []byte{FuncCodeReadHoldingRegisters, 0x00, 0x00, 0x00, 0x00}); err == nil {
+		t.Errorf("SendPdu() strict error = nil, want *QuantityError")
+	}
+}
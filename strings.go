@@ -0,0 +1,57 @@
+package modbus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// decodeString decode the text stored in the register bytes,
+// swap the bytes in every register if swap, the trailing NUL and space padding are trimmed.
+func decodeString(b []byte, swap bool) string {
+	if swap {
+		b = append([]byte(nil), b...)
+		swapBytes(b)
+	}
+	return strings.TrimRight(string(b), "\x00 ")
+}
+
+// encodeString encode the text into quantity registers bytes,
+// padding with NUL, swap the bytes in every register if swap.
+func encodeString(s string, quantity uint16, swap bool) ([]byte, error) {
+	if len(s) > int(quantity)*2 {
+		return nil, fmt.Errorf("modbus: string length '%v' exceed '%v' registers", len(s), quantity)
+	}
+	b := make([]byte, int(quantity)*2)
+	copy(b, s)
+	if swap {
+		swapBytes(b)
+	}
+	return b, nil
+}
+
+// swapBytes swap the bytes in every register
+func swapBytes(b []byte) {
+	for i := 0; i+1 < len(b); i += 2 {
+		b[i], b[i+1] = b[i+1], b[i]
+	}
+}
+
+// ReadString read the text stored in quantity holding registers,
+// swap the bytes in every register if swap, the trailing NUL and space padding are trimmed.
+func (sf *client) ReadString(slaveID byte, address, quantity uint16, swap bool) (string, error) {
+	b, err := sf.ReadHoldingRegistersBytes(slaveID, address, quantity)
+	if err != nil {
+		return "", err
+	}
+	return decodeString(b, swap), nil
+}
+
+// WriteString write the text into quantity holding registers padding with NUL,
+// swap the bytes in every register if swap.
+func (sf *client) WriteString(slaveID byte, address, quantity uint16, s string, swap bool) error {
+	b, err := encodeString(s, quantity, swap)
+	if err != nil {
+		return err
+	}
+	return sf.WriteMultipleRegisters(slaveID, address, quantity, b)
+}
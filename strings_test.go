@@ -0,0 +1,58 @@
+package modbus
+
+import (
+	"net"
+	"testing"
+)
+
+func Test_String(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10)
+	mbSrv.AddNodes(node)
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	tests := []struct {
+		name string
+		swap bool
+		want []uint16
+	}{
+		{"not swapped", false, []uint16{0x7631, 0x2e32, 0x0000}},
+		{"swapped", true, []uint16{0x3176, 0x322e, 0x0000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := mbCli.WriteString(testslaveID1, 0, 3, "v1.2", tt.swap); err != nil {
+				t.Fatalf("WriteString() error = %v", err)
+			}
+			regs, _ := node.ReadHoldings(0, 3)
+			for i := range tt.want {
+				if regs[i] != tt.want[i] {
+					t.Fatalf("holding = %#x, want %#x", regs, tt.want)
+				}
+			}
+			if s, err := mbCli.ReadString(testslaveID1, 0, 3, tt.swap); err != nil || s != "v1.2" {
+				t.Errorf("ReadString() = %q, %v, want v1.2", s, err)
+			}
+			if s, err := node.ReadString(0, 3, tt.swap); err != nil || s != "v1.2" {
+				t.Errorf("NodeRegister.ReadString() = %q, %v, want v1.2", s, err)
+			}
+		})
+	}
+	if err = node.WriteString(5, 2, "name", false); err != nil {
+		t.Errorf("NodeRegister.WriteString() error = %v", err)
+	}
+	if err = mbCli.WriteString(testslaveID1, 0, 1, "too long", false); err == nil {
+		t.Errorf("WriteString() too long error = nil, want error")
+	}
+}
@@ -0,0 +1,210 @@
+package sunspec
+
+import (
+	"math"
+	"strings"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// the standard model ids
+const (
+	ModelCommon              = 1
+	ModelInverterSinglePhase = 101
+	ModelInverterSplitPhase  = 102
+	ModelInverterThreePhase  = 103
+	ModelMeterSinglePhase    = 201
+	ModelMeterSplitPhase     = 202
+	ModelMeterWye            = 203
+	ModelMeterDelta          = 204
+)
+
+// Common the common model(1), identify the device
+type Common struct {
+	Manufacturer string
+	Model        string
+	Options      string
+	Version      string
+	SerialNumber string
+	DeviceAddr   uint16
+}
+
+// ReadCommon read and decode the common model
+func ReadCommon(c modbus.RegisterReader, slaveID byte, m Model) (*Common, error) {
+	r, err := readModel(c, slaveID, m, 65, ModelCommon)
+	if err != nil {
+		return nil, err
+	}
+	return &Common{
+		Manufacturer: str(r[0:16]),
+		Model:        str(r[16:32]),
+		Options:      str(r[32:40]),
+		Version:      str(r[40:48]),
+		SerialNumber: str(r[48:64]),
+		DeviceAddr:   r[64],
+	}, nil
+}
+
+// Inverter the inverter models(101, 102, 103), the values are scaled to the units,
+// the value not implemented by the device is NaN, such as the phase B and C of a single phase inverter.
+type Inverter struct {
+	A, AphA, AphB, AphC            float64 // current, A
+	PPVphAB, PPVphBC, PPVphCA      float64 // phase to phase voltage, V
+	PhVphA, PhVphB, PhVphC         float64 // phase to neutral voltage, V
+	W                              float64 // AC power, W
+	Hz                             float64 // line frequency, Hz
+	VA                             float64 // apparent power, VA
+	VAr                            float64 // reactive power, var
+	PF                             float64 // power factor, %
+	WH                             float64 // lifetime energy production, Wh
+	DCA, DCV, DCW                  float64 // DC current(A), voltage(V) and power(W)
+	TmpCab, TmpSnk, TmpTrns, TmpOt float64 // temperatures, C
+	St                             uint16  // operating state
+	StVnd                          uint16  // vendor operating state
+	Evt1                           uint32  // event flags
+}
+
+// ReadInverter read and decode an inverter model
+func ReadInverter(c modbus.RegisterReader, slaveID byte, m Model) (*Inverter, error) {
+	r, err := readModel(c, slaveID, m, 40,
+		ModelInverterSinglePhase, ModelInverterSplitPhase, ModelInverterThreePhase)
+	if err != nil {
+		return nil, err
+	}
+	aSF, vSF, tmpSF := r[4], r[11], r[35]
+	return &Inverter{
+		A:       uint16v(r[0], aSF),
+		AphA:    uint16v(r[1], aSF),
+		AphB:    uint16v(r[2], aSF),
+		AphC:    uint16v(r[3], aSF),
+		PPVphAB: uint16v(r[5], vSF),
+		PPVphBC: uint16v(r[6], vSF),
+		PPVphCA: uint16v(r[7], vSF),
+		PhVphA:  uint16v(r[8], vSF),
+		PhVphB:  uint16v(r[9], vSF),
+		PhVphC:  uint16v(r[10], vSF),
+		W:       int16v(r[12], r[13]),
+		Hz:      uint16v(r[14], r[15]),
+		VA:      int16v(r[16], r[17]),
+		VAr:     int16v(r[18], r[19]),
+		PF:      int16v(r[20], r[21]),
+		WH:      acc32v(r[22], r[23], r[24]),
+		DCA:     uint16v(r[25], r[26]),
+		DCV:     uint16v(r[27], r[28]),
+		DCW:     int16v(r[29], r[30]),
+		TmpCab:  int16v(r[31], tmpSF),
+		TmpSnk:  int16v(r[32], tmpSF),
+		TmpTrns: int16v(r[33], tmpSF),
+		TmpOt:   int16v(r[34], tmpSF),
+		St:      r[36],
+		StVnd:   r[37],
+		Evt1:    uint32(r[38])<<16 | uint32(r[39]),
+	}, nil
+}
+
+// Meter the meter models(201, 202, 203, 204), the values are scaled to the units,
+// the value not implemented by the device is NaN.
+type Meter struct {
+	A, AphA, AphB, AphC            float64 // current, A
+	PhV, PhVphA, PhVphB, PhVphC    float64 // line to neutral voltage, V
+	PPV, PPVphAB, PPVphBC, PPVphCA float64 // line to line voltage, V
+	Hz                             float64 // frequency, Hz
+	W, WphA, WphB, WphC            float64 // real power, W
+	VA, VAphA, VAphB, VAphC        float64 // apparent power, VA
+	VAR, VARphA, VARphB, VARphC    float64 // reactive power, var
+	PF, PFphA, PFphB, PFphC        float64 // power factor, %
+	TotWhExp                       float64 // total real energy exported, Wh
+	TotWhImp                       float64 // total real energy imported, Wh
+}
+
+// ReadMeter read and decode a meter model
+func ReadMeter(c modbus.RegisterReader, slaveID byte, m Model) (*Meter, error) {
+	r, err := readModel(c, slaveID, m, 53,
+		ModelMeterSinglePhase, ModelMeterSplitPhase, ModelMeterWye, ModelMeterDelta)
+	if err != nil {
+		return nil, err
+	}
+	aSF, vSF, wSF, vaSF, varSF, pfSF, whSF := r[4], r[13], r[20], r[25], r[30], r[35], r[52]
+	return &Meter{
+		A:        int16v(r[0], aSF),
+		AphA:     int16v(r[1], aSF),
+		AphB:     int16v(r[2], aSF),
+		AphC:     int16v(r[3], aSF),
+		PhV:      int16v(r[5], vSF),
+		PhVphA:   int16v(r[6], vSF),
+		PhVphB:   int16v(r[7], vSF),
+		PhVphC:   int16v(r[8], vSF),
+		PPV:      int16v(r[9], vSF),
+		PPVphAB:  int16v(r[10], vSF),
+		PPVphBC:  int16v(r[11], vSF),
+		PPVphCA:  int16v(r[12], vSF),
+		Hz:       int16v(r[14], r[15]),
+		W:        int16v(r[16], wSF),
+		WphA:     int16v(r[17], wSF),
+		WphB:     int16v(r[18], wSF),
+		WphC:     int16v(r[19], wSF),
+		VA:       int16v(r[21], vaSF),
+		VAphA:    int16v(r[22], vaSF),
+		VAphB:    int16v(r[23], vaSF),
+		VAphC:    int16v(r[24], vaSF),
+		VAR:      int16v(r[26], varSF),
+		VARphA:   int16v(r[27], varSF),
+		VARphB:   int16v(r[28], varSF),
+		VARphC:   int16v(r[29], varSF),
+		PF:       int16v(r[31], pfSF),
+		PFphA:    int16v(r[32], pfSF),
+		PFphB:    int16v(r[33], pfSF),
+		PFphC:    int16v(r[34], pfSF),
+		TotWhExp: acc32v(r[36], r[37], whSF),
+		TotWhImp: acc32v(r[44], r[45], whSF),
+	}, nil
+}
+
+// the values of the point not implemented
+const (
+	notImplInt16  = 0x8000
+	notImplUint16 = 0xffff
+	notImplSF     = 0x8000
+)
+
+// scale v*10^sf, NaN if the scale factor not implemented
+func scale(v float64, sf uint16) float64 {
+	if sf == notImplSF {
+		return math.NaN()
+	}
+	return v * math.Pow10(int(int16(sf)))
+}
+
+// int16v the scaled int16 point
+func int16v(v, sf uint16) float64 {
+	if v == notImplInt16 {
+		return math.NaN()
+	}
+	return scale(float64(int16(v)), sf)
+}
+
+// uint16v the scaled uint16 point
+func uint16v(v, sf uint16) float64 {
+	if v == notImplUint16 {
+		return math.NaN()
+	}
+	return scale(float64(v), sf)
+}
+
+// acc32v the scaled acc32 point, an accumulator of 0 is not implemented
+func acc32v(hi, lo, sf uint16) float64 {
+	v := uint32(hi)<<16 | uint32(lo)
+	if v == 0 {
+		return math.NaN()
+	}
+	return scale(float64(v), sf)
+}
+
+// str the string point, padded with NUL
+func str(regs []uint16) string {
+	b := make([]byte, 0, len(regs)*2)
+	for _, r := range regs {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return strings.TrimRight(string(b), "\x00 ")
+}
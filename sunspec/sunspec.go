@@ -0,0 +1,114 @@
+// Package sunspec read the SunSpec devices, such as the solar inverters and meters,
+// on top of the modbus client: discover the base address by the "SunS" marker,
+// walk the model headers and decode the standard models into structs.
+//
+//	base, err := sunspec.Discover(client, slaveID)
+//	models, err := sunspec.Models(client, slaveID, base)
+//	for _, m := range models {
+//		if m.ID == sunspec.ModelInverterThreePhase {
+//			inv, err := sunspec.ReadInverter(client, slaveID, m)
+//		}
+//	}
+package sunspec
+
+import (
+	"errors"
+	"fmt"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// Marker the "SunS" marker at the base address
+const Marker uint32 = 0x53756e53
+
+// endModelID the model id of the end model
+const endModelID = 0xffff
+
+// BaseAddresses the base addresses probed by Discover in order, as the specification
+var BaseAddresses = []uint16{40000, 0, 50000}
+
+// ErrNotSunSpec no SunSpec marker at the base addresses
+var ErrNotSunSpec = errors.New("sunspec: marker not found")
+
+// Model the header of a model, Address is the register address of the model id
+type Model struct {
+	ID      uint16
+	Address uint16
+	Length  uint16 // the registers of the model, not including the 2 registers of the header
+}
+
+// Discover probe BaseAddresses for the "SunS" marker, return the base address,
+// the probe errors are ignored since the device respond exception at the address not used.
+func Discover(c modbus.RegisterReader, slaveID byte) (uint16, error) {
+	for _, base := range BaseAddresses {
+		regs, err := c.ReadHoldingRegisters(slaveID, base, 2)
+		if err == nil && len(regs) == 2 && uint32(regs[0])<<16|uint32(regs[1]) == Marker {
+			return base, nil
+		}
+	}
+	return 0, ErrNotSunSpec
+}
+
+// Models walk the model headers following the marker at base until the end model
+func Models(c modbus.RegisterReader, slaveID byte, base uint16) ([]Model, error) {
+	var models []Model
+
+	address := uint32(base) + 2
+	for address+1 <= 0xffff {
+		regs, err := c.ReadHoldingRegisters(slaveID, uint16(address), 2)
+		if err != nil {
+			return models, err
+		}
+		if regs[0] == endModelID {
+			return models, nil
+		}
+		models = append(models, Model{regs[0], uint16(address), regs[1]})
+		address += 2 + uint32(regs[1])
+	}
+	return models, errors.New("sunspec: end model not found")
+}
+
+// Find the first model of the id, ok false when not found
+func Find(models []Model, id uint16) (m Model, ok bool) {
+	for _, m = range models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// ReadModel read the registers of the model body, in chunks of the maximum read quantity
+func ReadModel(c modbus.RegisterReader, slaveID byte, m Model) ([]uint16, error) {
+	regs := make([]uint16, 0, m.Length)
+	address := m.Address + 2
+	for remain := m.Length; remain > 0; {
+		count := remain
+		if count > modbus.ReadRegQuantityMax {
+			count = modbus.ReadRegQuantityMax
+		}
+		b, err := c.ReadHoldingRegisters(slaveID, address, count)
+		if err != nil {
+			return nil, err
+		}
+		regs = append(regs, b...)
+		address += count
+		remain -= count
+	}
+	return regs, nil
+}
+
+// readModel read the model body of the ids, the body must have at least length registers
+func readModel(c modbus.RegisterReader, slaveID byte, m Model, length uint16, ids ...uint16) ([]uint16, error) {
+	found := false
+	for _, id := range ids {
+		found = found || m.ID == id
+	}
+	if !found {
+		return nil, fmt.Errorf("sunspec: unexpected model %d", m.ID)
+	}
+	if m.Length < length {
+		return nil, fmt.Errorf("sunspec: model %d length %d less than %d", m.ID, m.Length, length)
+	}
+	return ReadModel(c, slaveID, m)
+}
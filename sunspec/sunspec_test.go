@@ -0,0 +1,103 @@
+package sunspec
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	modbus "github.com/aloncn/gomodbus"
+)
+
+// device a SunSpec device with the common, three phase inverter and wye meter models at base
+func device(t *testing.T, base uint16) modbus.Client {
+	regs := []uint16{0x5375, 0x6e53}
+	common := make([]uint16, 66)
+	copy(common, []uint16{0x4163, 0x6d65}) // "Acme"
+	copy(common[48:], []uint16{0x534e, 0x3100})
+	common[64] = 1
+	regs = append(regs, ModelCommon, 66)
+	regs = append(regs, common...)
+
+	inv := make([]uint16, 50)
+	inv[0], inv[1], inv[2], inv[3], inv[4] = 123, 41, 41, 41, 0xffff // A_SF -1
+	inv[8], inv[9], inv[10], inv[11] = 2301, 2302, notImplUint16, 0xffff
+	inv[12], inv[13] = 0xfc18, 0 // W -1000
+	inv[14], inv[15] = 5001, 0xfffe
+	inv[22], inv[23], inv[24] = 0x0001, 0x0000, 3 // WH 65536e3
+	inv[35] = notImplSF
+	inv[36] = 4
+	regs = append(regs, ModelInverterThreePhase, 50)
+	regs = append(regs, inv...)
+
+	meter := make([]uint16, 105)
+	meter[16], meter[20] = 1500, 1
+	meter[44], meter[45], meter[52] = 0, 42, 0
+	regs = append(regs, ModelMeterWye, 105)
+	regs = append(regs, meter...)
+	regs = append(regs, endModelID, 0)
+
+	node := modbus.NewNodeRegister(1, 0, 0, 0, 0, 0, 0, base, uint16(len(regs)))
+	if err := node.WriteHoldings(base, regs); err != nil {
+		t.Fatal(err)
+	}
+	c := modbus.NewClient(modbus.NewLoopbackProvider(node))
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSunSpec(t *testing.T) {
+	c := device(t, 50000)
+	defer c.Close()
+
+	base, err := Discover(c, 1)
+	if err != nil || base != 50000 {
+		t.Fatalf("Discover() = %v, %v, want 50000", base, err)
+	}
+	models, err := Models(c, 1, base)
+	want := []Model{{ModelCommon, 50002, 66}, {ModelInverterThreePhase, 50070, 50}, {ModelMeterWye, 50122, 105}}
+	if err != nil || !reflect.DeepEqual(models, want) {
+		t.Fatalf("Models() = %v, %v, want %v", models, err, want)
+	}
+
+	common, err := ReadCommon(c, 1, models[0])
+	if err != nil || common.Manufacturer != "Acme" || common.SerialNumber != "SN1" || common.DeviceAddr != 1 {
+		t.Errorf("ReadCommon() = %+v, %v", common, err)
+	}
+
+	m, _ := Find(models, ModelInverterThreePhase)
+	inv, err := ReadInverter(c, 1, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"A", inv.A, 12.3},
+		{"PhVphA", inv.PhVphA, 230.1},
+		{"W", inv.W, -1000},
+		{"Hz", inv.Hz, 50.01},
+		{"WH", inv.WH, 65536e3},
+	} {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("Inverter.%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if !math.IsNaN(inv.PhVphC) || !math.IsNaN(inv.TmpCab) || inv.St != 4 {
+		t.Errorf("Inverter = %+v, want not implemented NaN", inv)
+	}
+	if _, err = ReadInverter(c, 1, models[0]); err == nil {
+		t.Errorf("ReadInverter() common model, want error")
+	}
+
+	meter, err := ReadMeter(c, 1, models[2])
+	if err != nil || meter.W != 15000 || meter.TotWhImp != 42 || !math.IsNaN(meter.TotWhExp) {
+		t.Errorf("ReadMeter() = %+v, %v", meter, err)
+	}
+
+	if _, err = Discover(c, 2); err != ErrNotSunSpec {
+		t.Errorf("Discover() unknown slave error = %v, want ErrNotSunSpec", err)
+	}
+}
@@ -0,0 +1,479 @@
+// Package tags named points on top of the modbus tables,
+// a point defines where and how a value is stored (slave, table, address, type,
+// scale, offset, byte order), the client or the mb poller read and write them
+// by name in engineering units.
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+)
+
+// Type the data type of the point
+type Type byte
+
+// data type
+const (
+	Bool    Type = iota // a coil or discrete input
+	Int16               // 1 register
+	Uint16              // 1 register
+	Int32               // 2 registers
+	Uint32              // 2 registers
+	Float32             // 2 registers
+	Int64               // 4 registers
+	Uint64              // 4 registers
+	Float64             // 4 registers
+)
+
+// String implement fmt.Stringer
+func (t Type) String() string {
+	switch t {
+	case Bool:
+		return "bool"
+	case Int16:
+		return "int16"
+	case Uint16:
+		return "uint16"
+	case Int32:
+		return "int32"
+	case Uint32:
+		return "uint32"
+	case Float32:
+		return "float32"
+	case Int64:
+		return "int64"
+	case Uint64:
+		return "uint64"
+	case Float64:
+		return "float64"
+	}
+	return "unknown"
+}
+
+// Quantity the quantity of the bits or registers of the type
+func (t Type) Quantity() uint16 {
+	switch t {
+	case Int32, Uint32, Float32:
+		return 2
+	case Int64, Uint64, Float64:
+		return 4
+	}
+	return 1
+}
+
+// ErrReadOnly the point is on the read only table
+var ErrReadOnly = errors.New("tags: point is read only")
+
+// ErrNotFound the point is not defined
+var ErrNotFound = errors.New("tags: point not found")
+
+// Point a named point, the engineering value is raw*Scale+Offset,
+// when Clamp is set, it is limited to [Min, Max] on read, and so is the value to write.
+// the 64 bits integer beyond 2^53 lose precision as the value is float64.
+type Point struct {
+	Name    string
+	SlaveID byte
+	Table   modbus.Table
+	Address uint16
+	Type    Type
+	Scale   float64      // 0 means 1
+	Offset  float64      // 偏移
+	Order   modbus.Order // the byte and word order of 32/64 bits type
+	Clamp   bool         // 是否限幅
+	Min     float64      // 工程值下限
+	Max     float64      // 工程值上限
+}
+
+// WithSpan return the point map the raw range [rawMin, rawMax] linearly to
+// the engineering range [engMin, engMax] and clamp into it,
+// such as the PLC analog 0-27648 to 0-100%.
+func (p Point) WithSpan(rawMin, rawMax, engMin, engMax float64) Point {
+	p.Scale = (engMax - engMin) / (rawMax - rawMin)
+	p.Offset = engMin - rawMin*p.Scale
+	p.Clamp, p.Min, p.Max = true, math.Min(engMin, engMax), math.Max(engMin, engMax)
+	return p
+}
+
+// Quantity the quantity of the bits or registers of the point
+func (p Point) Quantity() uint16 {
+	return p.Type.Quantity()
+}
+
+// isBit whether the point is on the bit table
+func (p Point) isBit() bool {
+	return p.Table == modbus.TableCoils || p.Table == modbus.TableDiscreteInputs
+}
+
+// Validate check the point definition
+func (p Point) Validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("tags: point name is empty")
+	case p.Type > Float64:
+		return fmt.Errorf("tags: point '%s' unknown type '%v'", p.Name, p.Type)
+	case p.Table > modbus.TableHoldingRegisters:
+		return fmt.Errorf("tags: point '%s' unknown table '%v'", p.Name, p.Table)
+	case p.isBit() != (p.Type == Bool):
+		return fmt.Errorf("tags: point '%s' type '%v' is not on the table '%v'", p.Name, p.Type, p.Table)
+	case int(p.Address)+int(p.Quantity()) > 0x10000:
+		return fmt.Errorf("tags: point '%s' address '%v' out of range", p.Name, p.Address)
+	case math.IsNaN(p.Scale) || math.IsInf(p.Scale, 0):
+		return fmt.Errorf("tags: point '%s' invalid scale '%v'", p.Name, p.Scale)
+	case p.Clamp && !(p.Min <= p.Max):
+		return fmt.Errorf("tags: point '%s' min '%v' bigger than max '%v'", p.Name, p.Min, p.Max)
+	}
+	return nil
+}
+
+// scale the scale of the point, 0 means 1
+func (p Point) scale() float64 {
+	if p.Scale == 0 {
+		return 1
+	}
+	return p.Scale
+}
+
+// Decode decode the engineering value from the raw data,
+// the raw data is the packed bits of the bit table,
+// or the big endian register bytes of the register table.
+func (p Point) Decode(raw []byte) (float64, error) {
+	if p.Type == Bool {
+		if len(raw) < 1 {
+			return 0, fmt.Errorf("tags: point '%s' raw data too short", p.Name)
+		}
+		return float64(raw[0] & 0x01), nil
+	}
+	if len(raw) < int(p.Quantity())*2 {
+		return 0, fmt.Errorf("tags: point '%s' raw data too short", p.Name)
+	}
+	var v float64
+	switch p.Type {
+	case Int16:
+		v = float64(int16(uint16(raw[0])<<8 | uint16(raw[1])))
+	case Uint16:
+		v = float64(uint16(raw[0])<<8 | uint16(raw[1]))
+	case Int32:
+		v = float64(int32(p.Order.Uint32(raw)))
+	case Uint32:
+		v = float64(p.Order.Uint32(raw))
+	case Float32:
+		v = float64(math.Float32frombits(p.Order.Uint32(raw)))
+	case Int64:
+		v = float64(int64(p.Order.Uint64(raw)))
+	case Uint64:
+		v = float64(p.Order.Uint64(raw))
+	case Float64:
+		v = math.Float64frombits(p.Order.Uint64(raw))
+	}
+	return p.clamp(v*p.scale() + p.Offset), nil
+}
+
+// clamp limit the engineering value into [Min, Max] if Clamp is set
+func (p Point) clamp(v float64) float64 {
+	if p.Clamp {
+		return math.Max(p.Min, math.Min(p.Max, v))
+	}
+	return v
+}
+
+// Encode encode the engineering value to the raw data, it is the inverse of Decode,
+// the value is clamped first if Clamp is set, the integer type is rounded to the nearest, and out of range is an error.
+func (p Point) Encode(value float64) ([]byte, error) {
+	if p.Type == Bool {
+		if value != 0 {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	}
+	raw := (p.clamp(value) - p.Offset) / p.scale()
+	b := make([]byte, p.Quantity()*2)
+	switch p.Type {
+	case Float32:
+		p.Order.PutUint32(b, math.Float32bits(float32(raw)))
+		return b, nil
+	case Float64:
+		p.Order.PutUint64(b, math.Float64bits(raw))
+		return b, nil
+	}
+
+	raw = math.Floor(raw + 0.5)
+	var min, limit float64 // the range is [min, limit)
+	switch p.Type {
+	case Int16:
+		min, limit = math.MinInt16, 1<<15
+	case Uint16:
+		min, limit = 0, 1<<16
+	case Int32:
+		min, limit = math.MinInt32, 1<<31
+	case Uint32:
+		min, limit = 0, 1<<32
+	case Int64:
+		min, limit = math.MinInt64, 1<<63
+	case Uint64:
+		min, limit = 0, 1<<64
+	}
+	if math.IsNaN(raw) || raw < min || raw >= limit {
+		return nil, fmt.Errorf("tags: point '%s' value '%v' out of range of '%v'", p.Name, value, p.Type)
+	}
+	switch p.Type {
+	case Int16:
+		v := int16(raw)
+		b[0], b[1] = byte(v>>8), byte(v)
+	case Uint16:
+		v := uint16(raw)
+		b[0], b[1] = byte(v>>8), byte(v)
+	case Int32:
+		p.Order.PutUint32(b, uint32(int32(raw)))
+	case Uint32:
+		p.Order.PutUint32(b, uint32(raw))
+	case Int64:
+		p.Order.PutUint64(b, uint64(int64(raw)))
+	case Uint64:
+		p.Order.PutUint64(b, uint64(raw))
+	}
+	return b, nil
+}
+
+// Set the named points, it is safe for concurrent use
+type Set struct {
+	mu     sync.RWMutex
+	points map[string]Point
+}
+
+// NewSet new point set with the points
+func NewSet(points ...Point) (*Set, error) {
+	s := &Set{points: make(map[string]Point)}
+	for _, p := range points {
+		if err := s.Add(p); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add add the point, the name must be unique
+func (sf *Set) Add(p Point) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if _, ok := sf.points[p.Name]; ok {
+		return fmt.Errorf("tags: point '%s' already exist", p.Name)
+	}
+	sf.points[p.Name] = p
+	return nil
+}
+
+// Remove remove the point
+func (sf *Set) Remove(name string) {
+	sf.mu.Lock()
+	delete(sf.points, name)
+	sf.mu.Unlock()
+}
+
+// Point got the point by name
+func (sf *Set) Point(name string) (Point, bool) {
+	sf.mu.RLock()
+	p, ok := sf.points[name]
+	sf.mu.RUnlock()
+	return p, ok
+}
+
+// Points got all the points sorted by name
+func (sf *Set) Points() []Point {
+	sf.mu.RLock()
+	list := make([]Point, 0, len(sf.points))
+	for _, p := range sf.points {
+		list = append(list, p)
+	}
+	sf.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Read read the points by name with the client, all the points if no name given,
+// the adjacent points are read in one request.
+// the error is the first failed one, the values of the succeeded points are still returned.
+func (sf *Set) Read(c modbus.Client, names ...string) (map[string]float64, error) {
+	var points []Point
+	if len(names) == 0 {
+		points = sf.Points()
+	} else {
+		for _, name := range names {
+			p, ok := sf.Point(name)
+			if !ok {
+				return nil, fmt.Errorf("%v, '%s'", ErrNotFound, name)
+			}
+			points = append(points, p)
+		}
+	}
+
+	specs := make([]modbus.ReadSpec, 0, len(points))
+	for _, p := range points {
+		specs = append(specs, modbus.ReadSpec{
+			SlaveID:  p.SlaveID,
+			Table:    p.Table,
+			Address:  p.Address,
+			Quantity: p.Quantity(),
+		})
+	}
+	results, err := c.ReadBatch(specs, modbus.BatchCoalesce(0))
+	values := make(map[string]float64, len(points))
+	for i, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		v, e := points[i].Decode(r.Data)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		values[points[i].Name] = v
+	}
+	return values, err
+}
+
+// Write write the engineering value of the point by name with the client
+func (sf *Set) Write(c modbus.Client, name string, value float64) error {
+	p, ok := sf.Point(name)
+	if !ok {
+		return fmt.Errorf("%v, '%s'", ErrNotFound, name)
+	}
+	if p.Table == modbus.TableDiscreteInputs || p.Table == modbus.TableInputRegisters {
+		return fmt.Errorf("%v, '%s'", ErrReadOnly, name)
+	}
+	raw, err := p.Encode(value)
+	if err != nil {
+		return err
+	}
+	if p.Type == Bool {
+		return c.WriteSingleCoil(p.SlaveID, p.Address, raw[0] != 0)
+	}
+	if p.Quantity() == 1 {
+		return c.WriteSingleRegister(p.SlaveID, p.Address, uint16(raw[0])<<8|uint16(raw[1]))
+	}
+	return c.WriteMultipleRegisters(p.SlaveID, p.Address, p.Quantity(), raw)
+}
+
+// GatherJobs the mb poller jobs cover all the points, adjacent points share a job,
+// add them by mb.Client.AddGatherJob, and handle the result by Handler.
+func (sf *Set) GatherJobs(scanRate time.Duration) []mb.Request {
+	points := sf.Points()
+	sort.Slice(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if a.SlaveID != b.SlaveID {
+			return a.SlaveID < b.SlaveID
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Address < b.Address
+	})
+
+	var jobs []mb.Request
+	for _, p := range points {
+		funcCode := readFuncCode(p.Table)
+		end := uint32(p.Address) + uint32(p.Quantity())
+		if n := len(jobs); n > 0 {
+			last := &jobs[n-1]
+			lastEnd := uint32(last.Address) + uint32(last.Quantity)
+			if last.SlaveID == p.SlaveID && last.FuncCode == funcCode && uint32(p.Address) <= lastEnd &&
+				end-uint32(last.Address) <= quantityMax(p.Table) {
+				if end > lastEnd {
+					last.Quantity = uint16(end - uint32(last.Address))
+				}
+				continue
+			}
+		}
+		jobs = append(jobs, mb.Request{
+			SlaveID:  p.SlaveID,
+			FuncCode: funcCode,
+			Address:  p.Address,
+			Quantity: p.Quantity(),
+			ScanRate: scanRate,
+		})
+	}
+	return jobs
+}
+
+// Handler the mb poller handler which decode the points in the polled block,
+// and call onValue with the engineering value, onValue must not be nil.
+// the ProcResult is ignored, wrap the handler to observe it.
+func (sf *Set) Handler(onValue func(p Point, value float64)) mb.Handler {
+	return &handler{sf, onValue}
+}
+
+// handler implements mb.Handler
+type handler struct {
+	set     *Set
+	onValue func(p Point, value float64)
+}
+
+func (sf *handler) ProcReadCoils(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.proc(slaveID, modbus.TableCoils, address, quantity, valBuf)
+}
+
+func (sf *handler) ProcReadDiscretes(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.proc(slaveID, modbus.TableDiscreteInputs, address, quantity, valBuf)
+}
+
+func (sf *handler) ProcReadHoldingRegisters(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.proc(slaveID, modbus.TableHoldingRegisters, address, quantity, valBuf)
+}
+
+func (sf *handler) ProcReadInputRegisters(slaveID byte, address, quantity uint16, valBuf []byte) {
+	sf.proc(slaveID, modbus.TableInputRegisters, address, quantity, valBuf)
+}
+
+func (sf *handler) ProcResult(error, *mb.Result) {}
+
+// proc decode the points inside the block
+func (sf *handler) proc(slaveID byte, table modbus.Table, address, quantity uint16, valBuf []byte) {
+	for _, p := range sf.set.Points() {
+		if p.SlaveID != slaveID || p.Table != table || p.Address < address ||
+			uint32(p.Address)+uint32(p.Quantity()) > uint32(address)+uint32(quantity) {
+			continue
+		}
+		offset := int(p.Address - address)
+		var raw []byte
+		if p.Type == Bool {
+			raw = []byte{valBuf[offset/8] >> uint(offset%8) & 0x01}
+		} else {
+			raw = valBuf[offset*2:]
+		}
+		if v, err := p.Decode(raw); err == nil {
+			sf.onValue(p, v)
+		}
+	}
+}
+
+// quantityMax the max read quantity of the table
+func quantityMax(t modbus.Table) uint32 {
+	if t == modbus.TableCoils || t == modbus.TableDiscreteInputs {
+		return modbus.ReadBitsQuantityMax
+	}
+	return modbus.ReadRegQuantityMax
+}
+
+// readFuncCode the read function code of the table
+func readFuncCode(t modbus.Table) byte {
+	switch t {
+	case modbus.TableCoils:
+		return modbus.FuncCodeReadCoils
+	case modbus.TableDiscreteInputs:
+		return modbus.FuncCodeReadDiscreteInputs
+	case modbus.TableInputRegisters:
+		return modbus.FuncCodeReadInputRegisters
+	}
+	return modbus.FuncCodeReadHoldingRegisters
+}
@@ -0,0 +1,175 @@
+package tags
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	modbus "github.com/aloncn/gomodbus"
+	"github.com/aloncn/gomodbus/mb"
+)
+
+func TestPoint_EncodeDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		point   Point
+		value   float64
+		wantErr bool
+	}{
+		{"int16 scaled", Point{Name: "a", Table: modbus.TableHoldingRegisters, Type: Int16, Scale: 0.1}, -12.3, false},
+		{"uint16 offset", Point{Name: "b", Table: modbus.TableHoldingRegisters, Type: Uint16, Offset: -40}, 25, false},
+		{"uint16 out of range", Point{Name: "c", Table: modbus.TableHoldingRegisters, Type: Uint16}, 65536, true},
+		{"int32 word swapped", Point{Name: "d", Table: modbus.TableHoldingRegisters, Type: Int32, Order: modbus.OrderCDAB}, -70000, false},
+		{"float32", Point{Name: "e", Table: modbus.TableInputRegisters, Type: Float32}, 3.25, false},
+		{"uint64", Point{Name: "f", Table: modbus.TableHoldingRegisters, Type: Uint64, Order: modbus.OrderDCBA}, 1 << 40, false},
+		{"float64 scaled", Point{Name: "g", Table: modbus.TableHoldingRegisters, Type: Float64, Scale: 2, Offset: 1}, 7, false},
+		{"bool", Point{Name: "h", Table: modbus.TableCoils, Type: Bool}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := tt.point.Encode(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := tt.point.Decode(raw)
+			if err != nil || got-tt.value > 1e-9 || tt.value-got > 1e-9 {
+				t.Errorf("Decode() = %v, %v, want %v", got, err, tt.value)
+			}
+		})
+	}
+}
+
+func TestPoint_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		point Point
+	}{
+		{"no name", Point{Table: modbus.TableHoldingRegisters, Type: Int16}},
+		{"bool on register", Point{Name: "a", Table: modbus.TableHoldingRegisters, Type: Bool}},
+		{"register type on coil", Point{Name: "a", Table: modbus.TableCoils, Type: Int16}},
+		{"address overflow", Point{Name: "a", Table: modbus.TableHoldingRegisters, Type: Float32, Address: 0xffff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.point.Validate(); err == nil {
+				t.Errorf("Validate() error = nil, want error")
+			}
+		})
+	}
+}
+
+func newTestSet(t *testing.T) *Set {
+	set, err := NewSet(
+		Point{Name: "temperature", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 0, Type: Int16, Scale: 0.1},
+		Point{Name: "flow", SlaveID: 1, Table: modbus.TableHoldingRegisters, Address: 1, Type: Float32, Order: modbus.OrderCDAB},
+		Point{Name: "pump", SlaveID: 1, Table: modbus.TableCoils, Address: 3, Type: Bool},
+		Point{Name: "counter", SlaveID: 1, Table: modbus.TableInputRegisters, Address: 2, Type: Uint32},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestSet(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := modbus.NewTCPServer()
+	node := modbus.NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10)
+	node.WriteInputs(2, []uint16{0x0001, 0x0002})
+	srv.AddNodes(node)
+	go srv.Serve(listen)
+	defer srv.Close()
+
+	c := modbus.NewClient(modbus.NewTCPClientProvider(listen.Addr().String()))
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	set := newTestSet(t)
+	if _, err = NewSet(Point{Name: "a", Type: Int16, Table: modbus.TableHoldingRegisters},
+		Point{Name: "a", Type: Int16, Table: modbus.TableHoldingRegisters}); err == nil {
+		t.Errorf("NewSet() duplicated name error = nil, want error")
+	}
+	for name, v := range map[string]float64{"temperature": 21.5, "flow": 0.5, "pump": 1} {
+		if err = set.Write(c, name, v); err != nil {
+			t.Fatalf("Write(%s) error = %v", name, err)
+		}
+	}
+	if err = set.Write(c, "counter", 1); err == nil {
+		t.Errorf("Write(counter) error = nil, want read only")
+	}
+	values, err := set.Read(c)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := map[string]float64{"temperature": 21.5, "flow": 0.5, "pump": 1, "counter": 0x00010002}
+	for name, v := range want {
+		if values[name] != v {
+			t.Errorf("Read()[%s] = %v, want %v", name, values[name], v)
+		}
+	}
+	if _, err = set.Read(c, "unknown"); err == nil {
+		t.Errorf("Read(unknown) error = nil, want error")
+	}
+}
+
+func TestSet_poller(t *testing.T) {
+	set := newTestSet(t)
+	jobs := set.GatherJobs(time.Second)
+	want := []mb.Request{
+		{SlaveID: 1, FuncCode: modbus.FuncCodeReadCoils, Address: 3, Quantity: 1, ScanRate: time.Second},
+		{SlaveID: 1, FuncCode: modbus.FuncCodeReadInputRegisters, Address: 2, Quantity: 2, ScanRate: time.Second},
+		{SlaveID: 1, FuncCode: modbus.FuncCodeReadHoldingRegisters, Address: 0, Quantity: 3, ScanRate: time.Second},
+	}
+	if len(jobs) != len(want) {
+		t.Fatalf("GatherJobs() = %+v, want %+v", jobs, want)
+	}
+	for i := range want {
+		if jobs[i] != want[i] {
+			t.Errorf("GatherJobs()[%d] = %+v, want %+v", i, jobs[i], want[i])
+		}
+	}
+
+	got := make(map[string]float64)
+	h := set.Handler(func(p Point, v float64) { got[p.Name] = v })
+	h.ProcReadHoldingRegisters(1, 0, 3, []byte{0x00, 0xd7, 0x00, 0x00, 0x3f, 0x00})
+	h.ProcReadCoils(1, 3, 1, []byte{0x01})
+	if got["temperature"] != 21.5 || got["flow"] != 0.5 || got["pump"] != 1 || len(got) != 3 {
+		t.Errorf("Handler values = %v", got)
+	}
+}
+
+func TestPoint_WithSpan(t *testing.T) {
+	p := Point{Name: "level", Table: modbus.TableInputRegisters, Type: Int16}.WithSpan(0, 27648, 0, 100)
+	tests := []struct {
+		raw     []byte
+		want    float64
+		wantRaw []byte
+	}{
+		{[]byte{0x00, 0x00}, 0, []byte{0x00, 0x00}},
+		{[]byte{0x36, 0x00}, 50, []byte{0x36, 0x00}},
+		{[]byte{0x6c, 0x00}, 100, []byte{0x6c, 0x00}},
+		{[]byte{0x7f, 0xff}, 100, []byte{0x6c, 0x00}}, // overflow clamped
+		{[]byte{0xff, 0x00}, 0, []byte{0x00, 0x00}},   // underflow clamped
+	}
+	for _, tt := range tests {
+		got, err := p.Decode(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("Decode(% x) = %v, %v, want %v", tt.raw, got, err, tt.want)
+		}
+		raw, err := p.Encode(got)
+		if err != nil || raw[0] != tt.wantRaw[0] || raw[1] != tt.wantRaw[1] {
+			t.Errorf("Encode(%v) = % x, %v, want % x", got, raw, err, tt.wantRaw)
+		}
+	}
+	if raw, _ := p.Encode(150); raw[0] != 0x6c || raw[1] != 0x00 {
+		t.Errorf("Encode(150) = % x, want clamped 6c 00", raw)
+	}
+}
@@ -1,7 +1,13 @@
 package modbus
 
 import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -83,3 +89,197 @@ func Test_TCPClientWithServer(t *testing.T) {
 		}
 	})
 }
+
+type testMetrics struct {
+	mu     sync.Mutex
+	opened int
+	closed int
+	stats  []RequestStat
+}
+
+func (sf *testMetrics) ConnectionOpened() { sf.mu.Lock(); sf.opened++; sf.mu.Unlock() }
+func (sf *testMetrics) ConnectionClosed() { sf.mu.Lock(); sf.closed++; sf.mu.Unlock() }
+func (sf *testMetrics) RequestHandled(stat RequestStat) {
+	sf.mu.Lock()
+	sf.stats = append(sf.stats, stat)
+	sf.mu.Unlock()
+}
+
+func Test_TCPServerMetrics(t *testing.T) {
+	metrics := &testMetrics{}
+	mbSrv := NewTCPServer()
+	mbSrv.SetMetrics(metrics)
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10))
+	go mbSrv.ListenAndServe("localhost:48092")
+	defer mbSrv.Close()
+	time.Sleep(time.Millisecond * 200) // 让服务器完全启动
+
+	mbCli := NewClient(NewTCPClientProvider("localhost:48092"))
+	if err := mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	if _, err := mbCli.ReadHoldingRegisters(testslaveID1, 0, 2); err != nil {
+		t.Fatalf("ReadHoldingRegisters error = %v", err)
+	}
+	if _, err := mbCli.ReadHoldingRegisters(testslaveID1, 9, 2); err == nil {
+		t.Fatalf("ReadHoldingRegisters error = %v, want exception", err)
+	}
+	mbCli.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if metrics.opened != 1 || metrics.closed != 1 {
+		t.Errorf("connection opened = %v closed = %v, want 1 1", metrics.opened, metrics.closed)
+	}
+	if len(metrics.stats) != 2 {
+		t.Fatalf("request stats len = %v, want %v", len(metrics.stats), 2)
+	}
+	got := metrics.stats[0]
+	got.Latency = 0
+	want := RequestStat{SlaveID: testslaveID1, FuncCode: FuncCodeReadHoldingRegisters, RequestSize: 5, ResponseSize: 6}
+	if got != want {
+		t.Errorf("request stat = %+v, want %+v", got, want)
+	}
+	if metrics.stats[1].ExceptionCode != ExceptionCodeIllegalDataAddress {
+		t.Errorf("request stat exception = %v, want %v", metrics.stats[1].ExceptionCode, ExceptionCodeIllegalDataAddress)
+	}
+}
+
+func Test_UnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomodbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := "unix://" + filepath.Join(dir, "modbus.sock")
+
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10))
+	go mbSrv.ListenAndServe(addr)
+	defer mbSrv.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	mbCli := NewClient(NewTCPClientProvider(addr))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	if err = mbCli.WriteSingleRegister(testslaveID1, 1, 0x1234); err != nil {
+		t.Fatalf("WriteSingleRegister error = %v", err)
+	}
+	got, err := mbCli.ReadHoldingRegisters(testslaveID1, 1, 1)
+	if err != nil || got[0] != 0x1234 {
+		t.Errorf("ReadHoldingRegisters = %v, %v, want [4660]", got, err)
+	}
+}
+
+func Test_ReadInto(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 16, 0, 10, 0, 10, 0, 10))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	if err = mbCli.WriteMultipleRegisters(testslaveID1, 0, 2, []byte{0x12, 0x34, 0x56, 0x78}); err != nil {
+		t.Fatalf("WriteMultipleRegisters error = %v", err)
+	}
+	if err = mbCli.WriteSingleCoil(testslaveID1, 9, true); err != nil {
+		t.Fatalf("WriteSingleCoil error = %v", err)
+	}
+
+	dst := make([]byte, 0, 16)
+	for _, withMiddleware := range []bool{false, true} {
+		if withMiddleware {
+			mbCli.Use(func(next Doer) Doer { return next })
+		}
+		got, err := mbCli.ReadHoldingRegistersInto(dst, testslaveID1, 0, 2)
+		if err != nil || !bytes.Equal(got, []byte{0x12, 0x34, 0x56, 0x78}) {
+			t.Fatalf("ReadHoldingRegistersInto() = % x, %v, want 12 34 56 78", got, err)
+		}
+		if &got[0] != &dst[:1][0] {
+			t.Errorf("ReadHoldingRegistersInto() result not decoded into dst")
+		}
+		got, err = mbCli.ReadCoilsInto(got, testslaveID1, 0, 16)
+		if err != nil || !bytes.Equal(got, []byte{0x00, 0x02}) {
+			t.Fatalf("ReadCoilsInto() = % x, %v, want 00 02", got, err)
+		}
+	}
+}
+
+func BenchmarkReadHoldingRegistersInto(b *testing.B) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 125))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		b.Fatal(err)
+	}
+	defer mbCli.Close()
+
+	dst := make([]byte, 0, 250)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if dst, err = mbCli.ReadHoldingRegistersInto(dst, testslaveID1, 0, 125); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_TCPClientConcurrent(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 64)
+	mbSrv.AddNodes(node)
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbPro := NewTCPClientProvider(listen.Addr().String())
+	mbCli := NewClient(mbPro, WithRetry(1, nil, nil))
+	mbCli.Use(func(next Doer) Doer { return next })
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	// every goroutine own a register, the frames of the goroutines must not interleave
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(address uint16) {
+			defer wg.Done()
+			for i := uint16(0); i < 50; i++ {
+				if err := mbCli.WriteSingleRegister(testslaveID1, address, i); err != nil {
+					t.Errorf("WriteSingleRegister error = %v", err)
+					return
+				}
+				got, err := mbCli.ReadHoldingRegisters(testslaveID1, address, 1)
+				if err != nil || got[0] != i {
+					t.Errorf("ReadHoldingRegisters = %v, %v, want [%v]", got, err, i)
+					return
+				}
+				_ = mbCli.Stats()
+				mbPro.SetTimeout(time.Second)
+			}
+		}(uint16(g))
+	}
+	wg.Wait()
+}
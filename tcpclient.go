@@ -1,6 +1,8 @@
 package modbus
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -8,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -21,20 +24,33 @@ const (
 // TCPClientProvider implements ClientProvider interface.
 type TCPClientProvider struct {
 	logger
+	providerCommon
 	Address string
 	mu      sync.Mutex
 	// TCP connection
 	conn net.Conn
-	// Connect & Read timeout
+	// Connect & Read timeout, set it before use or by SetTimeout when it is in use
 	Timeout time.Duration
 	// if > 0, when disconnect,it will try to reconnect the remote
 	// but if we active close self,it will not to reconnect
 	// if == 0 auto reconnect not active
 	autoReconnect byte
+	// custom dialer, nil use net.Dialer with the socket options
+	dialer      Dialer
+	dialTimeout time.Duration
+	tlsConfig   *tls.Config // the tls config of wss
+	// socket options
+	keepAlive time.Duration
+	noDelay   bool
+	control   func(network, address string, c syscall.RawConn) error
+	// idle probe, see SetProbe
+	probe      tcpProbe
+	lastActive time.Time
 	// For synchronization between messages of server & client
 	transactionID uint32
 	// 请求池,所有tcp客户端共用一个请求池
 	*pool
+	counters tcpCounters
 }
 
 // check TCPClientProvider implements underlying method
@@ -43,12 +59,15 @@ var _ ClientProvider = (*TCPClientProvider)(nil)
 // 请求池,所有TCP客户端共用一个请求池
 var tcpPool = newPool(tcpAduMaxSize)
 
-// NewTCPClientProvider allocates a new TCPClientProvider.
+// NewTCPClientProvider allocates a new TCPClientProvider,
+// address is "host:port", unix domain socket "unix:///path.sock",
+// or websocket "ws://host:port/path" and "wss://host:port/path".
 func NewTCPClientProvider(address string) *TCPClientProvider {
 	return &TCPClientProvider{
 		Address:       address,
 		Timeout:       TCPDefaultTimeout,
 		autoReconnect: TCPDefaultAutoReconnect,
+		noDelay:       true,
 		pool:          tcpPool,
 		logger:        newLogger("modbusTCPMaster =>"),
 	}
@@ -118,21 +137,26 @@ func decodeTCPFrame(adu []byte) (protocolTCPHeader, []byte, error) {
 	return head, adu[tcpHeaderMbapSize:], nil
 }
 
+// tcpSlaveID got the unit identifier of the TCP frame,use for log only
+func tcpSlaveID(adu []byte) interface{} {
+	if len(adu) < tcpHeaderMbapSize {
+		return "unknown"
+	}
+	return adu[6]
+}
+
 // verify confirms valid data
 func verifyTCPFrame(reqHead, rspHead protocolTCPHeader, reqPDU, rspPDU ProtocolDataUnit) error {
 	switch {
 	case rspHead.transactionID != reqHead.transactionID:
 		// Check transaction ID
-		return fmt.Errorf("modbus: response transaction id '%v' does not match request '%v'",
-			rspHead.transactionID, reqHead.transactionID)
+		return &HeaderError{"transaction id", rspHead.transactionID, reqHead.transactionID}
 	case rspHead.protocolID != reqHead.protocolID:
 		// Check protocol ID
-		return fmt.Errorf("modbus: response protocol id '%v' does not match request '%v'",
-			rspHead.protocolID, reqHead.protocolID)
+		return &HeaderError{"protocol id", rspHead.protocolID, reqHead.protocolID}
 	case rspHead.slaveID != reqHead.slaveID:
 		// Check slaveID same
-		return fmt.Errorf("modbus: response unit id '%v' does not match request '%v'",
-			rspHead.slaveID, reqHead.slaveID)
+		return &HeaderError{"unit id", uint16(rspHead.slaveID), uint16(reqHead.slaveID)}
 	case rspPDU.FuncCode != reqPDU.FuncCode:
 		// Check correct function code returned (exception)
 		return responseError(rspPDU)
@@ -145,10 +169,28 @@ func verifyTCPFrame(reqHead, rspHead protocolTCPHeader, reqPDU, rspPDU ProtocolD
 
 // Send the request to tcp and get the response
 func (sf *TCPClientProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.sendContext(context.Background(), nil, slaveID, request)
+}
+
+// SendContext send the request like Send, the deadline of ctx replace the Timeout of the transaction
+func (sf *TCPClientProvider) SendContext(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.sendContext(ctx, nil, slaveID, request)
+}
+
+// sendInto send the request and decode the response data into dst,
+// the frame buffers come from the pool, so it is allocation free when dst is large enough.
+func (sf *TCPClientProvider) sendInto(dst []byte, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	return sf.sendContext(context.Background(), dst, slaveID, request)
+}
+
+// sendContext send the request with the context and decode the response data into dst
+func (sf *TCPClientProvider) sendContext(ctx context.Context, dst []byte, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
 	var response ProtocolDataUnit
 
 	frame := sf.pool.get()
 	defer sf.pool.put(frame)
+	rspFrame := sf.pool.get()
+	defer sf.pool.put(rspFrame)
 	// add transaction id
 	tid := uint16(atomic.AddUint32(&sf.transactionID, 1))
 
@@ -156,7 +198,7 @@ func (sf *TCPClientProvider) Send(slaveID byte, request ProtocolDataUnit) (Proto
 	if err != nil {
 		return response, err
 	}
-	aduResponse, err := sf.SendRawFrame(aduRequest)
+	aduResponse, err := sf.sendRawFrame(ctx, rspFrame.adu[:tcpAduMaxSize], aduRequest)
 	if err != nil {
 		return response, err
 	}
@@ -164,7 +206,8 @@ func (sf *TCPClientProvider) Send(slaveID byte, request ProtocolDataUnit) (Proto
 	if err != nil {
 		return response, err
 	}
-	response = ProtocolDataUnit{pdu[0], pdu[1:]}
+	response = ProtocolDataUnit{pdu[0], append(dst[:0], pdu[1:]...)}
+	rspHead.slaveID = sf.tolerate(slaveID, rspHead.slaveID, request, &response)
 	if err = verifyTCPFrame(head, rspHead, request, response); err != nil {
 		return response, err
 	}
@@ -196,31 +239,38 @@ func (sf *TCPClientProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, e
 	if err != nil {
 		return nil, err
 	}
-	if err = verifyTCPFrame(head, rspHead, request, ProtocolDataUnit{rspPdu[0], rspPdu[1:]}); err != nil {
+	response := ProtocolDataUnit{rspPdu[0], rspPdu[1:]}
+	rspHead.slaveID = sf.tolerate(slaveID, rspHead.slaveID, request, &response)
+	if err = verifyTCPFrame(head, rspHead, request, response); err != nil {
 		return nil, err
 	}
 	// rspPdu pass tcpMBAP head
-	return rspPdu, nil
+	return rspPdu[:1+len(response.Data)], nil
 }
 
 // SendRawFrame send raw adu request frame
 func (sf *TCPClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte, err error) {
+	return sf.sendRawFrame(context.Background(), make([]byte, tcpAduMaxSize), aduRequest)
+}
+
+// sendRawFrame send raw adu request frame, the response is read into data,
+// len(data) must be tcpAduMaxSize. the deadline of ctx replace the Timeout.
+func (sf *TCPClientProvider) sendRawFrame(ctx context.Context, data []byte, aduRequest []byte) (aduResponse []byte, err error) {
 	sf.mu.Lock()
 	defer sf.mu.Unlock()
 
 	if !sf.isConnected() {
 		return nil, ErrClosedConnection
 	}
+	sf.lastActive = time.Now()
 	// Send data
-	sf.Debug("sending [% x]", aduRequest)
+	sf.pace()
+	sf.with("slave", tcpSlaveID(aduRequest)).Debug("sending [% x]", aduRequest)
+	sf.tapSend(aduRequest)
 	// Set write and read timeout
-	var timeout time.Time
 	var tryCnt byte
 	for {
-		if sf.Timeout > 0 {
-			timeout = time.Now().Add(sf.Timeout)
-		}
-		if err = sf.conn.SetDeadline(timeout); err != nil {
+		if err = sf.conn.SetDeadline(transactionDeadline(ctx, sf.Timeout)); err != nil {
 			return nil, err
 		}
 
@@ -232,7 +282,9 @@ func (sf *TCPClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte
 			return
 		}
 
+		sf.emitDisconnected(err)
 		for {
+			sf.emitReconnecting(int(tryCnt) + 1)
 			err = sf.connect()
 			if err == nil {
 				break
@@ -244,93 +296,206 @@ func (sf *TCPClientProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte
 	}
 
 	// Read header first
-	var data [tcpAduMaxSize]byte
 	var cnt int
 	var mErr error
+	var length int
+	// one deadline for the whole response, the stale frames discarded not extend it
+	deadline := transactionDeadline(ctx, sf.Timeout)
 	for {
-		if sf.Timeout > 0 {
-			timeout = time.Now().Add(sf.Timeout)
-		}
-		if err = sf.conn.SetDeadline(timeout); err != nil {
-			return nil, err
-		}
+		for {
+			if err = sf.conn.SetDeadline(deadline); err != nil {
+				return nil, err
+			}
 
-		if cnt, err = io.ReadFull(sf.conn, data[:tcpHeaderMbapSize]); err == nil {
-			break
-		}
-		if sf.autoReconnect == 0 {
-			return
-		}
-		mErr = err
-		if e, ok := err.(net.Error); ok && !e.Temporary() ||
-			err != io.EOF && err != io.ErrClosedPipe ||
-			strings.Contains(err.Error(), "use of closed network connection") ||
-			cnt == 0 && err == io.EOF {
-			for {
-				err = sf.connect()
-				if err == nil {
-					break
-				}
-				if tryCnt++; tryCnt >= sf.autoReconnect {
-					return
+			if cnt, err = io.ReadFull(sf.conn, data[:tcpHeaderMbapSize]); err == nil {
+				break
+			}
+			if sf.autoReconnect == 0 {
+				return
+			}
+			mErr = err
+			if e, ok := err.(net.Error); ok && !e.Temporary() ||
+				err != io.EOF && err != io.ErrClosedPipe ||
+				strings.Contains(err.Error(), "use of closed network connection") ||
+				cnt == 0 && err == io.EOF {
+				sf.emitDisconnected(err)
+				for {
+					sf.emitReconnecting(int(tryCnt) + 1)
+					err = sf.connect()
+					if err == nil {
+						break
+					}
+					if tryCnt++; tryCnt >= sf.autoReconnect {
+						return
+					}
 				}
 			}
+			if tryCnt++; tryCnt >= sf.autoReconnect {
+				err = mErr
+				return
+			}
 		}
-		if tryCnt++; tryCnt >= sf.autoReconnect {
-			err = mErr
+		// Read length, ignore transaction & protocol id (4 bytes)
+		length = int(binary.BigEndian.Uint16(data[4:]))
+		switch {
+		case length <= 0:
+			_ = sf.flush(data)
+			err = fmt.Errorf("modbus: length in response header '%v' must not be zero", length)
+			return
+		case length > (tcpAduMaxSize - (tcpHeaderMbapSize - 1)):
+			_ = sf.flush(data)
+			err = fmt.Errorf("modbus: length in response header '%v' must not greater than '%v'", length, tcpAduMaxSize-tcpHeaderMbapSize+1)
 			return
 		}
-	}
-	// Read length, ignore transaction & protocol id (4 bytes)
-	length := int(binary.BigEndian.Uint16(data[4:]))
-	switch {
-	case length <= 0:
-		_ = sf.flush(data[:])
-		err = fmt.Errorf("modbus: length in response header '%v' must not be zero", length)
-		return
-	case length > (tcpAduMaxSize - (tcpHeaderMbapSize - 1)):
-		_ = sf.flush(data[:])
-		err = fmt.Errorf("modbus: length in response header '%v' must not greater than '%v'", length, tcpAduMaxSize-tcpHeaderMbapSize+1)
-		return
-	}
 
-	if sf.Timeout > 0 {
-		timeout = time.Now().Add(sf.Timeout)
-	}
-	if err = sf.conn.SetDeadline(timeout); err != nil {
-		return nil, err
-	}
+		if err = sf.conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
 
-	// Skip unit id
-	length += tcpHeaderMbapSize - 1
-	if _, err = io.ReadFull(sf.conn, data[tcpHeaderMbapSize:length]); err != nil {
-		return
+		// Skip unit id
+		length += tcpHeaderMbapSize - 1
+		if _, err = io.ReadFull(sf.conn, data[tcpHeaderMbapSize:length]); err != nil {
+			return
+		}
+		aduResponse = data[:length]
+		// the late response of a timed out transaction, discard it and read the next one
+		if len(aduRequest) >= 2 && binary.BigEndian.Uint16(aduResponse) != binary.BigEndian.Uint16(aduRequest) &&
+			(deadline.IsZero() || time.Now().Before(deadline)) {
+			sf.counters.stale()
+			sf.with("slave", tcpSlaveID(aduResponse)).Debug("discard stale response [% x]", aduResponse)
+			continue
+		}
+		break
+	}
+	if sf.Quirks()&QuirkTrailingGarbage != 0 {
+		// discard the garbage received with the frame, wait a moment as it may arrive in the next segment
+		if err = sf.conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+			return nil, err
+		}
+		_, _ = sf.conn.Read(data[length:])
 	}
-	aduResponse = data[:length]
-	sf.Debug("received [% x]", aduResponse)
+	sf.with("slave", tcpSlaveID(aduResponse)).Debug("received [% x]", aduResponse)
+	sf.tapReceived(aduResponse)
 	return
 }
 
 // Connect establishes a new connection to the address in Address.
 // Connect and Close are exported so that multiple requests can be done with one session
 func (sf *TCPClientProvider) Connect() error {
+	return sf.ConnectContext(context.Background())
+}
+
+// ConnectContext establishes a new connection like Connect,
+// the dial is aborted when the context is done.
+func (sf *TCPClientProvider) ConnectContext(ctx context.Context) error {
 	sf.mu.Lock()
-	err := sf.connect()
+	err := sf.connectContext(ctx)
 	sf.mu.Unlock()
 	return err
 }
 
 // Caller must hold the mutex before calling this method.
 func (sf *TCPClientProvider) connect() error {
-	dialer := &net.Dialer{Timeout: sf.Timeout}
-	conn, err := dialer.Dial("tcp", sf.Address)
+	return sf.connectContext(context.Background())
+}
+
+// Caller must hold the mutex before calling this method.
+func (sf *TCPClientProvider) connectContext(ctx context.Context) error {
+	timeout := sf.dialTimeout
+	if timeout <= 0 {
+		timeout = sf.Timeout
+	}
+	var dialer Dialer = &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: sf.keepAlive,
+		Control:   sf.control,
+	}
+	if sf.dialer != nil {
+		dialer = sf.dialer
+	}
+	var conn net.Conn
+	var err error
+	if isWebSocketAddress(sf.Address) {
+		conn, err = dialWebSocket(ctx, dialer, sf.Address, timeout, sf.tlsConfig)
+	} else {
+		network, address := splitNetworkAddress(sf.Address)
+		conn, err = dialContext(ctx, dialer, network, address, timeout)
+	}
 	if err != nil {
 		return err
 	}
+	if tc, ok := conn.(*net.TCPConn); ok && !sf.noDelay {
+		if err = tc.SetNoDelay(false); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	if sf.conn != nil { // reconnect, release the stale connection
+		sf.conn.Close()
+	}
 	sf.conn = conn
+	sf.lastActive = time.Now()
+	sf.stopProbe()
+	sf.startProbe()
+	sf.emitConnected()
 	return nil
 }
 
+// SetTimeout set the read and write timeout of a transaction, it is safe to call when in use.
+func (sf *TCPClientProvider) SetTimeout(d time.Duration) {
+	sf.mu.Lock()
+	sf.Timeout = d
+	sf.mu.Unlock()
+}
+
+// SetDialer set the custom dialer, such as SOCKS5 proxy or jump host,
+// nil use the net.Dialer with the socket options, it takes effect on next connect.
+func (sf *TCPClientProvider) SetDialer(d Dialer) {
+	sf.mu.Lock()
+	sf.dialer = d
+	sf.mu.Unlock()
+}
+
+// SetTLSConfig set the tls config of the "wss://" address, such as the root CAs and the client certificate,
+// nil use the default, the host of the url is the ServerName if not set, it takes effect on next connect.
+func (sf *TCPClientProvider) SetTLSConfig(config *tls.Config) {
+	sf.mu.Lock()
+	sf.tlsConfig = config
+	sf.mu.Unlock()
+}
+
+// SetDialTimeout set the dial timeout, 0 use the Timeout, it takes effect on next connect.
+func (sf *TCPClientProvider) SetDialTimeout(d time.Duration) {
+	sf.mu.Lock()
+	sf.dialTimeout = d
+	sf.mu.Unlock()
+}
+
+// SetKeepAlive set the TCP keepalive interval, it takes effect on next connect,
+// 0 use the system default, negative disable keepalive,
+// set it shorter than the NAT idle timeout to keep long-idle connections alive.
+func (sf *TCPClientProvider) SetKeepAlive(d time.Duration) {
+	sf.mu.Lock()
+	sf.keepAlive = d
+	sf.mu.Unlock()
+}
+
+// SetNoDelay set TCP_NODELAY(disable Nagle's algorithm), default true,
+// it takes effect on next connect.
+func (sf *TCPClientProvider) SetNoDelay(noDelay bool) {
+	sf.mu.Lock()
+	sf.noDelay = noDelay
+	sf.mu.Unlock()
+}
+
+// SetControl set the function called after creating the socket but before dialing,
+// it can set any socket options, it takes effect on next connect.
+func (sf *TCPClientProvider) SetControl(f func(network, address string, c syscall.RawConn) error) {
+	sf.mu.Lock()
+	sf.control = f
+	sf.mu.Unlock()
+}
+
 // IsConnected returns a bool signifying whether
 // the client is connected or not.
 func (sf *TCPClientProvider) IsConnected() bool {
@@ -359,9 +524,11 @@ func (sf *TCPClientProvider) SetAutoReconnect(cnt byte) {
 func (sf *TCPClientProvider) Close() error {
 	var err error
 	sf.mu.Lock()
+	sf.stopProbe()
 	if sf.conn != nil {
 		err = sf.conn.Close()
 		sf.conn = nil
+		sf.emitDisconnected(nil)
 	}
 	sf.mu.Unlock()
 	return err
@@ -1,8 +1,12 @@
 package modbus
 
 import (
+	"errors"
+	"net"
 	"reflect"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func Test_protocolFrame_encodeTCPFrame(t *testing.T) {
@@ -194,3 +198,44 @@ func BenchmarkTCPClientProvider_decodeTCPFrame(b *testing.B) {
 		}
 	}
 }
+
+func TestTCPClientProvider_socketOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var network string
+	p := NewTCPClientProvider(ln.Addr().String())
+	p.SetKeepAlive(30 * time.Second)
+	p.SetNoDelay(false)
+	p.SetControl(func(nw, _ string, _ syscall.RawConn) error {
+		network = nw
+		return nil
+	})
+	if err = p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if network != "tcp4" && network != "tcp6" {
+		t.Errorf("control called with network %q, want tcp4 or tcp6", network)
+	}
+
+	p.SetControl(func(string, string, syscall.RawConn) error {
+		return errors.New("control failed")
+	})
+	if err = p.Connect(); err == nil {
+		p.Close()
+		t.Errorf("Connect() want control error")
+	}
+}
@@ -0,0 +1,97 @@
+package modbus
+
+import (
+	"net"
+	"time"
+)
+
+// tcpProbe 空闲探测
+type tcpProbe struct {
+	interval time.Duration
+	slaveID  byte
+	request  ProtocolDataUnit
+	stop     chan struct{} // 当前连接的探测协程, nil 没有运行
+}
+
+// SetProbe 连接空闲 interval 后发送探测请求 request, 如读1个保持寄存器
+// ProtocolDataUnit{FuncCode: FuncCodeReadHoldingRegisters, Data: []byte{0x00, 0x00, 0x00, 0x01}},
+// 用于NAT等静默丢弃空闲连接的场景, 探测失败时按 SetAutoReconnect 重连, 不自动重连时关闭连接,
+// 异常响应表示连接正常. interval<=0 关闭探测, 可在使用中设置.
+func (sf *TCPClientProvider) SetProbe(interval time.Duration, slaveID byte, request ProtocolDataUnit) {
+	sf.mu.Lock()
+	sf.stopProbe()
+	sf.probe.interval, sf.probe.slaveID, sf.probe.request = interval, slaveID, request
+	if sf.conn != nil {
+		sf.startProbe()
+	}
+	sf.mu.Unlock()
+}
+
+// startProbe 启动当前连接的探测协程
+// Caller must hold the mutex before calling this method.
+func (sf *TCPClientProvider) startProbe() {
+	if sf.probe.interval <= 0 {
+		return
+	}
+	sf.probe.stop = make(chan struct{})
+	go sf.probeLoop(sf.conn, sf.probe.interval, sf.probe.stop)
+}
+
+// stopProbe 停止探测协程
+// Caller must hold the mutex before calling this method.
+func (sf *TCPClientProvider) stopProbe() {
+	if sf.probe.stop != nil {
+		close(sf.probe.stop)
+		sf.probe.stop = nil
+	}
+}
+
+// probeLoop 连接 conn 空闲时探测, 直到 stop
+func (sf *TCPClientProvider) probeLoop(conn net.Conn, interval time.Duration, stop chan struct{}) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		sf.mu.Lock()
+		idle := time.Since(sf.lastActive)
+		slaveID, request := sf.probe.slaveID, sf.probe.request
+		sf.mu.Unlock()
+		if idle < interval {
+			timer.Reset(interval - idle)
+			continue
+		}
+
+		_, err := sf.Send(slaveID, request)
+		if _, isException := AsExceptionError(err); err != nil && !isException {
+			sf.probeFailed(conn, err)
+		}
+		timer.Reset(interval)
+	}
+}
+
+// probeFailed 探测失败, 重连或关闭连接 conn
+func (sf *TCPClientProvider) probeFailed(conn net.Conn, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.conn != conn { // 已经重连或关闭
+		return
+	}
+	sf.Error("probe failed, %v", err)
+	sf.emitDisconnected(err)
+	if sf.autoReconnect == 0 {
+		sf.stopProbe()
+		sf.conn.Close()
+		sf.conn = nil
+		return
+	}
+	for tryCnt := byte(0); tryCnt < sf.autoReconnect; tryCnt++ {
+		sf.emitReconnecting(int(tryCnt) + 1)
+		if sf.connect() == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package modbus
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var probeRequest = ProtocolDataUnit{FuncCode: FuncCodeReadHoldingRegisters, Data: []byte{0x00, 0x00, 0x00, 0x01}}
+
+func TestTCPClientProvider_SetProbe(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	var probes int32
+	srv.RegisterFunctionHandler(FuncCodeReadHoldingRegisters, func(reg *NodeRegister, data []byte) ([]byte, error) {
+		atomic.AddInt32(&probes, 1)
+		return []byte{0x02, 0x00, 0x00}, nil
+	})
+	go srv.Serve(listen)
+	defer srv.Close()
+
+	p := NewTCPClientProvider(listen.Addr().String())
+	p.SetProbe(10*time.Millisecond, 1, probeRequest)
+	if err = p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(55 * time.Millisecond)
+	if n := atomic.LoadInt32(&probes); n < 3 {
+		t.Errorf("probes = %v, want at least 3 when idle", n)
+	}
+	p.SetProbe(0, 1, probeRequest)
+	n := atomic.LoadInt32(&probes)
+	time.Sleep(30 * time.Millisecond)
+	if got := atomic.LoadInt32(&probes); got != n {
+		t.Errorf("probes = %v after disabled, want %v", got, n)
+	}
+	_ = p.Close()
+}
+
+// blackholeListener accept the connections but never respond, as a NAT dropped the session
+func blackholeListener(t *testing.T) (net.Listener, *int32) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accepts int32
+	go func() {
+		for {
+			conn, err := listen.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepts, 1)
+			go func() {
+				b := make([]byte, 256)
+				for {
+					if _, err := conn.Read(b); err != nil {
+						conn.Close()
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listen, &accepts
+}
+
+func TestTCPClientProvider_ProbeFailed(t *testing.T) {
+	tests := []struct {
+		name          string
+		autoReconnect byte
+		wantConnected bool
+	}{
+		{"reconnect", 1, true},
+		{"close", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listen, accepts := blackholeListener(t)
+			defer listen.Close()
+			p := NewTCPClientProvider(listen.Addr().String())
+			p.SetTimeout(10 * time.Millisecond)
+			p.SetAutoReconnect(tt.autoReconnect)
+			var disconnects int32
+			p.OnDisconnected(func(error) { atomic.AddInt32(&disconnects, 1) })
+			p.SetProbe(10*time.Millisecond, 1, probeRequest)
+			if err := p.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			deadline := time.Now().Add(time.Second)
+			for atomic.LoadInt32(&disconnects) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("probe failure not detected")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			time.Sleep(5 * time.Millisecond)
+			if got := p.IsConnected(); got != tt.wantConnected {
+				t.Errorf("IsConnected() = %v, want %v", got, tt.wantConnected)
+			}
+			if tt.wantConnected && atomic.LoadInt32(accepts) < 2 {
+				t.Errorf("accepts = %v, want reconnected", atomic.LoadInt32(accepts))
+			}
+		})
+	}
+}
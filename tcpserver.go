@@ -3,7 +3,9 @@ package modbus
 import (
 	"context"
 	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,9 +20,14 @@ type TCPServer struct {
 	mu           sync.Mutex
 	listen       net.Listener
 	wg           sync.WaitGroup
+	ctx          context.Context // the context of the sessions, see serveContext
 	cancel       context.CancelFunc
 	readTimeout  time.Duration
 	writeTimeout time.Duration
+	connLimit    atomic.Value // rateLimitConfig
+	ipLimit      atomic.Value // rateLimitConfig
+	ipMu         sync.Mutex
+	ipLimiters   map[string]*ipLimiter
 	*serverCommon
 	logger
 }
@@ -45,32 +52,49 @@ func (sf *TCPServer) SetWriteTimeout(t time.Duration) {
 	sf.writeTimeout = t
 }
 
-// Close close the server until all server close then return
+// Close close the server until all server close then return,
+// the sessions of WebSocketHandler are closed too.
 func (sf *TCPServer) Close() error {
 	sf.mu.Lock()
 	if sf.listen != nil {
 		sf.listen.Close()
-		sf.cancel()
 		sf.listen = nil
 	}
+	if sf.cancel != nil {
+		sf.cancel()
+		sf.ctx, sf.cancel = nil, nil
+	}
 	sf.mu.Unlock()
 	sf.wg.Wait()
 	return nil
 }
 
-// ListenAndServe 服务
+// serveContext the context of the sessions, it is canceled by Close.
+// Caller must hold the mutex before calling this method.
+func (sf *TCPServer) serveContext() context.Context {
+	if sf.ctx == nil {
+		sf.ctx, sf.cancel = context.WithCancel(context.Background())
+	}
+	return sf.ctx
+}
+
+// ListenAndServe 服务, addr 为 "host:port" 或 unix domain socket "unix:///path.sock"
 func (sf *TCPServer) ListenAndServe(addr string) error {
-	listen, err := net.Listen("tcp", addr)
+	listen, err := net.Listen(splitNetworkAddress(addr))
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithCancel(context.Background())
+	return sf.Serve(listen)
+}
+
+// Serve 在监听器上服务, 直到 Close
+func (sf *TCPServer) Serve(listen net.Listener) error {
 	sf.mu.Lock()
 	sf.listen = listen
-	sf.cancel = cancel
+	ctx := sf.serveContext()
 	sf.mu.Unlock()
 
-	sf.Debug("server started,and listen address: %s", addr)
+	sf.Debug("server started,and listen address: %s", listen.Addr())
 	defer func() {
 		sf.Close()
 		sf.Debug("server stopped")
@@ -83,15 +107,28 @@ func (sf *TCPServer) ListenAndServe(addr string) error {
 		}
 		sf.wg.Add(1)
 		go func() {
+			limits, release := sf.sessionLimits(conn.RemoteAddr().String())
 			sess := &ServerSession{
 				conn,
 				sf.readTimeout,
 				sf.writeTimeout,
 				sf.serverCommon,
-				sf.logger,
+				sf.logger.with("remote", conn.RemoteAddr().String()),
+				limits,
+				nil,
 			}
 			sess.running(ctx)
+			release()
 			sf.wg.Done()
 		}()
 	}
 }
+
+// splitNetworkAddress split the address to network and address,
+// "unix:///path.sock" is unix domain socket, others are tcp
+func splitNetworkAddress(addr string) (network, address string) {
+	if strings.HasPrefix(addr, "unix://") {
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	return "tcp", addr
+}
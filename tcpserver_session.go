@@ -2,6 +2,7 @@ package modbus
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -18,15 +19,20 @@ type ServerSession struct {
 	writeTimeout time.Duration
 	*serverCommon
 	logger
+	limits []sessionLimit // 请求速率限制
+	// TLS连接状态, 用于 conn 本身不是 *tls.Conn 的会话, 如 wss 上的会话, nil 时从 conn 获取
+	tlsState *tls.ConnectionState
 }
 
-// handler net conn
-func (sf *ServerSession) running(ctx context.Context) {
-	var err error
+// handler net conn, return the reason why the session stopped
+func (sf *ServerSession) running(ctx context.Context) (err error) {
 	var bytesRead int
 
 	sf.Debug("client(%v) -> server(%v) connected", sf.conn.RemoteAddr(), sf.conn.LocalAddr())
+	metrics := sf.serverMetrics()
+	metrics.ConnectionOpened()
 	defer func() {
+		metrics.ConnectionClosed()
 		sf.conn.Close()
 		sf.Debug("client(%v) -> server(%v) disconnected,cause by %v", sf.conn.RemoteAddr(), sf.conn.LocalAddr(), err)
 	}()
@@ -89,7 +95,6 @@ func (sf *ServerSession) frameHandler(requestAdu []byte) error {
 		}
 	}()
 
-	sf.Debug("RX Raw[% x]", requestAdu)
 	// got head from request adu
 	tcpHeader := protocolTCPHeader{
 		binary.BigEndian.Uint16(requestAdu[0:]),
@@ -97,23 +102,42 @@ func (sf *ServerSession) frameHandler(requestAdu []byte) error {
 		binary.BigEndian.Uint16(requestAdu[4:]),
 		requestAdu[6],
 	}
+	log := sf.with("slave", tcpHeader.slaveID)
+	log.Debug("RX Raw[% x]", requestAdu)
 	funcCode := requestAdu[7]
-	pduData := requestAdu[8:]
+	reqSize := len(requestAdu) - tcpHeaderMbapSize
 
-	node, err := sf.GetNode(tcpHeader.slaveID)
-	if err != nil { // slave id not exit, ignore it
+	start := time.Now()
+	fault := sf.fault(tcpHeader.slaveID, funcCode)
+	var rspPduData []byte
+	err := sf.throttle()
+	if err == nil {
+		err = sf.authorize(tcpHeader.slaveID, funcCode)
+	}
+	if err == nil {
+		rspPduData, err = sf.serveWithFault(fault, &ServerRequest{
+			SlaveID:    tcpHeader.slaveID,
+			FuncCode:   funcCode,
+			Data:       requestAdu[8:],
+			RemoteAddr: sf.conn.RemoteAddr(),
+		})
+	}
+	if err == ErrSlaveNotExist || err == errNoReply { // slave id not exit or broadcast, ignore it
 		return nil
 	}
-	var rspPduData []byte
-	if handle, ok := sf.function[funcCode]; ok {
-		rspPduData, err = handle(node, pduData)
-	} else {
-		err = &ExceptionError{ExceptionCodeIllegalFunction}
+	stat := RequestStat{
+		SlaveID:     tcpHeader.slaveID,
+		FuncCode:    funcCode,
+		RequestSize: reqSize,
 	}
 	if err != nil {
 		funcCode |= 0x80
-		rspPduData = []byte{err.(*ExceptionError).ExceptionCode}
+		rspPduData = []byte{exceptionCode(err)}
+		stat.ExceptionCode = rspPduData[0]
 	}
+	stat.ResponseSize = len(rspPduData) + 1
+	stat.Latency = time.Since(start)
+	sf.serverMetrics().RequestHandled(stat)
 
 	// prepare responseAdu data,fill it
 	responseAdu := requestAdu[:tcpHeaderMbapSize]
@@ -124,7 +148,19 @@ func (sf *ServerSession) frameHandler(requestAdu []byte) error {
 	responseAdu = append(responseAdu, funcCode)
 	responseAdu = append(responseAdu, rspPduData...)
 
-	sf.Debug("TX Raw[% x]", responseAdu)
+	if d := sf.latency(tcpHeader.slaveID); d > 0 {
+		time.Sleep(d)
+	}
+	if fault != nil {
+		time.Sleep(fault.Delay)
+		if fault.Fault == FaultDrop {
+			log.Debug("fault injected, response dropped")
+			return nil
+		}
+		responseAdu = fault.mangle(responseAdu)
+	}
+
+	log.Debug("TX Raw[% x]", responseAdu)
 	// write response
 	return func(b []byte) error {
 		for wrCnt := 0; len(b) > wrCnt; {
@@ -18,13 +18,46 @@ const (
 	DefaultConnectTimeout    = 15 * time.Second
 	DefaultReconnectInterval = 1 * time.Minute
 	DefaultKeepAliveInterval = 30 * time.Second
+	DefaultHandshakeTimeout  = 10 * time.Second
 )
+
+// ConnectState the connection state of TCPServerSpecial
+type ConnectState uint32
+
+// connection state
 const (
-	initial uint32 = iota
-	disconnected
-	connected
+	StateClosed       ConnectState = iota // 未启动或已关闭
+	StateDisconnected                     // 连接断开
+	StateConnecting                       // 正在连接
+	StateBackoff                          // 等待重连
+	StateConnected                        // 已连接
 )
 
+// String implement fmt.Stringer
+func (sf ConnectState) String() string {
+	switch sf {
+	case StateClosed:
+		return "closed"
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateBackoff:
+		return "backoff"
+	case StateConnected:
+		return "connected"
+	}
+	return "unknown"
+}
+
+// ConnectionStatus the connection status snapshot of TCPServerSpecial
+type ConnectionStatus struct {
+	Server         string       // 远端服务器
+	State          ConnectState // 连接状态
+	LastError      error        // 最后一次错误
+	ConnectedSince time.Time    // 连接建立的时间,未连接时为零值
+}
+
 // OnConnectHandler when connected it will be call
 type OnConnectHandler func(c *TCPServerSpecial) error
 
@@ -34,13 +67,21 @@ type OnConnectionLostHandler func(c *TCPServerSpecial)
 // OnKeepAliveHandler keep alive function
 type OnKeepAliveHandler func(c *TCPServerSpecial)
 
-// TCPServerSpecial modbus tcp server special
+// TCPServerSpecial modbus tcp server special,
+// the connections to the other remote servers are cloned from its settings by Start,
+// so the setters take effect only when they are called before Start.
 type TCPServerSpecial struct {
 	ServerSession
-	server    *url.URL // 连接的服务器端
+	server    *url.URL            // 连接的服务器端
+	servers   []*url.URL          // 所有远端服务器,第一个由自身连接
+	remotes   []*TCPServerSpecial // 其余远端服务器,每个远端独立的连接状态
 	TLSConfig *tls.Config
+	dialer    Dialer // 拨号器, nil 直连
 	rwMux     sync.RWMutex
 	status    uint32 // 状态
+	lastErr   error
+	since     time.Time
+	watchers  map[chan ConnectionStatus]struct{}
 
 	connectTimeout    time.Duration           // 连接超时时间
 	autoReconnect     bool                    // 是否启动重连
@@ -50,6 +91,8 @@ type TCPServerSpecial struct {
 	onConnect         OnConnectHandler        // 连接回调
 	onConnectionLost  OnConnectionLostHandler // 失连回调
 	onKeepAlive       OnKeepAliveHandler      // 保活函数
+	handshake         HandshakeHandler        // 握手, nil 不握手
+	handshakeTimeout  time.Duration           // 握手超时时间
 	cancel            context.CancelFunc      // cancel
 }
 
@@ -67,6 +110,7 @@ func NewTCPServerSpecial() *TCPServerSpecial {
 		reconnectInterval: DefaultReconnectInterval,
 		enableKeepAlive:   false,
 		keepAliveInterval: DefaultKeepAliveInterval,
+		handshakeTimeout:  DefaultHandshakeTimeout,
 		onKeepAlive:       func(*TCPServerSpecial) {},
 		onConnect:         func(*TCPServerSpecial) error { return nil },
 		onConnectionLost:  func(*TCPServerSpecial) {},
@@ -93,11 +137,30 @@ func (sf *TCPServerSpecial) EnableAutoReconnect(b bool) {
 	sf.autoReconnect = b
 }
 
-// SetTLSConfig set tls config
+// SetTLSConfig set tls config, when it is set, the connection to the remote server
+// will use tls even if the remote server scheme is "tcp://",
+// set the Certificates for the remote which require mutually authenticated.
 func (sf *TCPServerSpecial) SetTLSConfig(t *tls.Config) {
 	sf.TLSConfig = t
 }
 
+// SetDialer set the dialer of the connection to the remote server, such as ProxyDialer
+// when the remote can only be reached through a proxy, nil dial directly.
+// the TLS, if used, is established over the connection of the dialer.
+func (sf *TCPServerSpecial) SetDialer(d Dialer) {
+	sf.dialer = d
+}
+
+// SetProxy set the proxy of the connection to the remote server, see ProxyDialer
+func (sf *TCPServerSpecial) SetProxy(proxyURL string) error {
+	d, err := ProxyDialer(proxyURL, nil)
+	if err != nil {
+		return err
+	}
+	sf.SetDialer(d)
+	return nil
+}
+
 // SetReadTimeout set read timeout
 func (sf *ServerSession) SetReadTimeout(t time.Duration) {
 	sf.readTimeout = t
@@ -137,6 +200,10 @@ func (sf *TCPServerSpecial) SetKeepAlive(b bool, t time.Duration, f OnKeepAliveH
 // The format should be scheme://host:port
 // Default values for hostname is "127.0.0.1", for schema is "tcp://".
 // An example broker URI would look like: tcp://foobar.com:1204
+// It can be called multiple times, every remote server has its own connection
+// and connection state, but all of them serve the same nodes.
+// the remote server added after Start is connected by the next Start,
+// with the settings at that time.
 func (sf *TCPServerSpecial) AddRemoteServer(server string) error {
 	if len(server) > 0 && server[0] == ':' {
 		server = "127.0.0.1" + server
@@ -148,33 +215,101 @@ func (sf *TCPServerSpecial) AddRemoteServer(server string) error {
 	if err != nil {
 		return err
 	}
-	sf.server = remoteURL
+	sf.rwMux.Lock()
+	sf.servers = append(sf.servers, remoteURL)
+	if sf.server == nil {
+		sf.server = remoteURL
+	}
+	sf.rwMux.Unlock()
 	return nil
 }
 
+// RemoteServer the remote server which this connection connect to
+func (sf *TCPServerSpecial) RemoteServer() string {
+	sf.rwMux.RLock()
+	defer sf.rwMux.RUnlock()
+	return sf.remoteServer()
+}
+
+// Caller must hold the mutex before calling this method.
+func (sf *TCPServerSpecial) remoteServer() string {
+	if sf.server == nil {
+		return ""
+	}
+	return sf.server.String()
+}
+
+// Remotes return all the remote server connection,the first is itself.
+// the others are created when Start with a copy of its settings, changing the settings
+// after Start does not apply to them, they share the same nodes and handler,
+// and the handler will be called with the connection which it belongs to.
+func (sf *TCPServerSpecial) Remotes() []*TCPServerSpecial {
+	sf.rwMux.RLock()
+	list := make([]*TCPServerSpecial, 0, len(sf.remotes)+1)
+	list = append(list, sf)
+	list = append(list, sf.remotes...)
+	sf.rwMux.RUnlock()
+	return list
+}
+
 // Start start the server,and return quickly,if it nil,the server will connecting background,other failed
 func (sf *TCPServerSpecial) Start() error {
+	sf.rwMux.Lock()
 	if sf.server == nil {
+		sf.rwMux.Unlock()
 		return errors.New("empty remote server")
 	}
+	for _, server := range sf.servers[len(sf.remotes)+1:] {
+		sf.remotes = append(sf.remotes, sf.clone(server))
+	}
+	remotes := sf.remotes
+	sf.rwMux.Unlock()
 
 	go sf.run()
+	for _, v := range remotes {
+		go v.run()
+	}
 	return nil
 }
 
+// clone 以当前配置创建一个连接到另一个远端服务器的会话,共用节点
+func (sf *TCPServerSpecial) clone(server *url.URL) *TCPServerSpecial {
+	return &TCPServerSpecial{
+		ServerSession: ServerSession{
+			readTimeout:  sf.readTimeout,
+			writeTimeout: sf.writeTimeout,
+			serverCommon: sf.serverCommon,
+			logger:       sf.logger,
+		},
+		server:            server,
+		TLSConfig:         sf.TLSConfig,
+		dialer:            sf.dialer,
+		connectTimeout:    sf.connectTimeout,
+		autoReconnect:     sf.autoReconnect,
+		reconnectInterval: sf.reconnectInterval,
+		enableKeepAlive:   sf.enableKeepAlive,
+		keepAliveInterval: sf.keepAliveInterval,
+		onConnect:         sf.onConnect,
+		onConnectionLost:  sf.onConnectionLost,
+		onKeepAlive:       sf.onKeepAlive,
+		handshake:         sf.handshake,
+		handshakeTimeout:  sf.handshakeTimeout,
+	}
+}
+
 // 增加间隔
 func (sf *TCPServerSpecial) run() {
 	var ctx context.Context
 
 	sf.rwMux.Lock()
-	if !atomic.CompareAndSwapUint32(&sf.status, initial, disconnected) {
+	if !atomic.CompareAndSwapUint32(&sf.status, uint32(StateClosed), uint32(StateDisconnected)) {
 		sf.rwMux.Unlock()
 		return
 	}
 	ctx, sf.cancel = context.WithCancel(context.Background())
 	sf.rwMux.Unlock()
 	defer func() {
-		sf.setConnectStatus(initial)
+		sf.setConnectStatus(StateClosed, nil)
 		sf.Debug("tcp server special stop!")
 	}()
 	sf.Debug("tcp server special start!")
@@ -187,19 +322,36 @@ func (sf *TCPServerSpecial) run() {
 		}
 
 		sf.Debug("connecting server %+v", sf.server)
-		conn, err := openConnection(sf.server, sf.TLSConfig, sf.connectTimeout)
+		sf.setConnectStatus(StateConnecting, nil)
+		conn, err := openConnection(sf.server, sf.TLSConfig, sf.dialer, sf.connectTimeout)
 		if err != nil {
 			sf.Error("connect failed, %v", err)
 			if !sf.autoReconnect {
 				return
 			}
-			time.Sleep(sf.reconnectInterval)
+			sf.setConnectStatus(StateBackoff, err)
+			if sleepContext(ctx, sf.reconnectInterval) != nil {
+				return
+			}
 			continue
 		}
 		sf.Debug("connect success")
 		sf.conn = conn
 		if err := sf.onConnect(sf); err != nil {
-			time.Sleep(sf.reconnectInterval)
+			conn.Close()
+			sf.setConnectStatus(StateBackoff, err)
+			if sleepContext(ctx, sf.reconnectInterval) != nil {
+				return
+			}
+			continue
+		}
+		if err := sf.runHandshake(conn); err != nil {
+			sf.Error("handshake failed, %v", err)
+			conn.Close()
+			sf.setConnectStatus(StateBackoff, err)
+			if sleepContext(ctx, sf.reconnectInterval) != nil {
+				return
+			}
 			continue
 		}
 
@@ -220,64 +372,138 @@ func (sf *TCPServerSpecial) run() {
 				}
 			}()
 		}
-		sf.setConnectStatus(connected)
-		sf.running(ctx)
-		sf.setConnectStatus(disconnected)
+		sf.setConnectStatus(StateConnected, nil)
+		err = sf.running(ctx)
+		sf.setConnectStatus(StateDisconnected, err)
 		sf.onConnectionLost(sf)
 		close(stopKeepAlive)
-		select {
-		case <-ctx.Done():
+		if ctx.Err() != nil {
+			return
+		}
+		sf.setConnectStatus(StateBackoff, err)
+		// 随机500ms-1s的重试，避免快速重试造成服务器许多无效连接
+		if sleepContext(ctx, time.Millisecond*time.Duration(500+rand.Intn(500))) != nil {
 			return
-		default:
-			// 随机500ms-1s的重试，避免快速重试造成服务器许多无效连接
-			time.Sleep(time.Millisecond * time.Duration(500+rand.Intn(500)))
 		}
 	}
 }
 
-// IsConnected check connect is online
+// IsConnected check connect is online,
+// only the first remote server, use Remotes to check each of them
 func (sf *TCPServerSpecial) IsConnected() bool {
-	return sf.connectStatus() == connected
+	return sf.connectStatus() == StateConnected
 }
 
 // IsClosed check server is closed
 func (sf *TCPServerSpecial) IsClosed() bool {
-	return sf.connectStatus() == initial
+	return sf.connectStatus() == StateClosed
+}
+
+// Status return the connection status snapshot
+func (sf *TCPServerSpecial) Status() ConnectionStatus {
+	sf.rwMux.RLock()
+	status := sf.statusSnapshot()
+	sf.rwMux.RUnlock()
+	return status
+}
+
+// SubscribeStatus subscribe the connection state change,
+// the channel has size buffer, when it is full,the state change will be discarded.
+// call the returned function to unsubscribe,it will close the channel.
+func (sf *TCPServerSpecial) SubscribeStatus(size int) (<-chan ConnectionStatus, func()) {
+	ch := make(chan ConnectionStatus, size)
+	sf.rwMux.Lock()
+	if sf.watchers == nil {
+		sf.watchers = make(map[chan ConnectionStatus]struct{})
+	}
+	sf.watchers[ch] = struct{}{}
+	sf.rwMux.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			sf.rwMux.Lock()
+			delete(sf.watchers, ch)
+			sf.rwMux.Unlock()
+			close(ch)
+		})
+	}
 }
 
-// Close close the server
+// Close close the server, include all the remote server connection
 func (sf *TCPServerSpecial) Close() error {
 	sf.rwMux.Lock()
 	if sf.cancel != nil {
 		sf.cancel()
 	}
+	remotes := sf.remotes
 	sf.rwMux.Unlock()
+	for _, v := range remotes {
+		v.Close()
+	}
 	return nil
 }
 
-func (sf *TCPServerSpecial) setConnectStatus(status uint32) {
+func (sf *TCPServerSpecial) setConnectStatus(state ConnectState, err error) {
 	sf.rwMux.Lock()
-	atomic.StoreUint32(&sf.status, status)
+	atomic.StoreUint32(&sf.status, uint32(state))
+	if err != nil {
+		sf.lastErr = err
+	}
+	if state == StateConnected {
+		sf.since = time.Now()
+	} else {
+		sf.since = time.Time{}
+	}
+	status := sf.statusSnapshot()
+	for ch := range sf.watchers {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
 	sf.rwMux.Unlock()
 }
 
-func (sf *TCPServerSpecial) connectStatus() uint32 {
+// Caller must hold the mutex before calling this method.
+func (sf *TCPServerSpecial) statusSnapshot() ConnectionStatus {
+	return ConnectionStatus{
+		Server:         sf.remoteServer(),
+		State:          ConnectState(atomic.LoadUint32(&sf.status)),
+		LastError:      sf.lastErr,
+		ConnectedSince: sf.since,
+	}
+}
+
+func (sf *TCPServerSpecial) connectStatus() ConnectState {
 	sf.rwMux.RLock()
 	status := atomic.LoadUint32(&sf.status)
 	sf.rwMux.RUnlock()
-	return status
+	return ConnectState(status)
 }
 
-func openConnection(uri *url.URL, tlsc *tls.Config, timeout time.Duration) (net.Conn, error) {
+func openConnection(uri *url.URL, tlsc *tls.Config, d Dialer, timeout time.Duration) (net.Conn, error) {
+	var useTLS bool
 	switch uri.Scheme {
 	case "tcp":
-		return net.DialTimeout("tcp", uri.Host, timeout)
-	case "ssl":
-		fallthrough
-	case "tls":
-		fallthrough
-	case "tcps":
-		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", uri.Host, tlsc)
+		useTLS = tlsc != nil
+	case "ssl", "tls", "tcps":
+		useTLS = true
+	default:
+		return nil, errors.New("Unknown protocol")
+	}
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn, err := dialContext(ctx, d, "tcp", uri.Host, 0)
+	if err != nil || !useTLS {
+		return conn, err
 	}
-	return nil, errors.New("Unknown protocol")
+	return tlsHandshake(ctx, conn, uri.Host, tlsc)
 }
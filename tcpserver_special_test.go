@@ -0,0 +1,299 @@
+package modbus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// acceptAndRead 接受一个反向连接,并请求读取保持寄存器
+func acceptAndRead(t *testing.T, l net.Listener, slaveID byte) []byte {
+	l.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+	return requestHoldings(t, conn, slaveID)
+}
+
+// requestHoldings 在连接上请求读取保持寄存器
+func requestHoldings(t *testing.T, conn net.Conn, slaveID byte) []byte {
+	frame := &protocolFrame{make([]byte, 0, tcpAduMaxSize)}
+	_, adu, _ := frame.encodeTCPFrame(1, slaveID, ProtocolDataUnit{
+		FuncCodeReadHoldingRegisters, pduDataBlock(0, 2)})
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(adu); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	head := make([]byte, tcpHeaderMbapSize)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	pdu := make([]byte, binary.BigEndian.Uint16(head[4:])-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	return pdu
+}
+
+func TestTCPServerSpecial_MultipleRemote(t *testing.T) {
+	l1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l1.Close()
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10)
+	node.WriteHoldings(0, []uint16{0x1234, 0x5678})
+
+	srv := NewTCPServerSpecial()
+	srv.AddNodes(node)
+	if err = srv.AddRemoteServer(l1.Addr().String()); err != nil {
+		t.Fatalf("AddRemoteServer() error = %v", err)
+	}
+	if err = srv.AddRemoteServer(l2.Addr().String()); err != nil {
+		t.Fatalf("AddRemoteServer() error = %v", err)
+	}
+	if err = srv.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer srv.Close()
+
+	remotes := srv.Remotes()
+	if len(remotes) != 2 {
+		t.Fatalf("Remotes() len = %v, want %v", len(remotes), 2)
+	}
+	if remotes[1].RemoteServer() != "tcp://"+l2.Addr().String() {
+		t.Errorf("RemoteServer() = %v, want %v", remotes[1].RemoteServer(), "tcp://"+l2.Addr().String())
+	}
+
+	want := []byte{FuncCodeReadHoldingRegisters, 4, 0x12, 0x34, 0x56, 0x78}
+	for _, l := range []net.Listener{l1, l2} {
+		if got := acceptAndRead(t, l, testslaveID1); !reflect.DeepEqual(got, want) {
+			t.Errorf("response pdu = % x, want % x", got, want)
+		}
+	}
+}
+
+// newTestCertificate 生成自签名证书,用于tls测试
+func newTestCertificate(t *testing.T, cn string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestTCPServerSpecial_TLS(t *testing.T) {
+	srvCert, srvPool := newTestCertificate(t, "master")
+	cliCert, cliPool := newTestCertificate(t, "slave")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	tl := tls.NewListener(l, &tls.Config{
+		Certificates: []tls.Certificate{srvCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    cliPool,
+	})
+
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10)
+	node.WriteHoldings(0, []uint16{0x1234, 0x5678})
+
+	srv := NewTCPServerSpecial()
+	srv.AddNodes(node)
+	srv.SetTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{cliCert},
+		RootCAs:      srvPool,
+	})
+	if err = srv.AddRemoteServer(l.Addr().String()); err != nil {
+		t.Fatalf("AddRemoteServer() error = %v", err)
+	}
+	if err = srv.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer srv.Close()
+
+	l.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := tl.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err = conn.(*tls.Conn).Handshake(); err != nil {
+		t.Fatalf("Handshake() error = %v", err)
+	}
+	peers := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(peers) == 0 || peers[0].Subject.CommonName != "slave" {
+		t.Fatalf("peer certificates = %v, want CommonName %v", peers, "slave")
+	}
+
+	frame := &protocolFrame{make([]byte, 0, tcpAduMaxSize)}
+	_, adu, _ := frame.encodeTCPFrame(1, testslaveID1, ProtocolDataUnit{
+		FuncCodeReadHoldingRegisters, pduDataBlock(0, 2)})
+	if _, err = conn.Write(adu); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	rsp := make([]byte, tcpHeaderMbapSize+6)
+	if _, err = io.ReadFull(conn, rsp); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []byte{FuncCodeReadHoldingRegisters, 4, 0x12, 0x34, 0x56, 0x78}
+	if !reflect.DeepEqual(rsp[tcpHeaderMbapSize:], want) {
+		t.Errorf("response pdu = % x, want % x", rsp[tcpHeaderMbapSize:], want)
+	}
+}
+
+func TestTCPServerSpecial_Status(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	srv := NewTCPServerSpecial()
+	if got := srv.Status().State; got != StateClosed {
+		t.Errorf("Status().State = %v, want %v", got, StateClosed)
+	}
+	ch, unsubscribe := srv.SubscribeStatus(16)
+	defer unsubscribe()
+
+	if err = srv.AddRemoteServer(l.Addr().String()); err != nil {
+		t.Fatalf("AddRemoteServer() error = %v", err)
+	}
+	if err = srv.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer srv.Close()
+
+	l.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+
+	waitState := func(want ConnectState) ConnectionStatus {
+		for {
+			select {
+			case status := <-ch:
+				if status.State == want {
+					return status
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("wait state %v timeout", want)
+			}
+		}
+	}
+	status := waitState(StateConnected)
+	if status.ConnectedSince.IsZero() {
+		t.Errorf("ConnectedSince should not be zero")
+	}
+	if !srv.IsConnected() {
+		t.Errorf("IsConnected() = %v, want %v", false, true)
+	}
+
+	conn.Close()
+	status = waitState(StateDisconnected)
+	if status.LastError == nil {
+		t.Errorf("LastError should not be nil")
+	}
+	if !status.ConnectedSince.IsZero() {
+		t.Errorf("ConnectedSince = %v, want zero", status.ConnectedSince)
+	}
+}
+
+func TestTCPServerSpecial_CloseInBackoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close() // the connection is refused
+
+	srv := NewTCPServerSpecial()
+	srv.SetReconnectInterval(time.Hour)
+	ch, unsubscribe := srv.SubscribeStatus(16)
+	defer unsubscribe()
+	if err = srv.AddRemoteServer(addr); err != nil {
+		t.Fatalf("AddRemoteServer() error = %v", err)
+	}
+	if err = srv.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	for _, want := range []ConnectState{StateBackoff, StateClosed} {
+	wait:
+		for {
+			select {
+			case status := <-ch:
+				if status.State == want {
+					break wait
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("wait state %v timeout", want)
+			}
+		}
+		if want == StateBackoff {
+			srv.Close() // the backoff is interrupted
+		}
+	}
+}
+
+func TestTCPServerSpecial_RemoteServerConcurrent(t *testing.T) {
+	srv := NewTCPServerSpecial()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = srv.AddRemoteServer("127.0.0.1:" + strconv.Itoa(10000+i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = srv.RemoteServer()
+	}
+	<-done
+	if got := srv.RemoteServer(); got != "tcp://127.0.0.1:10000" {
+		t.Errorf("RemoteServer() = %v, want the first added", got)
+	}
+}
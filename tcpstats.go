@@ -0,0 +1,37 @@
+package modbus
+
+import (
+	"sync"
+)
+
+// TCPStats TCP 连接的帧统计
+type TCPStats struct {
+	StaleResponses uint64 // 丢弃的过期响应数, 即超时事务的迟到响应
+}
+
+// tcpCounters the frame counters of the connection
+type tcpCounters struct {
+	mu sync.Mutex
+	s  TCPStats
+}
+
+// stale a stale response is discarded
+func (sf *tcpCounters) stale() {
+	sf.mu.Lock()
+	sf.s.StaleResponses++
+	sf.mu.Unlock()
+}
+
+// TCPStats return the frame counters of the provider since created or last reset
+func (sf *TCPClientProvider) TCPStats() TCPStats {
+	sf.counters.mu.Lock()
+	defer sf.counters.mu.Unlock()
+	return sf.counters.s
+}
+
+// ResetTCPStats clear the frame counters of the provider
+func (sf *TCPClientProvider) ResetTCPStats() {
+	sf.counters.mu.Lock()
+	sf.counters.s = TCPStats{}
+	sf.counters.mu.Unlock()
+}
@@ -0,0 +1,106 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPClientProvider_staleResponse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the server reply a late response of the previous transaction ahead of the right one
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req := make([]byte, 12)
+		if _, err = io.ReadFull(conn, req); err != nil {
+			return
+		}
+		tid := binary.BigEndian.Uint16(req)
+		rsp := []byte{0, 0, 0x00, 0x00, 0x00, 0x05, req[6], req[7], 0x02, 0x12, 0x34}
+		binary.BigEndian.PutUint16(rsp, tid-1)
+		stale := append([]byte{}, rsp...)
+		rsp[9], rsp[10] = 0x56, 0x78
+		binary.BigEndian.PutUint16(rsp, tid)
+		_, _ = conn.Write(append(stale, rsp...))
+	}()
+
+	p := NewTCPClientProvider(ln.Addr().String())
+	c := NewClient(p)
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, err := c.ReadHoldingRegisters(1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0x5678 {
+		t.Errorf("ReadHoldingRegisters() = %#x, want 0x5678", got[0])
+	}
+	if s := p.TCPStats(); s.StaleResponses != 1 {
+		t.Errorf("TCPStats().StaleResponses = %v, want 1", s.StaleResponses)
+	}
+	p.ResetTCPStats()
+	if s := p.TCPStats(); s.StaleResponses != 0 {
+		t.Errorf("TCPStats() after reset = %+v, want zero", s)
+	}
+}
+
+func TestTCPClientProvider_staleResponseDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the server keep replying stale responses, each one within the timeout
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req := make([]byte, 12)
+		if _, err = io.ReadFull(conn, req); err != nil {
+			return
+		}
+		rsp := []byte{0, 0, 0x00, 0x00, 0x00, 0x05, req[6], req[7], 0x02, 0x12, 0x34}
+		binary.BigEndian.PutUint16(rsp, binary.BigEndian.Uint16(req)-1)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(40 * time.Millisecond):
+			}
+			if _, err = conn.Write(rsp); err != nil {
+				return
+			}
+		}
+	}()
+
+	p := NewTCPClientProvider(ln.Addr().String())
+	p.SetTimeout(150 * time.Millisecond)
+	c := NewClient(p)
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	start := time.Now()
+	if _, err = c.ReadHoldingRegisters(1, 0, 1); err == nil {
+		t.Fatal("ReadHoldingRegisters() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("ReadHoldingRegisters() took %v, want about the timeout", elapsed)
+	}
+}
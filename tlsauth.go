@@ -0,0 +1,193 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"net"
+	"sync"
+)
+
+// OIDModbusRole the x509v3 extension of the role in the client certificate,
+// defined by the Modbus/TCP Security specification, the value is an ASN.1 UTF8String.
+var OIDModbusRole = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// RoleExtractor got the role from the client certificate, ok is false if it has not a role
+type RoleExtractor func(cert *x509.Certificate) (role string, ok bool)
+
+// ModbusRole got the role of the Modbus/TCP Security role extension
+func ModbusRole(cert *x509.Certificate) (string, bool) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(OIDModbusRole) {
+			continue
+		}
+		var role string
+		if _, err := asn1.Unmarshal(ext.Value, &role); err != nil {
+			return "", false
+		}
+		return role, true
+	}
+	return "", false
+}
+
+// OrganizationalUnitRole got the role of the first organizational unit (OU) of the subject
+func OrganizationalUnitRole(cert *x509.Certificate) (string, bool) {
+	if len(cert.Subject.OrganizationalUnit) == 0 {
+		return "", false
+	}
+	return cert.Subject.OrganizationalUnit[0], true
+}
+
+// Permission the access granted to a role
+type Permission struct {
+	Write   bool   // read-write if true, read-only if false
+	UnitIDs []byte // the allowed unit ids, empty allows all
+}
+
+// allow whether the function code to the unit id is permitted
+func (p Permission) allow(slaveID, funcCode byte) bool {
+	if !p.Write {
+		for _, fc := range writeFuncCodes {
+			if fc == funcCode {
+				return false
+			}
+		}
+	}
+	if len(p.UnitIDs) == 0 {
+		return true
+	}
+	for _, id := range p.UnitIDs {
+		if id == slaveID {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleAuthorizer map the role of the client certificate to the permission,
+// the request not permitted is replied the exception illegal function (0x01) as the
+// Modbus/TCP Security specification, the connection without a known role is denied.
+type RoleAuthorizer struct {
+	extract RoleExtractor
+	mu      sync.RWMutex
+	roles   map[string]Permission
+}
+
+// NewRoleAuthorizer new a role authorizer, extract is ModbusRole if it is nil
+func NewRoleAuthorizer(extract RoleExtractor) *RoleAuthorizer {
+	if extract == nil {
+		extract = ModbusRole
+	}
+	return &RoleAuthorizer{extract: extract, roles: make(map[string]Permission)}
+}
+
+// SetRole set the permission of the role, the empty role is the permission of the
+// clients which certificate has not a role.
+func (sf *RoleAuthorizer) SetRole(role string, p Permission) {
+	sf.mu.Lock()
+	sf.roles[role] = p
+	sf.mu.Unlock()
+}
+
+// DeleteRole delete the role, the clients of it are denied
+func (sf *RoleAuthorizer) DeleteRole(role string) {
+	sf.mu.Lock()
+	delete(sf.roles, role)
+	sf.mu.Unlock()
+}
+
+// Authorize implements Authorizer
+func (sf *RoleAuthorizer) Authorize(certs []*x509.Certificate, slaveID, funcCode byte) error {
+	var role string
+	if len(certs) > 0 {
+		role, _ = sf.extract(certs[0])
+	}
+	sf.mu.RLock()
+	p, ok := sf.roles[role]
+	sf.mu.RUnlock()
+	if !ok || !p.allow(slaveID, funcCode) {
+		return &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}
+	}
+	return nil
+}
+
+// Authorizer authorize the request of the TLS client by its verified certificate chain,
+// certs[0] is the client certificate, the error is replied to the client, usually an ExceptionError.
+type Authorizer interface {
+	Authorize(certs []*x509.Certificate, slaveID, funcCode byte) error
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as Authorizer.
+type AuthorizerFunc func(certs []*x509.Certificate, slaveID, funcCode byte) error
+
+// Authorize implements Authorizer, calls f(certs, slaveID, funcCode).
+func (f AuthorizerFunc) Authorize(certs []*x509.Certificate, slaveID, funcCode byte) error {
+	return f(certs, slaveID, funcCode)
+}
+
+// RoleHook the role enforcement hook, extract the role from the client certificate
+// and let f decide, role is empty if the certificate has not a role, extract is ModbusRole if it is nil,
+// f return an error to deny the request, such as &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}.
+func RoleHook(extract RoleExtractor, f func(role string, slaveID, funcCode byte) error) Authorizer {
+	if extract == nil {
+		extract = ModbusRole
+	}
+	return AuthorizerFunc(func(certs []*x509.Certificate, slaveID, funcCode byte) error {
+		var role string
+		if len(certs) > 0 {
+			role, _ = extract(certs[0])
+		}
+		return f(role, slaveID, funcCode)
+	})
+}
+
+// authorizerHolder atomic.Value 需要存储相同的具体类型
+type authorizerHolder struct {
+	Authorizer
+}
+
+// SetAuthorizer enforce the authorization on the TLS connections, such as RoleAuthorizer
+// and RoleHook, nil to disable it, the plain tcp connections are not affected.
+// the websocket sessions served by WebSocketHandler on a https server (wss) are authorized too.
+func (sf *serverCommon) SetAuthorizer(a Authorizer) {
+	sf.authorizer.Store(authorizerHolder{a})
+}
+
+// connectionState the TLS state of the session, nil if the session is not TLS
+func (sf *ServerSession) connectionState() *tls.ConnectionState {
+	if sf.tlsState != nil {
+		return sf.tlsState
+	}
+	if conn, ok := sf.conn.(*tls.Conn); ok {
+		state := conn.ConnectionState()
+		return &state
+	}
+	return nil
+}
+
+// authorize check the request with the authorizer if the session is TLS, include wss
+func (sf *ServerSession) authorize(slaveID, funcCode byte) error {
+	a, ok := sf.authorizer.Load().(authorizerHolder)
+	if !ok || a.Authorizer == nil {
+		return nil
+	}
+	state := sf.connectionState()
+	if state == nil {
+		return nil
+	}
+	return a.Authorize(state.PeerCertificates, slaveID, funcCode)
+}
+
+// ListenAndServeTLS 在TLS上服务, 即 Modbus/TCP Security, addr 没有端口时使用 SecurityDefaultPort,
+// config 需要设置服务端证书, 需要客户端证书授权时设置 ClientAuth 和 ClientCAs, 见 SecurityServerConfig 和 SetAuthorizer
+func (sf *TCPServer) ListenAndServeTLS(addr string, config *tls.Config) error {
+	network, address := splitNetworkAddress(addr)
+	if network == "tcp" {
+		address = withSecurityPort(address)
+	}
+	listen, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return sf.Serve(tls.NewListener(listen, config))
+}
@@ -0,0 +1,192 @@
+package modbus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA a self signed ca issue the certificates for test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert, key, pool}
+}
+
+// issue a certificate, with the modbus role extension if role is not empty
+func (sf *testCA) issue(t *testing.T, serial int64, role, ou string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if ou != "" {
+		tmpl.Subject.OrganizationalUnit = []string{ou}
+	}
+	if role != "" {
+		v, _ := asn1.MarshalWithParams(role, "utf8")
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: OIDModbusRole, Value: v}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, sf.cert, &key.PublicKey, sf.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestModbusRole(t *testing.T) {
+	ca := newTestCA(t)
+	c := ca.issue(t, 2, "operator", "viewer")
+	cert, _ := x509.ParseCertificate(c.Certificate[0])
+	if role, ok := ModbusRole(cert); !ok || role != "operator" {
+		t.Errorf("ModbusRole() = %v, %v, want operator", role, ok)
+	}
+	if role, ok := OrganizationalUnitRole(cert); !ok || role != "viewer" {
+		t.Errorf("OrganizationalUnitRole() = %v, %v, want viewer", role, ok)
+	}
+	c = ca.issue(t, 3, "", "")
+	cert, _ = x509.ParseCertificate(c.Certificate[0])
+	if _, ok := ModbusRole(cert); ok {
+		t.Errorf("ModbusRole() without extension, want not ok")
+	}
+	if _, ok := OrganizationalUnitRole(cert); ok {
+		t.Errorf("OrganizationalUnitRole() without ou, want not ok")
+	}
+}
+
+func Test_TCPServerTLSAuthorizer(t *testing.T) {
+	ca := newTestCA(t)
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10),
+		NewNodeRegister(2, 0, 10, 0, 10, 0, 10, 0, 10))
+	auth := NewRoleAuthorizer(nil)
+	auth.SetRole("operator", Permission{Write: true})
+	auth.SetRole("viewer", Permission{UnitIDs: []byte{1}})
+	srv.SetAuthorizer(auth)
+	go srv.Serve(tls.NewListener(listen, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, 2, "", "")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	}))
+	defer srv.Close()
+
+	connect := func(cert tls.Certificate) Client {
+		p := NewTCPClientProvider(listen.Addr().String())
+		p.SetDialer(DialerFunc(func(network, address string) (net.Conn, error) {
+			return tls.Dial(network, address, &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				RootCAs:      ca.pool,
+			})
+		}))
+		c := NewClient(p)
+		if err := c.Connect(); err != nil {
+			t.Fatalf("Connect error = %v", err)
+		}
+		return c
+	}
+
+	operator := connect(ca.issue(t, 3, "operator", ""))
+	defer operator.Close()
+	if err = operator.WriteSingleRegister(2, 0, 1); err != nil {
+		t.Errorf("operator WriteSingleRegister() error = %v", err)
+	}
+
+	viewer := connect(ca.issue(t, 4, "viewer", ""))
+	defer viewer.Close()
+	if _, err = viewer.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Errorf("viewer ReadHoldingRegisters() error = %v", err)
+	}
+	if err = viewer.WriteSingleRegister(1, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("viewer WriteSingleRegister() error = %v, want illegal function", err)
+	}
+	if _, err = viewer.ReadHoldingRegisters(2, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("viewer ReadHoldingRegisters() unit 2 error = %v, want illegal function", err)
+	}
+
+	nobody := connect(ca.issue(t, 5, "", "viewer"))
+	defer nobody.Close()
+	if _, err = nobody.ReadHoldingRegisters(1, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("no role ReadHoldingRegisters() error = %v, want illegal function", err)
+	}
+}
+
+func Test_WebSocketTLSAuthorizer(t *testing.T) {
+	ca := newTestCA(t)
+	srv := NewTCPServer()
+	srv.AddNodes(NewNodeRegister(1, 0, 10, 0, 10, 0, 10, 0, 10))
+	auth := NewRoleAuthorizer(nil)
+	auth.SetRole("viewer", Permission{})
+	srv.SetAuthorizer(auth)
+	httpSrv := httptest.NewUnstartedServer(srv.WebSocketHandler())
+	httpSrv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, 2, "", "")},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	}
+	httpSrv.StartTLS()
+	defer httpSrv.Close()
+
+	// the websocket runs on the tls connection of the dialer, as wss does
+	p := NewWebSocketClientProvider("ws" + strings.TrimPrefix(httpSrv.URL, "https") + "/modbus")
+	p.SetDialer(SecurityDialer(nil, &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, 3, "viewer", "")},
+		RootCAs:      ca.pool,
+	}))
+	c := NewClient(p)
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer c.Close()
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Errorf("viewer ReadHoldingRegisters() error = %v", err)
+	}
+	if err := c.WriteSingleRegister(1, 0, 1); !IsIllegalFunction(err) {
+		t.Errorf("viewer WriteSingleRegister() error = %v, want illegal function", err)
+	}
+}
@@ -0,0 +1,74 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+)
+
+// transport name used by tracing
+const (
+	TransportTCP   = "tcp"
+	TransportRTU   = "rtu"
+	TransportASCII = "ascii"
+)
+
+// TraceInfo the attributes of a modbus transaction
+type TraceInfo struct {
+	Transport string // transport name, see TransportTCP etc.
+	SlaveID   byte
+	FuncCode  byte
+	Address   uint16 // start address, 0 if the function has not address
+	Quantity  uint16 // quantity, 1 for single coil and register, 0 if the function has not quantity
+}
+
+// Tracer emits a span per modbus transaction,
+// it can be adapted to OpenTelemetry or other tracing system.
+type Tracer interface {
+	// Start a span with the transaction attributes as a child of the span in ctx,
+	// and return the context carry the new span and a function which end it with the transaction error.
+	Start(ctx context.Context, info TraceInfo) (context.Context, func(err error))
+}
+
+// TracingMiddleware return a client middleware which emit a span per transaction.
+// use it like: client.Use(modbus.TracingMiddleware(tracer, modbus.TransportTCP)),
+// the span is the child of the span in the context of Client.WithContext,
+// such as the trace of the HTTP request which issue the transaction.
+func TracingMiddleware(t Tracer, transport string) Middleware {
+	return func(next Doer) Doer {
+		return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+			info := TraceInfo{
+				Transport: transport,
+				SlaveID:   slaveID,
+				FuncCode:  request.FuncCode,
+			}
+			info.Address, info.Quantity = pduAddressQuantity(request)
+			ctx, end := t.Start(ctx, info)
+			response, err := DoContext(ctx, next, slaveID, request)
+			end(err)
+			return response, err
+		})
+	}
+}
+
+// pduAddressQuantity got the address and quantity of the request pdu
+func pduAddressQuantity(pdu ProtocolDataUnit) (address, quantity uint16) {
+	data := pdu.Data
+	switch pdu.FuncCode {
+	case FuncCodeReadCoils, FuncCodeReadDiscreteInputs,
+		FuncCodeReadHoldingRegisters, FuncCodeReadInputRegisters,
+		FuncCodeWriteMultipleCoils, FuncCodeWriteMultipleRegisters,
+		FuncCodeReadWriteMultipleRegisters:
+		if len(data) >= 4 {
+			address, quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		}
+	case FuncCodeWriteSingleCoil, FuncCodeWriteSingleRegister, FuncCodeMaskWriteRegister:
+		if len(data) >= 2 {
+			address, quantity = binary.BigEndian.Uint16(data), 1
+		}
+	case FuncCodeReadFIFOQueue:
+		if len(data) >= 2 {
+			address = binary.BigEndian.Uint16(data)
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type spanKey struct{}
+
+type testTracer struct {
+	infos   []TraceInfo
+	errs    []error
+	parents []interface{}
+}
+
+func (sf *testTracer) Start(ctx context.Context, info TraceInfo) (context.Context, func(err error)) {
+	sf.infos = append(sf.infos, info)
+	sf.parents = append(sf.parents, ctx.Value(spanKey{}))
+	return context.WithValue(ctx, spanKey{}, "modbus"), func(err error) {
+		sf.errs = append(sf.errs, err)
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	tracer := &testTracer{}
+	c := NewClient(&provider{data: []byte{0x00, 0x01, 0xff, 0x00}})
+	c.Use(TracingMiddleware(tracer, TransportRTU))
+	if err := c.WriteSingleCoil(2, 1, true); err != nil {
+		t.Fatalf("WriteSingleCoil() error = %v", err)
+	}
+
+	c = NewClient(&provider{err: errors.New("timeout")})
+	c.Use(TracingMiddleware(tracer, TransportTCP))
+	if _, err := c.ReadHoldingRegisters(3, 100, 10); err == nil {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want error", err)
+	}
+
+	want := []TraceInfo{
+		{TransportRTU, 2, FuncCodeWriteSingleCoil, 1, 1},
+		{TransportTCP, 3, FuncCodeReadHoldingRegisters, 100, 10},
+	}
+	if !reflect.DeepEqual(tracer.infos, want) {
+		t.Errorf("trace info = %+v, want %+v", tracer.infos, want)
+	}
+	if len(tracer.errs) != 2 || tracer.errs[0] != nil || tracer.errs[1] == nil {
+		t.Errorf("trace errors = %v, want [nil timeout]", tracer.errs)
+	}
+}
+
+func TestTracingMiddleware_Context(t *testing.T) {
+	tracer := &testTracer{}
+	var inner interface{}
+	c := NewClient(&provider{data: []byte{0x02, 0x00, 0x01}})
+	c.Use(TracingMiddleware(tracer, TransportTCP), func(next Doer) Doer {
+		return ContextDoerFunc(func(ctx context.Context, slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+			inner = ctx.Value(spanKey{})
+			return DoContext(ctx, next, slaveID, request)
+		})
+	})
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "http")
+	if _, err := c.WithContext(ctx).ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"http", nil}; !reflect.DeepEqual(tracer.parents, want) {
+		t.Errorf("parent spans = %v, want %v", tracer.parents, want)
+	}
+	if inner != "modbus" {
+		t.Errorf("inner middleware span = %v, want modbus", inner)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.WithContext(cancelled).ReadHoldingRegisters(1, 0, 1); err != context.Canceled {
+		t.Errorf("cancelled ReadHoldingRegisters() error = %v, want %v", err, context.Canceled)
+	}
+}
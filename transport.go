@@ -0,0 +1,165 @@
+package modbus
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Transport 链路, 负责收发整帧, 不关心帧格式, 配合 Codec 使用
+type Transport interface {
+	// Open open the link
+	Open() error
+	// Transact send the request adu and read the whole response adu,
+	// the broadcast request should return nil response without waiting.
+	Transact(aduRequest []byte) (aduResponse []byte, err error)
+	// Close close the link
+	Close() error
+}
+
+// TransportProvider implements ClientProvider interface over any Transport with a Codec,
+// it reuses the transaction logic of the built-in providers, such as the verify, quirks, capture and statistics.
+type TransportProvider struct {
+	logger
+	providerCommon
+	mu            sync.Mutex
+	transport     Transport
+	codec         Codec
+	connected     bool
+	autoReconnect byte
+}
+
+// check TransportProvider implements underlying method
+var _ ClientProvider = (*TransportProvider)(nil)
+
+// NewTransportProvider allocates a TransportProvider with the transport and codec
+func NewTransportProvider(t Transport, c Codec) *TransportProvider {
+	return &TransportProvider{
+		logger:    newLogger("modbusTransportMaster =>"),
+		transport: t,
+		codec:     c,
+	}
+}
+
+// Connect open the transport
+func (sf *TransportProvider) Connect() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.connect()
+}
+
+// Caller must hold the mutex before calling this method.
+func (sf *TransportProvider) connect() error {
+	if err := sf.transport.Open(); err != nil {
+		return err
+	}
+	sf.connected = true
+	sf.emitConnected()
+	return nil
+}
+
+// IsConnected returns a bool signifying whether the transport is opened or not.
+func (sf *TransportProvider) IsConnected() bool {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.connected
+}
+
+// SetAutoReconnect set auto reconnect count,
+// the transport is reopened at most cnt times after the transaction failed,
+// the failed request is not resent.
+// if cnt == 0, disable auto reconnect
+// if cnt > 0 ,enable auto reconnect,but max 6
+func (sf *TransportProvider) SetAutoReconnect(cnt byte) {
+	sf.mu.Lock()
+	sf.autoReconnect = cnt
+	if sf.autoReconnect > 6 {
+		sf.autoReconnect = 6
+	}
+	sf.mu.Unlock()
+}
+
+// Close close the transport
+func (sf *TransportProvider) Close() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if !sf.connected {
+		return nil
+	}
+	sf.connected = false
+	sf.emitDisconnected(nil)
+	return sf.transport.Close()
+}
+
+// Send request to the remote server,it implements on SendRawFrame
+func (sf *TransportProvider) Send(slaveID byte, request ProtocolDataUnit) (ProtocolDataUnit, error) {
+	aduRequest, err := sf.codec.Encode(slaveID, request)
+	if err != nil {
+		return ProtocolDataUnit{}, err
+	}
+	aduResponse, err := sf.SendRawFrame(aduRequest)
+	if err != nil || slaveID == AddressBroadCast && len(aduResponse) == 0 {
+		return ProtocolDataUnit{}, err
+	}
+	rspSlaveID, response, err := sf.codec.Decode(aduRequest, aduResponse)
+	if err != nil {
+		return ProtocolDataUnit{}, err
+	}
+	rspSlaveID = sf.tolerate(slaveID, rspSlaveID, request, &response)
+	if err = verify(slaveID, rspSlaveID, request, response); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// SendPdu send pdu request to the remote server
+func (sf *TransportProvider) SendPdu(slaveID byte, pduRequest []byte) ([]byte, error) {
+	if len(pduRequest) < pduMinSize || len(pduRequest) > pduMaxSize {
+		return nil, fmt.Errorf("modbus: pdu size '%v' must not be between '%v' and '%v'",
+			len(pduRequest), pduMinSize, pduMaxSize)
+	}
+	response, err := sf.Send(slaveID, ProtocolDataUnit{pduRequest[0], pduRequest[1:]})
+	if err != nil || slaveID == AddressBroadCast && response.FuncCode == 0 {
+		return nil, err
+	}
+	return append([]byte{response.FuncCode}, response.Data...), nil
+}
+
+// SendRawFrame send the adu frame through the transport
+func (sf *TransportProvider) SendRawFrame(aduRequest []byte) (aduResponse []byte, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if !sf.connected {
+		return nil, ErrClosedConnection
+	}
+	sf.pace()
+	sf.Debug("sending [% x]", aduRequest)
+	sf.tapSend(aduRequest)
+	aduResponse, err = sf.transport.Transact(aduRequest)
+	if err != nil {
+		sf.reconnect(err)
+		return nil, err
+	}
+	if len(aduResponse) > 0 {
+		sf.Debug("received [% x]", aduResponse)
+		sf.tapReceived(aduResponse)
+	}
+	return aduResponse, nil
+}
+
+// reconnect reopen the transport after the transaction failed
+// Caller must hold the mutex before calling this method.
+func (sf *TransportProvider) reconnect(err error) {
+	if sf.autoReconnect == 0 {
+		return
+	}
+	sf.transport.Close()
+	sf.connected = false
+	sf.emitDisconnected(err)
+	for i := byte(0); i < sf.autoReconnect; i++ {
+		sf.emitReconnecting(int(i) + 1)
+		if err := sf.connect(); err == nil {
+			return
+		}
+	}
+}
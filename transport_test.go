@@ -0,0 +1,80 @@
+package modbus
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// scriptTransport reply the responses one by one
+type scriptTransport struct {
+	opened    int
+	sent      [][]byte
+	responses [][]byte
+	err       error
+}
+
+func (sf *scriptTransport) Open() error  { sf.opened++; return nil }
+func (sf *scriptTransport) Close() error { return nil }
+func (sf *scriptTransport) Transact(aduRequest []byte) ([]byte, error) {
+	sf.sent = append(sf.sent, aduRequest)
+	if sf.err != nil {
+		return nil, sf.err
+	}
+	if len(sf.responses) == 0 || aduRequest[0] == AddressBroadCast {
+		return nil, nil
+	}
+	rsp := sf.responses[0]
+	sf.responses = sf.responses[1:]
+	return rsp, nil
+}
+
+func TestTransportProvider(t *testing.T) {
+	tr := &scriptTransport{responses: [][]byte{
+		rtuFrame([]byte{0x01, 0x03, 0x02, 0x12, 0x34}),
+		rtuFrame([]byte{0x02, 0x03, 0x02, 0x12, 0x34}),
+		rtuFrame([]byte{0x01, 0x83, ExceptionCodeIllegalDataAddress}),
+	}}
+	p := NewTransportProvider(tr, RTUCodec{})
+	c := NewClient(p)
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != ErrClosedConnection {
+		t.Fatalf("ReadHoldingRegisters() error = %v, want %v", err, ErrClosedConnection)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.ReadHoldingRegisters(1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{0x1234}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadHoldingRegisters() = %v, want %v", got, want)
+	}
+	if _, err = c.ReadHoldingRegisters(1, 0, 1); err == nil {
+		t.Errorf("ReadHoldingRegisters() want slave id mismatch error")
+	}
+	if _, err = c.ReadHoldingRegisters(1, 0, 1); !IsIllegalDataAddress(err) {
+		t.Errorf("ReadHoldingRegisters() error = %v, want illegal data address", err)
+	}
+	if err = c.WriteSingleRegister(AddressBroadCast, 0, 1); err != nil {
+		t.Errorf("WriteSingleRegister() broadcast error = %v", err)
+	}
+	if want := rtuFrame([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}); !reflect.DeepEqual(tr.sent[0], want) {
+		t.Errorf("sent = % x, want % x", tr.sent[0], want)
+	}
+}
+
+func TestTransportProvider_autoReconnect(t *testing.T) {
+	tr := &scriptTransport{err: errors.New("link lost")}
+	p := NewTransportProvider(tr, &TCPCodec{})
+	p.SetAutoReconnect(2)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SendPdu(1, []byte{FuncCodeReadHoldingRegisters, 0x00, 0x00, 0x00, 0x01}); err == nil {
+		t.Fatal("SendPdu() want error")
+	}
+	if tr.opened != 2 || !p.IsConnected() {
+		t.Errorf("opened = %v, connected = %v, want 2, true", tr.opened, p.IsConnected())
+	}
+}
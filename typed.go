@@ -0,0 +1,173 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Order the byte and word order of the value spanning multiple registers,
+// the letters are the bytes of the big endian value, in the register order on the wire.
+type Order byte
+
+// byte and word order
+const (
+	OrderABCD Order = iota // big endian, high word first, the modbus default
+	OrderDCBA              // little endian, low word first and bytes swapped
+	OrderBADC              // high word first, bytes swapped in the words
+	OrderCDAB              // low word first, word swapped
+)
+
+// String implement fmt.Stringer
+func (o Order) String() string {
+	switch o {
+	case OrderABCD:
+		return "ABCD"
+	case OrderDCBA:
+		return "DCBA"
+	case OrderBADC:
+		return "BADC"
+	case OrderCDAB:
+		return "CDAB"
+	}
+	return "unknown"
+}
+
+// swap convert the register bytes between the order and big endian in place,
+// the conversion is symmetric, so it is used by both encode and decode.
+func (o Order) swap(b []byte) {
+	switch o {
+	case OrderDCBA:
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	case OrderBADC:
+		for i := 0; i+1 < len(b); i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	case OrderCDAB:
+		for i, j := 0, len(b)-2; i < j; i, j = i+2, j-2 {
+			b[i], b[i+1], b[j], b[j+1] = b[j], b[j+1], b[i], b[i+1]
+		}
+	}
+}
+
+// Uint32 decode the uint32 from 2 registers bytes
+func (o Order) Uint32(b []byte) uint32 {
+	var v [4]byte
+	copy(v[:], b)
+	o.swap(v[:])
+	return binary.BigEndian.Uint32(v[:])
+}
+
+// PutUint32 encode the uint32 into 2 registers bytes
+func (o Order) PutUint32(b []byte, v uint32) {
+	binary.BigEndian.PutUint32(b, v)
+	o.swap(b[:4])
+}
+
+// Uint64 decode the uint64 from 4 registers bytes
+func (o Order) Uint64(b []byte) uint64 {
+	var v [8]byte
+	copy(v[:], b)
+	o.swap(v[:])
+	return binary.BigEndian.Uint64(v[:])
+}
+
+// PutUint64 encode the uint64 into 4 registers bytes
+func (o Order) PutUint64(b []byte, v uint64) {
+	binary.BigEndian.PutUint64(b, v)
+	o.swap(b[:8])
+}
+
+// readHolding32 read 2 holding registers as uint32
+func (sf *client) readHolding32(slaveID byte, address uint16, order Order) (uint32, error) {
+	b, err := sf.ReadHoldingRegistersBytes(slaveID, address, 2)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 4 {
+		return 0, fmt.Errorf("modbus: response data size '%v' does not match '%v'", len(b), 4)
+	}
+	return order.Uint32(b), nil
+}
+
+// readHolding64 read 4 holding registers as uint64
+func (sf *client) readHolding64(slaveID byte, address uint16, order Order) (uint64, error) {
+	b, err := sf.ReadHoldingRegistersBytes(slaveID, address, 4)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 8 {
+		return 0, fmt.Errorf("modbus: response data size '%v' does not match '%v'", len(b), 8)
+	}
+	return order.Uint64(b), nil
+}
+
+// ReadUint32 read 2 holding registers as uint32 in the order
+func (sf *client) ReadUint32(slaveID byte, address uint16, order Order) (uint32, error) {
+	return sf.readHolding32(slaveID, address, order)
+}
+
+// ReadInt32 read 2 holding registers as int32 in the order
+func (sf *client) ReadInt32(slaveID byte, address uint16, order Order) (int32, error) {
+	v, err := sf.readHolding32(slaveID, address, order)
+	return int32(v), err
+}
+
+// ReadFloat32 read 2 holding registers as IEEE 754 float32 in the order
+func (sf *client) ReadFloat32(slaveID byte, address uint16, order Order) (float32, error) {
+	v, err := sf.readHolding32(slaveID, address, order)
+	return math.Float32frombits(v), err
+}
+
+// ReadUint64 read 4 holding registers as uint64 in the order
+func (sf *client) ReadUint64(slaveID byte, address uint16, order Order) (uint64, error) {
+	return sf.readHolding64(slaveID, address, order)
+}
+
+// ReadInt64 read 4 holding registers as int64 in the order
+func (sf *client) ReadInt64(slaveID byte, address uint16, order Order) (int64, error) {
+	v, err := sf.readHolding64(slaveID, address, order)
+	return int64(v), err
+}
+
+// ReadFloat64 read 4 holding registers as IEEE 754 float64 in the order
+func (sf *client) ReadFloat64(slaveID byte, address uint16, order Order) (float64, error) {
+	v, err := sf.readHolding64(slaveID, address, order)
+	return math.Float64frombits(v), err
+}
+
+// WriteUint32 write uint32 into 2 holding registers in the order
+func (sf *client) WriteUint32(slaveID byte, address uint16, value uint32, order Order) error {
+	var b [4]byte
+	order.PutUint32(b[:], value)
+	return sf.WriteMultipleRegisters(slaveID, address, 2, b[:])
+}
+
+// WriteInt32 write int32 into 2 holding registers in the order
+func (sf *client) WriteInt32(slaveID byte, address uint16, value int32, order Order) error {
+	return sf.WriteUint32(slaveID, address, uint32(value), order)
+}
+
+// WriteFloat32 write IEEE 754 float32 into 2 holding registers in the order
+func (sf *client) WriteFloat32(slaveID byte, address uint16, value float32, order Order) error {
+	return sf.WriteUint32(slaveID, address, math.Float32bits(value), order)
+}
+
+// WriteUint64 write uint64 into 4 holding registers in the order
+func (sf *client) WriteUint64(slaveID byte, address uint16, value uint64, order Order) error {
+	var b [8]byte
+	order.PutUint64(b[:], value)
+	return sf.WriteMultipleRegisters(slaveID, address, 4, b[:])
+}
+
+// WriteInt64 write int64 into 4 holding registers in the order
+func (sf *client) WriteInt64(slaveID byte, address uint16, value int64, order Order) error {
+	return sf.WriteUint64(slaveID, address, uint64(value), order)
+}
+
+// WriteFloat64 write IEEE 754 float64 into 4 holding registers in the order
+func (sf *client) WriteFloat64(slaveID byte, address uint16, value float64, order Order) error {
+	return sf.WriteUint64(slaveID, address, math.Float64bits(value), order)
+}
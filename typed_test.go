@@ -0,0 +1,79 @@
+package modbus
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		order  Order
+		want32 []byte
+		want64 []byte
+	}{
+		{OrderABCD, []byte{0x01, 0x02, 0x03, 0x04}, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+		{OrderDCBA, []byte{0x04, 0x03, 0x02, 0x01}, []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}},
+		{OrderBADC, []byte{0x02, 0x01, 0x04, 0x03}, []byte{0x02, 0x01, 0x04, 0x03, 0x06, 0x05, 0x08, 0x07}},
+		{OrderCDAB, []byte{0x03, 0x04, 0x01, 0x02}, []byte{0x07, 0x08, 0x05, 0x06, 0x03, 0x04, 0x01, 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order.String(), func(t *testing.T) {
+			b := make([]byte, 8)
+			tt.order.PutUint32(b, 0x01020304)
+			if !bytes.Equal(b[:4], tt.want32) {
+				t.Errorf("PutUint32() = % x, want % x", b[:4], tt.want32)
+			}
+			if got := tt.order.Uint32(tt.want32); got != 0x01020304 {
+				t.Errorf("Uint32() = %#x, want 0x01020304", got)
+			}
+			tt.order.PutUint64(b, 0x0102030405060708)
+			if !bytes.Equal(b, tt.want64) {
+				t.Errorf("PutUint64() = % x, want % x", b, tt.want64)
+			}
+			if got := tt.order.Uint64(tt.want64); got != 0x0102030405060708 {
+				t.Errorf("Uint64() = %#x, want 0x0102030405060708", got)
+			}
+		})
+	}
+}
+
+func Test_clientTyped(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	node := NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10)
+	mbSrv.AddNodes(node)
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	mbCli := NewClient(NewTCPClientProvider(listen.Addr().String()))
+	if err = mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	if err = mbCli.WriteFloat32(testslaveID1, 0, 1.5, OrderCDAB); err != nil {
+		t.Fatalf("WriteFloat32() error = %v", err)
+	}
+	if regs, _ := node.ReadHoldings(0, 2); regs[0] != 0x0000 || regs[1] != 0x3fc0 {
+		t.Errorf("holding = %#x, want [0 0x3fc0]", regs)
+	}
+	if v, err := mbCli.ReadFloat32(testslaveID1, 0, OrderCDAB); err != nil || v != 1.5 {
+		t.Errorf("ReadFloat32() = %v, %v, want 1.5", v, err)
+	}
+	if err = mbCli.WriteInt64(testslaveID1, 2, -2, OrderABCD); err != nil {
+		t.Fatalf("WriteInt64() error = %v", err)
+	}
+	if v, err := mbCli.ReadInt64(testslaveID1, 2, OrderABCD); err != nil || v != -2 {
+		t.Errorf("ReadInt64() = %v, %v, want -2", v, err)
+	}
+	if v, err := mbCli.ReadUint32(testslaveID1, 4, OrderDCBA); err != nil || v != 0xfeffffff {
+		t.Errorf("ReadUint32() = %#x, %v, want 0xfeffffff", v, err)
+	}
+	if _, err = mbCli.ReadFloat64(testslaveID1, 8, OrderABCD); !IsIllegalDataAddress(err) {
+		t.Errorf("ReadFloat64() error = %v, want illegal data address", err)
+	}
+}
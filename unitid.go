@@ -0,0 +1,66 @@
+package modbus
+
+import (
+	"errors"
+)
+
+// errNoReply the request is served but not replied, such as broadcast
+var errNoReply = errors.New("no reply")
+
+// UnitIDMode the handling of the special unit id 0 or 255 on the modbus tcp server
+type UnitIDMode byte
+
+// unit id mode
+const (
+	// UnitIDDefault look up the node as an ordinary unit id, the default
+	UnitIDDefault UnitIDMode = iota
+	// UnitIDThisDevice answer by the designated node, as "this device"
+	UnitIDThisDevice
+	// UnitIDReject reply the exception gateway path unavailable
+	UnitIDReject
+	// UnitIDBroadcast serve the request on every node and not reply
+	UnitIDBroadcast
+)
+
+// unitIDMode the mode and the designated node
+type unitIDMode struct {
+	mode    UnitIDMode
+	slaveID byte
+}
+
+// SetUnitIDMode set the handling of the unit id, it is meant for 0 and 255 which
+// the clients use as "this device" on modbus tcp, slaveID is the designated node of UnitIDThisDevice.
+func (sf *TCPServer) SetUnitIDMode(unitID byte, mode UnitIDMode, slaveID byte) {
+	if mode == UnitIDDefault {
+		sf.unitModes.Delete(unitID)
+		return
+	}
+	sf.unitModes.Store(unitID, unitIDMode{mode, slaveID})
+}
+
+// dispatchUnitMode dispatch the request of the unit id with mode
+func (sf *serverCommon) dispatchUnitMode(m unitIDMode, req *ServerRequest) ([]byte, error) {
+	handle, ok := sf.functionHandler(req.FuncCode)
+	switch m.mode {
+	case UnitIDThisDevice:
+		node, err := sf.GetNode(m.slaveID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalFunction}
+		}
+		return handle(node, req.Data)
+	case UnitIDReject:
+		return nil, &ExceptionError{ExceptionCode: ExceptionCodeGatewayPathUnavailable}
+	case UnitIDBroadcast:
+		if ok {
+			sf.Range(func(_ byte, node *NodeRegister) bool {
+				_, _ = handle(node, req.Data)
+				return true
+			})
+		}
+		return nil, errNoReply
+	}
+	return nil, ErrSlaveNotExist
+}
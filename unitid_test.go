@@ -0,0 +1,67 @@
+package modbus
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPServer_SetUnitIDMode(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(
+		NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10),
+		NewNodeRegister(testslaveID2, 0, 10, 0, 10, 0, 10, 0, 10))
+	go mbSrv.Serve(listen)
+	defer mbSrv.Close()
+
+	p := NewTCPClientProvider(listen.Addr().String())
+	p.Timeout = 200 * time.Millisecond
+	if err = p.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer p.Close()
+	// the client reject unit id 255, so send the raw pdu
+	write := func(address, value byte) error {
+		_, err := p.SendPdu(255, []byte{FuncCodeWriteSingleRegister, 0x00, address, 0x00, value})
+		return err
+	}
+
+	// default, no node of 255
+	if err = write(0, 1); err == nil {
+		t.Errorf("WriteSingleRegister(255) error = nil, want timeout")
+	}
+
+	mbSrv.SetUnitIDMode(255, UnitIDThisDevice, testslaveID2)
+	if err = write(0, 0x55); err != nil {
+		t.Errorf("WriteSingleRegister(255) this device error = %v", err)
+	}
+	node2, _ := mbSrv.GetNode(testslaveID2)
+	if v, _ := node2.ReadHoldings(0, 1); v[0] != 0x55 {
+		t.Errorf("node 2 holding[0] = %v, want 0x55", v[0])
+	}
+
+	mbSrv.SetUnitIDMode(255, UnitIDReject, 0)
+	if err = write(0, 1); !IsGatewayPathUnavailable(err) {
+		t.Errorf("WriteSingleRegister(255) reject error = %v, want gateway path unavailable", err)
+	}
+
+	mbSrv.SetUnitIDMode(255, UnitIDBroadcast, 0)
+	if err = write(1, 0x77); err == nil {
+		t.Errorf("WriteSingleRegister(255) broadcast error = nil, want no reply")
+	}
+	mbSrv.Range(func(slaveID byte, node *NodeRegister) bool {
+		if v, _ := node.ReadHoldings(1, 1); v[0] != 0x77 {
+			t.Errorf("node %d holding[1] = %v, want 0x77", slaveID, v[0])
+		}
+		return true
+	})
+
+	mbSrv.SetUnitIDMode(255, UnitIDDefault, 0)
+	if err = write(0, 1); err == nil || IsGatewayPathUnavailable(err) {
+		t.Errorf("WriteSingleRegister(255) default error = %v, want timeout", err)
+	}
+}
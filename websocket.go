@@ -0,0 +1,381 @@
+package modbus
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modbus/TCP ADU over WebSocket, every ADU is framed in a binary message.
+// see RFC 6455
+
+// WebSocketSubprotocol the subprotocol of the modbus over websocket
+const WebSocketSubprotocol = "modbus"
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// websocket opcode
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+)
+
+// ErrWebSocketHandshake websocket handshake failed
+var ErrWebSocketHandshake = errors.New("modbus: websocket handshake failed")
+
+// wsConn net.Conn on the websocket, data messages are read as a byte stream
+type wsConn struct {
+	net.Conn
+	br       *bufio.Reader
+	isClient bool // client mask the frames
+
+	rmu       sync.Mutex
+	remaining uint64 // remaining payload of the current data frame
+	masked    bool
+	mask      [4]byte
+	maskPos   int
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// Read read the payload of the data frames
+func (sf *wsConn) Read(b []byte) (int, error) {
+	sf.rmu.Lock()
+	defer sf.rmu.Unlock()
+
+	for sf.remaining == 0 {
+		if err := sf.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if uint64(len(b)) > sf.remaining {
+		b = b[:sf.remaining]
+	}
+	n, err := sf.br.Read(b)
+	if sf.masked {
+		for i := 0; i < n; i++ {
+			b[i] ^= sf.mask[sf.maskPos&3]
+			sf.maskPos++
+		}
+	}
+	sf.remaining -= uint64(n)
+	return n, err
+}
+
+// nextFrame read the next frame header, handle the control frames.
+func (sf *wsConn) nextFrame() error {
+	var head [14]byte
+	if _, err := io.ReadFull(sf.br, head[:2]); err != nil {
+		return err
+	}
+	opcode := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(sf.br, head[2:4]); err != nil {
+			return err
+		}
+		length = uint64(binary.BigEndian.Uint16(head[2:4]))
+	case 127:
+		if _, err := io.ReadFull(sf.br, head[2:10]); err != nil {
+			return err
+		}
+		length = binary.BigEndian.Uint64(head[2:10])
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(sf.br, mask[:]); err != nil {
+			return err
+		}
+	}
+
+	switch opcode {
+	case wsOpContinuation, wsOpText, wsOpBinary:
+		sf.remaining, sf.masked, sf.mask, sf.maskPos = length, masked, mask, 0
+		return nil
+	}
+
+	// control frame, the payload must not be longer than 125
+	if length > 125 {
+		return errors.New("modbus: websocket control frame too long")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(sf.br, payload); err != nil {
+		return err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i&3]
+		}
+	}
+	switch opcode {
+	case wsOpPing:
+		return sf.writeFrame(wsOpPong, payload)
+	case wsOpClose:
+		sf.writeFrame(wsOpClose, payload) // echo the close frame
+		return io.EOF
+	}
+	return nil // pong and unknown frame ignored
+}
+
+// Write write the data in a binary message
+func (sf *wsConn) Write(b []byte) (int, error) {
+	if err := sf.writeFrame(wsOpBinary, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// writeFrame write a final frame
+func (sf *wsConn) writeFrame(opcode byte, payload []byte) error {
+	sf.wmu.Lock()
+	defer sf.wmu.Unlock()
+	if sf.closed {
+		return io.ErrClosedPipe
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if sf.isClient {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	if sf.isClient {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, v := range payload {
+			frame = append(frame, v^mask[i&3])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := sf.Conn.Write(frame)
+	return err
+}
+
+// Close send the close frame and close the connection
+func (sf *wsConn) Close() error {
+	sf.writeFrame(wsOpClose, []byte{0x03, 0xe8}) // 1000 normal closure
+	sf.wmu.Lock()
+	sf.closed = true
+	sf.wmu.Unlock()
+	return sf.Conn.Close()
+}
+
+// webSocketAccept compute the Sec-WebSocket-Accept of the key
+func webSocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// isWebSocketAddress whether the address is a websocket url
+func isWebSocketAddress(addr string) bool {
+	return strings.HasPrefix(addr, "ws://") || strings.HasPrefix(addr, "wss://")
+}
+
+// dialWebSocket dial the websocket url "ws://host:port/path" or "wss://host:port/path" with the dialer,
+// config is the tls config of wss, nil use the default, the host of the url is the ServerName if not set.
+func dialWebSocket(ctx context.Context, d Dialer, rawurl string, timeout time.Duration, config *tls.Config) (net.Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	conn, err := dialContext(ctx, d, "tcp", host, timeout)
+	if err != nil {
+		return nil, err
+	}
+	// the handshake is bounded by the timeout and the deadline of the context
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if dl, ok := ctx.Deadline(); ok && (deadline.IsZero() || dl.Before(deadline)) {
+		deadline = dl
+	}
+	if !deadline.IsZero() {
+		conn.SetDeadline(deadline)
+	}
+	if u.Scheme == "wss" {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName = u.Hostname()
+		}
+		tc := tls.Client(conn, config)
+		if err = tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+
+	var nonce [16]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":                {"websocket"},
+			"Connection":             {"Upgrade"},
+			"Sec-WebSocket-Key":      {key},
+			"Sec-WebSocket-Version":  {"13"},
+			"Sec-WebSocket-Protocol": {WebSocketSubprotocol},
+		},
+		Host: u.Host,
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusSwitchingProtocols ||
+		rsp.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%v, status %v", ErrWebSocketHandshake, rsp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{Conn: conn, br: br, isClient: true}, nil
+}
+
+// NewWebSocketClientProvider allocates a TCPClientProvider which frames
+// Modbus/TCP ADUs over websocket, url is "ws://host:port/path" or "wss://host:port/path".
+// it is the same as NewTCPClientProvider with the websocket url.
+func NewWebSocketClientProvider(url string) *TCPClientProvider {
+	return NewTCPClientProvider(url)
+}
+
+// WebSocketHandler return a http.Handler which serve Modbus/TCP ADUs over websocket,
+// mount it on a http server, such as http.Handle("/modbus", srv.WebSocketHandler()).
+// the session ends when the request context is done or the server is closed.
+func (sf *TCPServer) WebSocketHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet ||
+			!headerContains(r.Header, "Connection", "upgrade") ||
+			!headerContains(r.Header, "Upgrade", "websocket") ||
+			r.Header.Get("Sec-WebSocket-Version") != "13" ||
+			r.Header.Get("Sec-WebSocket-Key") == "" {
+			http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+			return
+		}
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "websocket not supported", http.StatusInternalServerError)
+			return
+		}
+		conn, brw, err := hj.Hijack()
+		if err != nil {
+			sf.Error("websocket hijack failed, %v", err)
+			return
+		}
+		rsp := "HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n"
+		if headerContains(r.Header, "Sec-WebSocket-Protocol", WebSocketSubprotocol) {
+			rsp += "Sec-WebSocket-Protocol: " + WebSocketSubprotocol + "\r\n"
+		}
+		if _, err = conn.Write([]byte(rsp + "\r\n")); err != nil {
+			conn.Close()
+			return
+		}
+
+		sf.mu.Lock()
+		srvCtx := sf.serveContext()
+		sf.wg.Add(1)
+		sf.mu.Unlock()
+		defer sf.wg.Done()
+
+		ws := &wsConn{Conn: conn, br: brw.Reader}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-srvCtx.Done(): // the server is closed, interrupt the blocked read
+				cancel()
+				ws.Close()
+			case <-ctx.Done():
+			}
+		}()
+		limits, release := sf.sessionLimits(r.RemoteAddr)
+		defer release()
+		sess := &ServerSession{
+			ws,
+			sf.readTimeout,
+			sf.writeTimeout,
+			sf.serverCommon,
+			sf.logger.with("remote", r.RemoteAddr),
+			limits,
+			r.TLS,
+		}
+		sess.running(ctx)
+	})
+}
+
+// headerContains whether the comma separated header values contain the token, case insensitive
+func headerContains(h http.Header, key, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(key)] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
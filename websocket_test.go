@@ -0,0 +1,128 @@
+package modbus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_webSocketAccept(t *testing.T) {
+	// example of RFC 6455 section 1.3
+	if got := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("webSocketAccept() = %v, want s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", got)
+	}
+}
+
+func Test_WebSocket(t *testing.T) {
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 200))
+	httpSrv := httptest.NewServer(mbSrv.WebSocketHandler())
+	defer httpSrv.Close()
+
+	mbCli := NewClient(NewWebSocketClientProvider("ws" + strings.TrimPrefix(httpSrv.URL, "http") + "/modbus"))
+	if err := mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+
+	values := make([]uint16, 120) // payload over 125 bytes use the extended length
+	buf := make([]byte, 0, len(values)*2)
+	for i := range values {
+		values[i] = uint16(i * 3)
+		buf = append(buf, byte(values[i]>>8), byte(values[i]))
+	}
+	if err := mbCli.WriteMultipleRegisters(testslaveID1, 0, uint16(len(values)), buf); err != nil {
+		t.Fatalf("WriteMultipleRegisters error = %v", err)
+	}
+	got, err := mbCli.ReadHoldingRegisters(testslaveID1, 0, uint16(len(values)))
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters error = %v", err)
+	}
+	for i := range values {
+		if got[i] != values[i] {
+			t.Fatalf("ReadHoldingRegisters()[%d] = %v, want %v", i, got[i], values[i])
+		}
+	}
+}
+
+func Test_WebSocketHandler_notUpgrade(t *testing.T) {
+	httpSrv := httptest.NewServer(NewTCPServer().WebSocketHandler())
+	defer httpSrv.Close()
+
+	rsp, err := http.Get(httpSrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %v, want %v", rsp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func Test_WebSocketHandler_Close(t *testing.T) {
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10))
+	httpSrv := httptest.NewServer(mbSrv.WebSocketHandler())
+	defer httpSrv.Close()
+
+	mbCli := NewClient(NewWebSocketClientProvider("ws" + strings.TrimPrefix(httpSrv.URL, "http") + "/modbus"))
+	if err := mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	if _, err := mbCli.ReadHoldingRegisters(testslaveID1, 0, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters error = %v", err)
+	}
+
+	// the session blocked in the read is closed and waited
+	done := make(chan struct{})
+	go func() {
+		mbSrv.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close() not return with the websocket session")
+	}
+	if _, err := mbCli.ReadHoldingRegisters(testslaveID1, 0, 1); err == nil {
+		t.Errorf("ReadHoldingRegisters after server Close error = nil, want error")
+	}
+}
+
+func Test_WebSocketTLSConfig(t *testing.T) {
+	mbSrv := NewTCPServer()
+	mbSrv.AddNodes(NewNodeRegister(testslaveID1, 0, 10, 0, 10, 0, 10, 0, 10))
+	defer mbSrv.Close()
+	httpSrv := httptest.NewUnstartedServer(mbSrv.WebSocketHandler())
+	httpSrv.Config.ErrorLog = log.New(ioutil.Discard, "", 0) // the rejected handshake is expected
+	httpSrv.StartTLS()
+	defer httpSrv.Close()
+	url := "wss" + strings.TrimPrefix(httpSrv.URL, "https") + "/modbus"
+
+	// the certificate of the test server is not trusted by default
+	p := NewWebSocketClientProvider(url)
+	p.SetTimeout(time.Second)
+	if err := p.Connect(); err == nil {
+		p.Close()
+		t.Fatal("Connect without the root CA error = nil, want error")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(httpSrv.Certificate())
+	p.SetTLSConfig(&tls.Config{RootCAs: roots})
+	mbCli := NewClient(p)
+	if err := mbCli.Connect(); err != nil {
+		t.Fatalf("Connect error = %v", err)
+	}
+	defer mbCli.Close()
+	if _, err := mbCli.ReadHoldingRegisters(testslaveID1, 0, 1); err != nil {
+		t.Errorf("ReadHoldingRegisters error = %v", err)
+	}
+}
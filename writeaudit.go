@@ -0,0 +1,149 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// WriteRecord a write request served by the server, with the remote address and the written values
+type WriteRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"` // 远端地址, 串口为空
+	SlaveID    byte      `json:"slaveId"`
+	FuncCode   byte      `json:"funcCode"`
+	Table      Table     `json:"table"` // TableCoils or TableHoldingRegisters
+	Address    uint16    `json:"address"`
+	Quantity   uint16    `json:"quantity"`
+	// Values the written register values or the coils as 0 and 1,
+	// it is the and-mask and the or-mask of the mask write register.
+	Values []uint16 `json:"values"`
+	// ExceptionCode 0 if the write succeed
+	ExceptionCode byte `json:"exception,omitempty"`
+}
+
+// WriteAuditSink receive the write records, it is called synchronously by the server
+// after the request is handled, a slow sink should buffer itself.
+type WriteAuditSink interface {
+	RecordWrite(r WriteRecord)
+}
+
+// WriteAuditSinkFunc an adapter to allow the use of ordinary functions as WriteAuditSink
+type WriteAuditSinkFunc func(r WriteRecord)
+
+// RecordWrite calls f(r)
+func (f WriteAuditSinkFunc) RecordWrite(r WriteRecord) {
+	f(r)
+}
+
+// WriteAudit return the server middleware which record who wrote which values when to the sink,
+// the reads and the requests to not exist slave are not recorded, the rejected writes are recorded
+// with the exception code. use it like: server.Use(modbus.WriteAudit(sink))
+func WriteAudit(sink WriteAuditSink) ServerMiddleware {
+	return func(next ServerHandler) ServerHandler {
+		return func(req *ServerRequest) ([]byte, error) {
+			// decode before handling, the handler may reuse the request data
+			r, ok := decodeWrite(req.FuncCode, req.Data)
+			rsp, err := next(req)
+			if !ok || err == ErrSlaveNotExist {
+				return rsp, err
+			}
+			r.Time, r.SlaveID, r.FuncCode = time.Now(), req.SlaveID, req.FuncCode
+			if req.RemoteAddr != nil {
+				r.RemoteAddr = req.RemoteAddr.String()
+			}
+			if err != nil && err != errNoReply {
+				r.ExceptionCode = exceptionCode(err)
+			}
+			sink.RecordWrite(r)
+			return rsp, err
+		}
+	}
+}
+
+// decodeWrite decode the address, quantity and values of the write request pdu data,
+// ok is false if it is not a write or malformed
+func decodeWrite(funcCode byte, data []byte) (r WriteRecord, ok bool) {
+	r.Table = TableHoldingRegisters
+	switch funcCode {
+	case FuncCodeWriteSingleCoil:
+		if len(data) != 4 {
+			return r, false
+		}
+		r.Table, r.Address, r.Quantity = TableCoils, binary.BigEndian.Uint16(data), 1
+		r.Values = []uint16{0}
+		if binary.BigEndian.Uint16(data[2:]) == 0xff00 {
+			r.Values[0] = 1
+		}
+	case FuncCodeWriteMultipleCoils:
+		if len(data) < 5 {
+			return r, false
+		}
+		r.Table, r.Address, r.Quantity = TableCoils, binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if len(data)-5 < (int(r.Quantity)+7)/8 {
+			return r, false
+		}
+		r.Values = make([]uint16, r.Quantity)
+		for i := range r.Values {
+			r.Values[i] = uint16(data[5+i/8]>>uint(i%8)) & 1
+		}
+	case FuncCodeWriteSingleRegister:
+		if len(data) != 4 {
+			return r, false
+		}
+		r.Address, r.Quantity = binary.BigEndian.Uint16(data), 1
+		r.Values = []uint16{binary.BigEndian.Uint16(data[2:])}
+	case FuncCodeWriteMultipleRegisters:
+		if len(data) < 5 {
+			return r, false
+		}
+		r.Address, r.Quantity = binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if len(data)-5 < int(r.Quantity)*2 {
+			return r, false
+		}
+		r.Values = bytes2Uint16(data[5 : 5+int(r.Quantity)*2])
+	case FuncCodeReadWriteMultipleRegisters:
+		if len(data) < 9 {
+			return r, false
+		}
+		r.Address, r.Quantity = binary.BigEndian.Uint16(data[4:]), binary.BigEndian.Uint16(data[6:])
+		if len(data)-9 < int(r.Quantity)*2 {
+			return r, false
+		}
+		r.Values = bytes2Uint16(data[9 : 9+int(r.Quantity)*2])
+	case FuncCodeMaskWriteRegister:
+		if len(data) != 6 {
+			return r, false
+		}
+		r.Address, r.Quantity = binary.BigEndian.Uint16(data), 1
+		r.Values = bytes2Uint16(data[2:6])
+	default:
+		return r, false
+	}
+	return r, true
+}
+
+// JSONWriteAuditSink write the records as lines of json, it is safe for concurrent use
+type JSONWriteAuditSink struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	onError func(error)
+}
+
+// NewJSONWriteAuditSink new a sink write the records to w as lines of json,
+// onError is called if write failed, it can be nil.
+func NewJSONWriteAuditSink(w io.Writer, onError func(error)) *JSONWriteAuditSink {
+	return &JSONWriteAuditSink{enc: json.NewEncoder(w), onError: onError}
+}
+
+// RecordWrite implements WriteAuditSink
+func (sf *JSONWriteAuditSink) RecordWrite(r WriteRecord) {
+	sf.mu.Lock()
+	err := sf.enc.Encode(r)
+	sf.mu.Unlock()
+	if err != nil && sf.onError != nil {
+		sf.onError(err)
+	}
+}
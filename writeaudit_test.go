@@ -0,0 +1,89 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestWriteAudit(t *testing.T) {
+	var records []WriteRecord
+	h := WriteAudit(WriteAuditSinkFunc(func(r WriteRecord) {
+		records = append(records, r)
+	}))(func(req *ServerRequest) ([]byte, error) {
+		switch req.SlaveID {
+		case 1:
+			req.Data[0] = 0xee // the handler may reuse the request data
+			return req.Data, nil
+		case 2:
+			return nil, &ExceptionError{ExceptionCode: ExceptionCodeIllegalDataAddress}
+		}
+		return nil, ErrSlaveNotExist
+	})
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 1502}
+	h(&ServerRequest{1, FuncCodeWriteSingleCoil, []byte{0x00, 0x05, 0xff, 0x00}, remote})
+	h(&ServerRequest{1, FuncCodeReadHoldingRegisters, []byte{0x00, 0x10, 0x00, 0x02}, remote}) // not recorded
+	h(&ServerRequest{3, FuncCodeWriteSingleRegister, []byte{0x00, 0x10, 0x00, 0x02}, remote})  // not recorded
+	h(&ServerRequest{2, FuncCodeWriteMultipleRegisters, []byte{0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x02}, remote})
+	h(&ServerRequest{1, FuncCodeWriteMultipleCoils, []byte{0x00, 0x08, 0x00, 0x03, 0x01, 0x05}, nil})
+	h(&ServerRequest{1, FuncCodeWriteSingleRegister, []byte{0x00, 0x03, 0x12, 0x34}, nil})
+	h(&ServerRequest{1, FuncCodeReadWriteMultipleRegisters, []byte{0x00, 0x00, 0x00, 0x01, 0x00, 0x07, 0x00, 0x01, 0x02, 0xab, 0xcd}, nil})
+	h(&ServerRequest{1, FuncCodeMaskWriteRegister, []byte{0x00, 0x04, 0x00, 0xf2, 0x00, 0x25}, nil})
+	h(&ServerRequest{1, FuncCodeWriteMultipleRegisters, []byte{0x00, 0x01, 0x00, 0x02, 0x04, 0x00}, nil}) // malformed
+
+	want := []WriteRecord{
+		{RemoteAddr: remote.String(), SlaveID: 1, FuncCode: FuncCodeWriteSingleCoil, Table: TableCoils, Address: 5, Quantity: 1, Values: []uint16{1}},
+		{RemoteAddr: remote.String(), SlaveID: 2, FuncCode: FuncCodeWriteMultipleRegisters, Table: TableHoldingRegisters, Address: 1, Quantity: 2, Values: []uint16{1, 2}, ExceptionCode: ExceptionCodeIllegalDataAddress},
+		{SlaveID: 1, FuncCode: FuncCodeWriteMultipleCoils, Table: TableCoils, Address: 8, Quantity: 3, Values: []uint16{1, 0, 1}},
+		{SlaveID: 1, FuncCode: FuncCodeWriteSingleRegister, Table: TableHoldingRegisters, Address: 3, Quantity: 1, Values: []uint16{0x1234}},
+		{SlaveID: 1, FuncCode: FuncCodeReadWriteMultipleRegisters, Table: TableHoldingRegisters, Address: 7, Quantity: 1, Values: []uint16{0xabcd}},
+		{SlaveID: 1, FuncCode: FuncCodeMaskWriteRegister, Table: TableHoldingRegisters, Address: 4, Quantity: 1, Values: []uint16{0xf2, 0x25}},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+	for i := range want {
+		if records[i].Time.IsZero() {
+			t.Errorf("records[%d] time is zero", i)
+		}
+		records[i].Time = want[i].Time
+		if !reflect.DeepEqual(records[i], want[i]) {
+			t.Errorf("records[%d] = %+v, want %+v", i, records[i], want[i])
+		}
+	}
+}
+
+func TestJSONWriteAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	node := NewNodeRegister(1, 0, 16, 0, 16, 0, 16, 0, 16)
+	p := NewLoopbackProvider(node)
+	p.Use(WriteAudit(NewJSONWriteAuditSink(&buf, nil)))
+	c := NewClient(p)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.WriteSingleRegister(1, 2, 0x55)
+	_, _ = c.ReadHoldingRegisters(1, 0, 4)
+	_ = c.WriteSingleRegister(1, 100, 0x55)
+
+	dec := json.NewDecoder(&buf)
+	var got []WriteRecord
+	for dec.More() {
+		var r WriteRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != 2 {
+		t.Fatalf("records = %+v, want 2 writes", got)
+	}
+	if got[0].Address != 2 || !reflect.DeepEqual(got[0].Values, []uint16{0x55}) || got[0].ExceptionCode != 0 {
+		t.Errorf("records[0] = %+v", got[0])
+	}
+	if got[1].ExceptionCode != ExceptionCodeIllegalDataAddress {
+		t.Errorf("records[1] = %+v, want illegal data address", got[1])
+	}
+}